@@ -6,11 +6,27 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// TokenTTL is how long an issued token stays valid
+const TokenTTL = 24 * time.Hour
+
 var jwtSecret []byte
 
+// RevocationStore knows the sessions that were signed out; their tokens are
+// rejected even before they expire
+type RevocationStore interface {
+	IsRevoked(sessionID string) (bool, error)
+}
+
+var revocations RevocationStore
+
+func SetRevocationStore(store RevocationStore) {
+	revocations = store
+}
+
 func init() {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
@@ -20,8 +36,9 @@ func init() {
 }
 
 type Claims struct {
-	UserID   int64  `json:"userId"`
-	Username string `json:"username"`
+	UserID    int64  `json:"userId"`
+	Username  string `json:"username"`
+	SessionID string `json:"sid,omitempty"` // Shared by a login's token and its refreshes
 	jwt.RegisteredClaims
 }
 
@@ -37,12 +54,27 @@ func VerifyPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
+// GenerateToken issues the token of a new session
 func GenerateToken(userID int64, username string) (string, error) {
+	return generateToken(userID, username, uuid.New().String())
+}
+
+// RefreshToken issues a fresh token for the session of claims
+func RefreshToken(claims *Claims) (string, error) {
+	sessionID := claims.SessionID
+	if sessionID == "" {
+		sessionID = uuid.New().String()
+	}
+	return generateToken(claims.UserID, claims.Username, sessionID)
+}
+
+func generateToken(userID int64, username string, sessionID string) (string, error) {
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
@@ -64,11 +96,22 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if revocations != nil && claims.SessionID != "" {
+		revoked, err := revocations.IsRevoked(claims.SessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check session: %w", err)
+		}
+		if revoked {
+			return nil, fmt.Errorf("session has been revoked")
+		}
 	}
 
-	return nil, fmt.Errorf("invalid token")
+	return claims, nil
 }
 
 
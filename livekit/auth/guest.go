@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const guestLinkAudience = "guest-link"
+
+// GuestLinkClaims identify the call a guest link grants access to.
+// Exactly one of CallID or ScheduledCallID is set; links to a scheduled call
+// also name its occurrence, since a recurring schedule starts each one anew.
+type GuestLinkClaims struct {
+	CallID          string `json:"callId,omitempty"`
+	ScheduledCallID int64  `json:"scheduledCallId,omitempty"`
+	Occurrence      int64  `json:"occurrence,omitempty"` // Unix time the occurrence is scheduled at
+	jwt.RegisteredClaims
+}
+
+// guestLinkSecret derives a separate signing key so a guest link can never
+// be presented as a user token
+func guestLinkSecret() []byte {
+	return append([]byte("guest-link:"), jwtSecret...)
+}
+
+func GenerateGuestLinkToken(callID string, scheduledCallID int64, occurrence time.Time, expiresAt time.Time) (string, error) {
+	claims := &GuestLinkClaims{
+		CallID:          callID,
+		ScheduledCallID: scheduledCallID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{guestLinkAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	if scheduledCallID != 0 {
+		claims.Occurrence = occurrence.Unix()
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(guestLinkSecret())
+}
+
+func ValidateGuestLinkToken(tokenString string) (*GuestLinkClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &GuestLinkClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return guestLinkSecret(), nil
+	}, jwt.WithAudience(guestLinkAudience))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse guest link: %w", err)
+	}
+
+	claims, ok := token.Claims.(*GuestLinkClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid guest link")
+	}
+	if claims.CallID == "" && (claims.ScheduledCallID == 0 || claims.Occurrence == 0) {
+		return nil, fmt.Errorf("guest link does not reference a call")
+	}
+
+	return claims, nil
+}
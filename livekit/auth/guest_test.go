@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signGuestClaims signs arbitrary claims the way a forged or foreign link would be
+func signGuestClaims(t *testing.T, claims *GuestLinkClaims, secret []byte) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestValidateGuestLinkToken(t *testing.T) {
+	occurrence := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	expiresAt := time.Now().Add(time.Hour)
+
+	t.Run("call link", func(t *testing.T) {
+		token, err := GenerateGuestLinkToken("call-1", 0, occurrence, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := ValidateGuestLinkToken(token)
+		if err != nil {
+			t.Fatalf("ValidateGuestLinkToken: %v", err)
+		}
+		if claims.CallID != "call-1" || claims.ScheduledCallID != 0 || claims.Occurrence != 0 {
+			t.Errorf("claims %+v, want call-1 without a scheduled occurrence", claims)
+		}
+	})
+
+	t.Run("scheduled link is bound to its occurrence", func(t *testing.T) {
+		token, err := GenerateGuestLinkToken("", 42, occurrence, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := ValidateGuestLinkToken(token)
+		if err != nil {
+			t.Fatalf("ValidateGuestLinkToken: %v", err)
+		}
+		if claims.ScheduledCallID != 42 || claims.Occurrence != occurrence.Unix() {
+			t.Errorf("claims %+v, want scheduled call 42 at %d", claims, occurrence.Unix())
+		}
+
+		// Moving the link to another occurrence breaks its signature
+		parts := strings.Split(token, ".")
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			t.Fatal(err)
+		}
+		fields["occurrence"] = occurrence.Add(7 * 24 * time.Hour).Unix()
+		payload, _ = json.Marshal(fields)
+		parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+		if _, err := ValidateGuestLinkToken(strings.Join(parts, ".")); err == nil {
+			t.Error("accepted a link moved to another occurrence")
+		}
+	})
+
+	registered := func(audience string, expiresAt time.Time) jwt.RegisteredClaims {
+		return jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		}
+	}
+	accessToken, err := GenerateToken(1, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := []struct {
+		name  string
+		token string
+	}{
+		{name: "access token", token: accessToken},
+		{
+			name:  "wrong audience",
+			token: signGuestClaims(t, &GuestLinkClaims{CallID: "call-1", RegisteredClaims: registered("access", expiresAt)}, guestLinkSecret()),
+		},
+		{
+			name:  "signed with the user token key",
+			token: signGuestClaims(t, &GuestLinkClaims{CallID: "call-1", RegisteredClaims: registered(guestLinkAudience, expiresAt)}, jwtSecret),
+		},
+		{
+			name:  "scheduled link without occurrence",
+			token: signGuestClaims(t, &GuestLinkClaims{ScheduledCallID: 42, RegisteredClaims: registered(guestLinkAudience, expiresAt)}, guestLinkSecret()),
+		},
+		{
+			name:  "no call",
+			token: signGuestClaims(t, &GuestLinkClaims{RegisteredClaims: registered(guestLinkAudience, expiresAt)}, guestLinkSecret()),
+		},
+	}
+
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			if claims, err := ValidateGuestLinkToken(tt.token); err == nil {
+				t.Errorf("accepted %+v", claims)
+			}
+		})
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		token, err := GenerateGuestLinkToken("call-1", 0, occurrence, time.Now().Add(-time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ValidateGuestLinkToken(token); err == nil {
+			t.Error("accepted an expired link")
+		}
+	})
+}
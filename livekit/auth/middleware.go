@@ -12,8 +12,9 @@ type contextKey string
 const UserContextKey contextKey = "user"
 
 type UserInfo struct {
-	UserID   int64  `json:"userId"`
-	Username string `json:"username"`
+	UserID   int64   `json:"userId"`
+	Username string  `json:"username"`
+	Claims   *Claims `json:"-"` // The request's token, e.g. for its session and expiry
 }
 
 func AuthMiddleware(next http.Handler) http.Handler {
@@ -40,6 +41,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		ctx := context.WithValue(r.Context(), UserContextKey, &UserInfo{
 			UserID:   claims.UserID,
 			Username: claims.Username,
+			Claims:   claims,
 		})
 
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrSecretsKeyNotConfigured is returned when SECRETS_ENCRYPTION_KEY is unset.
+// There is deliberately no fallback: a key derived from another secret would
+// make every stored credential unreadable once that secret is rotated.
+var ErrSecretsKeyNotConfigured = errors.New("SECRETS_ENCRYPTION_KEY is not set")
+
+// SecretsKeyConfigured reports whether SECRETS_ENCRYPTION_KEY is set. The API
+// refuses to start without it.
+func SecretsKeyConfigured() bool {
+	return os.Getenv("SECRETS_ENCRYPTION_KEY") != ""
+}
+
+// loadSecretsKey derives the key that encrypts credentials we have to store
+// but must be able to read back, such as the SIP outbound password. It is read
+// on use so the environment, including a loaded .env file, is complete by then.
+func loadSecretsKey() ([]byte, error) {
+	secret := os.Getenv("SECRETS_ENCRYPTION_KEY")
+	if secret == "" {
+		return nil, ErrSecretsKeyNotConfigured
+	}
+	key := sha256.Sum256([]byte(secret))
+	return key[:], nil
+}
+
+// EncryptSecret seals a value with AES-256-GCM and returns it base64 encoded
+func EncryptSecret(plaintext string) (string, error) {
+	key, err := loadSecretsKey()
+	if err != nil {
+		return "", err
+	}
+	return encryptSecret(key, plaintext)
+}
+
+func DecryptSecret(ciphertext string) (string, error) {
+	key, err := loadSecretsKey()
+	if err != nil {
+		return "", err
+	}
+	return decryptSecret(key, ciphertext)
+}
+
+func encryptSecret(key []byte, plaintext string) (string, error) {
+	gcm, err := secretsCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptSecret(key []byte, ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode secret: %w", err)
+	}
+
+	gcm, err := secretsCipher(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("secret is too short")
+	}
+
+	nonce, data := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func secretsCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testSecretsKey(secret string) []byte {
+	key := sha256.Sum256([]byte(secret))
+	return key[:]
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	t.Setenv("SECRETS_ENCRYPTION_KEY", "test-secrets-key")
+	for _, plaintext := range []string{"sip-password", "", strings.Repeat("k", 1024)} {
+		ciphertext, err := EncryptSecret(plaintext)
+		if err != nil {
+			t.Fatalf("EncryptSecret: %v", err)
+		}
+		if plaintext != "" && strings.Contains(ciphertext, plaintext) {
+			t.Errorf("ciphertext %q contains the plaintext", ciphertext)
+		}
+
+		got, err := DecryptSecret(ciphertext)
+		if err != nil {
+			t.Fatalf("DecryptSecret: %v", err)
+		}
+		if got != plaintext {
+			t.Errorf("decrypted %q, want %q", got, plaintext)
+		}
+	}
+
+	// A fresh nonce each time keeps equal secrets from looking equal
+	first, _ := EncryptSecret("same")
+	second, _ := EncryptSecret("same")
+	if first == second {
+		t.Error("encrypting the same secret twice gave the same ciphertext")
+	}
+}
+
+func TestSecretsKeyRequired(t *testing.T) {
+	t.Setenv("SECRETS_ENCRYPTION_KEY", "")
+	if _, err := EncryptSecret("sip-password"); !errors.Is(err, ErrSecretsKeyNotConfigured) {
+		t.Errorf("EncryptSecret without a key: %v, want ErrSecretsKeyNotConfigured", err)
+	}
+	if _, err := DecryptSecret("c2VhbGVk"); !errors.Is(err, ErrSecretsKeyNotConfigured) {
+		t.Errorf("DecryptSecret without a key: %v, want ErrSecretsKeyNotConfigured", err)
+	}
+}
+
+func TestDecryptSecretRejects(t *testing.T) {
+	key := testSecretsKey("current")
+	ciphertext, err := encryptSecret(key, "sip-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := base64.StdEncoding.DecodeString(ciphertext)
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	tests := []struct {
+		name       string
+		key        []byte
+		ciphertext string
+	}{
+		{name: "tampered ciphertext", key: key, ciphertext: base64.StdEncoding.EncodeToString(tampered)},
+		{name: "wrong key", key: testSecretsKey("previous"), ciphertext: ciphertext},
+		{name: "truncated", key: key, ciphertext: base64.StdEncoding.EncodeToString(sealed[:4])},
+		{name: "not base64", key: key, ciphertext: "not base64!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := decryptSecret(tt.key, tt.ciphertext); err == nil {
+				t.Errorf("decrypted %q, want an error", got)
+			}
+		})
+	}
+}
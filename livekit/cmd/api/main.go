@@ -12,6 +12,9 @@ import (
 	"livekit/workers"
 	"log"
 	"net/http"
+	"time"
+
+	lksdk "github.com/livekit/server-sdk-go/v2"
 )
 
 type CreateRoomRequest struct {
@@ -25,6 +28,9 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	if !auth.SecretsKeyConfigured() {
+		log.Fatalf("SECRETS_ENCRYPTION_KEY must be set to encrypt stored SIP passwords and stream keys")
+	}
 
 	db, err := database.NewDB()
 	if err != nil {
@@ -33,7 +39,30 @@ func main() {
 	defer db.Close()
 	log.Println("Database initialized successfully")
 
+	sessionRepo := database.NewSessionRepo(db)
+	auth.SetRevocationStore(sessionRepo)
+
 	wsHub := websocket.NewWebSocketHub()
+	eventRepo := database.NewEventRepo(db)
+	wsHub.SetEventStore(eventRepo)
+	connectionRepo := database.NewConnectionRepo(db)
+	connectionTTL := time.Duration(cfg.ConnectionTTL) * time.Second
+	wsHub.SetConnectionRegistry(connectionRepo, connectionTTL)
+	if cfg.NATSURL != "" {
+		pubsub, err := websocket.NewNATSPubSub(cfg.NATSURL)
+		if err != nil {
+			log.Fatalf("Failed to initialize NATS: %v", err)
+		}
+		if err := wsHub.SetPubSub(pubsub); err != nil {
+			log.Fatalf("Failed to subscribe to NATS: %v", err)
+		}
+		registry, err := pubsub.ConnectionRegistry(connectionTTL)
+		if err != nil {
+			log.Fatalf("Failed to open NATS connection registry: %v", err)
+		}
+		wsHub.SetConnectionRegistry(registry, connectionTTL)
+		log.Printf("WebSocket events shared through NATS at %s", cfg.NATSURL)
+	}
 
 	callServiceConfig := &services.CallServiceConfig{
 		APIKey:          cfg.APIKey,
@@ -50,10 +79,62 @@ func main() {
 
 	historyService := services.NewHistoryService(db)
 	scheduledService := services.NewScheduledService(db, callService, wsHub)
+	guestService := services.NewGuestService(db, &services.GuestServiceConfig{
+		LinkBaseURL: cfg.GuestLinkBaseURL,
+		LinkTTL:     time.Duration(cfg.GuestLinkTTL) * time.Second,
+	}, callService)
+	egressClient := lksdk.NewEgressClient(cfg.LiveKitHost, cfg.APIKey, cfg.APISecret)
+	recordingService := services.NewRecordingService(db, &services.RecordingServiceConfig{
+		Filepath: cfg.RecordingFilepath,
+	}, egressClient, callService, wsHub)
+	streamingService := services.NewStreamingService(db, egressClient, callService, wsHub)
+	sipClient := lksdk.NewSIPClient(cfg.LiveKitHost, cfg.APIKey, cfg.APISecret)
+	sipService := services.NewSIPService(db, &services.SIPServiceConfig{
+		InboundNumbers:   cfg.SIPInboundNumbers,
+		OutboundAddress:  cfg.SIPOutboundAddress,
+		OutboundNumbers:  cfg.SIPOutboundNumbers,
+		OutboundUsername: cfg.SIPOutboundUsername,
+		OutboundPassword: cfg.SIPOutboundPassword,
+	}, sipClient, callService, wsHub)
+	if err := sipService.SyncTrunks(); err != nil {
+		log.Printf("Warning: failed to sync SIP trunks: %v", err)
+	}
+	breakoutService := services.NewBreakoutService(db, callService, wsHub)
+	chatService := services.NewChatService(db, callService, wsHub)
+	messagingService := services.NewMessagingService(db, wsHub)
+	interactionService := services.NewInteractionService(db, callService, wsHub)
+	pollService := services.NewPollService(db, callService, wsHub)
+	presenceService := services.NewPresenceService(db, callService, wsHub)
+	preferenceService := services.NewPreferenceService(db, wsHub)
+
+	var transcriber services.Transcriber
+	switch cfg.TranscriptionEngine {
+	case "":
+	case "fake":
+		transcriber = &services.FakeTranscriber{}
+	default:
+		log.Printf("Warning: unknown transcription engine %q, transcription disabled", cfg.TranscriptionEngine)
+	}
+	var audioSource services.AudioSource
+	egressAudioSource := services.NewEgressAudioSource(egressClient, callService, cfg.TranscriptionAudioURL)
+	if cfg.TranscriptionAudioURL != "" {
+		audioSource = egressAudioSource
+	}
+	transcriptionService := services.NewTranscriptionService(db, transcriber, audioSource, callService, wsHub)
 
 	ctx := context.Background()
 	scheduledWorker := workers.NewScheduledWorker(scheduledService, db, wsHub)
 	go scheduledWorker.Run(ctx)
+	streamWorker := workers.NewStreamWorker(streamingService)
+	go streamWorker.Run(ctx)
+	breakoutWorker := workers.NewBreakoutWorker(breakoutService)
+	go breakoutWorker.Run(ctx)
+	eventWorker := workers.NewEventWorker(eventRepo, time.Duration(cfg.EventRetention)*time.Second)
+	go eventWorker.Run(ctx)
+	connectionWorker := workers.NewConnectionWorker(wsHub, connectionRepo, connectionTTL)
+	go connectionWorker.Run(ctx)
+	presenceWorker := workers.NewPresenceWorker(presenceService, connectionTTL)
+	go presenceWorker.Run(ctx)
 
 	mux := http.NewServeMux()
 
@@ -62,20 +143,71 @@ func main() {
 	mux.Handle("/api/auth/register", cors(handlers.HandleRegister(db)))
 	mux.Handle("/api/auth/login", cors(handlers.HandleLogin(db)))
 	mux.Handle("/api/auth/me", cors(auth.AuthMiddleware(handlers.HandleMe(db))))
+	mux.Handle("/api/auth/refresh", cors(auth.AuthMiddleware(handlers.HandleRefreshToken(db))))
+	mux.Handle("/api/auth/logout", cors(auth.AuthMiddleware(handlers.HandleLogout(db, wsHub))))
 
 	mux.Handle("/api/contacts/add", cors(auth.AuthMiddleware(handlers.HandleAddContact(db))))
-	mux.Handle("/api/contacts", cors(auth.AuthMiddleware(handlers.HandleGetContacts(db))))
+	mux.Handle("/api/contacts", cors(auth.AuthMiddleware(handlers.HandleGetContacts(db, presenceService))))
 	mux.Handle("/api/contacts/remove", cors(auth.AuthMiddleware(handlers.HandleRemoveContact(db))))
 	mux.Handle("/api/contacts/search", cors(auth.AuthMiddleware(handlers.HandleSearchContacts(db))))
+	mux.Handle("/api/presence", cors(auth.AuthMiddleware(handlers.HandleGetPresence(db, presenceService))))
+	mux.Handle("/api/presence/update", cors(auth.AuthMiddleware(handlers.HandleUpdatePresence(db, presenceService))))
+	mux.Handle("/api/preferences", cors(auth.AuthMiddleware(handlers.HandleGetPreferences(db, preferenceService))))
+	mux.Handle("/api/preferences/update", cors(auth.AuthMiddleware(handlers.HandleUpdatePreferences(db, preferenceService))))
+
+	mux.Handle("/api/conversations", cors(auth.AuthMiddleware(handlers.HandleGetConversations(db, messagingService))))
+	mux.Handle("/api/conversations/create", cors(auth.AuthMiddleware(handlers.HandleCreateConversation(db, messagingService))))
+	mux.Handle("/api/conversations/messages", cors(auth.AuthMiddleware(handlers.HandleGetDirectMessages(db, messagingService))))
+	mux.Handle("/api/conversations/messages/send", cors(auth.AuthMiddleware(handlers.HandleSendDirectMessage(db, messagingService))))
+	mux.Handle("/api/conversations/read", cors(auth.AuthMiddleware(handlers.HandleMarkConversationRead(db, messagingService))))
+	mux.Handle("/api/conversations/unread", cors(auth.AuthMiddleware(handlers.HandleGetUnreadCount(db, messagingService))))
 
 	mux.Handle("/api/calls/invite", cors(auth.AuthMiddleware(handlers.HandleInvite(db, callService))))
-	mux.Handle("/api/calls/invitations", cors(auth.AuthMiddleware(handlers.HandleGetInvitations(db))))
+	mux.Handle("/api/calls/invitations", cors(auth.AuthMiddleware(handlers.HandleGetInvitations(db, callService))))
 	mux.Handle("/api/calls/invitations/respond", cors(auth.AuthMiddleware(handlers.HandleRespondInvitation(db, callService))))
 	mux.Handle("/api/calls/end", cors(auth.AuthMiddleware(handlers.HandleEndCall(db, callService))))
 	mux.Handle("/api/calls/cancel", cors(auth.AuthMiddleware(handlers.HandleCancelCall(db, callService))))
+	mux.Handle("/api/calls/settings", cors(auth.AuthMiddleware(handlers.HandleUpdateCallSettings(db, callService))))
+	mux.Handle("/api/calls/type", cors(auth.AuthMiddleware(handlers.HandleChangeCallType(db, callService))))
+	mux.Handle("/api/calls/guest-link", cors(auth.AuthMiddleware(handlers.HandleCreateGuestLink(db, guestService))))
+	mux.Handle("/api/calls/lobby", cors(auth.AuthMiddleware(handlers.HandleGetLobby(db, callService))))
+	mux.Handle("/api/calls/lobby/admit", cors(auth.AuthMiddleware(handlers.HandleAdmitLobbyRequest(db, callService))))
+	mux.Handle("/api/calls/lobby/deny", cors(auth.AuthMiddleware(handlers.HandleDenyLobbyRequest(db, callService))))
+	mux.Handle("/api/calls/lobby/status", cors(auth.AuthMiddleware(handlers.HandleGetLobbyStatus(db, callService))))
+	mux.Handle("/api/calls/screenshare", cors(auth.AuthMiddleware(handlers.HandleGetScreenShareRequests(db, callService))))
+	mux.Handle("/api/calls/screenshare/request", cors(auth.AuthMiddleware(handlers.HandleRequestScreenShare(db, callService))))
+	mux.Handle("/api/calls/screenshare/approve", cors(auth.AuthMiddleware(handlers.HandleApproveScreenShare(db, callService))))
+	mux.Handle("/api/calls/screenshare/deny", cors(auth.AuthMiddleware(handlers.HandleDenyScreenShare(db, callService))))
+	mux.Handle("/api/calls/recording/start", cors(auth.AuthMiddleware(handlers.HandleStartRecording(db, recordingService))))
+	mux.Handle("/api/calls/recording/stop", cors(auth.AuthMiddleware(handlers.HandleStopRecording(db, recordingService))))
+	mux.Handle("/api/calls/streams", cors(auth.AuthMiddleware(handlers.HandleGetStreams(db, streamingService))))
+	mux.Handle("/api/calls/streams/start", cors(auth.AuthMiddleware(handlers.HandleStartStreams(db, streamingService))))
+	mux.Handle("/api/calls/streams/stop", cors(auth.AuthMiddleware(handlers.HandleStopStreams(db, streamingService))))
+	mux.Handle("/api/calls/sip/dial-in", cors(auth.AuthMiddleware(handlers.HandleGetDialIn(db, sipService))))
+	mux.Handle("/api/calls/sip/dial-in/enable", cors(auth.AuthMiddleware(handlers.HandleEnableDialIn(db, sipService))))
+	mux.Handle("/api/calls/sip/dial-in/disable", cors(auth.AuthMiddleware(handlers.HandleDisableDialIn(db, sipService))))
+	mux.Handle("/api/calls/sip/dial-out", cors(auth.AuthMiddleware(handlers.HandleDialOut(db, sipService))))
+	mux.Handle("/api/calls/breakouts", cors(auth.AuthMiddleware(handlers.HandleGetBreakouts(db, breakoutService))))
+	mux.Handle("/api/calls/breakouts/create", cors(auth.AuthMiddleware(handlers.HandleCreateBreakouts(db, breakoutService))))
+	mux.Handle("/api/calls/breakouts/close", cors(auth.AuthMiddleware(handlers.HandleCloseBreakouts(db, breakoutService))))
+	mux.Handle("/api/calls/messages/send", cors(auth.AuthMiddleware(handlers.HandleSendChatMessage(db, chatService))))
+	mux.Handle("/api/calls/hands", cors(auth.AuthMiddleware(handlers.HandleGetInteractions(db, interactionService))))
+	mux.Handle("/api/calls/hands/raise", cors(auth.AuthMiddleware(handlers.HandleRaiseHand(db, interactionService))))
+	mux.Handle("/api/calls/hands/lower", cors(auth.AuthMiddleware(handlers.HandleLowerHand(db, interactionService))))
+	mux.Handle("/api/calls/reactions", cors(auth.AuthMiddleware(handlers.HandleReact(db, interactionService))))
+	mux.Handle("/api/calls/polls", cors(auth.AuthMiddleware(handlers.HandleGetPolls(db, pollService))))
+	mux.Handle("/api/calls/polls/create", cors(auth.AuthMiddleware(handlers.HandleCreatePoll(db, pollService))))
+	mux.Handle("/api/calls/polls/vote", cors(auth.AuthMiddleware(handlers.HandleVotePoll(db, pollService))))
+	mux.Handle("/api/calls/polls/close", cors(auth.AuthMiddleware(handlers.HandleClosePoll(db, pollService))))
+	mux.Handle("/api/calls/transcription/start", cors(auth.AuthMiddleware(handlers.HandleStartTranscription(db, transcriptionService))))
+	mux.Handle("/api/calls/transcription/stop", cors(auth.AuthMiddleware(handlers.HandleStopTranscription(db, transcriptionService))))
+	mux.Handle("/api/calls/transcript", cors(auth.AuthMiddleware(handlers.HandleGetTranscript(db, transcriptionService))))
+	mux.Handle("/api/transcripts/search", cors(auth.AuthMiddleware(handlers.HandleSearchTranscripts(db, transcriptionService))))
+	mux.Handle("/api/transcription/audio", handlers.HandleTranscriptionAudio(egressAudioSource))
 
 	mux.Handle("/api/calls/history", cors(auth.AuthMiddleware(handlers.HandleGetCallHistory(db, historyService))))
-	mux.Handle("/api/calls/history/details", cors(auth.AuthMiddleware(handlers.HandleGetCallDetails(db, historyService))))
+	mux.Handle("/api/calls/history/details", cors(auth.AuthMiddleware(handlers.HandleGetCallDetails(db, historyService, recordingService))))
+	mux.Handle("/api/calls/history/messages", cors(auth.AuthMiddleware(handlers.HandleGetChatMessages(db, chatService))))
 	mux.Handle("/api/calls/history/delete", cors(auth.AuthMiddleware(handlers.HandleDeleteCallHistory(db, historyService))))
 
 	mux.Handle("/api/calls/scheduled", cors(auth.AuthMiddleware(handlers.HandleCreateScheduledCall(db, scheduledService))))
@@ -84,10 +216,15 @@ func main() {
 	mux.Handle("/api/calls/scheduled/update", cors(auth.AuthMiddleware(handlers.HandleUpdateScheduledCall(db, scheduledService))))
 	mux.Handle("/api/calls/scheduled/cancel", cors(auth.AuthMiddleware(handlers.HandleCancelScheduledCall(db, scheduledService))))
 	mux.Handle("/api/calls/scheduled/start", cors(auth.AuthMiddleware(handlers.HandleStartScheduledCall(db, scheduledService))))
+	mux.Handle("/api/calls/scheduled/guest-link", cors(auth.AuthMiddleware(handlers.HandleCreateScheduledGuestLink(db, guestService))))
+
+	mux.Handle("/api/guest/join", cors(handlers.HandleGuestJoin(db, guestService)))
+	mux.Handle("/api/guest/lobby/status", cors(handlers.HandleGuestLobbyStatus(db, callService)))
 
-	mux.Handle("/ws", cors(websocket.HandleWebSocket(wsHub)))
+	mux.Handle("/ws", cors(websocket.HandleWebSocket(wsHub, cfg.WSAllowedOrigins)))
+	mux.Handle("/api/events", cors(auth.AuthMiddleware(websocket.HandleEvents(wsHub))))
+	mux.Handle("/api/events/schema", cors(handlers.HandleGetEventSchemas()))
 
-	mux.Handle("/api/token", cors(livekit.HandleToken(cfg)))
 	mux.Handle("/health", cors(livekit.HandleHealth(cfg)))
 
 	serverAddr := fmt.Sprintf(":%d", cfg.ServerPort)
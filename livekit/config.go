@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -18,6 +19,21 @@ type Config struct {
 	MaxParticipants     int
 	MaxCallDuration     int
 	DefaultCallDuration int
+	GuestLinkBaseURL    string
+	GuestLinkTTL        int
+	RecordingFilepath   string
+	SIPInboundNumbers   []string
+	SIPOutboundAddress  string
+	SIPOutboundNumbers  []string
+	SIPOutboundUsername string
+	SIPOutboundPassword string
+
+	TranscriptionEngine   string
+	TranscriptionAudioURL string
+	EventRetention        int
+	NATSURL               string
+	ConnectionTTL         int
+	WSAllowedOrigins      []string
 }
 
 func LoadConfig() (*Config, error) {
@@ -81,6 +97,40 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	guestLinkBaseURL := os.Getenv("GUEST_LINK_BASE_URL")
+	if guestLinkBaseURL == "" {
+		guestLinkBaseURL = "app://call/guest"
+	}
+
+	guestLinkTTL := 7 * 24 * 60 * 60
+	if ttlStr := os.Getenv("GUEST_LINK_TTL"); ttlStr != "" {
+		ttl, err := strconv.Atoi(ttlStr)
+		if err == nil && ttl > 0 {
+			guestLinkTTL = ttl
+		}
+	}
+
+	recordingFilepath := os.Getenv("RECORDING_FILEPATH")
+	if recordingFilepath == "" {
+		recordingFilepath = "recordings/{room_name}-{time}.mp4"
+	}
+
+	eventRetention := 24 * 60 * 60
+	if retentionStr := os.Getenv("EVENT_RETENTION"); retentionStr != "" {
+		retention, err := strconv.Atoi(retentionStr)
+		if err == nil && retention > 0 {
+			eventRetention = retention
+		}
+	}
+
+	connectionTTL := 60
+	if ttlStr := os.Getenv("CONNECTION_TTL"); ttlStr != "" {
+		ttl, err := strconv.Atoi(ttlStr)
+		if err == nil && ttl > 0 {
+			connectionTTL = ttl
+		}
+	}
+
 	return &Config{
 		APIKey:              apiKey,
 		APISecret:           apiSecret,
@@ -90,5 +140,31 @@ func LoadConfig() (*Config, error) {
 		MaxParticipants:     maxParticipants,
 		MaxCallDuration:     maxCallDuration,
 		DefaultCallDuration: defaultCallDuration,
+		GuestLinkBaseURL:    guestLinkBaseURL,
+		GuestLinkTTL:        guestLinkTTL,
+		RecordingFilepath:   recordingFilepath,
+		SIPInboundNumbers:   splitList(os.Getenv("SIP_INBOUND_NUMBERS")),
+		SIPOutboundAddress:  os.Getenv("SIP_OUTBOUND_ADDRESS"),
+		SIPOutboundNumbers:  splitList(os.Getenv("SIP_OUTBOUND_NUMBERS")),
+		SIPOutboundUsername: os.Getenv("SIP_OUTBOUND_USERNAME"),
+		SIPOutboundPassword: os.Getenv("SIP_OUTBOUND_PASSWORD"),
+
+		TranscriptionEngine:   os.Getenv("TRANSCRIPTION_ENGINE"),
+		TranscriptionAudioURL: os.Getenv("TRANSCRIPTION_AUDIO_URL"),
+		EventRetention:        eventRetention,
+		NATSURL:               os.Getenv("NATS_URL"),
+		ConnectionTTL:         connectionTTL,
+		WSAllowedOrigins:      splitList(os.Getenv("WS_ALLOWED_ORIGINS")),
 	}, nil
 }
+
+// splitList parses a comma separated environment value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+type BreakoutRepo struct {
+	db *DB
+}
+
+func NewBreakoutRepo(db *DB) *BreakoutRepo {
+	return &BreakoutRepo{db: db}
+}
+
+func (r *BreakoutRepo) CreateRoom(callID, roomName, name string, endsAt *time.Time) (*models.BreakoutRoom, error) {
+	createdAt := time.Now()
+	result, err := r.db.conn.Exec(
+		`INSERT INTO breakout_rooms (call_id, room_name, name, status, ends_at, created_at)
+		 VALUES (?, ?, ?, 'open', ?, ?)`,
+		callID, roomName, name, endsAt, createdAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create breakout room: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return &models.BreakoutRoom{
+		ID:          id,
+		CallID:      callID,
+		RoomName:    roomName,
+		Name:        name,
+		Status:      "open",
+		EndsAt:      endsAt,
+		CreatedAt:   createdAt,
+		Assignments: []*models.BreakoutAssignment{},
+	}, nil
+}
+
+func (r *BreakoutRepo) CreateAssignment(breakoutRoomID int64, identity string) (*models.BreakoutAssignment, error) {
+	assignedAt := time.Now()
+	result, err := r.db.conn.Exec(
+		`INSERT INTO breakout_assignments (breakout_room_id, identity, attended, assigned_at) VALUES (?, ?, 0, ?)`,
+		breakoutRoomID, identity, assignedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create breakout assignment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return &models.BreakoutAssignment{
+		ID:             id,
+		BreakoutRoomID: breakoutRoomID,
+		Identity:       identity,
+		AssignedAt:     assignedAt,
+	}, nil
+}
+
+// GetByCallID returns the breakout rooms of a call with their assignments
+func (r *BreakoutRepo) GetByCallID(callID string) ([]*models.BreakoutRoom, error) {
+	return r.getRooms(callID, false)
+}
+
+func (r *BreakoutRepo) GetOpenByCallID(callID string) ([]*models.BreakoutRoom, error) {
+	return r.getRooms(callID, true)
+}
+
+func (r *BreakoutRepo) getRooms(callID string, openOnly bool) ([]*models.BreakoutRoom, error) {
+	query := `SELECT id, call_id, room_name, name, status, ends_at, created_at, closed_at
+		FROM breakout_rooms WHERE call_id = ?`
+	if openOnly {
+		query += " AND status = 'open'"
+	}
+	query += " ORDER BY id ASC"
+
+	rows, err := r.db.conn.Query(query, callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get breakout rooms: %w", err)
+	}
+	defer rows.Close()
+
+	var rooms []*models.BreakoutRoom
+	for rows.Next() {
+		var room models.BreakoutRoom
+		var endsAt, closedAt sql.NullTime
+		if err := rows.Scan(&room.ID, &room.CallID, &room.RoomName, &room.Name, &room.Status, &endsAt, &room.CreatedAt, &closedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan breakout room: %w", err)
+		}
+		if endsAt.Valid {
+			room.EndsAt = &endsAt.Time
+		}
+		if closedAt.Valid {
+			room.ClosedAt = &closedAt.Time
+		}
+		room.Assignments = []*models.BreakoutAssignment{}
+		rooms = append(rooms, &room)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, room := range rooms {
+		assignments, err := r.getAssignments(room.ID)
+		if err != nil {
+			return nil, err
+		}
+		room.Assignments = assignments
+	}
+
+	return rooms, nil
+}
+
+func (r *BreakoutRepo) getAssignments(breakoutRoomID int64) ([]*models.BreakoutAssignment, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT id, breakout_room_id, identity, attended, assigned_at
+		 FROM breakout_assignments WHERE breakout_room_id = ? ORDER BY id ASC`,
+		breakoutRoomID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get breakout assignments: %w", err)
+	}
+	defer rows.Close()
+
+	assignments := []*models.BreakoutAssignment{}
+	for rows.Next() {
+		var assignment models.BreakoutAssignment
+		if err := rows.Scan(&assignment.ID, &assignment.BreakoutRoomID, &assignment.Identity, &assignment.Attended, &assignment.AssignedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan breakout assignment: %w", err)
+		}
+		assignments = append(assignments, &assignment)
+	}
+
+	return assignments, rows.Err()
+}
+
+// MarkAttended flags that a participant actually joined the breakout room.
+// Participants who joined without an assignment are added as well.
+func (r *BreakoutRepo) MarkAttended(breakoutRoomID int64, identity string) error {
+	_, err := r.db.conn.Exec(
+		`INSERT INTO breakout_assignments (breakout_room_id, identity, attended, assigned_at) VALUES (?, ?, 1, ?)
+		 ON CONFLICT(breakout_room_id, identity) DO UPDATE SET attended = 1`,
+		breakoutRoomID, identity, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark breakout attendance: %w", err)
+	}
+	return nil
+}
+
+// CloseRoom closes an open breakout room and reports whether this call closed
+// it, so only one instance sends its participants back
+func (r *BreakoutRepo) CloseRoom(id int64) (bool, error) {
+	result, err := r.db.conn.Exec(
+		`UPDATE breakout_rooms SET status = 'closed', closed_at = ? WHERE id = ? AND status = 'open'`,
+		time.Now(), id,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to close breakout room: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// GetExpiredCallIDs returns the calls with open breakout rooms whose time is up
+func (r *BreakoutRepo) GetExpiredCallIDs(now time.Time) ([]string, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT DISTINCT call_id FROM breakout_rooms WHERE status = 'open' AND ends_at IS NOT NULL AND ends_at <= ?`,
+		now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired breakout rooms: %w", err)
+	}
+	defer rows.Close()
+
+	var callIDs []string
+	for rows.Next() {
+		var callID string
+		if err := rows.Scan(&callID); err != nil {
+			return nil, fmt.Errorf("failed to scan breakout room: %w", err)
+		}
+		callIDs = append(callIDs, callID)
+	}
+
+	return callIDs, rows.Err()
+}
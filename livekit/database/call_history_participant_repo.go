@@ -0,0 +1,127 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"livekit/models"
+	"strings"
+	"time"
+)
+
+type CallHistoryParticipantRepo struct {
+	db *DB
+}
+
+func NewCallHistoryParticipantRepo(db *DB) *CallHistoryParticipantRepo {
+	return &CallHistoryParticipantRepo{db: db}
+}
+
+func (r *CallHistoryParticipantRepo) Create(callID string, userID int64, identity, role string, invitationID int64) error {
+	var userIDValue, invitationIDValue interface{}
+	if userID > 0 {
+		userIDValue = userID
+	}
+	if invitationID > 0 {
+		invitationIDValue = invitationID
+	}
+
+	_, err := r.db.conn.Exec(
+		`INSERT OR IGNORE INTO call_history_participants (call_id, user_id, identity, role, outcome, invitation_id, created_at)
+		 VALUES (?, ?, ?, ?, 'pending', ?, ?)`,
+		callID, userIDValue, identity, role, invitationIDValue, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create call history participant: %w", err)
+	}
+	return nil
+}
+
+func (r *CallHistoryParticipantRepo) UpdateOutcome(callID, identity, outcome string) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE call_history_participants SET outcome = ?, responded_at = ? WHERE call_id = ? AND identity = ?`,
+		outcome, time.Now(), callID, identity,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update participant outcome: %w", err)
+	}
+	return nil
+}
+
+// UpdatePendingOutcomes resolves every participant that never answered
+func (r *CallHistoryParticipantRepo) UpdatePendingOutcomes(callID, outcome string) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE call_history_participants SET outcome = ? WHERE call_id = ? AND outcome = 'pending'`,
+		outcome, callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update pending outcomes: %w", err)
+	}
+	return nil
+}
+
+func (r *CallHistoryParticipantRepo) GetByCallID(callID string) ([]*models.CallParticipantOutcome, error) {
+	return r.query(
+		`SELECT id, call_id, user_id, identity, role, outcome, invitation_id, responded_at, created_at
+		 FROM call_history_participants WHERE call_id = ? ORDER BY created_at ASC, id ASC`,
+		callID,
+	)
+}
+
+// GetByCallIDs loads the participants of several calls in one query, keyed by call ID
+func (r *CallHistoryParticipantRepo) GetByCallIDs(callIDs []string) (map[string][]*models.CallParticipantOutcome, error) {
+	byCallID := make(map[string][]*models.CallParticipantOutcome, len(callIDs))
+	if len(callIDs) == 0 {
+		return byCallID, nil
+	}
+
+	args := make([]interface{}, len(callIDs))
+	for i, callID := range callIDs {
+		args[i] = callID
+	}
+	outcomes, err := r.query(
+		`SELECT id, call_id, user_id, identity, role, outcome, invitation_id, responded_at, created_at
+		 FROM call_history_participants WHERE call_id IN (?`+strings.Repeat(", ?", len(callIDs)-1)+`)
+		 ORDER BY created_at ASC, id ASC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, outcome := range outcomes {
+		byCallID[outcome.CallID] = append(byCallID[outcome.CallID], outcome)
+	}
+	return byCallID, nil
+}
+
+func (r *CallHistoryParticipantRepo) query(query string, args ...interface{}) ([]*models.CallParticipantOutcome, error) {
+	rows, err := r.db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call history participants: %w", err)
+	}
+	defer rows.Close()
+
+	var outcomes []*models.CallParticipantOutcome
+	for rows.Next() {
+		var outcome models.CallParticipantOutcome
+		var userID, invitationID sql.NullInt64
+		var respondedAt sql.NullTime
+		if err := rows.Scan(&outcome.ID, &outcome.CallID, &userID, &outcome.Identity, &outcome.Role, &outcome.Outcome,
+			&invitationID, &respondedAt, &outcome.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan call history participant: %w", err)
+		}
+		if userID.Valid {
+			outcome.UserID = userID.Int64
+		}
+		if invitationID.Valid {
+			outcome.InvitationID = invitationID.Int64
+		}
+		if respondedAt.Valid {
+			outcome.RespondedAt = &respondedAt.Time
+		}
+		outcomes = append(outcomes, &outcome)
+	}
+
+	return outcomes, rows.Err()
+}
+
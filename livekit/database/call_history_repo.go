@@ -70,6 +70,33 @@ func (r *CallHistoryRepo) Update(callID string, endedAt time.Time, duration int,
 	return nil
 }
 
+func (r *CallHistoryRepo) UpdateParticipants(callID string, participants []string) error {
+	participantsJSON, err := json.Marshal(participants)
+	if err != nil {
+		return fmt.Errorf("failed to marshal participants: %w", err)
+	}
+
+	_, err = r.db.conn.Exec(
+		`UPDATE call_history SET participants = ? WHERE call_id = ?`,
+		string(participantsJSON), callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update call history participants: %w", err)
+	}
+	return nil
+}
+
+func (r *CallHistoryRepo) UpdateCallType(callID, callType string) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE call_history SET call_type = ? WHERE call_id = ?`,
+		callType, callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update call history type: %w", err)
+	}
+	return nil
+}
+
 func (r *CallHistoryRepo) GetByCallID(callID string) (*models.CallHistory, error) {
 	var history models.CallHistory
 	var endedAt sql.NullTime
@@ -227,11 +254,25 @@ func (r *CallHistoryRepo) GetByDateRange(userID int64, startDate, endDate time.T
 	return histories, rows.Err()
 }
 
+// Delete removes a call's history together with everything recorded about the
+// call. Poll options and votes and breakout assignments cascade from their
+// parent rows.
 func (r *CallHistoryRepo) Delete(callID string) error {
-	_, err := r.db.conn.Exec(`DELETE FROM call_history WHERE call_id = ?`, callID)
+	tx, err := r.db.BeginTx()
 	if err != nil {
-		return fmt.Errorf("failed to delete call history: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	return nil
+	defer tx.Rollback()
+
+	for _, table := range []string{
+		"call_history_participants", "call_messages", "transcript_segments", "call_polls",
+		"recordings", "live_streams", "breakout_rooms", "call_history",
+	} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE call_id = ?`, callID); err != nil {
+			return fmt.Errorf("failed to delete call history from %s: %w", table, err)
+		}
+	}
+
+	return tx.Commit()
 }
 
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+type CallMessageRepo struct {
+	db *DB
+}
+
+func NewCallMessageRepo(db *DB) *CallMessageRepo {
+	return &CallMessageRepo{db: db}
+}
+
+func (r *CallMessageRepo) Create(callID string, senderID int64, sender, body string) (*models.CallMessage, error) {
+	createdAt := time.Now()
+	result, err := r.db.conn.Exec(
+		`INSERT INTO call_messages (call_id, sender_id, sender, body, created_at) VALUES (?, ?, ?, ?, ?)`,
+		callID, senderID, sender, body, createdAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create call message: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return &models.CallMessage{
+		ID:        id,
+		CallID:    callID,
+		SenderID:  senderID,
+		Sender:    sender,
+		Body:      body,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// GetByCallID returns a page of a call's messages, oldest first
+func (r *CallMessageRepo) GetByCallID(callID string, limit, offset int) ([]*models.CallMessage, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT id, call_id, sender_id, sender, body, created_at
+		 FROM call_messages WHERE call_id = ? ORDER BY id ASC LIMIT ? OFFSET ?`,
+		callID, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []*models.CallMessage{}
+	for rows.Next() {
+		var message models.CallMessage
+		if err := rows.Scan(&message.ID, &message.CallID, &message.SenderID, &message.Sender, &message.Body, &message.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan call message: %w", err)
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, rows.Err()
+}
+
+func (r *CallMessageRepo) CountByCallID(callID string) (int, error) {
+	var count int
+	err := r.db.conn.QueryRow(`SELECT COUNT(*) FROM call_messages WHERE call_id = ?`, callID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count call messages: %w", err)
+	}
+	return count, nil
+}
+
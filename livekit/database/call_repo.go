@@ -31,13 +31,15 @@ func (r *CallRepo) Create(callID, roomName, callType string, createdBy int64) (*
 	}
 
 	return &models.ActiveCall{
-		ID:        id,
-		CallID:    callID,
-		RoomName:  roomName,
-		CallType:  callType,
-		CreatedBy: createdBy,
-		Status:    "active",
-		CreatedAt: time.Now(),
+		ID:                 id,
+		CallID:             callID,
+		RoomName:           roomName,
+		CallType:           callType,
+		CreatedBy:          createdBy,
+		Status:             "active",
+		CreatedAt:          time.Now(),
+		GuestAccessEnabled: true,
+		ScreenSharePolicy:  "everyone",
 	}, nil
 }
 
@@ -45,9 +47,9 @@ func (r *CallRepo) GetByCallID(callID string) (*models.ActiveCall, error) {
 	var call models.ActiveCall
 	var endedAt sql.NullTime
 	err := r.db.conn.QueryRow(
-		"SELECT id, call_id, room_name, call_type, created_by, created_at, ended_at, status FROM active_calls WHERE call_id = ?",
+		"SELECT id, call_id, room_name, call_type, created_by, created_at, ended_at, status, guest_access_enabled, lobby_enabled, passcode_hash, screen_share_policy FROM active_calls WHERE call_id = ?",
 		callID,
-	).Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.CreatedAt, &endedAt, &call.Status)
+	).Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.CreatedAt, &endedAt, &call.Status, &call.GuestAccessEnabled, &call.LobbyEnabled, &call.PasscodeHash, &call.ScreenSharePolicy)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -60,6 +62,8 @@ func (r *CallRepo) GetByCallID(callID string) (*models.ActiveCall, error) {
 		call.EndedAt = &endedAt.Time
 	}
 
+	call.HasPasscode = call.PasscodeHash != ""
+
 	return &call, nil
 }
 
@@ -67,9 +71,9 @@ func (r *CallRepo) GetByRoomName(roomName string) (*models.ActiveCall, error) {
 	var call models.ActiveCall
 	var endedAt sql.NullTime
 	err := r.db.conn.QueryRow(
-		"SELECT id, call_id, room_name, call_type, created_by, created_at, ended_at, status FROM active_calls WHERE room_name = ?",
+		"SELECT id, call_id, room_name, call_type, created_by, created_at, ended_at, status, guest_access_enabled, lobby_enabled, passcode_hash, screen_share_policy FROM active_calls WHERE room_name = ?",
 		roomName,
-	).Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.CreatedAt, &endedAt, &call.Status)
+	).Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.CreatedAt, &endedAt, &call.Status, &call.GuestAccessEnabled, &call.LobbyEnabled, &call.PasscodeHash, &call.ScreenSharePolicy)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -82,6 +86,8 @@ func (r *CallRepo) GetByRoomName(roomName string) (*models.ActiveCall, error) {
 		call.EndedAt = &endedAt.Time
 	}
 
+	call.HasPasscode = call.PasscodeHash != ""
+
 	return &call, nil
 }
 
@@ -104,7 +110,7 @@ func (r *CallRepo) UpdateStatus(callID, status string) error {
 
 func (r *CallRepo) GetActiveCalls() ([]*models.ActiveCall, error) {
 	rows, err := r.db.conn.Query(
-		"SELECT id, call_id, room_name, call_type, created_by, created_at, ended_at, status FROM active_calls WHERE status = 'active' ORDER BY created_at DESC",
+		"SELECT id, call_id, room_name, call_type, created_by, created_at, ended_at, status, guest_access_enabled, lobby_enabled, passcode_hash, screen_share_policy FROM active_calls WHERE status = 'active' ORDER BY created_at DESC",
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get active calls: %w", err)
@@ -115,16 +121,138 @@ func (r *CallRepo) GetActiveCalls() ([]*models.ActiveCall, error) {
 	for rows.Next() {
 		var call models.ActiveCall
 		var endedAt sql.NullTime
-		if err := rows.Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.CreatedAt, &endedAt, &call.Status); err != nil {
+		if err := rows.Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.CreatedAt, &endedAt, &call.Status, &call.GuestAccessEnabled, &call.LobbyEnabled, &call.PasscodeHash, &call.ScreenSharePolicy); err != nil {
 			return nil, fmt.Errorf("failed to scan call: %w", err)
 		}
 		if endedAt.Valid {
 			call.EndedAt = &endedAt.Time
 		}
+		call.HasPasscode = call.PasscodeHash != ""
 		calls = append(calls, &call)
 	}
 
 	return calls, rows.Err()
 }
 
+func (r *CallRepo) UpdateGuestAccess(callID string, enabled bool) error {
+	_, err := r.db.conn.Exec(
+		"UPDATE active_calls SET guest_access_enabled = ? WHERE call_id = ?",
+		enabled, callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update guest access: %w", err)
+	}
+
+	return nil
+}
+
+func (r *CallRepo) UpdateLobby(callID string, enabled bool) error {
+	_, err := r.db.conn.Exec(
+		"UPDATE active_calls SET lobby_enabled = ? WHERE call_id = ?",
+		enabled, callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update lobby: %w", err)
+	}
+
+	return nil
+}
+
+// UpdatePasscode stores the bcrypt hash of the call passcode; an empty hash removes it
+func (r *CallRepo) UpdatePasscode(callID, passcodeHash string) error {
+	_, err := r.db.conn.Exec(
+		"UPDATE active_calls SET passcode_hash = ? WHERE call_id = ?",
+		passcodeHash, callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update passcode: %w", err)
+	}
+
+	return nil
+}
+
+func (r *CallRepo) UpdateCallType(callID, callType string) error {
+	_, err := r.db.conn.Exec(
+		"UPDATE active_calls SET call_type = ? WHERE call_id = ?",
+		callType, callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update call type: %w", err)
+	}
 
+	return nil
+}
+
+func (r *CallRepo) UpdateScreenSharePolicy(callID, policy string) error {
+	_, err := r.db.conn.Exec(
+		"UPDATE active_calls SET screen_share_policy = ? WHERE call_id = ?",
+		policy, callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update screen share policy: %w", err)
+	}
+
+	return nil
+}
+
+// SetScheduledOccurrence records which occurrence of a scheduled call the call
+// was started for, as a recurring schedule starts every occurrence in the same room
+func (r *CallRepo) SetScheduledOccurrence(callID string, scheduledCallID int64, occurrence time.Time) error {
+	_, err := r.db.conn.Exec(
+		"UPDATE active_calls SET scheduled_call_id = ?, scheduled_occurrence = ? WHERE call_id = ?",
+		scheduledCallID, occurrence.Unix(), callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update scheduled occurrence: %w", err)
+	}
+
+	return nil
+}
+
+// GetByScheduledOccurrence returns the call started for one occurrence of a
+// scheduled call, or nil when it has not been started
+func (r *CallRepo) GetByScheduledOccurrence(scheduledCallID int64, occurrence time.Time) (*models.ActiveCall, error) {
+	var call models.ActiveCall
+	var endedAt sql.NullTime
+	err := r.db.conn.QueryRow(
+		`SELECT id, call_id, room_name, call_type, created_by, created_at, ended_at, status, guest_access_enabled, lobby_enabled, passcode_hash, screen_share_policy
+		 FROM active_calls WHERE scheduled_call_id = ? AND scheduled_occurrence = ?
+		 ORDER BY created_at DESC LIMIT 1`,
+		scheduledCallID, occurrence.Unix(),
+	).Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.CreatedAt, &endedAt, &call.Status, &call.GuestAccessEnabled, &call.LobbyEnabled, &call.PasscodeHash, &call.ScreenSharePolicy)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+
+	if endedAt.Valid {
+		call.EndedAt = &endedAt.Time
+	}
+
+	call.HasPasscode = call.PasscodeHash != ""
+
+	return &call, nil
+}
+
+// IsInActiveCall reports whether the user hosts or has accepted an invitation
+// to a call that is still running
+func (r *CallRepo) IsInActiveCall(userID int64) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM active_calls a
+			WHERE a.status = 'active' AND (a.created_by = ? OR EXISTS(
+				SELECT 1 FROM call_invitations i
+				WHERE i.call_id = a.call_id AND i.invitee_id = ? AND i.status = 'accepted'
+			))
+		)
+	`
+
+	var inCall bool
+	if err := r.db.conn.QueryRow(query, userID, userID).Scan(&inCall); err != nil {
+		return false, fmt.Errorf("failed to check active calls: %w", err)
+	}
+	return inCall, nil
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConnectionRepo is the registry of open WebSocket connections when no NATS
+// server is configured. It only spans instances that share the database;
+// multi-instance deployments use the NATS registry instead. Entries carry an
+// expiry that their instance keeps pushing back, so sockets of an instance
+// that died without cleaning up drop out on their own.
+type ConnectionRepo struct {
+	db *DB
+}
+
+func NewConnectionRepo(db *DB) *ConnectionRepo {
+	return &ConnectionRepo{db: db}
+}
+
+func (r *ConnectionRepo) Add(instanceID, username, connID string, acks bool, ttl time.Duration) error {
+	query := `
+		INSERT INTO websocket_connections (instance_id, connection_id, username, acks, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(instance_id, connection_id) DO UPDATE SET
+			username = excluded.username, acks = excluded.acks, expires_at = excluded.expires_at
+	`
+
+	_, err := r.db.conn.Exec(query, instanceID, connID, username, acks, time.Now().UTC().Add(ttl))
+	if err != nil {
+		return fmt.Errorf("failed to register connection: %w", err)
+	}
+	return nil
+}
+
+func (r *ConnectionRepo) Remove(instanceID, username, connID string) error {
+	query := `DELETE FROM websocket_connections WHERE instance_id = ? AND connection_id = ? AND username = ?`
+
+	if _, err := r.db.conn.Exec(query, instanceID, connID, username); err != nil {
+		return fmt.Errorf("failed to unregister connection: %w", err)
+	}
+	return nil
+}
+
+// Refresh extends every connection of an instance by ttl
+func (r *ConnectionRepo) Refresh(instanceID string, ttl time.Duration) error {
+	query := `UPDATE websocket_connections SET expires_at = ? WHERE instance_id = ?`
+
+	if _, err := r.db.conn.Exec(query, time.Now().UTC().Add(ttl), instanceID); err != nil {
+		return fmt.Errorf("failed to refresh connections: %w", err)
+	}
+	return nil
+}
+
+// CountConnections returns the user's unexpired connections on all instances
+// and how many of them acknowledge critical events
+func (r *ConnectionRepo) CountConnections(username string) (int, int, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(acks), 0)
+		FROM websocket_connections
+		WHERE username = ? AND expires_at > ?
+	`
+
+	var total, acking int
+	if err := r.db.conn.QueryRow(query, username, time.Now().UTC()).Scan(&total, &acking); err != nil {
+		return 0, 0, fmt.Errorf("failed to count connections: %w", err)
+	}
+	return total, acking, nil
+}
+
+func (r *ConnectionRepo) DeleteExpired() (int64, error) {
+	result, err := r.db.conn.Exec(`DELETE FROM websocket_connections WHERE expires_at <= ?`, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired connections: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+type ConversationRepo struct {
+	db *DB
+}
+
+func NewConversationRepo(db *DB) *ConversationRepo {
+	return &ConversationRepo{db: db}
+}
+
+// Create stores a conversation with its members. directKey identifies a 1:1
+// conversation so there is only ever one per pair; it is empty for groups.
+func (r *ConversationRepo) Create(convType, name string, createdBy int64, directKey string, memberIDs []int64) (int64, error) {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var key interface{}
+	if directKey != "" {
+		key = directKey
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(
+		`INSERT INTO conversations (type, name, created_by, direct_key, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		convType, name, createdBy, key, now, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create conversation: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	for _, userID := range memberIDs {
+		if _, err := tx.Exec(
+			`INSERT OR IGNORE INTO conversation_members (conversation_id, user_id, joined_at) VALUES (?, ?, ?)`,
+			id, userID, now,
+		); err != nil {
+			return 0, fmt.Errorf("failed to add conversation member: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit conversation: %w", err)
+	}
+	return id, nil
+}
+
+func (r *ConversationRepo) GetByID(id int64) (*models.Conversation, error) {
+	var conv models.Conversation
+	err := r.db.conn.QueryRow(
+		`SELECT id, type, name, created_by, created_at, updated_at FROM conversations WHERE id = ?`,
+		id,
+	).Scan(&conv.ID, &conv.Type, &conv.Name, &conv.CreatedBy, &conv.CreatedAt, &conv.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	return &conv, nil
+}
+
+func (r *ConversationRepo) GetIDByDirectKey(directKey string) (int64, error) {
+	var id int64
+	err := r.db.conn.QueryRow(`SELECT id FROM conversations WHERE direct_key = ?`, directKey).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	return id, nil
+}
+
+// GetForUser lists the conversations a user belongs to, most recently active first
+func (r *ConversationRepo) GetForUser(userID int64) ([]*models.Conversation, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT c.id, c.type, c.name, c.created_by, c.created_at, c.updated_at
+		 FROM conversations c
+		 JOIN conversation_members m ON m.conversation_id = c.id
+		 WHERE m.user_id = ?
+		 ORDER BY c.updated_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversations: %w", err)
+	}
+	defer rows.Close()
+
+	conversations := []*models.Conversation{}
+	for rows.Next() {
+		var conv models.Conversation
+		if err := rows.Scan(&conv.ID, &conv.Type, &conv.Name, &conv.CreatedBy, &conv.CreatedAt, &conv.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		conversations = append(conversations, &conv)
+	}
+
+	return conversations, rows.Err()
+}
+
+func (r *ConversationRepo) GetMembers(conversationID int64) ([]*models.ConversationMember, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT m.user_id, u.username, m.last_delivered_message_id, m.last_read_message_id, m.joined_at
+		 FROM conversation_members m
+		 JOIN users u ON m.user_id = u.id
+		 WHERE m.conversation_id = ?
+		 ORDER BY m.id ASC`,
+		conversationID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []*models.ConversationMember{}
+	for rows.Next() {
+		var member models.ConversationMember
+		if err := rows.Scan(&member.UserID, &member.Username, &member.LastDeliveredMessageID, &member.LastReadMessageID, &member.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation member: %w", err)
+		}
+		members = append(members, &member)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *ConversationRepo) CreateMessage(conversationID, senderID int64, body string) (*models.DirectMessage, error) {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	createdAt := time.Now()
+	result, err := tx.Exec(
+		`INSERT INTO direct_messages (conversation_id, sender_id, body, created_at) VALUES (?, ?, ?, ?)`,
+		conversationID, senderID, body, createdAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create message: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE conversations SET updated_at = ? WHERE id = ?`, createdAt, conversationID); err != nil {
+		return nil, fmt.Errorf("failed to update conversation: %w", err)
+	}
+	// The sender has obviously seen their own message
+	if _, err := tx.Exec(
+		`UPDATE conversation_members SET last_delivered_message_id = ?, last_read_message_id = ?
+		 WHERE conversation_id = ? AND user_id = ?`,
+		id, id, conversationID, senderID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to update sender receipts: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit message: %w", err)
+	}
+
+	return &models.DirectMessage{
+		ID:             id,
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Body:           body,
+		CreatedAt:      createdAt,
+	}, nil
+}
+
+const directMessageColumns = `SELECT dm.id, dm.conversation_id, dm.sender_id, u.username, dm.body, dm.created_at
+	FROM direct_messages dm
+	JOIN users u ON dm.sender_id = u.id`
+
+// GetMessages returns up to limit messages older than beforeID (0 for the
+// newest), oldest first
+func (r *ConversationRepo) GetMessages(conversationID, beforeID int64, limit int) ([]*models.DirectMessage, error) {
+	query := directMessageColumns + ` WHERE dm.conversation_id = ?`
+	args := []interface{}{conversationID}
+	if beforeID > 0 {
+		query += ` AND dm.id < ?`
+		args = append(args, beforeID)
+	}
+	query += ` ORDER BY dm.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	messages, err := r.queryMessages(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+func (r *ConversationRepo) GetLastMessage(conversationID int64) (*models.DirectMessage, error) {
+	messages, err := r.GetMessages(conversationID, 0, 1)
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+	return messages[0], nil
+}
+
+// GetUndelivered returns messages from others that haven't reached the user yet, oldest first
+func (r *ConversationRepo) GetUndelivered(userID int64, limit int) ([]*models.DirectMessage, error) {
+	return r.queryMessages(
+		directMessageColumns+`
+		 JOIN conversation_members m ON m.conversation_id = dm.conversation_id AND m.user_id = ?
+		 WHERE dm.sender_id != ? AND dm.id > m.last_delivered_message_id
+		 ORDER BY dm.id ASC LIMIT ?`,
+		userID, userID, limit,
+	)
+}
+
+func (r *ConversationRepo) queryMessages(query string, args ...interface{}) ([]*models.DirectMessage, error) {
+	rows, err := r.db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []*models.DirectMessage{}
+	for rows.Next() {
+		var message models.DirectMessage
+		if err := rows.Scan(&message.ID, &message.ConversationID, &message.SenderID, &message.Sender, &message.Body, &message.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, rows.Err()
+}
+
+// MarkDelivered advances the member's delivery cursor. It reports whether the
+// cursor moved so receipts are only sent once.
+func (r *ConversationRepo) MarkDelivered(conversationID, userID, messageID int64) (bool, error) {
+	result, err := r.db.conn.Exec(
+		`UPDATE conversation_members SET last_delivered_message_id = ?
+		 WHERE conversation_id = ? AND user_id = ? AND last_delivered_message_id < ?`,
+		messageID, conversationID, userID, messageID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark messages delivered: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// MarkRead advances the member's read cursor, which implies delivery
+func (r *ConversationRepo) MarkRead(conversationID, userID, messageID int64) (bool, error) {
+	result, err := r.db.conn.Exec(
+		`UPDATE conversation_members
+		 SET last_read_message_id = ?, last_delivered_message_id = MAX(last_delivered_message_id, ?)
+		 WHERE conversation_id = ? AND user_id = ? AND last_read_message_id < ?`,
+		messageID, messageID, conversationID, userID, messageID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark messages read: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *ConversationRepo) UnreadCount(conversationID, userID int64) (int, error) {
+	var count int
+	err := r.db.conn.QueryRow(
+		`SELECT COUNT(*) FROM direct_messages dm
+		 JOIN conversation_members m ON m.conversation_id = dm.conversation_id AND m.user_id = ?
+		 WHERE dm.conversation_id = ? AND dm.sender_id != ? AND dm.id > m.last_read_message_id`,
+		userID, conversationID, userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread messages: %w", err)
+	}
+	return count, nil
+}
+
+func (r *ConversationRepo) TotalUnread(userID int64) (int, error) {
+	var count int
+	err := r.db.conn.QueryRow(
+		`SELECT COUNT(*) FROM direct_messages dm
+		 JOIN conversation_members m ON m.conversation_id = dm.conversation_id AND m.user_id = ?
+		 WHERE dm.sender_id != ? AND dm.id > m.last_read_message_id`,
+		userID, userID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unread messages: %w", err)
+	}
+	return count, nil
+}
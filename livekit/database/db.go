@@ -51,8 +51,34 @@ func (db *DB) migrate() error {
 		createCallInvitationsTable,
 		createActiveCallsTable,
 		createCallHistoryTable,
+		createCallHistoryParticipantsTable,
 		createScheduledCallsTable,
 		createScheduledCallInvitationsTable,
+		createLobbyRequestsTable,
+		createRecordingsTable,
+		createLiveStreamsTable,
+		createSIPTrunksTable,
+		createSIPDialInsTable,
+		createBreakoutRoomsTable,
+		createBreakoutAssignmentsTable,
+		createCallMessagesTable,
+		createConversationsTable,
+		createConversationMembersTable,
+		createDirectMessagesTable,
+		createCallPollsTable,
+		createCallPollOptionsTable,
+		createCallPollVotesTable,
+		createTranscriptSegmentsTable,
+		createRaisedHandsTable,
+		createCallReactionsTable,
+		createScreenShareRequestsTable,
+		createUserEventsTable,
+		createUserEventSequencesTable,
+		createWebSocketConnectionsTable,
+		createRevokedSessionsTable,
+		createUserPresenceTable,
+		createUserPreferencesTable,
+		createPasscodeAttemptsTable,
 		createIndexes,
 	}
 
@@ -62,6 +88,10 @@ func (db *DB) migrate() error {
 		}
 	}
 
+	if err := db.migrateActiveCalls(); err != nil {
+		return fmt.Errorf("failed to migrate active_calls: %w", err)
+	}
+
 	if err := db.migrateScheduledCalls(); err != nil {
 		return fmt.Errorf("failed to migrate scheduled_calls: %w", err)
 	}
@@ -69,10 +99,38 @@ func (db *DB) migrate() error {
 	return nil
 }
 
+func (db *DB) migrateActiveCalls() error {
+	migrations := []string{
+		`ALTER TABLE active_calls ADD COLUMN guest_access_enabled INTEGER DEFAULT 1`,
+		`ALTER TABLE active_calls ADD COLUMN lobby_enabled INTEGER DEFAULT 0`,
+		`ALTER TABLE active_calls ADD COLUMN passcode_hash TEXT DEFAULT ''`,
+		`ALTER TABLE active_calls ADD COLUMN screen_share_policy TEXT DEFAULT 'everyone'`,
+		`ALTER TABLE active_calls ADD COLUMN scheduled_call_id INTEGER`,
+		`ALTER TABLE active_calls ADD COLUMN scheduled_occurrence INTEGER`,
+		// Indexed here rather than in createIndexes, which runs before the column exists
+		`CREATE INDEX IF NOT EXISTS idx_active_calls_scheduled_call_id ON active_calls(scheduled_call_id, scheduled_occurrence)`,
+	}
+
+	for _, migration := range migrations {
+		_, err := db.conn.Exec(migration)
+		if err != nil {
+			msg := err.Error()
+			if !contains(msg, "duplicate column name") {
+				return fmt.Errorf("failed to execute migration: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (db *DB) migrateScheduledCalls() error {
 	migrations := []string{
 		`ALTER TABLE scheduled_calls ADD COLUMN max_participants INTEGER DEFAULT 0`,
 		`ALTER TABLE scheduled_calls ADD COLUMN max_duration_seconds INTEGER DEFAULT 0`,
+		`ALTER TABLE scheduled_calls ADD COLUMN guest_access_enabled INTEGER DEFAULT 1`,
+		`ALTER TABLE scheduled_calls ADD COLUMN lobby_enabled INTEGER DEFAULT 0`,
+		`ALTER TABLE scheduled_calls ADD COLUMN passcode_hash TEXT DEFAULT ''`,
 	}
 
 	for _, migration := range migrations {
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+type EventRepo struct {
+	db *DB
+}
+
+func NewEventRepo(db *DB) *EventRepo {
+	return &EventRepo{db: db}
+}
+
+// Append stores an event under the user's next sequence number. The counter
+// lives in its own table so sequence numbers never go back, even after
+// expired events were deleted.
+func (r *EventRepo) Append(username, eventType string, data []byte) (*models.UserEvent, error) {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var seq int64
+	err = tx.QueryRow(
+		`INSERT INTO user_event_sequences (username, last_seq) VALUES (?, 1)
+		 ON CONFLICT(username) DO UPDATE SET last_seq = last_seq + 1
+		 RETURNING last_seq`,
+		username,
+	).Scan(&seq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate event sequence: %w", err)
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(
+		`INSERT INTO user_events (username, seq, type, data, created_at) VALUES (?, ?, ?, ?, ?)`,
+		username, seq, eventType, string(data), now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store event: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit event: %w", err)
+	}
+
+	return &models.UserEvent{
+		ID:        id,
+		Username:  username,
+		Seq:       seq,
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: now,
+	}, nil
+}
+
+// GetSince returns the user's events after a sequence number, oldest first
+func (r *EventRepo) GetSince(username string, afterSeq int64, limit int) ([]*models.UserEvent, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT id, username, seq, type, data, created_at
+		 FROM user_events WHERE username = ? AND seq > ?
+		 ORDER BY seq ASC LIMIT ?`,
+		username, afterSeq, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.UserEvent
+	for rows.Next() {
+		var event models.UserEvent
+		var data string
+		if err := rows.Scan(&event.ID, &event.Username, &event.Seq, &event.Type, &data, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		event.Data = []byte(data)
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+// DeleteOlderThan expires events past the retention window
+func (r *EventRepo) DeleteOlderThan(before time.Time) (int64, error) {
+	result, err := r.db.conn.Exec(`DELETE FROM user_events WHERE created_at < ?`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired events: %w", err)
+	}
+	return result.RowsAffected()
+}
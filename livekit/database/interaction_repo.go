@@ -0,0 +1,122 @@
+package database
+
+import (
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+// InteractionRepo keeps the raised hands and reaction tallies of active calls,
+// so every API instance and late joiners see the same speaker queue
+type InteractionRepo struct {
+	db *DB
+}
+
+func NewInteractionRepo(db *DB) *InteractionRepo {
+	return &InteractionRepo{db: db}
+}
+
+// RaiseHand puts the user at the end of the speaker queue and reports whether
+// they were not in it yet
+func (r *InteractionRepo) RaiseHand(callID, username string) (bool, error) {
+	result, err := r.db.conn.Exec(
+		`INSERT OR IGNORE INTO raised_hands (call_id, username, raised_at) VALUES (?, ?, ?)`,
+		callID, username, time.Now(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to raise hand: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// LowerHand takes the user out of the speaker queue and reports whether their
+// hand was raised
+func (r *InteractionRepo) LowerHand(callID, username string) (bool, error) {
+	result, err := r.db.conn.Exec(`DELETE FROM raised_hands WHERE call_id = ? AND username = ?`, callID, username)
+	if err != nil {
+		return false, fmt.Errorf("failed to lower hand: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// GetRaisedHands returns the speaker queue in the order hands were raised
+func (r *InteractionRepo) GetRaisedHands(callID string) ([]*models.RaisedHand, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT username, raised_at FROM raised_hands WHERE call_id = ? ORDER BY id ASC`,
+		callID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get raised hands: %w", err)
+	}
+	defer rows.Close()
+
+	hands := []*models.RaisedHand{}
+	for rows.Next() {
+		hand := &models.RaisedHand{Position: len(hands) + 1}
+		if err := rows.Scan(&hand.Username, &hand.RaisedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan raised hand: %w", err)
+		}
+		hands = append(hands, hand)
+	}
+
+	return hands, rows.Err()
+}
+
+func (r *InteractionRepo) AddReaction(callID, emoji string) error {
+	_, err := r.db.conn.Exec(
+		`INSERT INTO call_reactions (call_id, emoji, count) VALUES (?, ?, 1)
+		 ON CONFLICT(call_id, emoji) DO UPDATE SET count = count + 1`,
+		callID, emoji,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add reaction: %w", err)
+	}
+	return nil
+}
+
+// GetReactions returns how often each emoji was sent in the call
+func (r *InteractionRepo) GetReactions(callID string) (map[string]int, error) {
+	rows, err := r.db.conn.Query(`SELECT emoji, count FROM call_reactions WHERE call_id = ?`, callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reactions: %w", err)
+	}
+	defer rows.Close()
+
+	reactions := make(map[string]int)
+	for rows.Next() {
+		var emoji string
+		var count int
+		if err := rows.Scan(&emoji, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan reaction: %w", err)
+		}
+		reactions[emoji] = count
+	}
+
+	return reactions, rows.Err()
+}
+
+// DeleteByCallID drops a call's hands and reactions once it is over
+func (r *InteractionRepo) DeleteByCallID(callID string) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM raised_hands WHERE call_id = ?`, callID); err != nil {
+		return fmt.Errorf("failed to delete raised hands: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM call_reactions WHERE call_id = ?`, callID); err != nil {
+		return fmt.Errorf("failed to delete reactions: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -90,7 +90,7 @@ func (r *InvitationRepo) GetPendingForUser(userID int64) ([]*models.Invitation,
 
 func (r *InvitationRepo) UpdateStatus(invitationID int64, status string) error {
 	var respondedAt interface{}
-	if status == "accepted" || status == "rejected" {
+	if status == "accepted" || status == "rejected" || status == "lobby" || status == "denied" {
 		respondedAt = time.Now()
 	}
 
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+type LiveStreamRepo struct {
+	db *DB
+}
+
+func NewLiveStreamRepo(db *DB) *LiveStreamRepo {
+	return &LiveStreamRepo{db: db}
+}
+
+func (r *LiveStreamRepo) Create(stream *models.LiveStream) error {
+	stream.StartedAt = time.Now()
+	result, err := r.db.conn.Exec(
+		`INSERT INTO live_streams (call_id, room_name, egress_id, started_by, protocol, url, stream_key_encrypted, status, error, started_at, ended_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		stream.CallID, stream.RoomName, stream.EgressID, stream.StartedBy, stream.Protocol, stream.URL,
+		stream.StreamKeyEncrypted, stream.Status, stream.Error, stream.StartedAt, stream.EndedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create live stream: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	stream.ID = id
+
+	return nil
+}
+
+func (r *LiveStreamRepo) GetByCallID(callID string) ([]*models.LiveStream, error) {
+	return r.query(
+		`SELECT id, call_id, room_name, egress_id, started_by, protocol, url, stream_key_encrypted, status, error, started_at, ended_at
+		 FROM live_streams WHERE call_id = ? ORDER BY started_at ASC, id ASC`,
+		callID,
+	)
+}
+
+// GetLive returns every stream that has not finished yet
+func (r *LiveStreamRepo) GetLive() ([]*models.LiveStream, error) {
+	return r.query(
+		`SELECT id, call_id, room_name, egress_id, started_by, protocol, url, stream_key_encrypted, status, error, started_at, ended_at
+		 FROM live_streams WHERE status IN ('starting', 'active') ORDER BY started_at ASC, id ASC`,
+	)
+}
+
+func (r *LiveStreamRepo) UpdateStatus(id int64, status, errorMessage string, endedAt *time.Time) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE live_streams SET status = ?, error = ?, ended_at = ? WHERE id = ?`,
+		status, errorMessage, endedAt, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update live stream status: %w", err)
+	}
+	return nil
+}
+
+func (r *LiveStreamRepo) query(query string, args ...interface{}) ([]*models.LiveStream, error) {
+	rows, err := r.db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get live streams: %w", err)
+	}
+	defer rows.Close()
+
+	var streams []*models.LiveStream
+	for rows.Next() {
+		var stream models.LiveStream
+		var egressID, streamKey, errorMessage sql.NullString
+		var endedAt sql.NullTime
+		if err := rows.Scan(&stream.ID, &stream.CallID, &stream.RoomName, &egressID, &stream.StartedBy, &stream.Protocol,
+			&stream.URL, &streamKey, &stream.Status, &errorMessage, &stream.StartedAt, &endedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan live stream: %w", err)
+		}
+		stream.EgressID = egressID.String
+		stream.StreamKeyEncrypted = streamKey.String
+		stream.HasStreamKey = streamKey.String != ""
+		stream.Error = errorMessage.String
+		if endedAt.Valid {
+			stream.EndedAt = &endedAt.Time
+		}
+		streams = append(streams, &stream)
+	}
+
+	return streams, rows.Err()
+}
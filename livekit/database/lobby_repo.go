@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+type LobbyRepo struct {
+	db *DB
+}
+
+func NewLobbyRepo(db *DB) *LobbyRepo {
+	return &LobbyRepo{db: db}
+}
+
+func (r *LobbyRepo) Create(callID, roomName string, userID int64, identity, displayName, role, waitSecret string) (*models.LobbyRequest, error) {
+	var userIDValue interface{}
+	if userID > 0 {
+		userIDValue = userID
+	}
+
+	result, err := r.db.conn.Exec(
+		`INSERT INTO lobby_requests (call_id, room_name, user_id, identity, display_name, role, status, wait_secret, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, 'waiting', ?, ?)`,
+		callID, roomName, userIDValue, identity, displayName, role, waitSecret, time.Now(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lobby request: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return &models.LobbyRequest{
+		ID:          id,
+		CallID:      callID,
+		RoomName:    roomName,
+		UserID:      userID,
+		Identity:    identity,
+		DisplayName: displayName,
+		Role:        role,
+		Status:      "waiting",
+		WaitSecret:  waitSecret,
+		CreatedAt:   time.Now(),
+	}, nil
+}
+
+func (r *LobbyRepo) GetByID(id int64) (*models.LobbyRequest, error) {
+	row := r.db.conn.QueryRow(
+		`SELECT id, call_id, room_name, user_id, identity, display_name, role, status, wait_secret, created_at, responded_at
+		 FROM lobby_requests WHERE id = ?`,
+		id,
+	)
+
+	request, err := scanLobbyRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lobby request: %w", err)
+	}
+
+	return request, nil
+}
+
+// GetWaitingByIdentity returns the open request of a participant so repeated
+// join attempts don't flood the host with duplicates
+func (r *LobbyRepo) GetWaitingByIdentity(callID, identity string) (*models.LobbyRequest, error) {
+	row := r.db.conn.QueryRow(
+		`SELECT id, call_id, room_name, user_id, identity, display_name, role, status, wait_secret, created_at, responded_at
+		 FROM lobby_requests WHERE call_id = ? AND identity = ? AND status = 'waiting'
+		 ORDER BY created_at DESC LIMIT 1`,
+		callID, identity,
+	)
+
+	request, err := scanLobbyRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lobby request: %w", err)
+	}
+
+	return request, nil
+}
+
+func (r *LobbyRepo) GetWaitingByCallID(callID string) ([]*models.LobbyRequest, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT id, call_id, room_name, user_id, identity, display_name, role, status, wait_secret, created_at, responded_at
+		 FROM lobby_requests WHERE call_id = ? AND status = 'waiting'
+		 ORDER BY created_at ASC`,
+		callID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lobby requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*models.LobbyRequest
+	for rows.Next() {
+		request, err := scanLobbyRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lobby request: %w", err)
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, rows.Err()
+}
+
+func (r *LobbyRepo) UpdateStatus(id int64, status string) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE lobby_requests SET status = ?, responded_at = ? WHERE id = ?`,
+		status, time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update lobby request status: %w", err)
+	}
+	return nil
+}
+
+// CloseWaiting resolves every request still waiting when the call ends
+func (r *LobbyRepo) CloseWaiting(callID string) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE lobby_requests SET status = 'closed', responded_at = ? WHERE call_id = ? AND status = 'waiting'`,
+		time.Now(), callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to close lobby requests: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanLobbyRequest(row rowScanner) (*models.LobbyRequest, error) {
+	var request models.LobbyRequest
+	var userID sql.NullInt64
+	var respondedAt sql.NullTime
+	if err := row.Scan(&request.ID, &request.CallID, &request.RoomName, &userID, &request.Identity, &request.DisplayName,
+		&request.Role, &request.Status, &request.WaitSecret, &request.CreatedAt, &respondedAt); err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		request.UserID = userID.Int64
+	}
+	if respondedAt.Valid {
+		request.RespondedAt = &respondedAt.Time
+	}
+	return &request, nil
+}
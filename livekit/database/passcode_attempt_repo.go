@@ -0,0 +1,61 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PasscodeAttemptRepo counts failed passcode attempts per key. Keeping the
+// count in the database makes every API instance enforce the same limit.
+type PasscodeAttemptRepo struct {
+	db *DB
+}
+
+func NewPasscodeAttemptRepo(db *DB) *PasscodeAttemptRepo {
+	return &PasscodeAttemptRepo{db: db}
+}
+
+// Failures returns how many attempts failed in the key's window if that window
+// started after since
+func (r *PasscodeAttemptRepo) Failures(key string, since time.Time) (int, error) {
+	query := `SELECT failures FROM passcode_attempts WHERE attempt_key = ? AND window_start > ?`
+
+	var failures int
+	err := r.db.conn.QueryRow(query, key, since.UTC()).Scan(&failures)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get passcode attempts: %w", err)
+	}
+	return failures, nil
+}
+
+// RecordFailure counts a failed attempt, starting a new window at now when the
+// key's window started at or before since. Expired windows are cleared on the way.
+func (r *PasscodeAttemptRepo) RecordFailure(key string, now, since time.Time) error {
+	query := `
+		INSERT INTO passcode_attempts (attempt_key, failures, window_start)
+		VALUES (?, 1, ?)
+		ON CONFLICT(attempt_key) DO UPDATE SET
+			failures = CASE WHEN window_start <= ? THEN 1 ELSE failures + 1 END,
+			window_start = CASE WHEN window_start <= ? THEN excluded.window_start ELSE window_start END
+	`
+
+	if _, err := r.db.conn.Exec(query, key, now.UTC(), since.UTC(), since.UTC()); err != nil {
+		return fmt.Errorf("failed to record passcode attempt: %w", err)
+	}
+
+	if _, err := r.db.conn.Exec(`DELETE FROM passcode_attempts WHERE window_start <= ?`, since.UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired passcode attempts: %w", err)
+	}
+	return nil
+}
+
+func (r *PasscodeAttemptRepo) Reset(key string) error {
+	if _, err := r.db.conn.Exec(`DELETE FROM passcode_attempts WHERE attempt_key = ?`, key); err != nil {
+		return fmt.Errorf("failed to reset passcode attempts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+type PollRepo struct {
+	db *DB
+}
+
+func NewPollRepo(db *DB) *PollRepo {
+	return &PollRepo{db: db}
+}
+
+// Create stores a poll and its options, filling in the generated IDs
+func (r *PollRepo) Create(poll *models.Poll) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	poll.Status = "open"
+	poll.CreatedAt = time.Now()
+	result, err := tx.Exec(
+		`INSERT INTO call_polls (call_id, created_by, question, multiple_choice, anonymous, status, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		poll.CallID, poll.CreatedBy, poll.Question, poll.MultipleChoice, poll.Anonymous, poll.Status, poll.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create poll: %w", err)
+	}
+
+	poll.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	for i, option := range poll.Options {
+		result, err := tx.Exec(
+			`INSERT INTO call_poll_options (poll_id, position, label) VALUES (?, ?, ?)`,
+			poll.ID, i, option.Label,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create poll option: %w", err)
+		}
+		option.ID, err = result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get last insert id: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+const pollColumns = `SELECT id, call_id, created_by, question, multiple_choice, anonymous, status, created_at, closed_at FROM call_polls`
+
+func (r *PollRepo) GetByID(id int64) (*models.Poll, error) {
+	poll, err := scanPoll(r.db.conn.QueryRow(pollColumns+` WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get poll: %w", err)
+	}
+
+	if err := r.loadResults(poll); err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
+
+// GetByCallID returns the polls of a call with their tallies, oldest first
+func (r *PollRepo) GetByCallID(callID string) ([]*models.Poll, error) {
+	rows, err := r.db.conn.Query(pollColumns+` WHERE call_id = ? ORDER BY id ASC`, callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get polls: %w", err)
+	}
+	defer rows.Close()
+
+	var polls []*models.Poll
+	for rows.Next() {
+		poll, err := scanPoll(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan poll: %w", err)
+		}
+		polls = append(polls, poll)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, poll := range polls {
+		if err := r.loadResults(poll); err != nil {
+			return nil, err
+		}
+	}
+	return polls, nil
+}
+
+func scanPoll(row rowScanner) (*models.Poll, error) {
+	var poll models.Poll
+	var closedAt sql.NullTime
+	if err := row.Scan(&poll.ID, &poll.CallID, &poll.CreatedBy, &poll.Question, &poll.MultipleChoice, &poll.Anonymous,
+		&poll.Status, &poll.CreatedAt, &closedAt); err != nil {
+		return nil, err
+	}
+	if closedAt.Valid {
+		poll.ClosedAt = &closedAt.Time
+	}
+	return &poll, nil
+}
+
+// loadResults fills the options with their vote counts and, unless the poll
+// is anonymous, who voted for them
+func (r *PollRepo) loadResults(poll *models.Poll) error {
+	rows, err := r.db.conn.Query(
+		`SELECT id, label FROM call_poll_options WHERE poll_id = ? ORDER BY position ASC`,
+		poll.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get poll options: %w", err)
+	}
+
+	poll.Options = []*models.PollOption{}
+	byID := make(map[int64]*models.PollOption)
+	for rows.Next() {
+		var option models.PollOption
+		if err := rows.Scan(&option.ID, &option.Label); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan poll option: %w", err)
+		}
+		poll.Options = append(poll.Options, &option)
+		byID[option.ID] = &option
+	}
+	rows.Close()
+
+	rows, err = r.db.conn.Query(
+		`SELECT option_id, username FROM call_poll_votes WHERE poll_id = ? ORDER BY id ASC`,
+		poll.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get poll votes: %w", err)
+	}
+	defer rows.Close()
+
+	voters := make(map[string]bool)
+	for rows.Next() {
+		var optionID int64
+		var username string
+		if err := rows.Scan(&optionID, &username); err != nil {
+			return fmt.Errorf("failed to scan poll vote: %w", err)
+		}
+		voters[username] = true
+		option, ok := byID[optionID]
+		if !ok {
+			continue
+		}
+		option.Votes++
+		if !poll.Anonymous {
+			option.Voters = append(option.Voters, username)
+		}
+	}
+	poll.TotalVoters = len(voters)
+
+	return rows.Err()
+}
+
+func (r *PollRepo) GetUserVotes(pollID, userID int64) ([]int64, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT option_id FROM call_poll_votes WHERE poll_id = ? AND user_id = ? ORDER BY option_id ASC`,
+		pollID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get poll votes: %w", err)
+	}
+	defer rows.Close()
+
+	var optionIDs []int64
+	for rows.Next() {
+		var optionID int64
+		if err := rows.Scan(&optionID); err != nil {
+			return nil, fmt.Errorf("failed to scan poll vote: %w", err)
+		}
+		optionIDs = append(optionIDs, optionID)
+	}
+	return optionIDs, rows.Err()
+}
+
+// ReplaceVotes swaps a user's ballot for the given options, so voting again
+// changes the vote instead of adding to it
+func (r *PollRepo) ReplaceVotes(pollID, userID int64, username string, optionIDs []int64) error {
+	tx, err := r.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM call_poll_votes WHERE poll_id = ? AND user_id = ?`, pollID, userID); err != nil {
+		return fmt.Errorf("failed to clear poll votes: %w", err)
+	}
+
+	now := time.Now()
+	for _, optionID := range optionIDs {
+		if _, err := tx.Exec(
+			`INSERT INTO call_poll_votes (poll_id, option_id, user_id, username, created_at) VALUES (?, ?, ?, ?, ?)`,
+			pollID, optionID, userID, username, now,
+		); err != nil {
+			return fmt.Errorf("failed to record poll vote: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *PollRepo) Close(id int64) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE call_polls SET status = 'closed', closed_at = ? WHERE id = ? AND status = 'open'`,
+		time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to close poll: %w", err)
+	}
+	return nil
+}
+
+func (r *PollRepo) CloseOpenByCallID(callID string) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE call_polls SET status = 'closed', closed_at = ? WHERE call_id = ? AND status = 'open'`,
+		time.Now(), callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to close polls: %w", err)
+	}
+	return nil
+}
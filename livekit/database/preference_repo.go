@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+type PreferenceRepo struct {
+	db *DB
+}
+
+func NewPreferenceRepo(db *DB) *PreferenceRepo {
+	return &PreferenceRepo{db: db}
+}
+
+const preferenceColumns = `do_not_disturb, quiet_hours_start, quiet_hours_end, quiet_hours_timezone, muted_events, reject_while_in_call`
+
+// Get returns the user's preferences; users that never saved any get the defaults
+func (r *PreferenceRepo) Get(userID int64) (*models.UserPreferences, error) {
+	query := `SELECT ` + preferenceColumns + ` FROM user_preferences WHERE user_id = ?`
+
+	return r.get(query, userID)
+}
+
+func (r *PreferenceRepo) GetByUsername(username string) (*models.UserPreferences, error) {
+	query := `SELECT ` + preferenceColumns + ` FROM user_preferences
+		WHERE user_id = (SELECT id FROM users WHERE username = ?)`
+
+	return r.get(query, username)
+}
+
+func (r *PreferenceRepo) get(query string, arg interface{}) (*models.UserPreferences, error) {
+	var prefs models.UserPreferences
+	var quietHours models.QuietHours
+	var mutedEvents string
+	err := r.db.conn.QueryRow(query, arg).Scan(&prefs.DoNotDisturb, &quietHours.Start, &quietHours.End,
+		&quietHours.Timezone, &mutedEvents, &prefs.RejectWhileInCall)
+	if err == sql.ErrNoRows {
+		return &models.UserPreferences{MutedEvents: []string{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+
+	if quietHours.Start != "" {
+		prefs.QuietHours = &quietHours
+	}
+	if err := json.Unmarshal([]byte(mutedEvents), &prefs.MutedEvents); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal muted events: %w", err)
+	}
+	return &prefs, nil
+}
+
+func (r *PreferenceRepo) Save(userID int64, prefs *models.UserPreferences) error {
+	mutedEvents, err := json.Marshal(prefs.MutedEvents)
+	if err != nil {
+		return fmt.Errorf("failed to marshal muted events: %w", err)
+	}
+
+	var quietHours models.QuietHours
+	if prefs.QuietHours != nil {
+		quietHours = *prefs.QuietHours
+	}
+
+	query := `
+		INSERT INTO user_preferences (user_id, ` + preferenceColumns + `, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			do_not_disturb = excluded.do_not_disturb, quiet_hours_start = excluded.quiet_hours_start,
+			quiet_hours_end = excluded.quiet_hours_end, quiet_hours_timezone = excluded.quiet_hours_timezone,
+			muted_events = excluded.muted_events, reject_while_in_call = excluded.reject_while_in_call,
+			updated_at = excluded.updated_at
+	`
+
+	_, err = r.db.conn.Exec(query, userID, prefs.DoNotDisturb, quietHours.Start, quietHours.End, quietHours.Timezone,
+		string(mutedEvents), prefs.RejectWhileInCall, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save preferences: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+// PresenceRepo keeps the last presence each user's contacts were told about,
+// along with what the user chose themselves: their status text and being away
+type PresenceRepo struct {
+	db *DB
+}
+
+func NewPresenceRepo(db *DB) *PresenceRepo {
+	return &PresenceRepo{db: db}
+}
+
+const presenceColumns = `u.id, u.username, COALESCE(p.status, 'offline'), COALESCE(p.away, 0), COALESCE(p.status_text, ''), p.last_seen_at`
+
+// Get returns the user's presence; users that never connected are offline
+func (r *PresenceRepo) Get(userID int64) (*models.Presence, error) {
+	query := `SELECT ` + presenceColumns + `
+		FROM users u
+		LEFT JOIN user_presence p ON p.user_id = u.id
+		WHERE u.id = ?`
+
+	presence, err := scanPresence(r.db.conn.QueryRow(query, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get presence: %w", err)
+	}
+	return presence, nil
+}
+
+// GetForContacts returns the presence of each of the user's contacts
+func (r *PresenceRepo) GetForContacts(userID int64) ([]*models.Presence, error) {
+	query := `SELECT ` + presenceColumns + `
+		FROM contacts c
+		JOIN users u ON u.id = c.contact_user_id
+		LEFT JOIN user_presence p ON p.user_id = u.id
+		WHERE c.user_id = ?`
+
+	return r.query(query, userID)
+}
+
+// GetNotOffline returns everyone whose contacts last saw them as connected
+func (r *PresenceRepo) GetNotOffline() ([]*models.Presence, error) {
+	query := `SELECT ` + presenceColumns + `
+		FROM user_presence p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.status != 'offline'`
+
+	return r.query(query)
+}
+
+func (r *PresenceRepo) query(query string, args ...interface{}) ([]*models.Presence, error) {
+	rows, err := r.db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get presence: %w", err)
+	}
+	defer rows.Close()
+
+	var presences []*models.Presence
+	for rows.Next() {
+		presence, err := scanPresence(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan presence: %w", err)
+		}
+		presences = append(presences, presence)
+	}
+
+	return presences, rows.Err()
+}
+
+func scanPresence(row rowScanner) (*models.Presence, error) {
+	var presence models.Presence
+	var lastSeenAt sql.NullTime
+	if err := row.Scan(&presence.UserID, &presence.Username, &presence.Status, &presence.Away,
+		&presence.StatusText, &lastSeenAt); err != nil {
+		return nil, err
+	}
+	if lastSeenAt.Valid {
+		presence.LastSeenAt = &lastSeenAt.Time
+	}
+	return &presence, nil
+}
+
+// Save stores the user's presence as published to their contacts
+func (r *PresenceRepo) Save(presence *models.Presence) error {
+	query := `
+		INSERT INTO user_presence (user_id, status, away, status_text, last_seen_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			status = excluded.status, away = excluded.away, status_text = excluded.status_text,
+			last_seen_at = excluded.last_seen_at, updated_at = excluded.updated_at
+	`
+
+	var lastSeenAt interface{}
+	if presence.LastSeenAt != nil {
+		lastSeenAt = presence.LastSeenAt.UTC()
+	}
+	_, err := r.db.conn.Exec(query, presence.UserID, presence.Status, presence.Away, presence.StatusText,
+		lastSeenAt, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save presence: %w", err)
+	}
+	return nil
+}
+
+// Touch records a heartbeat of a connected user
+func (r *PresenceRepo) Touch(userID int64, seenAt time.Time) error {
+	query := `UPDATE user_presence SET last_seen_at = ? WHERE user_id = ?`
+
+	if _, err := r.db.conn.Exec(query, seenAt.UTC(), userID); err != nil {
+		return fmt.Errorf("failed to update last seen: %w", err)
+	}
+	return nil
+}
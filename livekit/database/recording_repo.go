@@ -0,0 +1,118 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+type RecordingRepo struct {
+	db *DB
+}
+
+func NewRecordingRepo(db *DB) *RecordingRepo {
+	return &RecordingRepo{db: db}
+}
+
+func (r *RecordingRepo) Create(callID, roomName, egressID string, startedBy int64) (*models.Recording, error) {
+	startedAt := time.Now()
+	result, err := r.db.conn.Exec(
+		`INSERT INTO recordings (call_id, room_name, egress_id, started_by, status, started_at)
+		 VALUES (?, ?, ?, ?, 'recording', ?)`,
+		callID, roomName, egressID, startedBy, startedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return &models.Recording{
+		ID:        id,
+		CallID:    callID,
+		RoomName:  roomName,
+		EgressID:  egressID,
+		StartedBy: startedBy,
+		Status:    "recording",
+		StartedAt: startedAt,
+	}, nil
+}
+
+// GetActiveByCallID returns the recording currently running for a call, if any
+func (r *RecordingRepo) GetActiveByCallID(callID string) (*models.Recording, error) {
+	row := r.db.conn.QueryRow(
+		`SELECT id, call_id, room_name, egress_id, started_by, status, file_path, location, duration_seconds, size_bytes, error, started_at, ended_at
+		 FROM recordings WHERE call_id = ? AND status = 'recording'
+		 ORDER BY started_at DESC LIMIT 1`,
+		callID,
+	)
+
+	recording, err := scanRecording(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recording: %w", err)
+	}
+
+	return recording, nil
+}
+
+func (r *RecordingRepo) GetByCallID(callID string) ([]*models.Recording, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT id, call_id, room_name, egress_id, started_by, status, file_path, location, duration_seconds, size_bytes, error, started_at, ended_at
+		 FROM recordings WHERE call_id = ? ORDER BY started_at ASC`,
+		callID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recordings: %w", err)
+	}
+	defer rows.Close()
+
+	var recordings []*models.Recording
+	for rows.Next() {
+		recording, err := scanRecording(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recording: %w", err)
+		}
+		recordings = append(recordings, recording)
+	}
+
+	return recordings, rows.Err()
+}
+
+// Finish stores the final state of a recording reported by the egress service
+func (r *RecordingRepo) Finish(recording *models.Recording) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE recordings SET status = ?, file_path = ?, location = ?, duration_seconds = ?, size_bytes = ?, error = ?, ended_at = ?
+		 WHERE id = ?`,
+		recording.Status, recording.FilePath, recording.Location, recording.DurationSeconds, recording.SizeBytes,
+		recording.Error, recording.EndedAt, recording.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update recording: %w", err)
+	}
+	return nil
+}
+
+func scanRecording(row rowScanner) (*models.Recording, error) {
+	var recording models.Recording
+	var filePath, location, errorMessage sql.NullString
+	var endedAt sql.NullTime
+	if err := row.Scan(&recording.ID, &recording.CallID, &recording.RoomName, &recording.EgressID, &recording.StartedBy,
+		&recording.Status, &filePath, &location, &recording.DurationSeconds, &recording.SizeBytes, &errorMessage,
+		&recording.StartedAt, &endedAt); err != nil {
+		return nil, err
+	}
+	recording.FilePath = filePath.String
+	recording.Location = location.String
+	recording.Error = errorMessage.String
+	if endedAt.Valid {
+		recording.EndedAt = &endedAt.Time
+	}
+	return &recording, nil
+}
@@ -15,11 +15,11 @@ func NewScheduledCallRepo(db *DB) *ScheduledCallRepo {
 	return &ScheduledCallRepo{db: db}
 }
 
-func (r *ScheduledCallRepo) Create(callID, roomName, callType string, createdBy int64, scheduledAt time.Time, timezone, recurrence, title, description, joinLink string, maxParticipants, maxDurationSeconds int) (*models.ScheduledCall, error) {
+func (r *ScheduledCallRepo) Create(callID, roomName, callType string, createdBy int64, scheduledAt time.Time, timezone, recurrence, title, description, joinLink string, maxParticipants, maxDurationSeconds int, lobbyEnabled bool) (*models.ScheduledCall, error) {
 	result, err := r.db.conn.Exec(
-		`INSERT INTO scheduled_calls (call_id, room_name, call_type, created_by, scheduled_at, timezone, recurrence_pattern, title, description, join_link, status, max_participants, max_duration_seconds, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'scheduled', ?, ?, ?, ?)`,
-		callID, roomName, callType, createdBy, scheduledAt, timezone, recurrence, title, description, joinLink, maxParticipants, maxDurationSeconds, time.Now(), time.Now(),
+		`INSERT INTO scheduled_calls (call_id, room_name, call_type, created_by, scheduled_at, timezone, recurrence_pattern, title, description, join_link, status, max_participants, max_duration_seconds, lobby_enabled, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'scheduled', ?, ?, ?, ?, ?)`,
+		callID, roomName, callType, createdBy, scheduledAt, timezone, recurrence, title, description, joinLink, maxParticipants, maxDurationSeconds, lobbyEnabled, time.Now(), time.Now(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create scheduled call: %w", err)
@@ -45,6 +45,8 @@ func (r *ScheduledCallRepo) Create(callID, roomName, callType string, createdBy
 		Status:            "scheduled",
 		MaxParticipants:   maxParticipants,
 		MaxDurationSeconds: maxDurationSeconds,
+		GuestAccessEnabled: true,
+		LobbyEnabled:      lobbyEnabled,
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
 	}, nil
@@ -54,11 +56,11 @@ func (r *ScheduledCallRepo) GetByID(id int64) (*models.ScheduledCall, error) {
 	var call models.ScheduledCall
 	var reminderSentAt sql.NullTime
 	err := r.db.conn.QueryRow(
-		`SELECT id, call_id, room_name, call_type, created_by, scheduled_at, timezone, recurrence_pattern, title, description, join_link, status, reminder_sent_at, max_participants, max_duration_seconds, created_at, updated_at
+		`SELECT id, call_id, room_name, call_type, created_by, scheduled_at, timezone, recurrence_pattern, title, description, join_link, status, reminder_sent_at, max_participants, max_duration_seconds, guest_access_enabled, lobby_enabled, passcode_hash, created_at, updated_at
 		 FROM scheduled_calls WHERE id = ?`,
 		id,
 	).Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.ScheduledAt, &call.Timezone,
-		&call.Recurrence, &call.Title, &call.Description, &call.JoinLink, &call.Status, &reminderSentAt, &call.MaxParticipants, &call.MaxDurationSeconds, &call.CreatedAt, &call.UpdatedAt)
+		&call.Recurrence, &call.Title, &call.Description, &call.JoinLink, &call.Status, &reminderSentAt, &call.MaxParticipants, &call.MaxDurationSeconds, &call.GuestAccessEnabled, &call.LobbyEnabled, &call.PasscodeHash, &call.CreatedAt, &call.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -71,6 +73,8 @@ func (r *ScheduledCallRepo) GetByID(id int64) (*models.ScheduledCall, error) {
 		call.ReminderSentAt = &reminderSentAt.Time
 	}
 
+	call.HasPasscode = call.PasscodeHash != ""
+
 	return &call, nil
 }
 
@@ -80,13 +84,13 @@ func (r *ScheduledCallRepo) GetByUserID(userID int64, status string) ([]*models.
 
 	if status != "" {
 		rows, err = r.db.conn.Query(
-			`SELECT id, call_id, room_name, call_type, created_by, scheduled_at, timezone, recurrence_pattern, title, description, join_link, status, reminder_sent_at, max_participants, max_duration_seconds, created_at, updated_at
+			`SELECT id, call_id, room_name, call_type, created_by, scheduled_at, timezone, recurrence_pattern, title, description, join_link, status, reminder_sent_at, max_participants, max_duration_seconds, guest_access_enabled, lobby_enabled, passcode_hash, created_at, updated_at
 			 FROM scheduled_calls WHERE created_by = ? AND status = ? ORDER BY scheduled_at ASC`,
 			userID, status,
 		)
 	} else {
 		rows, err = r.db.conn.Query(
-			`SELECT id, call_id, room_name, call_type, created_by, scheduled_at, timezone, recurrence_pattern, title, description, join_link, status, reminder_sent_at, max_participants, max_duration_seconds, created_at, updated_at
+			`SELECT id, call_id, room_name, call_type, created_by, scheduled_at, timezone, recurrence_pattern, title, description, join_link, status, reminder_sent_at, max_participants, max_duration_seconds, guest_access_enabled, lobby_enabled, passcode_hash, created_at, updated_at
 			 FROM scheduled_calls WHERE created_by = ? ORDER BY scheduled_at ASC`,
 			userID,
 		)
@@ -102,12 +106,13 @@ func (r *ScheduledCallRepo) GetByUserID(userID int64, status string) ([]*models.
 		var call models.ScheduledCall
 		var reminderSentAt sql.NullTime
 		if err := rows.Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.ScheduledAt, &call.Timezone,
-			&call.Recurrence, &call.Title, &call.Description, &call.JoinLink, &call.Status, &reminderSentAt, &call.MaxParticipants, &call.MaxDurationSeconds, &call.CreatedAt, &call.UpdatedAt); err != nil {
+			&call.Recurrence, &call.Title, &call.Description, &call.JoinLink, &call.Status, &reminderSentAt, &call.MaxParticipants, &call.MaxDurationSeconds, &call.GuestAccessEnabled, &call.LobbyEnabled, &call.PasscodeHash, &call.CreatedAt, &call.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan scheduled call: %w", err)
 		}
 		if reminderSentAt.Valid {
 			call.ReminderSentAt = &reminderSentAt.Time
 		}
+		call.HasPasscode = call.PasscodeHash != ""
 		calls = append(calls, &call)
 	}
 
@@ -116,7 +121,7 @@ func (r *ScheduledCallRepo) GetByUserID(userID int64, status string) ([]*models.
 
 func (r *ScheduledCallRepo) GetUpcoming(limit int) ([]*models.ScheduledCall, error) {
 	rows, err := r.db.conn.Query(
-		`SELECT id, call_id, room_name, call_type, created_by, scheduled_at, timezone, recurrence_pattern, title, description, join_link, status, reminder_sent_at, max_participants, max_duration_seconds, created_at, updated_at
+		`SELECT id, call_id, room_name, call_type, created_by, scheduled_at, timezone, recurrence_pattern, title, description, join_link, status, reminder_sent_at, max_participants, max_duration_seconds, guest_access_enabled, lobby_enabled, passcode_hash, created_at, updated_at
 		 FROM scheduled_calls WHERE status = 'scheduled' AND scheduled_at >= ? ORDER BY scheduled_at ASC LIMIT ?`,
 		time.Now(), limit,
 	)
@@ -130,12 +135,13 @@ func (r *ScheduledCallRepo) GetUpcoming(limit int) ([]*models.ScheduledCall, err
 		var call models.ScheduledCall
 		var reminderSentAt sql.NullTime
 		if err := rows.Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.ScheduledAt, &call.Timezone,
-			&call.Recurrence, &call.Title, &call.Description, &call.JoinLink, &call.Status, &reminderSentAt, &call.MaxParticipants, &call.MaxDurationSeconds, &call.CreatedAt, &call.UpdatedAt); err != nil {
+			&call.Recurrence, &call.Title, &call.Description, &call.JoinLink, &call.Status, &reminderSentAt, &call.MaxParticipants, &call.MaxDurationSeconds, &call.GuestAccessEnabled, &call.LobbyEnabled, &call.PasscodeHash, &call.CreatedAt, &call.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan scheduled call: %w", err)
 		}
 		if reminderSentAt.Valid {
 			call.ReminderSentAt = &reminderSentAt.Time
 		}
+		call.HasPasscode = call.PasscodeHash != ""
 		calls = append(calls, &call)
 	}
 
@@ -153,6 +159,18 @@ func (r *ScheduledCallRepo) UpdateStatus(id int64, status string) error {
 	return nil
 }
 
+// UpdatePasscode stores the bcrypt hash of the meeting passcode; an empty hash removes it
+func (r *ScheduledCallRepo) UpdatePasscode(id int64, passcodeHash string) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE scheduled_calls SET passcode_hash = ?, updated_at = ? WHERE id = ?`,
+		passcodeHash, time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update scheduled call passcode: %w", err)
+	}
+	return nil
+}
+
 func (r *ScheduledCallRepo) UpdateReminderSent(id int64) error {
 	_, err := r.db.conn.Exec(
 		`UPDATE scheduled_calls SET reminder_sent_at = ? WHERE id = ?`,
@@ -180,7 +198,7 @@ func (r *ScheduledCallRepo) GetByInviteeID(inviteeID int64, status string) ([]*m
 	query := `
 		SELECT sc.id, sc.call_id, sc.room_name, sc.call_type, sc.created_by, sc.scheduled_at, 
 		       sc.timezone, sc.recurrence_pattern, sc.title, sc.description, sc.join_link, 
-		       sc.status, sc.reminder_sent_at, sc.max_participants, sc.max_duration_seconds, 
+		       sc.status, sc.reminder_sent_at, sc.max_participants, sc.max_duration_seconds, sc.guest_access_enabled, sc.lobby_enabled, sc.passcode_hash,
 		       sc.created_at, sc.updated_at
 		FROM scheduled_calls sc
 		INNER JOIN scheduled_call_invitations sci ON sc.id = sci.scheduled_call_id
@@ -205,12 +223,13 @@ func (r *ScheduledCallRepo) GetByInviteeID(inviteeID int64, status string) ([]*m
 		var call models.ScheduledCall
 		var reminderSentAt sql.NullTime
 		if err := rows.Scan(&call.ID, &call.CallID, &call.RoomName, &call.CallType, &call.CreatedBy, &call.ScheduledAt, &call.Timezone,
-			&call.Recurrence, &call.Title, &call.Description, &call.JoinLink, &call.Status, &reminderSentAt, &call.MaxParticipants, &call.MaxDurationSeconds, &call.CreatedAt, &call.UpdatedAt); err != nil {
+			&call.Recurrence, &call.Title, &call.Description, &call.JoinLink, &call.Status, &reminderSentAt, &call.MaxParticipants, &call.MaxDurationSeconds, &call.GuestAccessEnabled, &call.LobbyEnabled, &call.PasscodeHash, &call.CreatedAt, &call.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan scheduled call: %w", err)
 		}
 		if reminderSentAt.Valid {
 			call.ReminderSentAt = &reminderSentAt.Time
 		}
+		call.HasPasscode = call.PasscodeHash != ""
 		calls = append(calls, &call)
 	}
 
@@ -48,6 +48,12 @@ const (
 		status TEXT NOT NULL DEFAULT 'active',
 		duration_limit_seconds INTEGER,
 		max_duration_seconds INTEGER,
+		guest_access_enabled INTEGER DEFAULT 1,
+		lobby_enabled INTEGER DEFAULT 0,
+		passcode_hash TEXT DEFAULT '',
+		screen_share_policy TEXT DEFAULT 'everyone',
+		scheduled_call_id INTEGER,
+		scheduled_occurrence INTEGER,
 		FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
 	);`
 
@@ -67,6 +73,21 @@ const (
 		FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
 	);`
 
+	createCallHistoryParticipantsTable = `
+	CREATE TABLE IF NOT EXISTS call_history_participants (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		user_id INTEGER,
+		identity TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'invitee',
+		outcome TEXT NOT NULL DEFAULT 'pending',
+		invitation_id INTEGER,
+		responded_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(call_id, identity),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
 	createScheduledCallsTable = `
 	CREATE TABLE IF NOT EXISTS scheduled_calls (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -84,6 +105,9 @@ const (
 		reminder_sent_at DATETIME,
 		max_participants INTEGER DEFAULT 0,
 		max_duration_seconds INTEGER DEFAULT 0,
+		guest_access_enabled INTEGER DEFAULT 1,
+		lobby_enabled INTEGER DEFAULT 0,
+		passcode_hash TEXT DEFAULT '',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
@@ -102,6 +126,296 @@ const (
 		UNIQUE(scheduled_call_id, invitee_id)
 	);`
 
+	createLobbyRequestsTable = `
+	CREATE TABLE IF NOT EXISTS lobby_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		room_name TEXT NOT NULL,
+		user_id INTEGER,
+		identity TEXT NOT NULL,
+		display_name TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'participant',
+		status TEXT NOT NULL DEFAULT 'waiting',
+		wait_secret TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		responded_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createRecordingsTable = `
+	CREATE TABLE IF NOT EXISTS recordings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		room_name TEXT NOT NULL,
+		egress_id TEXT UNIQUE NOT NULL,
+		started_by INTEGER NOT NULL,
+		status TEXT NOT NULL DEFAULT 'recording',
+		file_path TEXT,
+		location TEXT,
+		duration_seconds INTEGER DEFAULT 0,
+		size_bytes INTEGER DEFAULT 0,
+		error TEXT,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME,
+		FOREIGN KEY (started_by) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createLiveStreamsTable = `
+	CREATE TABLE IF NOT EXISTS live_streams (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		room_name TEXT NOT NULL,
+		egress_id TEXT,
+		started_by INTEGER NOT NULL,
+		protocol TEXT NOT NULL,
+		url TEXT NOT NULL,
+		stream_key_encrypted TEXT,
+		status TEXT NOT NULL DEFAULT 'starting',
+		error TEXT,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME,
+		FOREIGN KEY (started_by) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createSIPTrunksTable = `
+	CREATE TABLE IF NOT EXISTS sip_trunks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		direction TEXT UNIQUE NOT NULL,
+		trunk_id TEXT NOT NULL,
+		numbers TEXT,
+		address TEXT,
+		auth_username TEXT,
+		auth_password_encrypted TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	createSIPDialInsTable = `
+	CREATE TABLE IF NOT EXISTS sip_dial_ins (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		room_name TEXT NOT NULL,
+		dispatch_rule_id TEXT NOT NULL,
+		pin TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'active',
+		created_by INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		closed_at DATETIME,
+		FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createBreakoutRoomsTable = `
+	CREATE TABLE IF NOT EXISTS breakout_rooms (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		room_name TEXT UNIQUE NOT NULL,
+		name TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'open',
+		ends_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		closed_at DATETIME
+	);`
+
+	createBreakoutAssignmentsTable = `
+	CREATE TABLE IF NOT EXISTS breakout_assignments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		breakout_room_id INTEGER NOT NULL,
+		identity TEXT NOT NULL,
+		attended INTEGER DEFAULT 0,
+		assigned_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(breakout_room_id, identity),
+		FOREIGN KEY (breakout_room_id) REFERENCES breakout_rooms(id) ON DELETE CASCADE
+	);`
+
+	createCallMessagesTable = `
+	CREATE TABLE IF NOT EXISTS call_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		sender_id INTEGER NOT NULL,
+		sender TEXT NOT NULL,
+		body TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createConversationsTable = `
+	CREATE TABLE IF NOT EXISTS conversations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL DEFAULT 'direct',
+		name TEXT DEFAULT '',
+		created_by INTEGER NOT NULL,
+		direct_key TEXT UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createConversationMembersTable = `
+	CREATE TABLE IF NOT EXISTS conversation_members (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		conversation_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		last_delivered_message_id INTEGER DEFAULT 0,
+		last_read_message_id INTEGER DEFAULT 0,
+		joined_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(conversation_id, user_id),
+		FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createDirectMessagesTable = `
+	CREATE TABLE IF NOT EXISTS direct_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		conversation_id INTEGER NOT NULL,
+		sender_id INTEGER NOT NULL,
+		body TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE,
+		FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createCallPollsTable = `
+	CREATE TABLE IF NOT EXISTS call_polls (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		created_by INTEGER NOT NULL,
+		question TEXT NOT NULL,
+		multiple_choice INTEGER DEFAULT 0,
+		anonymous INTEGER DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'open',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		closed_at DATETIME,
+		FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createCallPollOptionsTable = `
+	CREATE TABLE IF NOT EXISTS call_poll_options (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		poll_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		label TEXT NOT NULL,
+		FOREIGN KEY (poll_id) REFERENCES call_polls(id) ON DELETE CASCADE
+	);`
+
+	createCallPollVotesTable = `
+	CREATE TABLE IF NOT EXISTS call_poll_votes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		poll_id INTEGER NOT NULL,
+		option_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		username TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(poll_id, option_id, user_id),
+		FOREIGN KEY (poll_id) REFERENCES call_polls(id) ON DELETE CASCADE,
+		FOREIGN KEY (option_id) REFERENCES call_poll_options(id) ON DELETE CASCADE,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createTranscriptSegmentsTable = `
+	CREATE TABLE IF NOT EXISTS transcript_segments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		identity TEXT NOT NULL,
+		text TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	createRaisedHandsTable = `
+	CREATE TABLE IF NOT EXISTS raised_hands (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		username TEXT NOT NULL,
+		raised_at DATETIME NOT NULL,
+		UNIQUE(call_id, username)
+	);`
+
+	createCallReactionsTable = `
+	CREATE TABLE IF NOT EXISTS call_reactions (
+		call_id TEXT NOT NULL,
+		emoji TEXT NOT NULL,
+		count INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (call_id, emoji)
+	);`
+
+	createScreenShareRequestsTable = `
+	CREATE TABLE IF NOT EXISTS screen_share_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		identity TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		responded_at DATETIME
+	);`
+
+	createUserEventsTable = `
+	CREATE TABLE IF NOT EXISTS user_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		type TEXT NOT NULL,
+		data TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(username, seq)
+	);`
+
+	createUserEventSequencesTable = `
+	CREATE TABLE IF NOT EXISTS user_event_sequences (
+		username TEXT PRIMARY KEY,
+		last_seq INTEGER NOT NULL DEFAULT 0
+	);`
+
+	createWebSocketConnectionsTable = `
+	CREATE TABLE IF NOT EXISTS websocket_connections (
+		instance_id TEXT NOT NULL,
+		connection_id TEXT NOT NULL,
+		username TEXT NOT NULL,
+		acks INTEGER NOT NULL DEFAULT 0,
+		expires_at DATETIME NOT NULL,
+		PRIMARY KEY (instance_id, connection_id)
+	);`
+
+	createRevokedSessionsTable = `
+	CREATE TABLE IF NOT EXISTS revoked_sessions (
+		session_id TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		revoked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createUserPresenceTable = `
+	CREATE TABLE IF NOT EXISTS user_presence (
+		user_id INTEGER PRIMARY KEY,
+		status TEXT NOT NULL DEFAULT 'offline',
+		away INTEGER NOT NULL DEFAULT 0,
+		status_text TEXT NOT NULL DEFAULT '',
+		last_seen_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createUserPreferencesTable = `
+	CREATE TABLE IF NOT EXISTS user_preferences (
+		user_id INTEGER PRIMARY KEY,
+		do_not_disturb INTEGER NOT NULL DEFAULT 0,
+		quiet_hours_start TEXT NOT NULL DEFAULT '',
+		quiet_hours_end TEXT NOT NULL DEFAULT '',
+		quiet_hours_timezone TEXT NOT NULL DEFAULT '',
+		muted_events TEXT NOT NULL DEFAULT '[]',
+		reject_while_in_call INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);`
+
+	createPasscodeAttemptsTable = `
+	CREATE TABLE IF NOT EXISTS passcode_attempts (
+		attempt_key TEXT PRIMARY KEY,
+		failures INTEGER NOT NULL DEFAULT 0,
+		window_start DATETIME NOT NULL
+	);`
+
 	createIndexes = `
 	CREATE INDEX IF NOT EXISTS idx_contacts_user_id ON contacts(user_id);
 	CREATE INDEX IF NOT EXISTS idx_contacts_contact_user_id ON contacts(contact_user_id);
@@ -113,12 +427,33 @@ const (
 	CREATE INDEX IF NOT EXISTS idx_call_history_call_id ON call_history(call_id);
 	CREATE INDEX IF NOT EXISTS idx_call_history_created_by ON call_history(created_by);
 	CREATE INDEX IF NOT EXISTS idx_call_history_started_at ON call_history(started_at);
+	CREATE INDEX IF NOT EXISTS idx_call_history_participants_call_id ON call_history_participants(call_id);
+	CREATE INDEX IF NOT EXISTS idx_call_history_participants_user_id ON call_history_participants(user_id);
 	CREATE INDEX IF NOT EXISTS idx_scheduled_calls_call_id ON scheduled_calls(call_id);
 	CREATE INDEX IF NOT EXISTS idx_scheduled_calls_created_by ON scheduled_calls(created_by);
 	CREATE INDEX IF NOT EXISTS idx_scheduled_calls_scheduled_at ON scheduled_calls(scheduled_at);
 	CREATE INDEX IF NOT EXISTS idx_scheduled_calls_status ON scheduled_calls(status);
 	CREATE INDEX IF NOT EXISTS idx_scheduled_call_invitations_scheduled_call_id ON scheduled_call_invitations(scheduled_call_id);
 	CREATE INDEX IF NOT EXISTS idx_scheduled_call_invitations_invitee_id ON scheduled_call_invitations(invitee_id);
+	CREATE INDEX IF NOT EXISTS idx_lobby_requests_call_id ON lobby_requests(call_id);
+	CREATE INDEX IF NOT EXISTS idx_recordings_call_id ON recordings(call_id);
+	CREATE INDEX IF NOT EXISTS idx_live_streams_call_id ON live_streams(call_id);
+	CREATE INDEX IF NOT EXISTS idx_live_streams_status ON live_streams(status);
+	CREATE INDEX IF NOT EXISTS idx_sip_dial_ins_call_id ON sip_dial_ins(call_id);
+	CREATE INDEX IF NOT EXISTS idx_sip_dial_ins_pin ON sip_dial_ins(pin);
+	CREATE INDEX IF NOT EXISTS idx_breakout_rooms_call_id ON breakout_rooms(call_id);
+	CREATE INDEX IF NOT EXISTS idx_breakout_assignments_room_id ON breakout_assignments(breakout_room_id);
+	CREATE INDEX IF NOT EXISTS idx_call_messages_call_id ON call_messages(call_id, id);
+	CREATE INDEX IF NOT EXISTS idx_conversation_members_user_id ON conversation_members(user_id);
+	CREATE INDEX IF NOT EXISTS idx_direct_messages_conversation_id ON direct_messages(conversation_id, id);
+	CREATE INDEX IF NOT EXISTS idx_call_polls_call_id ON call_polls(call_id);
+	CREATE INDEX IF NOT EXISTS idx_call_poll_votes_poll_id ON call_poll_votes(poll_id);
+	CREATE INDEX IF NOT EXISTS idx_transcript_segments_call_id ON transcript_segments(call_id, started_at);
+	CREATE INDEX IF NOT EXISTS idx_screen_share_requests_call_id ON screen_share_requests(call_id, identity);
+	CREATE INDEX IF NOT EXISTS idx_user_events_created_at ON user_events(created_at);
+	CREATE INDEX IF NOT EXISTS idx_websocket_connections_username ON websocket_connections(username, expires_at);
+	CREATE INDEX IF NOT EXISTS idx_user_presence_status ON user_presence(status);
+	CREATE INDEX IF NOT EXISTS idx_passcode_attempts_window_start ON passcode_attempts(window_start);
 	`
 )
 
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"livekit/models"
+	"time"
+)
+
+type ScreenShareRepo struct {
+	db *DB
+}
+
+func NewScreenShareRepo(db *DB) *ScreenShareRepo {
+	return &ScreenShareRepo{db: db}
+}
+
+func (r *ScreenShareRepo) Create(callID, identity string) (*models.ScreenShareRequest, error) {
+	now := time.Now()
+	result, err := r.db.conn.Exec(
+		`INSERT INTO screen_share_requests (call_id, identity, status, created_at) VALUES (?, ?, 'pending', ?)`,
+		callID, identity, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create screen share request: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return &models.ScreenShareRequest{
+		ID:        id,
+		CallID:    callID,
+		Identity:  identity,
+		Status:    "pending",
+		CreatedAt: now,
+	}, nil
+}
+
+func (r *ScreenShareRepo) GetByID(id int64) (*models.ScreenShareRequest, error) {
+	row := r.db.conn.QueryRow(
+		`SELECT id, call_id, identity, status, created_at, responded_at
+		 FROM screen_share_requests WHERE id = ?`,
+		id,
+	)
+
+	request, err := scanScreenShareRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get screen share request: %w", err)
+	}
+
+	return request, nil
+}
+
+// GetOpenByIdentity returns the pending or approved request of a participant,
+// so asking twice doesn't queue a second request
+func (r *ScreenShareRepo) GetOpenByIdentity(callID, identity string) (*models.ScreenShareRequest, error) {
+	row := r.db.conn.QueryRow(
+		`SELECT id, call_id, identity, status, created_at, responded_at
+		 FROM screen_share_requests WHERE call_id = ? AND identity = ? AND status IN ('pending', 'approved')
+		 ORDER BY created_at DESC LIMIT 1`,
+		callID, identity,
+	)
+
+	request, err := scanScreenShareRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get screen share request: %w", err)
+	}
+
+	return request, nil
+}
+
+// GetOpenByCallID lists pending and approved requests, oldest first
+func (r *ScreenShareRepo) GetOpenByCallID(callID string) ([]*models.ScreenShareRequest, error) {
+	rows, err := r.db.conn.Query(
+		`SELECT id, call_id, identity, status, created_at, responded_at
+		 FROM screen_share_requests WHERE call_id = ? AND status IN ('pending', 'approved')
+		 ORDER BY created_at ASC`,
+		callID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get screen share requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*models.ScreenShareRequest
+	for rows.Next() {
+		request, err := scanScreenShareRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan screen share request: %w", err)
+		}
+		requests = append(requests, request)
+	}
+
+	return requests, rows.Err()
+}
+
+func (r *ScreenShareRepo) UpdateStatus(id int64, status string) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE screen_share_requests SET status = ?, responded_at = ? WHERE id = ?`,
+		status, time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update screen share request status: %w", err)
+	}
+	return nil
+}
+
+// CloseOpen resolves every pending or approved request, e.g. when the policy
+// changes or the call ends
+func (r *ScreenShareRepo) CloseOpen(callID string) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE screen_share_requests SET status = 'closed', responded_at = ?
+		 WHERE call_id = ? AND status IN ('pending', 'approved')`,
+		time.Now(), callID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to close screen share requests: %w", err)
+	}
+	return nil
+}
+
+func scanScreenShareRequest(row rowScanner) (*models.ScreenShareRequest, error) {
+	var request models.ScreenShareRequest
+	var respondedAt sql.NullTime
+	if err := row.Scan(&request.ID, &request.CallID, &request.Identity, &request.Status, &request.CreatedAt, &respondedAt); err != nil {
+		return nil, err
+	}
+	if respondedAt.Valid {
+		request.RespondedAt = &respondedAt.Time
+	}
+	return &request, nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// SessionRepo remembers signed out sessions until the last token they issued
+// has expired
+type SessionRepo struct {
+	db *DB
+}
+
+func NewSessionRepo(db *DB) *SessionRepo {
+	return &SessionRepo{db: db}
+}
+
+// Revoke signs a session out; entries past their expiry are cleared on the way
+func (r *SessionRepo) Revoke(sessionID string, userID int64, expiresAt time.Time) error {
+	query := `
+		INSERT INTO revoked_sessions (session_id, user_id, expires_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(session_id) DO UPDATE SET expires_at = MAX(expires_at, excluded.expires_at)
+	`
+
+	if _, err := r.db.conn.Exec(query, sessionID, userID, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	if _, err := r.db.conn.Exec(`DELETE FROM revoked_sessions WHERE expires_at <= ?`, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepo) IsRevoked(sessionID string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM revoked_sessions WHERE session_id = ?)`
+
+	var revoked bool
+	if err := r.db.conn.QueryRow(query, sessionID).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return revoked, nil
+}
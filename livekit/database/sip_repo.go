@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"livekit/models"
+	"strings"
+	"time"
+)
+
+type SIPRepo struct {
+	db *DB
+}
+
+func NewSIPRepo(db *DB) *SIPRepo {
+	return &SIPRepo{db: db}
+}
+
+func (r *SIPRepo) GetTrunk(direction string) (*models.SIPTrunk, error) {
+	var trunk models.SIPTrunk
+	var numbers, address, authUsername, authPassword sql.NullString
+	err := r.db.conn.QueryRow(
+		`SELECT id, direction, trunk_id, numbers, address, auth_username, auth_password_encrypted, created_at, updated_at
+		 FROM sip_trunks WHERE direction = ?`,
+		direction,
+	).Scan(&trunk.ID, &trunk.Direction, &trunk.TrunkID, &numbers, &address, &authUsername, &authPassword, &trunk.CreatedAt, &trunk.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sip trunk: %w", err)
+	}
+
+	if numbers.String != "" {
+		trunk.Numbers = strings.Split(numbers.String, ",")
+	}
+	trunk.Address = address.String
+	trunk.AuthUsername = authUsername.String
+	trunk.AuthPasswordEncrypted = authPassword.String
+
+	return &trunk, nil
+}
+
+// SaveTrunk stores the trunk of a direction, replacing the previous one
+func (r *SIPRepo) SaveTrunk(trunk *models.SIPTrunk) error {
+	_, err := r.db.conn.Exec(
+		`INSERT INTO sip_trunks (direction, trunk_id, numbers, address, auth_username, auth_password_encrypted, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(direction) DO UPDATE SET trunk_id = excluded.trunk_id, numbers = excluded.numbers, address = excluded.address,
+		 auth_username = excluded.auth_username, auth_password_encrypted = excluded.auth_password_encrypted, updated_at = excluded.updated_at`,
+		trunk.Direction, trunk.TrunkID, strings.Join(trunk.Numbers, ","), trunk.Address, trunk.AuthUsername,
+		trunk.AuthPasswordEncrypted, time.Now(), time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save sip trunk: %w", err)
+	}
+	return nil
+}
+
+func (r *SIPRepo) DeleteTrunk(direction string) error {
+	_, err := r.db.conn.Exec(`DELETE FROM sip_trunks WHERE direction = ?`, direction)
+	if err != nil {
+		return fmt.Errorf("failed to delete sip trunk: %w", err)
+	}
+	return nil
+}
+
+func (r *SIPRepo) CreateDialIn(callID, roomName, dispatchRuleID, pin string, createdBy int64) (*models.SIPDialIn, error) {
+	createdAt := time.Now()
+	result, err := r.db.conn.Exec(
+		`INSERT INTO sip_dial_ins (call_id, room_name, dispatch_rule_id, pin, status, created_by, created_at)
+		 VALUES (?, ?, ?, ?, 'active', ?, ?)`,
+		callID, roomName, dispatchRuleID, pin, createdBy, createdAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sip dial-in: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	return &models.SIPDialIn{
+		ID:             id,
+		CallID:         callID,
+		RoomName:       roomName,
+		DispatchRuleID: dispatchRuleID,
+		PIN:            pin,
+		Status:         "active",
+		CreatedBy:      createdBy,
+		CreatedAt:      createdAt,
+	}, nil
+}
+
+func (r *SIPRepo) GetActiveDialIn(callID string) (*models.SIPDialIn, error) {
+	var dialIn models.SIPDialIn
+	var closedAt sql.NullTime
+	err := r.db.conn.QueryRow(
+		`SELECT id, call_id, room_name, dispatch_rule_id, pin, status, created_by, created_at, closed_at
+		 FROM sip_dial_ins WHERE call_id = ? AND status = 'active'`,
+		callID,
+	).Scan(&dialIn.ID, &dialIn.CallID, &dialIn.RoomName, &dialIn.DispatchRuleID, &dialIn.PIN, &dialIn.Status,
+		&dialIn.CreatedBy, &dialIn.CreatedAt, &closedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sip dial-in: %w", err)
+	}
+
+	if closedAt.Valid {
+		dialIn.ClosedAt = &closedAt.Time
+	}
+
+	return &dialIn, nil
+}
+
+// PinInUse reports whether an open dial-in already uses the PIN; PINs have to
+// be unique because every dial-in shares the same phone numbers
+func (r *SIPRepo) PinInUse(pin string) (bool, error) {
+	var count int
+	err := r.db.conn.QueryRow(
+		`SELECT COUNT(*) FROM sip_dial_ins WHERE pin = ? AND status = 'active'`,
+		pin,
+	).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check sip pin: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *SIPRepo) CloseDialIn(id int64) error {
+	_, err := r.db.conn.Exec(
+		`UPDATE sip_dial_ins SET status = 'closed', closed_at = ? WHERE id = ?`,
+		time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to close sip dial-in: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"livekit/models"
+	"strings"
+)
+
+type TranscriptRepo struct {
+	db *DB
+}
+
+func NewTranscriptRepo(db *DB) *TranscriptRepo {
+	return &TranscriptRepo{db: db}
+}
+
+func (r *TranscriptRepo) Create(segment *models.TranscriptSegment) error {
+	result, err := r.db.conn.Exec(
+		`INSERT INTO transcript_segments (call_id, identity, text, started_at, ended_at) VALUES (?, ?, ?, ?, ?)`,
+		segment.CallID, segment.Identity, segment.Text, segment.StartedAt, segment.EndedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create transcript segment: %w", err)
+	}
+
+	segment.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	return nil
+}
+
+const transcriptColumns = `SELECT s.id, s.call_id, s.identity, s.text, s.started_at, s.ended_at FROM transcript_segments s`
+
+// GetByCallID returns a page of a call's transcript in spoken order
+func (r *TranscriptRepo) GetByCallID(callID string, limit, offset int) ([]*models.TranscriptSegment, error) {
+	return r.query(
+		transcriptColumns+` WHERE s.call_id = ? ORDER BY s.started_at ASC, s.id ASC LIMIT ? OFFSET ?`,
+		callID, limit, offset,
+	)
+}
+
+// Search finds segments containing query in the calls the user created or took
+// part in, newest first. callID narrows the search to a single call.
+func (r *TranscriptRepo) Search(userID int64, username, query, callID string, limit int) ([]*models.TranscriptSegment, error) {
+	sqlQuery := transcriptColumns + `
+		 JOIN call_history h ON h.call_id = s.call_id
+		 WHERE (h.created_by = ? OR h.participants LIKE ? ESCAPE '\') AND s.text LIKE ? ESCAPE '\'`
+	// participants is a JSON array, so match the username as a whole JSON string
+	quoted, _ := json.Marshal(username)
+	args := []interface{}{userID, "%" + escapeLike(string(quoted)) + "%", "%" + escapeLike(query) + "%"}
+	if callID != "" {
+		sqlQuery += ` AND s.call_id = ?`
+		args = append(args, callID)
+	}
+	sqlQuery += ` ORDER BY s.started_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	return r.query(sqlQuery, args...)
+}
+
+func (r *TranscriptRepo) CountByCallID(callID string) (int, error) {
+	var count int
+	err := r.db.conn.QueryRow(`SELECT COUNT(*) FROM transcript_segments WHERE call_id = ?`, callID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count transcript segments: %w", err)
+	}
+	return count, nil
+}
+
+func (r *TranscriptRepo) query(query string, args ...interface{}) ([]*models.TranscriptSegment, error) {
+	rows, err := r.db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transcript segments: %w", err)
+	}
+	defer rows.Close()
+
+	segments := []*models.TranscriptSegment{}
+	for rows.Next() {
+		var segment models.TranscriptSegment
+		if err := rows.Scan(&segment.ID, &segment.CallID, &segment.Identity, &segment.Text, &segment.StartedAt, &segment.EndedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transcript segment: %w", err)
+		}
+		segments = append(segments, &segment)
+	}
+
+	return segments, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in user input
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
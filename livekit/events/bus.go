@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrUnknownEvent = errors.New("event type is not registered")
+
+// Subscriber observes every published event, e.g. the WebSocket hub, webhooks,
+// push notifications or an audit log. Envelopes are shared between
+// subscribers and must not be modified.
+type Subscriber interface {
+	Deliver(ctx context.Context, recipients []string, env *Envelope)
+}
+
+// SubscriberFunc adapts a function to the Subscriber interface
+type SubscriberFunc func(ctx context.Context, recipients []string, env *Envelope)
+
+func (f SubscriberFunc) Deliver(ctx context.Context, recipients []string, env *Envelope) {
+	f(ctx, recipients, env)
+}
+
+type Bus struct {
+	subscribers []Subscriber
+	mu          sync.RWMutex
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(subscriber Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, subscriber)
+}
+
+// Publish wraps an event in an envelope and hands it to every subscriber
+func (b *Bus) Publish(ctx context.Context, recipients []string, event Event) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	env, err := NewEnvelope(event)
+	if err != nil {
+		return err
+	}
+
+	b.mu.RLock()
+	subscribers := append([]Subscriber{}, b.subscribers...)
+	b.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		subscriber.Deliver(ctx, recipients, env)
+	}
+	return nil
+}
+
+// NewEnvelope encodes an event under a fresh ID and the registered version of its type
+func NewEnvelope(event Event) (*Envelope, error) {
+	definition := Lookup(event.EventType())
+	if definition == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEvent, event.EventType())
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s event: %w", definition.Type, err)
+	}
+
+	return &Envelope{
+		ID:        uuid.New().String(),
+		Type:      definition.Type,
+		Version:   definition.Version,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	}, nil
+}
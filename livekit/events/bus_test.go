@@ -0,0 +1,87 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// recordingSubscriber keeps every envelope delivered to it
+type recordingSubscriber struct {
+	mu         sync.Mutex
+	recipients [][]string
+	envelopes  []*Envelope
+}
+
+func (s *recordingSubscriber) Deliver(ctx context.Context, recipients []string, env *Envelope) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recipients = append(s.recipients, recipients)
+	s.envelopes = append(s.envelopes, env)
+}
+
+func TestBusFansOutToEverySubscriber(t *testing.T) {
+	bus := NewBus()
+	subscribers := []*recordingSubscriber{{}, {}, {}}
+	for _, subscriber := range subscribers {
+		bus.Subscribe(subscriber)
+	}
+	var funcCalls int
+	bus.Subscribe(SubscriberFunc(func(ctx context.Context, recipients []string, env *Envelope) {
+		funcCalls++
+	}))
+
+	event := CallEnded{CallID: "call-1"}
+	if err := bus.Publish(context.Background(), []string{"alice", "bob"}, event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	first := subscribers[0].envelopes
+	if len(first) != 1 {
+		t.Fatalf("first subscriber got %d envelopes, want 1", len(first))
+	}
+	env := first[0]
+	if env.ID == "" || env.Type != "call_ended" || env.Version != 1 || env.Timestamp.IsZero() {
+		t.Errorf("unexpected envelope %+v", env)
+	}
+	var data CallEnded
+	if err := json.Unmarshal(env.Data, &data); err != nil || data != event {
+		t.Errorf("envelope data %s, %v; want %+v", env.Data, err, event)
+	}
+
+	for i, subscriber := range subscribers {
+		if len(subscriber.envelopes) != 1 || subscriber.envelopes[0].ID != env.ID {
+			t.Errorf("subscriber %d got %v, want the envelope %s", i, subscriber.envelopes, env.ID)
+			continue
+		}
+		if !slices.Equal(subscriber.recipients[0], []string{"alice", "bob"}) {
+			t.Errorf("subscriber %d got recipients %v, want [alice bob]", i, subscriber.recipients[0])
+		}
+	}
+	if funcCalls != 1 {
+		t.Errorf("SubscriberFunc called %d times, want 1", funcCalls)
+	}
+}
+
+func TestBusPublishRefuses(t *testing.T) {
+	bus := NewBus()
+	subscriber := &recordingSubscriber{}
+	bus.Subscribe(subscriber)
+
+	if err := bus.Publish(context.Background(), nil, CallEnded{CallID: "call-1"}); err != nil {
+		t.Errorf("Publish without recipients: %v", err)
+	}
+	if err := bus.Publish(context.Background(), []string{"alice"}, unregisteredEvent{}); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Publish of an unregistered event error = %v, want %v", err, ErrUnknownEvent)
+	}
+	if len(subscriber.envelopes) != 0 {
+		t.Errorf("subscriber got %d envelopes, want none", len(subscriber.envelopes))
+	}
+}
+
+type unregisteredEvent struct{}
+
+func (unregisteredEvent) EventType() string { return "not_registered" }
@@ -0,0 +1,24 @@
+// Package events defines the typed events the server pushes to users and the
+// bus that fans them out to subscribers such as the WebSocket hub.
+package events
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is a payload that can be published on the bus. EventType names the
+// registered definition the payload belongs to.
+type Event interface {
+	EventType() string
+}
+
+// Envelope is the wire format shared by every event
+type Envelope struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Version   int             `json:"version"`
+	Timestamp time.Time       `json:"timestamp"`
+	Seq       int64           `json:"seq,omitempty"` // Per-recipient sequence, set by sinks that keep a replay log
+	Data      json.RawMessage `json:"data"`
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Definition describes a registered event type and the JSON schema of its data
+type Definition struct {
+	Type    string                 `json:"type"`
+	Version int                    `json:"version"`
+	Schema  map[string]interface{} `json:"schema"`
+
+	payload reflect.Type // Go type the schema was derived from
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Definition)
+)
+
+// Register adds an event type to the registry. example is a zero value of the
+// payload, used to derive the schema; payloads that map to more than one type,
+// e.g. accepted and rejected invitations, are registered once per type.
+func Register(eventType string, version int, example Event) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[eventType]; exists {
+		panic(fmt.Sprintf("events: %s registered twice", eventType))
+	}
+	registry[eventType] = &Definition{
+		Type:    eventType,
+		Version: version,
+		Schema:  schemaFor(reflect.TypeOf(example)),
+		payload: reflect.TypeOf(example),
+	}
+}
+
+// Lookup returns the definition of an event type, or nil if it is not registered
+func Lookup(eventType string) *Definition {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return registry[eventType]
+}
+
+// Definitions lists every registered event type, sorted by type
+func Definitions() []*Definition {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	definitions := make([]*Definition, 0, len(registry))
+	for _, definition := range registry {
+		definitions = append(definitions, definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Type < definitions[j].Type
+	})
+	return definitions
+}
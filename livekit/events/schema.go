@@ -0,0 +1,95 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor derives a JSON schema from a Go type following encoding/json's
+// rules: json tags name the properties, omitempty fields are optional and
+// embedded structs are flattened
+func schemaFor(t reflect.Type) map[string]interface{} {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var schema map[string]interface{}
+	switch {
+	case t == timeType:
+		schema = map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType || t.Kind() == reflect.Interface:
+		schema = map[string]interface{}{}
+	case t.Kind() == reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		collectFields(t, properties, &required)
+		schema = map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		schema = map[string]interface{}{"type": "array", "items": schemaFor(t.Elem())}
+	case t.Kind() == reflect.Map:
+		schema = map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem())}
+	case t.Kind() == reflect.String:
+		schema = map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		schema = map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		schema = map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		schema = map[string]interface{}{"type": "number"}
+	default:
+		schema = map[string]interface{}{}
+	}
+
+	if nullable && schema["type"] != nil {
+		schema["type"] = []interface{}{schema["type"], "null"}
+	}
+	return schema
+}
+
+func collectFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				collectFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = schemaFor(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisteredEventsMatchTheirSchema(t *testing.T) {
+	definitions := Definitions()
+	if len(definitions) == 0 {
+		t.Fatal("no event types registered")
+	}
+
+	for _, definition := range definitions {
+		t.Run(definition.Type, func(t *testing.T) {
+			value := reflect.New(definition.payload).Elem()
+			fill(value, 0)
+
+			encoded, err := json.Marshal(value.Interface())
+			if err != nil {
+				t.Fatalf("marshal %s: %v", definition.payload, err)
+			}
+			decoder := json.NewDecoder(bytes.NewReader(encoded))
+			decoder.UseNumber()
+			var document interface{}
+			if err := decoder.Decode(&document); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := validate(definition.Schema, document, "$"); err != nil {
+				t.Errorf("%s does not match its schema: %v\n%s", definition.Type, err, encoded)
+			}
+		})
+	}
+}
+
+// fill sets every field reachable from v to a non-zero value, so that the
+// encoded payload exercises every property of the schema
+func fill(v reflect.Value, depth int) {
+	if depth > 8 {
+		return
+	}
+	switch {
+	case v.Type() == timeType:
+		v.Set(reflect.ValueOf(time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)))
+	case v.Type() == rawMessageType:
+		v.Set(reflect.ValueOf(json.RawMessage(`{"any":"thing"}`)))
+	case v.Kind() == reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), depth+1)
+	case v.Kind() == reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fill(v.Field(i), depth+1)
+			}
+		}
+	case v.Kind() == reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0), depth+1)
+	case v.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), depth+1)
+		}
+	case v.Kind() == reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		key := reflect.New(v.Type().Key()).Elem()
+		fill(key, depth+1)
+		elem := reflect.New(v.Type().Elem()).Elem()
+		fill(elem, depth+1)
+		v.SetMapIndex(key, elem)
+	case v.Kind() == reflect.String:
+		v.SetString("value")
+	case v.Kind() == reflect.Bool:
+		v.SetBool(true)
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		v.SetInt(7)
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64:
+		v.SetUint(7)
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		v.SetFloat(1.5)
+	}
+}
+
+// validate checks a decoded JSON document against the subset of JSON schema
+// that schemaFor produces
+func validate(schema map[string]interface{}, document interface{}, path string) error {
+	if types, ok := schemaTypes(schema); ok {
+		matched := false
+		for _, schemaType := range types {
+			if hasType(document, schemaType) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: %T is not %s", path, document, strings.Join(types, " or "))
+		}
+	}
+	if document == nil {
+		return nil
+	}
+
+	if format, _ := schema["format"].(string); format == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, document.(string)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	switch document := document.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]string)
+		for _, name := range required {
+			if _, ok := document[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		for name, value := range document {
+			propertySchema, known := properties[name].(map[string]interface{})
+			if !known {
+				if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+					propertySchema = additional
+				} else {
+					return fmt.Errorf("%s: property %q is not in the schema", path, name)
+				}
+			}
+			if err := validate(propertySchema, value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items, _ := schema["items"].(map[string]interface{})
+		for i, item := range document {
+			if err := validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func schemaTypes(schema map[string]interface{}) ([]string, bool) {
+	switch schemaType := schema["type"].(type) {
+	case string:
+		return []string{schemaType}, true
+	case []interface{}:
+		types := make([]string, 0, len(schemaType))
+		for _, t := range schemaType {
+			types = append(types, t.(string))
+		}
+		return types, true
+	}
+	return nil, false
+}
+
+func hasType(document interface{}, schemaType string) bool {
+	switch schemaType {
+	case "null":
+		return document == nil
+	case "object":
+		_, ok := document.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := document.([]interface{})
+		return ok
+	case "string":
+		_, ok := document.(string)
+		return ok
+	case "boolean":
+		_, ok := document.(bool)
+		return ok
+	case "number":
+		_, ok := document.(json.Number)
+		return ok
+	case "integer":
+		number, ok := document.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := number.Int64()
+		return err == nil
+	}
+	return false
+}
@@ -0,0 +1,289 @@
+package events
+
+import (
+	"livekit/models"
+	"time"
+)
+
+func init() {
+	Register("call_invitation", 1, CallInvitation{})
+	Register("invitation_accepted", 1, InvitationResponse{})
+	Register("invitation_rejected", 1, InvitationResponse{})
+	Register("call_ended", 1, CallEnded{})
+	Register("call_cancelled", 1, CallCancelled{})
+	Register("call_type_changed", 1, CallTypeChanged{})
+	Register("delivery_failed", 1, DeliveryFailed{})
+
+	Register("scheduled_call_created", 1, ScheduledCallCreated{})
+	Register("scheduled_call_reminder", 1, ScheduledCallReminder{})
+	Register("scheduled_call_starting", 1, ScheduledCallStarting{})
+
+	Register("lobby_join_request", 1, LobbyJoinRequest{})
+	Register("lobby_admitted", 1, LobbyDecision{})
+	Register("lobby_denied", 1, LobbyDecision{})
+
+	Register("recording_started", 1, RecordingStarted{})
+	Register("recording_stopped", 1, RecordingStopped{})
+	Register("live_stream_failed", 1, LiveStreamFailed{})
+	Register("sip_dial_out_status", 1, SIPDialOutStatus{})
+
+	Register("breakout_assigned", 1, BreakoutAssigned{})
+	Register("breakout_return", 1, BreakoutReturn{})
+
+	Register("screen_share_policy_changed", 1, ScreenSharePolicyChanged{})
+	Register("screen_share_requested", 1, ScreenShareRequested{})
+	Register("screen_share_decision", 1, ScreenShareDecision{})
+
+	Register("chat_message", 1, ChatMessage{})
+	Register("direct_message", 1, DirectMessage{})
+	Register("message_receipt", 1, MessageReceipt{})
+
+	Register("hand_raised", 1, HandRaised{})
+	Register("hand_lowered", 1, HandLowered{})
+	Register("reaction", 1, Reaction{})
+
+	Register("poll_created", 1, PollCreated{})
+	Register("poll_updated", 1, PollUpdated{})
+	Register("poll_closed", 1, PollClosed{})
+
+	Register("transcript_segment", 1, TranscriptSegment{})
+
+	Register("presence_changed", 1, PresenceChanged{})
+}
+
+type CallInvitation struct {
+	InvitationID int64     `json:"invitationId"`
+	CallID       string    `json:"callId"`
+	Inviter      string    `json:"inviter"`
+	CallType     string    `json:"callType"`
+	RoomName     string    `json:"roomName"`
+	Timestamp    time.Time `json:"timestamp"` // When the invitation was created
+}
+
+func (CallInvitation) EventType() string { return "call_invitation" }
+
+// InvitationResponse tells the inviter how an invitee answered
+type InvitationResponse struct {
+	InvitationID int64  `json:"invitationId"`
+	Invitee      string `json:"invitee"`
+	Status       string `json:"status"` // "accepted", "rejected"
+}
+
+func (e InvitationResponse) EventType() string {
+	if e.Status == "accepted" {
+		return "invitation_accepted"
+	}
+	return "invitation_rejected"
+}
+
+type CallEnded struct {
+	CallID string `json:"callId"`
+}
+
+func (CallEnded) EventType() string { return "call_ended" }
+
+type CallCancelled struct {
+	CallID string `json:"callId"`
+}
+
+func (CallCancelled) EventType() string { return "call_cancelled" }
+
+type CallTypeChanged struct {
+	CallID    string `json:"callId"`
+	CallType  string `json:"callType"`
+	ChangedBy string `json:"changedBy"`
+}
+
+func (CallTypeChanged) EventType() string { return "call_type_changed" }
+
+// DeliveryFailed tells a sender that a recipient never acknowledged an event
+type DeliveryFailed struct {
+	Event        string `json:"eventType"`
+	Recipient    string `json:"recipient"`
+	CallID       string `json:"callId"`
+	InvitationID int64  `json:"invitationId,omitempty"`
+}
+
+func (DeliveryFailed) EventType() string { return "delivery_failed" }
+
+type ScheduledCallCreated struct {
+	*models.ScheduledCall
+}
+
+func (ScheduledCallCreated) EventType() string { return "scheduled_call_created" }
+
+type ScheduledCallReminder struct {
+	ScheduledCall *models.ScheduledCall `json:"scheduledCall"`
+	ReminderTime  string                `json:"reminderTime"`
+}
+
+func (ScheduledCallReminder) EventType() string { return "scheduled_call_reminder" }
+
+type ScheduledCallStarting struct {
+	*models.ScheduledCall
+}
+
+func (ScheduledCallStarting) EventType() string { return "scheduled_call_starting" }
+
+type LobbyJoinRequest struct {
+	*models.LobbyRequest
+}
+
+func (LobbyJoinRequest) EventType() string { return "lobby_join_request" }
+
+// LobbyDecision answers a lobby request; admitted joiners get their token with it
+type LobbyDecision struct {
+	RequestID int64  `json:"requestId"`
+	CallID    string `json:"callId"`
+	Status    string `json:"status"` // "admitted", "denied"
+	Token     string `json:"token,omitempty"`
+	RoomName  string `json:"roomName,omitempty"`
+}
+
+func (e LobbyDecision) EventType() string {
+	if e.Status == "admitted" {
+		return "lobby_admitted"
+	}
+	return "lobby_denied"
+}
+
+type RecordingStarted struct {
+	*models.Recording
+}
+
+func (RecordingStarted) EventType() string { return "recording_started" }
+
+type RecordingStopped struct {
+	*models.Recording
+}
+
+func (RecordingStopped) EventType() string { return "recording_stopped" }
+
+type LiveStreamFailed struct {
+	*models.LiveStream
+}
+
+func (LiveStreamFailed) EventType() string { return "live_stream_failed" }
+
+type SIPDialOutStatus struct {
+	CallID   string `json:"callId"`
+	Identity string `json:"identity"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func (SIPDialOutStatus) EventType() string { return "sip_dial_out_status" }
+
+type BreakoutAssigned struct {
+	CallID         string     `json:"callId"`
+	BreakoutRoomID int64      `json:"breakoutRoomId"`
+	Name           string     `json:"name"`
+	RoomName       string     `json:"roomName"`
+	Token          string     `json:"token"`
+	EndsAt         *time.Time `json:"endsAt,omitempty"`
+}
+
+func (BreakoutAssigned) EventType() string { return "breakout_assigned" }
+
+type BreakoutReturn struct {
+	CallID   string `json:"callId"`
+	RoomName string `json:"roomName"`
+	Token    string `json:"token"`
+}
+
+func (BreakoutReturn) EventType() string { return "breakout_return" }
+
+type ScreenSharePolicyChanged struct {
+	CallID string `json:"callId"`
+	Policy string `json:"policy"`
+}
+
+func (ScreenSharePolicyChanged) EventType() string { return "screen_share_policy_changed" }
+
+type ScreenShareRequested struct {
+	*models.ScreenShareRequest
+}
+
+func (ScreenShareRequested) EventType() string { return "screen_share_requested" }
+
+type ScreenShareDecision struct {
+	*models.ScreenShareRequest
+}
+
+func (ScreenShareDecision) EventType() string { return "screen_share_decision" }
+
+type ChatMessage struct {
+	*models.CallMessage
+}
+
+func (ChatMessage) EventType() string { return "chat_message" }
+
+type DirectMessage struct {
+	*models.DirectMessage
+}
+
+func (DirectMessage) EventType() string { return "direct_message" }
+
+type MessageReceipt struct {
+	ConversationID int64  `json:"conversationId"`
+	Username       string `json:"username"`
+	Type           string `json:"type"` // "delivered", "read"
+	MessageID      int64  `json:"messageId"`
+}
+
+func (MessageReceipt) EventType() string { return "message_receipt" }
+
+type HandRaised struct {
+	CallID   string    `json:"callId"`
+	Username string    `json:"username"`
+	Position int       `json:"position"`
+	RaisedAt time.Time `json:"raisedAt"`
+}
+
+func (HandRaised) EventType() string { return "hand_raised" }
+
+type HandLowered struct {
+	CallID    string `json:"callId"`
+	Username  string `json:"username"`
+	LoweredBy string `json:"loweredBy"`
+}
+
+func (HandLowered) EventType() string { return "hand_lowered" }
+
+type Reaction struct {
+	CallID   string `json:"callId"`
+	Username string `json:"username"`
+	Emoji    string `json:"emoji"`
+}
+
+func (Reaction) EventType() string { return "reaction" }
+
+type PollCreated struct {
+	*models.Poll
+}
+
+func (PollCreated) EventType() string { return "poll_created" }
+
+type PollUpdated struct {
+	*models.Poll
+}
+
+func (PollUpdated) EventType() string { return "poll_updated" }
+
+type PollClosed struct {
+	*models.Poll
+}
+
+func (PollClosed) EventType() string { return "poll_closed" }
+
+type TranscriptSegment struct {
+	*models.TranscriptSegment
+}
+
+func (TranscriptSegment) EventType() string { return "transcript_segment" }
+
+type PresenceChanged struct {
+	*models.Presence
+}
+
+func (PresenceChanged) EventType() string { return "presence_changed" }
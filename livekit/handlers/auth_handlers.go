@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"livekit/auth"
 	"livekit/database"
+	"livekit/websocket"
 	"net/http"
+	"time"
 )
 
 type RegisterRequest struct {
@@ -160,4 +162,61 @@ func HandleMe(db *database.DB) http.HandlerFunc {
 	}
 }
 
+// HandleRefreshToken issues a new token for the caller's session; sockets
+// re-authenticate with it to stay connected past the old token's expiry
+func HandleRefreshToken(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		token, err := auth.RefreshToken(userInfo.Claims)
+		if err != nil {
+			auth.RespondError(w, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, map[string]string{"token": token})
+	}
+}
+
+// HandleLogout revokes the caller's session, rejecting its tokens and closing
+// the sockets opened with them
+func HandleLogout(db *database.DB, wsHub *websocket.WebSocketHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		sessionID := userInfo.Claims.SessionID
+		if sessionID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "Token has no session to revoke")
+			return
+		}
+
+		// Refreshed tokens of the session may outlive this one by up to a full TTL
+		sessionRepo := database.NewSessionRepo(db)
+		if err := sessionRepo.Revoke(sessionID, userInfo.UserID, time.Now().Add(auth.TokenTTL)); err != nil {
+			auth.RespondError(w, http.StatusInternalServerError, "Failed to revoke session")
+			return
+		}
+
+		wsHub.CloseSession(sessionID)
 
+		auth.RespondJSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"livekit/auth"
+	"livekit/database"
+	"livekit/services"
+	"net/http"
+)
+
+func HandleGetBreakouts(db *database.DB, breakoutService *services.BreakoutService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		rooms, err := breakoutService.GetBreakouts(callID, userInfo.UserID, userInfo.Username)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, rooms)
+	}
+}
+
+func HandleCreateBreakouts(db *database.DB, breakoutService *services.BreakoutService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		var req services.BreakoutRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		rooms, err := breakoutService.CreateBreakouts(callID, userInfo.UserID, req)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusCreated, rooms)
+	}
+}
+
+func HandleCloseBreakouts(db *database.DB, breakoutService *services.BreakoutService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		if err := breakoutService.CloseBreakouts(callID, userInfo.UserID); err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, map[string]string{"message": "Breakout rooms closed"})
+	}
+}
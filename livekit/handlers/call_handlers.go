@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"livekit/auth"
 	"livekit/database"
 	"livekit/services"
@@ -27,7 +28,7 @@ func HandleEndCall(db *database.DB, callService *services.CallService) http.Hand
 		}
 
 		if err := callService.EndCall(callID, userInfo.UserID); err != nil {
-			auth.RespondError(w, http.StatusInternalServerError, err.Error())
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
 			return
 		}
 
@@ -55,7 +56,7 @@ func HandleCancelCall(db *database.DB, callService *services.CallService) http.H
 		}
 
 		if err := callService.CancelCall(callID, userInfo.UserID); err != nil {
-			auth.RespondError(w, http.StatusInternalServerError, err.Error())
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
 			return
 		}
 
@@ -63,3 +64,76 @@ func HandleCancelCall(db *database.DB, callService *services.CallService) http.H
 	}
 }
 
+func HandleUpdateCallSettings(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		var req services.CallSettingsUpdate
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		call, err := callService.UpdateCallSettings(callID, userInfo.UserID, req)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, call)
+	}
+}
+
+type ChangeCallTypeRequest struct {
+	CallType string `json:"callType"`
+}
+
+func HandleChangeCallType(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		var req ChangeCallTypeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		call, err := callService.ChangeCallType(callID, userInfo.UserID, req.CallType)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, call)
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"livekit/auth"
+	"livekit/database"
+	"livekit/services"
+	"net/http"
+	"strconv"
+)
+
+type SendChatMessageRequest struct {
+	Body string `json:"body"`
+}
+
+func HandleSendChatMessage(db *database.DB, chatService *services.ChatService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		var req SendChatMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		message, err := chatService.SendMessage(callID, userInfo.UserID, userInfo.Username, req.Body)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusCreated, message)
+	}
+}
+
+func HandleGetChatMessages(db *database.DB, chatService *services.ChatService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		limit := 50
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 200 {
+				limit = l
+			}
+		}
+
+		offset := 0
+		if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+			if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+				offset = o
+			}
+		}
+
+		messages, err := chatService.GetMessages(callID, userInfo.UserID, userInfo.Username, limit, offset)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, messages)
+	}
+}
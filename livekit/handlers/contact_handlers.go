@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"livekit/auth"
 	"livekit/database"
+	"livekit/services"
 	"net/http"
 	"strconv"
 )
@@ -72,7 +73,7 @@ func HandleAddContact(db *database.DB) http.HandlerFunc {
 	}
 }
 
-func HandleGetContacts(db *database.DB) http.HandlerFunc {
+func HandleGetContacts(db *database.DB, presenceService *services.PresenceService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -92,6 +93,15 @@ func HandleGetContacts(db *database.DB) http.HandlerFunc {
 			return
 		}
 
+		presences, err := presenceService.GetContactPresences(userInfo.UserID)
+		if err != nil {
+			auth.RespondError(w, http.StatusInternalServerError, "Failed to get presence")
+			return
+		}
+		for _, contact := range contacts {
+			contact.Presence = presences[contact.ContactID]
+		}
+
 		auth.RespondJSON(w, http.StatusOK, contacts)
 	}
 }
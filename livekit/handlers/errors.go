@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"livekit/services"
+	"net/http"
+)
+
+// serviceErrorStatus maps well-known service errors to HTTP status codes
+func serviceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidGuestName), errors.Is(err, services.ErrInvalidPasscodeFormat),
+		errors.Is(err, services.ErrInvalidStreamDestination), errors.Is(err, services.ErrTooManyStreamDestinations),
+		errors.Is(err, services.ErrInvalidPhoneNumber), errors.Is(err, services.ErrInvalidBreakoutRequest),
+		errors.Is(err, services.ErrInvalidChatMessage), errors.Is(err, services.ErrInvalidConversation),
+		errors.Is(err, services.ErrInvalidReaction), errors.Is(err, services.ErrInvalidPoll),
+		errors.Is(err, services.ErrInvalidVote), errors.Is(err, services.ErrInvalidSearchQuery),
+		errors.Is(err, services.ErrInvalidCallType), errors.Is(err, services.ErrInvalidScreenSharePolicy),
+		errors.Is(err, services.ErrInvalidPresenceStatus), errors.Is(err, services.ErrStatusTextTooLong),
+		errors.Is(err, services.ErrInvalidQuietHours), errors.Is(err, services.ErrInvalidMutedEvent):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrInvalidGuestLink), errors.Is(err, services.ErrPasscodeRequired):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrNotCallHost), errors.Is(err, services.ErrGuestAccessDisabled),
+		errors.Is(err, services.ErrInvalidPasscode), errors.Is(err, services.ErrNotContact),
+		errors.Is(err, services.ErrScreenShareHostOnly):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrCallNotFound), errors.Is(err, services.ErrScheduledCallNotFound),
+		errors.Is(err, services.ErrLobbyRequestNotFound), errors.Is(err, services.ErrLiveStreamNotFound),
+		errors.Is(err, services.ErrDialInNotEnabled), errors.Is(err, services.ErrConversationNotFound),
+		errors.Is(err, services.ErrPollNotFound), errors.Is(err, services.ErrUnknownAudioStream),
+		errors.Is(err, services.ErrScreenShareRequestNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrCallNotStarted), errors.Is(err, services.ErrLobbyRequestResolved),
+		errors.Is(err, services.ErrRecordingInProgress), errors.Is(err, services.ErrNoActiveRecording),
+		errors.Is(err, services.ErrBreakoutsActive), errors.Is(err, services.ErrNoActiveBreakouts),
+		errors.Is(err, services.ErrCallNotActive), errors.Is(err, services.ErrPollClosed),
+		errors.Is(err, services.ErrTranscriptionActive), errors.Is(err, services.ErrNoActiveTranscription),
+		errors.Is(err, services.ErrScreenShareApprovalNotNeeded), errors.Is(err, services.ErrScreenShareRequestResolved):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrTooManyPasscodeAttempts):
+		return http.StatusTooManyRequests
+	case errors.Is(err, services.ErrSIPNotConfigured), errors.Is(err, services.ErrTranscriptionNotConfigured):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
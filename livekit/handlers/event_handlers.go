@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"livekit/auth"
+	"livekit/events"
+	"net/http"
+)
+
+// HandleGetEventSchemas lists every event type pushed to clients with its
+// version and the JSON schema of its data
+func HandleGetEventSchemas() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, events.Definitions())
+	}
+}
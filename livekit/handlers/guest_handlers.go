@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"livekit/auth"
+	"livekit/database"
+	"livekit/services"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type CreateGuestLinkRequest struct {
+	ExpiresInSeconds int `json:"expiresInSeconds,omitempty"`
+}
+
+type GuestJoinRequest struct {
+	Token       string `json:"token"`
+	DisplayName string `json:"displayName"`
+	Passcode    string `json:"passcode,omitempty"`
+}
+
+func HandleCreateGuestLink(db *database.DB, guestService *services.GuestService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		var req CreateGuestLinkRequest
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+		}
+
+		result, err := guestService.CreateCallGuestLink(callID, userInfo.UserID, time.Duration(req.ExpiresInSeconds)*time.Second)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusCreated, result)
+	}
+}
+
+func HandleCreateScheduledGuestLink(db *database.DB, guestService *services.GuestService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		idStr := r.URL.Query().Get("id")
+		if idStr == "" {
+			auth.RespondError(w, http.StatusBadRequest, "id is required")
+			return
+		}
+
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+
+		var req CreateGuestLinkRequest
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+		}
+
+		result, err := guestService.CreateScheduledCallGuestLink(id, userInfo.UserID, time.Duration(req.ExpiresInSeconds)*time.Second)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusCreated, result)
+	}
+}
+
+func HandleGuestJoin(db *database.DB, guestService *services.GuestService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		var req GuestJoinRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if req.Token == "" {
+			auth.RespondError(w, http.StatusBadRequest, "token is required")
+			return
+		}
+
+		result, err := guestService.JoinAsGuest(req.Token, req.DisplayName, req.Passcode, clientAddress(r))
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, result)
+	}
+}
+
+// clientAddress identifies an unauthenticated caller by its remote address
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -66,7 +66,7 @@ func HandleGetCallHistory(db *database.DB, historyService *services.HistoryServi
 	}
 }
 
-func HandleGetCallDetails(db *database.DB, historyService *services.HistoryService) http.HandlerFunc {
+func HandleGetCallDetails(db *database.DB, historyService *services.HistoryService, recordingService *services.RecordingService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -85,7 +85,7 @@ func HandleGetCallDetails(db *database.DB, historyService *services.HistoryServi
 			return
 		}
 
-		history, err := historyService.GetCallDetails(callID)
+		history, err := historyService.GetCallDetailsForUser(callID, userInfo.UserID)
 		if err != nil {
 			auth.RespondError(w, http.StatusInternalServerError, err.Error())
 			return
@@ -96,11 +96,18 @@ func HandleGetCallDetails(db *database.DB, historyService *services.HistoryServi
 			return
 		}
 
-		if history.CreatedBy != userInfo.UserID {
+		if !historyService.IsParticipant(history, userInfo.UserID) {
 			auth.RespondError(w, http.StatusForbidden, "Access denied")
 			return
 		}
 
+		recordings, err := recordingService.GetRecordings(callID)
+		if err != nil {
+			auth.RespondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		history.Recordings = recordings
+
 		auth.RespondJSON(w, http.StatusOK, history)
 	}
 }
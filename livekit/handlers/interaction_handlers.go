@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"livekit/auth"
+	"livekit/database"
+	"livekit/services"
+	"net/http"
+)
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+func HandleGetInteractions(db *database.DB, interactionService *services.InteractionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		interactions, err := interactionService.GetInteractions(callID, userInfo.UserID, userInfo.Username)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, interactions)
+	}
+}
+
+func HandleRaiseHand(db *database.DB, interactionService *services.InteractionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		hand, err := interactionService.RaiseHand(callID, userInfo.UserID, userInfo.Username)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, hand)
+	}
+}
+
+// HandleLowerHand lowers the caller's hand, or the hand of ?username= for the host
+func HandleLowerHand(db *database.DB, interactionService *services.InteractionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		target := r.URL.Query().Get("username")
+		if err := interactionService.LowerHand(callID, userInfo.UserID, userInfo.Username, target); err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, map[string]string{"message": "Hand lowered"})
+	}
+}
+
+func HandleReact(db *database.DB, interactionService *services.InteractionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		var req ReactionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if err := interactionService.React(callID, userInfo.UserID, userInfo.Username, req.Emoji); err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, map[string]string{"message": "Reaction sent"})
+	}
+}
@@ -18,9 +18,12 @@ type CallServiceConfig struct {
 }
 
 type InviteRequest struct {
-	CallType string   `json:"callType"`
-	Invitees []string `json:"invitees"`
-	RoomName string   `json:"roomName,omitempty"`
+	CallType           string   `json:"callType"`
+	Invitees           []string `json:"invitees"`
+	RoomName           string   `json:"roomName,omitempty"`
+	LobbyEnabled       bool     `json:"lobbyEnabled,omitempty"`
+	GuestAccessEnabled *bool    `json:"guestAccessEnabled,omitempty"`
+	Passcode           string   `json:"passcode,omitempty"`
 }
 
 type RespondInvitationRequest struct {
@@ -56,9 +59,15 @@ func HandleInvite(db *database.DB, callService *services.CallService) http.Handl
 			return
 		}
 
-		result, err := callService.CreateCallAndInvite(userInfo.UserID, req.CallType, req.Invitees, req.RoomName)
+		opts := services.CallOptions{
+			GuestAccessEnabled: req.GuestAccessEnabled == nil || *req.GuestAccessEnabled,
+			LobbyEnabled:       req.LobbyEnabled,
+			Passcode:           req.Passcode,
+		}
+
+		result, err := callService.CreateCallAndInvite(userInfo.UserID, req.CallType, req.Invitees, req.RoomName, opts)
 		if err != nil {
-			auth.RespondError(w, http.StatusInternalServerError, err.Error())
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
 			return
 		}
 
@@ -66,7 +75,7 @@ func HandleInvite(db *database.DB, callService *services.CallService) http.Handl
 	}
 }
 
-func HandleGetInvitations(db *database.DB) http.HandlerFunc {
+func HandleGetInvitations(db *database.DB, callService *services.CallService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -79,8 +88,7 @@ func HandleGetInvitations(db *database.DB) http.HandlerFunc {
 			return
 		}
 
-		invitationRepo := database.NewInvitationRepo(db)
-		invitations, err := invitationRepo.GetPendingForUser(userInfo.UserID)
+		invitations, err := callService.GetPendingInvitations(userInfo.UserID)
 		if err != nil {
 			auth.RespondError(w, http.StatusInternalServerError, "Failed to get invitations")
 			return
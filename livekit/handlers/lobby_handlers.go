@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"livekit/auth"
+	"livekit/database"
+	"livekit/services"
+	"net/http"
+	"strconv"
+)
+
+type GuestLobbyStatusRequest struct {
+	RequestID int64  `json:"requestId"`
+	WaitToken string `json:"waitToken"`
+}
+
+func HandleGetLobby(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		requests, err := callService.GetLobby(callID, userInfo.UserID)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, requests)
+	}
+}
+
+func HandleAdmitLobbyRequest(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return handleLobbyDecision(callService, true)
+}
+
+func HandleDenyLobbyRequest(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return handleLobbyDecision(callService, false)
+}
+
+func handleLobbyDecision(callService *services.CallService, admit bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		requestID, err := strconv.ParseInt(r.URL.Query().Get("requestId"), 10, 64)
+		if err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid requestId")
+			return
+		}
+
+		request, err := callService.RespondToLobbyRequest(requestID, userInfo.UserID, admit)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, request)
+	}
+}
+
+func HandleGetLobbyStatus(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		requestID, err := strconv.ParseInt(r.URL.Query().Get("requestId"), 10, 64)
+		if err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid requestId")
+			return
+		}
+
+		result, err := callService.GetLobbyStatus(requestID, userInfo.UserID)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, result)
+	}
+}
+
+func HandleGuestLobbyStatus(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		var req GuestLobbyStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if req.RequestID == 0 || req.WaitToken == "" {
+			auth.RespondError(w, http.StatusBadRequest, "requestId and waitToken are required")
+			return
+		}
+
+		result, err := callService.GetGuestLobbyStatus(req.RequestID, req.WaitToken)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, result)
+	}
+}
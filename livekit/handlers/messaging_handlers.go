@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"livekit/auth"
+	"livekit/database"
+	"livekit/services"
+	"net/http"
+	"strconv"
+)
+
+type CreateConversationRequest struct {
+	Usernames []string `json:"usernames"`
+	Name      string   `json:"name,omitempty"`
+}
+
+type SendDirectMessageRequest struct {
+	Body string `json:"body"`
+}
+
+type MarkReadRequest struct {
+	MessageID int64 `json:"messageId,omitempty"`
+}
+
+func HandleGetConversations(db *database.DB, messagingService *services.MessagingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		conversations, err := messagingService.ListConversations(userInfo.UserID)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, conversations)
+	}
+}
+
+func HandleCreateConversation(db *database.DB, messagingService *services.MessagingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		var req CreateConversationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		conversation, created, err := messagingService.CreateConversation(userInfo.UserID, req.Usernames, req.Name)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		auth.RespondJSON(w, status, conversation)
+	}
+}
+
+func HandleGetDirectMessages(db *database.DB, messagingService *services.MessagingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		conversationID, err := strconv.ParseInt(r.URL.Query().Get("conversationId"), 10, 64)
+		if err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid conversationId")
+			return
+		}
+
+		limit := 50
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 200 {
+				limit = l
+			}
+		}
+
+		var beforeID int64
+		if beforeStr := r.URL.Query().Get("before"); beforeStr != "" {
+			if b, err := strconv.ParseInt(beforeStr, 10, 64); err == nil && b > 0 {
+				beforeID = b
+			}
+		}
+
+		messages, err := messagingService.GetMessages(conversationID, userInfo.UserID, beforeID, limit)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, messages)
+	}
+}
+
+func HandleSendDirectMessage(db *database.DB, messagingService *services.MessagingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		conversationID, err := strconv.ParseInt(r.URL.Query().Get("conversationId"), 10, 64)
+		if err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid conversationId")
+			return
+		}
+
+		var req SendDirectMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		message, err := messagingService.SendMessage(conversationID, userInfo.UserID, req.Body)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusCreated, message)
+	}
+}
+
+func HandleMarkConversationRead(db *database.DB, messagingService *services.MessagingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		conversationID, err := strconv.ParseInt(r.URL.Query().Get("conversationId"), 10, 64)
+		if err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid conversationId")
+			return
+		}
+
+		// The body is optional, without it everything is marked read
+		var req MarkReadRequest
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+		}
+
+		if err := messagingService.MarkRead(conversationID, userInfo.UserID, req.MessageID); err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, map[string]string{"message": "Conversation marked as read"})
+	}
+}
+
+func HandleGetUnreadCount(db *database.DB, messagingService *services.MessagingService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		count, err := messagingService.UnreadCount(userInfo.UserID)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, map[string]int{"unreadCount": count})
+	}
+}
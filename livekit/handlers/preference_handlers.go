@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"livekit/auth"
+	"livekit/database"
+	"livekit/models"
+	"livekit/services"
+	"net/http"
+)
+
+func HandleGetPreferences(db *database.DB, preferenceService *services.PreferenceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		prefs, err := preferenceService.GetPreferences(userInfo.UserID)
+		if err != nil {
+			auth.RespondError(w, http.StatusInternalServerError, "Failed to get preferences")
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, prefs)
+	}
+}
+
+func HandleUpdatePreferences(db *database.DB, preferenceService *services.PreferenceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		var req models.UserPreferences
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		prefs, err := preferenceService.UpdatePreferences(userInfo.UserID, &req)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, prefs)
+	}
+}
@@ -12,6 +12,10 @@ import (
 	"time"
 )
 
+type StartScheduledCallRequest struct {
+	Passcode string `json:"passcode,omitempty"`
+}
+
 func HandleCreateScheduledCall(db *database.DB, scheduledService *services.ScheduledService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -44,7 +48,7 @@ func HandleCreateScheduledCall(db *database.DB, scheduledService *services.Sched
 
 		call, err := scheduledService.CreateScheduledCall(userInfo.UserID, req)
 		if err != nil {
-			auth.RespondError(w, http.StatusInternalServerError, err.Error())
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
 			return
 		}
 
@@ -167,7 +171,7 @@ func HandleUpdateScheduledCall(db *database.DB, scheduledService *services.Sched
 		}
 
 		if err := scheduledService.UpdateScheduledCall(id, updates); err != nil {
-			auth.RespondError(w, http.StatusInternalServerError, err.Error())
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
 			return
 		}
 
@@ -258,9 +262,18 @@ func HandleStartScheduledCall(db *database.DB, scheduledService *services.Schedu
 			}
 		}
 
-		result, err := scheduledService.StartScheduledCall(id, userInfo.UserID)
+		// The body is optional; only link joins of protected meetings carry a passcode
+		var req StartScheduledCallRequest
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				auth.RespondError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+		}
+
+		result, err := scheduledService.StartScheduledCall(id, userInfo.UserID, req.Passcode)
 		if err != nil {
-			auth.RespondError(w, http.StatusInternalServerError, err.Error())
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
 			return
 		}
 
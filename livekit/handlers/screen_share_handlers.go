@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"livekit/auth"
+	"livekit/database"
+	"livekit/services"
+	"net/http"
+	"strconv"
+)
+
+func HandleGetScreenShareRequests(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		requests, err := callService.GetScreenShareRequests(callID, userInfo.UserID)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, requests)
+	}
+}
+
+func HandleRequestScreenShare(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		request, err := callService.RequestScreenShare(callID, userInfo.UserID, userInfo.Username)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, request)
+	}
+}
+
+func HandleApproveScreenShare(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return handleScreenShareDecision(callService, true)
+}
+
+func HandleDenyScreenShare(db *database.DB, callService *services.CallService) http.HandlerFunc {
+	return handleScreenShareDecision(callService, false)
+}
+
+func handleScreenShareDecision(callService *services.CallService, approve bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		requestID, err := strconv.ParseInt(r.URL.Query().Get("requestId"), 10, 64)
+		if err != nil {
+			auth.RespondError(w, http.StatusBadRequest, "Invalid requestId")
+			return
+		}
+
+		request, err := callService.RespondToScreenShareRequest(requestID, userInfo.UserID, approve)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, request)
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"livekit/auth"
+	"livekit/database"
+	"livekit/services"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+)
+
+// Egress connects from the media server, not a browser, so there is no origin to check
+var audioUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+const maxAudioMessageSize = 1 << 20
+
+func HandleStartTranscription(db *database.DB, transcriptionService *services.TranscriptionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		status, err := transcriptionService.StartTranscription(callID, userInfo.UserID)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, status)
+	}
+}
+
+func HandleStopTranscription(db *database.DB, transcriptionService *services.TranscriptionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		status, err := transcriptionService.StopTranscription(callID, userInfo.UserID)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, status)
+	}
+}
+
+func HandleGetTranscript(db *database.DB, transcriptionService *services.TranscriptionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		callID := r.URL.Query().Get("callId")
+		if callID == "" {
+			auth.RespondError(w, http.StatusBadRequest, "callId is required")
+			return
+		}
+
+		limit := 100
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 500 {
+				limit = l
+			}
+		}
+
+		offset := 0
+		if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+			if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+				offset = o
+			}
+		}
+
+		segments, err := transcriptionService.GetTranscript(callID, userInfo.UserID, userInfo.Username, limit, offset)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, segments)
+	}
+}
+
+func HandleSearchTranscripts(db *database.DB, transcriptionService *services.TranscriptionService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		limit := 50
+		if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 200 {
+				limit = l
+			}
+		}
+
+		segments, err := transcriptionService.Search(userInfo.UserID, userInfo.Username, r.URL.Query().Get("q"), r.URL.Query().Get("callId"), limit)
+		if err != nil {
+			auth.RespondError(w, serviceErrorStatus(err), err.Error())
+			return
+		}
+
+		auth.RespondJSON(w, http.StatusOK, segments)
+	}
+}
+
+// HandleTranscriptionAudio receives the audio LiveKit egress streams for a
+// transcribed track. The unguessable stream key in the URL is the credential.
+func HandleTranscriptionAudio(audioSource *services.EgressAudioSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stream, err := audioSource.OpenStream(r.URL.Query().Get("key"))
+		if err != nil {
+			auth.RespondError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		defer stream.Close()
+
+		conn, err := audioUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("Transcription audio upgrade error: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.SetReadLimit(maxAudioMessageSize)
+
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					log.Printf("Transcription audio stream error: %v", err)
+				}
+				return
+			}
+			// Text messages carry track events such as mute, only binary ones are audio
+			if msgType != websocket.BinaryMessage {
+				continue
+			}
+			stream.Write(data)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type BreakoutRoom struct {
+	ID          int64                 `json:"id"`
+	CallID      string                `json:"callId"`
+	RoomName    string                `json:"roomName"`
+	Name        string                `json:"name"`
+	Status      string                `json:"status"` // "open", "closed"
+	EndsAt      *time.Time            `json:"endsAt,omitempty"`
+	CreatedAt   time.Time             `json:"createdAt"`
+	ClosedAt    *time.Time            `json:"closedAt,omitempty"`
+	Assignments []*BreakoutAssignment `json:"assignments"`
+}
+
+type BreakoutAssignment struct {
+	ID             int64     `json:"id"`
+	BreakoutRoomID int64     `json:"breakoutRoomId"`
+	Identity       string    `json:"identity"`
+	Attended       bool      `json:"attended"`
+	AssignedAt     time.Time `json:"assignedAt"`
+}
@@ -3,16 +3,35 @@ package models
 import "time"
 
 type CallHistory struct {
-	ID            int64      `json:"id"`
-	CallID        string     `json:"callId"`
-	RoomName      string     `json:"roomName"`
-	CallType      string     `json:"callType"`
-	CreatedBy     int64      `json:"createdBy"`
-	Participants  string     `json:"participants"` // JSON array of usernames
-	StartedAt     time.Time  `json:"startedAt"`
-	EndedAt       *time.Time `json:"endedAt,omitempty"`
-	Duration      int        `json:"durationSeconds"`
-	Status        string     `json:"status"` // "completed", "missed", "rejected"
-	InvitationIDs string     `json:"invitationIds,omitempty"` // JSON array of invitation IDs
+	ID                int64                     `json:"id"`
+	CallID            string                    `json:"callId"`
+	RoomName          string                    `json:"roomName"`
+	CallType          string                    `json:"callType"`
+	CreatedBy         int64                     `json:"createdBy"`
+	Participants      string                    `json:"participants"` // JSON array of usernames
+	StartedAt         time.Time                 `json:"startedAt"`
+	EndedAt           *time.Time                `json:"endedAt,omitempty"`
+	Duration          int                       `json:"durationSeconds"`
+	Status            string                    `json:"status"`                      // "pending", "completed", "partially_answered", "missed", "rejected", "cancelled"
+	InvitationIDs     string                    `json:"invitationIds,omitempty"`     // JSON array of invitation IDs
+	PerspectiveStatus string                    `json:"perspectiveStatus,omitempty"` // Status as seen by the requesting user
+	Outcomes          []*CallParticipantOutcome `json:"outcomes,omitempty"`
+	Recordings        []*Recording              `json:"recordings,omitempty"`
+	Breakouts         []*BreakoutRoom           `json:"breakouts,omitempty"`
+	MessageCount      int                       `json:"messageCount"`
+	Polls             []*Poll                   `json:"polls,omitempty"`
+
+	TranscriptSegments int `json:"transcriptSegments"`
 }
 
+type CallParticipantOutcome struct {
+	ID           int64      `json:"id"`
+	CallID       string     `json:"callId"`
+	UserID       int64      `json:"userId,omitempty"`
+	Identity     string     `json:"identity"`
+	Role         string     `json:"role"`    // "invitee", "guest", "sip"
+	Outcome      string     `json:"outcome"` // "pending", "accepted", "rejected", "missed", "cancelled", "dnd", "busy"
+	InvitationID int64      `json:"invitationId,omitempty"`
+	RespondedAt  *time.Time `json:"respondedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type CallMessage struct {
+	ID        int64     `json:"id"`
+	CallID    string    `json:"callId"`
+	SenderID  int64     `json:"senderId"`
+	Sender    string    `json:"sender"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
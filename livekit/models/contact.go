@@ -8,6 +8,7 @@ type Contact struct {
 	ContactID int64     `json:"contactId"`
 	Username  string    `json:"username"`
 	CreatedAt time.Time `json:"createdAt"`
+	Presence  *Presence `json:"presence,omitempty"`
 }
 
 
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+type Conversation struct {
+	ID          int64                 `json:"id"`
+	Type        string                `json:"type"` // "direct", "group"
+	Name        string                `json:"name,omitempty"`
+	CreatedBy   int64                 `json:"createdBy"`
+	Members     []*ConversationMember `json:"members"`
+	LastMessage *DirectMessage        `json:"lastMessage,omitempty"`
+	UnreadCount int                   `json:"unreadCount"`
+	CreatedAt   time.Time             `json:"createdAt"`
+	UpdatedAt   time.Time             `json:"updatedAt"`
+}
+
+// ConversationMember carries the member's receipt cursors: every message up to
+// LastDeliveredMessageID reached one of their devices, and up to
+// LastReadMessageID was read
+type ConversationMember struct {
+	UserID                 int64     `json:"userId"`
+	Username               string    `json:"username"`
+	LastDeliveredMessageID int64     `json:"lastDeliveredMessageId"`
+	LastReadMessageID      int64     `json:"lastReadMessageId"`
+	JoinedAt               time.Time `json:"joinedAt"`
+}
+
+type DirectMessage struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversationId"`
+	SenderID       int64     `json:"senderId"`
+	Sender         string    `json:"sender"`
+	Body           string    `json:"body"`
+	Status         string    `json:"status,omitempty"` // "sent", "delivered", "read"; only on the sender's own messages
+	CreatedAt      time.Time `json:"createdAt"`
+}
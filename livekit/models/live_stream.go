@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type LiveStream struct {
+	ID           int64  `json:"id"`
+	CallID       string `json:"callId"`
+	RoomName     string `json:"roomName"`
+	EgressID     string `json:"egressId,omitempty"`
+	StartedBy    int64  `json:"startedBy"`
+	Protocol     string `json:"protocol"` // "rtmp", "srt"
+	URL          string `json:"url"`      // Destination without the stream key or other credentials
+	HasStreamKey bool   `json:"hasStreamKey"`
+	// StreamKeyEncrypted is the stream key sealed with auth.EncryptSecret
+	StreamKeyEncrypted string     `json:"-"`
+	Status             string     `json:"status"` // "starting", "active", "stopped", "failed"
+	Error              string     `json:"error,omitempty"`
+	StartedAt          time.Time  `json:"startedAt"`
+	EndedAt            *time.Time `json:"endedAt,omitempty"`
+}
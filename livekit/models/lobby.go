@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type LobbyRequest struct {
+	ID          int64      `json:"id"`
+	CallID      string     `json:"callId"`
+	RoomName    string     `json:"roomName"`
+	UserID      int64      `json:"userId,omitempty"`
+	Identity    string     `json:"identity"`
+	DisplayName string     `json:"displayName"`
+	Role        string     `json:"role"`   // "participant", "guest"
+	Status      string     `json:"status"` // "waiting", "admitted", "denied", "closed"
+	WaitSecret  string     `json:"-"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	RespondedAt *time.Time `json:"respondedAt,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type Poll struct {
+	ID             int64         `json:"id"`
+	CallID         string        `json:"callId"`
+	CreatedBy      int64         `json:"createdBy"`
+	Question       string        `json:"question"`
+	MultipleChoice bool          `json:"multipleChoice"`
+	Anonymous      bool          `json:"anonymous"`
+	Status         string        `json:"status"` // "open", "closed"
+	Options        []*PollOption `json:"options"`
+	TotalVoters    int           `json:"totalVoters"`
+	MyVotes        []int64       `json:"myVotes,omitempty"` // Option IDs the requesting user voted for
+	CreatedAt      time.Time     `json:"createdAt"`
+	ClosedAt       *time.Time    `json:"closedAt,omitempty"`
+}
+
+type PollOption struct {
+	ID     int64    `json:"id"`
+	Label  string   `json:"label"`
+	Votes  int      `json:"votes"`
+	Voters []string `json:"voters,omitempty"` // Left out for anonymous polls
+}
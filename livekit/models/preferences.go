@@ -0,0 +1,15 @@
+package models
+
+// QuietHours is a daily window in which the user is not rung
+type QuietHours struct {
+	Start    string `json:"start"`    // "22:00" in Timezone
+	End      string `json:"end"`      // "07:00"; earlier than Start when the window spans midnight
+	Timezone string `json:"timezone"` // IANA name, e.g. "Europe/Berlin"
+}
+
+type UserPreferences struct {
+	DoNotDisturb      bool        `json:"doNotDisturb"`
+	QuietHours        *QuietHours `json:"quietHours,omitempty"`
+	MutedEvents       []string    `json:"mutedEvents"`       // Event types kept off the user's sockets
+	RejectWhileInCall bool        `json:"rejectWhileInCall"` // Decline new calls while in another one
+}
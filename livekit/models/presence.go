@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Presence tells a user's contacts whether they can pick up a call
+type Presence struct {
+	UserID     int64      `json:"userId"`
+	Username   string     `json:"username"`
+	Status     string     `json:"status"` // "online", "away", "in-call", "offline"
+	StatusText string     `json:"statusText,omitempty"`
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty"`
+	Away       bool       `json:"-"` // The user's client reported them idle
+}
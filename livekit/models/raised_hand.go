@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type RaisedHand struct {
+	Username string    `json:"username"`
+	Position int       `json:"position"` // 1-based place in the speaker queue
+	RaisedAt time.Time `json:"raisedAt"`
+}
+
+// CallInteractions is the live hand and reaction state of an active call
+type CallInteractions struct {
+	CallID      string         `json:"callId"`
+	RaisedHands []*RaisedHand  `json:"raisedHands"`
+	Reactions   map[string]int `json:"reactions"` // emoji -> count since the call started
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Recording struct {
+	ID              int64      `json:"id"`
+	CallID          string     `json:"callId"`
+	RoomName        string     `json:"roomName"`
+	EgressID        string     `json:"egressId"`
+	StartedBy       int64      `json:"startedBy"`
+	Status          string     `json:"status"` // "recording", "completed", "failed"
+	FilePath        string     `json:"filePath,omitempty"`
+	Location        string     `json:"location,omitempty"`
+	DurationSeconds int        `json:"durationSeconds"`
+	SizeBytes       int64      `json:"sizeBytes"`
+	Error           string     `json:"error,omitempty"`
+	StartedAt       time.Time  `json:"startedAt"`
+	EndedAt         *time.Time `json:"endedAt,omitempty"`
+}
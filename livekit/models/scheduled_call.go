@@ -18,6 +18,10 @@ type ScheduledCall struct {
 	ReminderSentAt    *time.Time `json:"reminderSentAt,omitempty"`
 	MaxParticipants   int        `json:"maxParticipants"`
 	MaxDurationSeconds int       `json:"maxDurationSeconds"`
+	GuestAccessEnabled bool      `json:"guestAccessEnabled"`
+	LobbyEnabled      bool       `json:"lobbyEnabled"`
+	HasPasscode       bool       `json:"hasPasscode"`
+	PasscodeHash      string     `json:"-"`
 	Invitees          []string   `json:"invitees,omitempty"` // Populated from scheduled_call_invitations
 	CreatedAt         time.Time  `json:"createdAt"`
 	UpdatedAt         time.Time  `json:"updatedAt"`
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ScreenShareRequest is a participant asking the host to share their screen
+// in a call whose policy is "request"
+type ScreenShareRequest struct {
+	ID          int64      `json:"id"`
+	CallID      string     `json:"callId"`
+	Identity    string     `json:"identity"`
+	Status      string     `json:"status"` // "pending", "approved", "denied", "closed"
+	CreatedAt   time.Time  `json:"createdAt"`
+	RespondedAt *time.Time `json:"respondedAt,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type SIPTrunk struct {
+	ID                    int64     `json:"id"`
+	Direction             string    `json:"direction"` // "inbound", "outbound"
+	TrunkID               string    `json:"trunkId"`   // LiveKit SIP trunk ID
+	Numbers               []string  `json:"numbers"`
+	Address               string    `json:"address,omitempty"`
+	AuthUsername          string    `json:"authUsername,omitempty"`
+	AuthPasswordEncrypted string    `json:"-"`
+	CreatedAt             time.Time `json:"createdAt"`
+	UpdatedAt             time.Time `json:"updatedAt"`
+}
+
+type SIPDialIn struct {
+	ID             int64      `json:"id"`
+	CallID         string     `json:"callId"`
+	RoomName       string     `json:"roomName"`
+	DispatchRuleID string     `json:"-"`
+	PIN            string     `json:"pin"`
+	PhoneNumbers   []string   `json:"phoneNumbers"` // Populated from the inbound trunk
+	Status         string     `json:"status"`       // "active", "closed"
+	CreatedBy      int64      `json:"createdBy"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	ClosedAt       *time.Time `json:"closedAt,omitempty"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type TranscriptSegment struct {
+	ID        int64     `json:"id"`
+	CallID    string    `json:"callId"`
+	Identity  string    `json:"identity"` // Participant who spoke
+	Text      string    `json:"text"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UserEvent is a WebSocket event kept for a user so it can be replayed after a
+// reconnect. Seq increases monotonically per user.
+type UserEvent struct {
+	ID        int64           `json:"id"`
+	Username  string          `json:"username"`
+	Seq       int64           `json:"seq"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+const maxBreakoutRooms = 50
+
+var (
+	ErrBreakoutsActive        = errors.New("call already has open breakout rooms")
+	ErrNoActiveBreakouts      = errors.New("call has no open breakout rooms")
+	ErrInvalidBreakoutRequest = errors.New("invalid breakout request")
+)
+
+// BreakoutRequest describes how a host wants to split a call
+type BreakoutRequest struct {
+	Count           int            `json:"count"`
+	Mode            string         `json:"mode"`                  // "random" or "manual"
+	Assignments     map[string]int `json:"assignments,omitempty"` // identity -> room number (1-based), manual mode only
+	Names           []string       `json:"names,omitempty"`       // Optional room names, defaults to "Room N"
+	DurationSeconds int            `json:"durationSeconds,omitempty"`
+}
+
+type BreakoutService struct {
+	db           *database.DB
+	breakoutRepo *database.BreakoutRepo
+	callRepo     *database.CallRepo
+	userRepo     *database.UserRepo
+	callService  *CallService
+	wsHub        *websocket.WebSocketHub
+
+	mu sync.Mutex
+}
+
+func NewBreakoutService(db *database.DB, callService *CallService, wsHub *websocket.WebSocketHub) *BreakoutService {
+	s := &BreakoutService{
+		db:           db,
+		breakoutRepo: database.NewBreakoutRepo(db),
+		callRepo:     database.NewCallRepo(db),
+		userRepo:     database.NewUserRepo(db),
+		callService:  callService,
+		wsHub:        wsHub,
+	}
+	callService.OnCallEnded(func(call *models.ActiveCall) {
+		if err := s.closeBreakouts(call, false); err != nil {
+			fmt.Printf("Failed to close breakout rooms for call %s: %v\n", call.CallID, err)
+		}
+	})
+	return s
+}
+
+// CreateBreakouts splits the participants of an active call into child rooms
+// and sends each of them a token for the room they were assigned to
+func (s *BreakoutService) CreateBreakouts(callID string, userID int64, req BreakoutRequest) ([]*models.BreakoutRoom, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	call, err := s.hostCall(callID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+	if err := validateBreakoutRequest(req); err != nil {
+		return nil, err
+	}
+
+	open, err := s.breakoutRepo.GetOpenByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+	if len(open) > 0 {
+		return nil, ErrBreakoutsActive
+	}
+
+	identities, err := s.eligibleParticipants(call)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err := assignBreakouts(identities, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var endsAt *time.Time
+	if req.DurationSeconds > 0 {
+		t := time.Now().Add(time.Duration(req.DurationSeconds) * time.Second)
+		endsAt = &t
+	}
+
+	rooms := make([]*models.BreakoutRoom, 0, req.Count)
+	for i := 0; i < req.Count; i++ {
+		name := fmt.Sprintf("Room %d", i+1)
+		if i < len(req.Names) && strings.TrimSpace(req.Names[i]) != "" {
+			name = strings.TrimSpace(req.Names[i])
+		}
+		roomName := fmt.Sprintf("%s-breakout-%d-%s", call.RoomName, i+1, uuid.New().String()[:8])
+
+		if err := s.callService.createRoom(roomName, 0, req.DurationSeconds); err != nil {
+			s.discardRooms(rooms)
+			return nil, err
+		}
+		room, err := s.breakoutRepo.CreateRoom(callID, roomName, name, endsAt)
+		if err != nil {
+			s.callService.deleteRoom(roomName)
+			s.discardRooms(rooms)
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+
+	for _, identity := range identities {
+		n, ok := assignments[identity]
+		if !ok {
+			continue // Left in the main room
+		}
+		room := rooms[n-1]
+		assignment, err := s.breakoutRepo.CreateAssignment(room.ID, identity)
+		if err != nil {
+			fmt.Printf("Failed to assign %s to breakout room %s: %v\n", identity, room.RoomName, err)
+			continue
+		}
+		room.Assignments = append(room.Assignments, assignment)
+
+		token, err := s.callService.generateToken(room.RoomName, identity, tokenOptions{
+			CallType:    call.CallType,
+			ScreenShare: s.callService.canScreenShare(call, identity),
+		})
+		if err != nil {
+			fmt.Printf("Failed to generate breakout token for %s: %v\n", identity, err)
+			continue
+		}
+		if s.wsHub != nil {
+			s.wsHub.Publish(context.Background(), []string{identity}, events.BreakoutAssigned{
+				CallID:         callID,
+				BreakoutRoomID: room.ID,
+				Name:           room.Name,
+				RoomName:       room.RoomName,
+				Token:          token,
+				EndsAt:         room.EndsAt,
+			})
+		}
+	}
+
+	return rooms, nil
+}
+
+// CloseBreakouts ends all open breakout rooms of a call and sends everyone
+// back to the main room
+func (s *BreakoutService) CloseBreakouts(callID string, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	call, err := s.hostCall(callID, userID)
+	if err != nil {
+		return err
+	}
+
+	open, err := s.breakoutRepo.GetOpenByCallID(callID)
+	if err != nil {
+		return err
+	}
+	if len(open) == 0 {
+		return ErrNoActiveBreakouts
+	}
+
+	return s.closeRooms(call, open, call.Status == "active")
+}
+
+// GetBreakouts lists the breakout rooms of a call for the host or its members
+func (s *BreakoutService) GetBreakouts(callID string, userID int64, username string) ([]*models.BreakoutRoom, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID && !slices.Contains(s.callService.callMemberUsernames(call), username) {
+		return nil, ErrCallNotFound
+	}
+
+	rooms, err := s.breakoutRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attendance is only known while the rooms exist, so sample it on every read
+	for _, room := range rooms {
+		if room.Status == "open" {
+			s.recordAttendance(room)
+		}
+	}
+
+	if rooms == nil {
+		rooms = []*models.BreakoutRoom{}
+	}
+	return rooms, nil
+}
+
+// ExpireBreakouts sends everyone back to the main room of calls whose timed
+// breakout rooms ran out. The end time is stored with the rooms, so any
+// instance can do this, also after the one that created them restarted.
+func (s *BreakoutService) ExpireBreakouts() error {
+	callIDs, err := s.breakoutRepo.GetExpiredCallIDs(time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, callID := range callIDs {
+		call, err := s.callRepo.GetByCallID(callID)
+		if err != nil || call == nil {
+			fmt.Printf("Failed to get call %s for breakout expiry: %v\n", callID, err)
+			continue
+		}
+		if err := s.closeBreakoutsLocked(call, call.Status == "active"); err != nil {
+			fmt.Printf("Failed to close breakout rooms for call %s: %v\n", callID, err)
+		}
+	}
+	return nil
+}
+
+func (s *BreakoutService) closeBreakouts(call *models.ActiveCall, returnToMain bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeBreakoutsLocked(call, returnToMain)
+}
+
+func (s *BreakoutService) closeBreakoutsLocked(call *models.ActiveCall, returnToMain bool) error {
+	open, err := s.breakoutRepo.GetOpenByCallID(call.CallID)
+	if err != nil {
+		return err
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	return s.closeRooms(call, open, returnToMain)
+}
+
+// closeRooms records who attended, rolls them into the parent call's history,
+// sends them back to the main room and tears the child rooms down. Rooms
+// another instance closed first are left to that instance.
+func (s *BreakoutService) closeRooms(call *models.ActiveCall, open []*models.BreakoutRoom, returnToMain bool) error {
+	var rooms []*models.BreakoutRoom
+	for _, room := range open {
+		closed, err := s.breakoutRepo.CloseRoom(room.ID)
+		if err != nil {
+			return err
+		}
+		if closed {
+			rooms = append(rooms, room)
+		}
+	}
+
+	var attendees []string
+	for _, room := range rooms {
+		for _, identity := range s.recordAttendance(room) {
+			if !slices.Contains(attendees, identity) {
+				attendees = append(attendees, identity)
+			}
+		}
+	}
+	if err := s.callService.historyService.AddParticipants(call.CallID, attendees); err != nil {
+		fmt.Printf("Failed to add breakout attendees to call history: %v\n", err)
+	}
+
+	if returnToMain && s.wsHub != nil {
+		for _, room := range rooms {
+			for _, assignment := range room.Assignments {
+				token, err := s.callService.generateToken(call.RoomName, assignment.Identity, tokenOptions{
+					CallType:    call.CallType,
+					ScreenShare: s.callService.canScreenShare(call, assignment.Identity),
+				})
+				if err != nil {
+					fmt.Printf("Failed to generate return token for %s: %v\n", assignment.Identity, err)
+					continue
+				}
+				s.wsHub.Publish(context.Background(), []string{assignment.Identity}, events.BreakoutReturn{
+					CallID:   call.CallID,
+					RoomName: call.RoomName,
+					Token:    token,
+				})
+			}
+		}
+	}
+
+	for _, room := range rooms {
+		s.callService.deleteRoom(room.RoomName)
+	}
+
+	return nil
+}
+
+// recordAttendance marks everyone currently in a breakout room as attended
+// and returns their identities
+func (s *BreakoutService) recordAttendance(room *models.BreakoutRoom) []string {
+	participants, err := s.callService.participantService.ListParticipants(room.RoomName)
+	if err != nil {
+		fmt.Printf("Failed to list participants for breakout room %s: %v\n", room.RoomName, err)
+	}
+
+	var identities []string
+	for _, p := range participants {
+		if p.Identity == "" || p.Kind != livekit.ParticipantInfo_STANDARD {
+			continue
+		}
+		if err := s.breakoutRepo.MarkAttended(room.ID, p.Identity); err != nil {
+			fmt.Printf("Failed to record breakout attendance for %s: %v\n", p.Identity, err)
+			continue
+		}
+		identities = append(identities, p.Identity)
+
+		found := false
+		for _, assignment := range room.Assignments {
+			if assignment.Identity == p.Identity {
+				assignment.Attended = true
+				found = true
+			}
+		}
+		if !found {
+			room.Assignments = append(room.Assignments, &models.BreakoutAssignment{
+				BreakoutRoomID: room.ID,
+				Identity:       p.Identity,
+				Attended:       true,
+				AssignedAt:     time.Now(),
+			})
+		}
+	}
+
+	return identities
+}
+
+// eligibleParticipants returns the registered users in the main room other
+// than the host. Guests and phone participants have no socket to receive an
+// assignment on, so they stay in the main room.
+func (s *BreakoutService) eligibleParticipants(call *models.ActiveCall) ([]string, error) {
+	participants, err := s.callService.participantService.ListParticipants(call.RoomName)
+	if err != nil {
+		return nil, err
+	}
+
+	var hostUsername string
+	if host, err := s.userRepo.GetByID(call.CreatedBy); err == nil && host != nil {
+		hostUsername = host.Username
+	}
+
+	var identities []string
+	for _, p := range participants {
+		if p.Identity == "" || p.Identity == hostUsername || p.Kind != livekit.ParticipantInfo_STANDARD {
+			continue
+		}
+		if p.Attributes["role"] == "guest" {
+			continue
+		}
+		identities = append(identities, p.Identity)
+	}
+	return identities, nil
+}
+
+func (s *BreakoutService) discardRooms(rooms []*models.BreakoutRoom) {
+	for _, room := range rooms {
+		if _, err := s.breakoutRepo.CloseRoom(room.ID); err != nil {
+			fmt.Printf("Failed to close breakout room %s: %v\n", room.RoomName, err)
+		}
+		s.callService.deleteRoom(room.RoomName)
+	}
+}
+
+func (s *BreakoutService) hostCall(callID string, userID int64) (*models.ActiveCall, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+	return call, nil
+}
+
+func validateBreakoutRequest(req BreakoutRequest) error {
+	if req.Count < 1 || req.Count > maxBreakoutRooms {
+		return fmt.Errorf("%w: count must be between 1 and %d", ErrInvalidBreakoutRequest, maxBreakoutRooms)
+	}
+	if req.Mode != "random" && req.Mode != "manual" {
+		return fmt.Errorf("%w: mode must be \"random\" or \"manual\"", ErrInvalidBreakoutRequest)
+	}
+	if req.DurationSeconds < 0 {
+		return fmt.Errorf("%w: durationSeconds must not be negative", ErrInvalidBreakoutRequest)
+	}
+	return nil
+}
+
+// assignBreakouts maps participant identities to 1-based room numbers
+func assignBreakouts(identities []string, req BreakoutRequest) (map[string]int, error) {
+	assignments := make(map[string]int, len(identities))
+
+	if req.Mode == "random" {
+		shuffled := slices.Clone(identities)
+		rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		for i, identity := range shuffled {
+			assignments[identity] = i%req.Count + 1
+		}
+		return assignments, nil
+	}
+
+	for identity, n := range req.Assignments {
+		if n < 1 || n > req.Count {
+			return nil, fmt.Errorf("%w: %s is assigned to room %d of %d", ErrInvalidBreakoutRequest, identity, n, req.Count)
+		}
+		if !slices.Contains(identities, identity) {
+			return nil, fmt.Errorf("%w: %s is not in the call", ErrInvalidBreakoutRequest, identity)
+		}
+		assignments[identity] = n
+	}
+	return assignments, nil
+}
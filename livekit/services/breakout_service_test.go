@@ -0,0 +1,261 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"slices"
+	"testing"
+	"time"
+
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+type breakoutTestEnv struct {
+	db          *database.DB
+	rooms       *fakeRoomClient
+	wsHub       *websocket.WebSocketHub
+	callService *CallService
+	breakouts   *BreakoutService
+	host        *models.User
+	callID      string
+	roomName    string
+	conns       map[string]*websocket.Connection
+}
+
+// newBreakoutTestEnv starts a call whose invitees have all joined the main
+// room, next to the host and a guest
+func newBreakoutTestEnv(t *testing.T, invitees ...string) *breakoutTestEnv {
+	t.Helper()
+
+	db := newTestDB(t)
+	rooms := newFakeRoomClient()
+	wsHub := websocket.NewWebSocketHub()
+	callService := newTestCallService(t, db, rooms, wsHub)
+	env := &breakoutTestEnv{
+		db:          db,
+		rooms:       rooms,
+		wsHub:       wsHub,
+		callService: callService,
+		breakouts:   NewBreakoutService(db, callService, wsHub),
+		host:        createTestUser(t, db, "host"),
+		conns:       make(map[string]*websocket.Connection),
+	}
+	for _, username := range invitees {
+		createTestUser(t, db, username)
+	}
+	created, err := callService.CreateCallAndInvite(env.host.ID, "video", invitees, "", CallOptions{})
+	if err != nil {
+		t.Fatalf("CreateCallAndInvite: %v", err)
+	}
+	env.callID, env.roomName = created.CallID, created.RoomName
+
+	invitations, err := database.NewInvitationRepo(db).GetCallParticipants(env.callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, invitation := range invitations {
+		if _, err := callService.RespondToInvitation(invitation.ID, invitation.InviteeID, "accept"); err != nil {
+			t.Fatalf("RespondToInvitation: %v", err)
+		}
+	}
+
+	rooms.join(env.roomName, &livekit.ParticipantInfo{Identity: "host"})
+	rooms.join(env.roomName, &livekit.ParticipantInfo{Identity: "guest-1", Attributes: map[string]string{"role": "guest"}})
+	for _, username := range invitees {
+		rooms.join(env.roomName, &livekit.ParticipantInfo{Identity: username})
+		env.conns[username] = &websocket.Connection{ID: username, Username: username, Send: make(chan []byte, 64)}
+		wsHub.Register(username, env.conns[username])
+	}
+	return env
+}
+
+// received decodes the events of one type queued on the user's socket
+func received[T any](t *testing.T, env *breakoutTestEnv, username, eventType string) []T {
+	t.Helper()
+
+	var got []T
+	for _, envelope := range drain(t, env.conns[username]) {
+		if envelope.Type != eventType {
+			continue
+		}
+		var event T
+		if err := json.Unmarshal(envelope.Data, &event); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, event)
+	}
+	return got
+}
+
+func assignedIdentities(room *models.BreakoutRoom) []string {
+	var identities []string
+	for _, assignment := range room.Assignments {
+		identities = append(identities, assignment.Identity)
+	}
+	slices.Sort(identities)
+	return identities
+}
+
+func TestManualBreakoutAssignment(t *testing.T) {
+	env := newBreakoutTestEnv(t, "alice", "bob", "carol")
+	alice, _ := database.NewUserRepo(env.db).GetByUsername("alice")
+
+	invalid := []struct {
+		name string
+		req  BreakoutRequest
+	}{
+		{name: "no rooms", req: BreakoutRequest{Count: 0, Mode: "manual"}},
+		{name: "unknown mode", req: BreakoutRequest{Count: 2, Mode: "alphabetical"}},
+		{name: "room out of range", req: BreakoutRequest{Count: 2, Mode: "manual", Assignments: map[string]int{"alice": 3}}},
+		{name: "not in the call", req: BreakoutRequest{Count: 2, Mode: "manual", Assignments: map[string]int{"dave": 1}}},
+	}
+	for _, tt := range invalid {
+		if _, err := env.breakouts.CreateBreakouts(env.callID, env.host.ID, tt.req); !errors.Is(err, ErrInvalidBreakoutRequest) {
+			t.Errorf("%s: %v, want ErrInvalidBreakoutRequest", tt.name, err)
+		}
+	}
+
+	req := BreakoutRequest{
+		Count:       2,
+		Mode:        "manual",
+		Assignments: map[string]int{"alice": 1, "bob": 2},
+		Names:       []string{"Design", ""},
+	}
+	if _, err := env.breakouts.CreateBreakouts(env.callID, alice.ID, req); !errors.Is(err, ErrNotCallHost) {
+		t.Errorf("CreateBreakouts by a participant: %v, want ErrNotCallHost", err)
+	}
+	rooms, err := env.breakouts.CreateBreakouts(env.callID, env.host.ID, req)
+	if err != nil {
+		t.Fatalf("CreateBreakouts: %v", err)
+	}
+	if len(rooms) != 2 || rooms[0].Name != "Design" || rooms[1].Name != "Room 2" {
+		t.Fatalf("rooms %+v, want Design and Room 2", rooms)
+	}
+	if got := assignedIdentities(rooms[0]); !slices.Equal(got, []string{"alice"}) {
+		t.Errorf("Design has %v, want alice", got)
+	}
+	if got := assignedIdentities(rooms[1]); !slices.Equal(got, []string{"bob"}) {
+		t.Errorf("Room 2 has %v, want bob", got)
+	}
+
+	// Each participant gets a token for their own room only
+	for username, room := range map[string]*models.BreakoutRoom{"alice": rooms[0], "bob": rooms[1]} {
+		assigned := received[events.BreakoutAssigned](t, env, username, "breakout_assigned")
+		if len(assigned) != 1 || assigned[0].RoomName != room.RoomName {
+			t.Fatalf("%s was assigned %+v, want %s", username, assigned, room.RoomName)
+		}
+		grants := tokenGrants(t, assigned[0].Token)
+		if grants.Identity != username || grants.Video.Room != room.RoomName {
+			t.Errorf("%s got a token for %s in %s", username, grants.Identity, grants.Video.Room)
+		}
+	}
+	if assigned := received[events.BreakoutAssigned](t, env, "carol", "breakout_assigned"); len(assigned) != 0 {
+		t.Errorf("carol was assigned %+v although she stays in the main room", assigned)
+	}
+
+	if _, err := env.breakouts.CreateBreakouts(env.callID, env.host.ID, req); !errors.Is(err, ErrBreakoutsActive) {
+		t.Errorf("second CreateBreakouts: %v, want ErrBreakoutsActive", err)
+	}
+}
+
+func TestRandomBreakoutAssignment(t *testing.T) {
+	env := newBreakoutTestEnv(t, "alice", "bob", "carol", "dave")
+
+	rooms, err := env.breakouts.CreateBreakouts(env.callID, env.host.ID, BreakoutRequest{Count: 2, Mode: "random"})
+	if err != nil {
+		t.Fatalf("CreateBreakouts: %v", err)
+	}
+
+	var everyone []string
+	for _, room := range rooms {
+		// Four participants over two rooms makes two per room
+		if len(room.Assignments) != 2 {
+			t.Errorf("%s has %d participants, want 2", room.Name, len(room.Assignments))
+		}
+		everyone = append(everyone, assignedIdentities(room)...)
+	}
+	slices.Sort(everyone)
+	// The host and guests stay in the main room
+	if !slices.Equal(everyone, []string{"alice", "bob", "carol", "dave"}) {
+		t.Errorf("assigned %v, want every invitee exactly once", everyone)
+	}
+}
+
+func TestBreakoutAutoReturn(t *testing.T) {
+	env := newBreakoutTestEnv(t, "alice", "bob")
+
+	rooms, err := env.breakouts.CreateBreakouts(env.callID, env.host.ID, BreakoutRequest{
+		Count:           1,
+		Mode:            "manual",
+		Assignments:     map[string]int{"alice": 1, "bob": 1},
+		DurationSeconds: 60,
+	})
+	if err != nil {
+		t.Fatalf("CreateBreakouts: %v", err)
+	}
+	room := rooms[0]
+	if room.EndsAt == nil {
+		t.Fatal("timed breakout room has no end time")
+	}
+	env.rooms.join(room.RoomName, &livekit.ParticipantInfo{Identity: "alice"})
+	env.rooms.join(room.RoomName, &livekit.ParticipantInfo{Identity: "erin"}) // Joined without an assignment
+	drain(t, env.conns["alice"])
+
+	if err := env.breakouts.ExpireBreakouts(); err != nil {
+		t.Fatalf("ExpireBreakouts: %v", err)
+	}
+	if open, _ := database.NewBreakoutRepo(env.db).GetOpenByCallID(env.callID); len(open) != 1 {
+		t.Fatalf("%d rooms open before the end time, want 1", len(open))
+	}
+
+	// Another instance, or this one after a restart, sends everyone back
+	if _, err := env.db.Conn().Exec(`UPDATE breakout_rooms SET ends_at = ? WHERE id = ?`, time.Now().Add(-time.Second), room.ID); err != nil {
+		t.Fatal(err)
+	}
+	other := NewBreakoutService(env.db, env.callService, env.wsHub)
+	if err := other.ExpireBreakouts(); err != nil {
+		t.Fatalf("ExpireBreakouts: %v", err)
+	}
+	if err := env.breakouts.ExpireBreakouts(); err != nil {
+		t.Fatalf("ExpireBreakouts: %v", err)
+	}
+
+	returned := received[events.BreakoutReturn](t, env, "alice", "breakout_return")
+	if len(returned) != 1 || returned[0].RoomName != env.roomName {
+		t.Fatalf("alice got %+v, want one return to %s", returned, env.roomName)
+	}
+	if grants := tokenGrants(t, returned[0].Token); grants.Video.Room != env.roomName {
+		t.Errorf("return token is for %s, want %s", grants.Video.Room, env.roomName)
+	}
+	env.rooms.mu.Lock()
+	childExists := env.rooms.rooms[room.RoomName]
+	env.rooms.mu.Unlock()
+	if childExists {
+		t.Error("breakout room still exists after the return")
+	}
+
+	// Attendance is kept on the room and rolled into the parent call's history
+	stored, err := env.breakouts.GetBreakouts(env.callID, env.host.ID, "host")
+	if err != nil || len(stored) != 1 {
+		t.Fatalf("GetBreakouts = %v, %v", stored, err)
+	}
+	attended := make(map[string]bool)
+	for _, assignment := range stored[0].Assignments {
+		attended[assignment.Identity] = assignment.Attended
+	}
+	if stored[0].Status != "closed" || !attended["alice"] || attended["bob"] || !attended["erin"] {
+		t.Errorf("room is %s with attendance %v, want closed with alice and erin", stored[0].Status, attended)
+	}
+	history, err := env.callService.historyService.GetCallDetails(env.callID)
+	if err != nil || history == nil {
+		t.Fatalf("GetCallDetails: %v, %v", history, err)
+	}
+	participants, err := env.callService.historyService.ParseParticipants(history.Participants)
+	if err != nil || !slices.Contains(participants, "erin") {
+		t.Errorf("history participants %v, %v; want erin from the breakout room", participants, err)
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"livekit/database"
+	"livekit/models"
+)
+
+// Call actions clients can take over the socket instead of REST, saving a
+// round trip on flaky mobile networks. Each answers with the same result as
+// its REST counterpart.
+
+type callSocketRequest struct {
+	CallID       string `json:"callId"`
+	InvitationID int64  `json:"invitationId"`
+	Action       string `json:"action"`
+}
+
+// GetPendingInvitations lists the invitations still waiting for the user's answer
+func (s *CallService) GetPendingInvitations(userID int64) ([]*models.Invitation, error) {
+	invitationRepo := database.NewInvitationRepo(s.db)
+	return invitationRepo.GetPendingForUser(userID)
+}
+
+func (s *CallService) socketUserID(username string) (int64, error) {
+	userRepo := database.NewUserRepo(s.db)
+	user, err := userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		return 0, fmt.Errorf("user not found")
+	}
+	return user.ID, nil
+}
+
+func (s *CallService) handleRespondInvitation(username string, data json.RawMessage) (interface{}, error) {
+	var req callSocketRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.InvitationID == 0 {
+		return nil, fmt.Errorf("invitationId is required")
+	}
+	if req.Action != "accept" && req.Action != "reject" {
+		return nil, fmt.Errorf("action must be 'accept' or 'reject'")
+	}
+
+	userID, err := s.socketUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.RespondToInvitation(req.InvitationID, userID, req.Action)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return map[string]string{"message": "Invitation rejected"}, nil
+	}
+	return result, nil
+}
+
+func (s *CallService) handleEndCall(username string, data json.RawMessage) (interface{}, error) {
+	var req callSocketRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.CallID == "" {
+		return nil, fmt.Errorf("callId is required")
+	}
+
+	userID, err := s.socketUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.EndCall(req.CallID, userID); err != nil {
+		return nil, err
+	}
+	return map[string]string{"message": "Call ended"}, nil
+}
+
+func (s *CallService) handleCancelCall(username string, data json.RawMessage) (interface{}, error) {
+	var req callSocketRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.CallID == "" {
+		return nil, fmt.Errorf("callId is required")
+	}
+
+	userID, err := s.socketUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.CancelCall(req.CallID, userID); err != nil {
+		return nil, err
+	}
+	return map[string]string{"message": "Call cancelled"}, nil
+}
+
+func (s *CallService) handleGetInvitations(username string, data json.RawMessage) (interface{}, error) {
+	userID, err := s.socketUserID(username)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPendingInvitations(userID)
+}
@@ -2,10 +2,13 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"livekit/database"
+	"livekit/events"
 	"livekit/models"
 	"livekit/websocket"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -25,21 +28,42 @@ type CallServiceConfig struct {
 type CallService struct {
 	db                 *database.DB
 	config             *CallServiceConfig
-	roomClient         *lksdk.RoomServiceClient
+	roomClient         RoomClient
 	wsHub              *websocket.WebSocketHub
 	historyService     *HistoryService
 	participantService *ParticipantService
+	passcodeLimiter    *passcodeLimiter
+	callEndedHooks     []func(call *models.ActiveCall)
+	callJoinedHooks    []func(call *models.ActiveCall, userID int64)
 }
 
 type CreateCallResult struct {
-	CallID   string `json:"callId"`
-	RoomName string `json:"roomName"`
-	Token    string `json:"token"`
+	CallID         string            `json:"callId"`
+	RoomName       string            `json:"roomName"`
+	Token          string            `json:"token,omitempty"`
+	State          string            `json:"state"` // "joined", "lobby"
+	LobbyRequestID int64             `json:"lobbyRequestId,omitempty"`
+	Invitees       map[string]string `json:"invitees,omitempty"` // Username -> "ringing", "dnd", "busy"
 }
 
 type RespondInvitationResult struct {
-	Token    string `json:"token"`
-	RoomName string `json:"roomName"`
+	Token          string `json:"token,omitempty"`
+	RoomName       string `json:"roomName"`
+	State          string `json:"state"` // "joined", "lobby"
+	LobbyRequestID int64  `json:"lobbyRequestId,omitempty"`
+}
+
+// CallOptions are the host controls applied when a call is created
+type CallOptions struct {
+	GuestAccessEnabled bool
+	LobbyEnabled       bool
+	Passcode           string // Plain passcode chosen by the creator, hashed before storing
+
+	// passcodeHash carries an already hashed passcode, e.g. from a scheduled call
+	passcodeHash string
+	// scheduledCallID and occurrence identify the scheduled meeting the call starts
+	scheduledCallID int64
+	occurrence      time.Time
 }
 
 func NewCallService(db *database.DB, cfg *CallServiceConfig, wsHub *websocket.WebSocketHub) (*CallService, error) {
@@ -47,23 +71,69 @@ func NewCallService(db *database.DB, cfg *CallServiceConfig, wsHub *websocket.We
 	historyService := NewHistoryService(db)
 	participantService := NewParticipantService(roomClient, wsHub)
 
-	return &CallService{
+	s := &CallService{
 		db:                 db,
 		config:             cfg,
 		roomClient:         roomClient,
 		wsHub:              wsHub,
 		historyService:     historyService,
 		participantService: participantService,
-	}, nil
+		passcodeLimiter:    newPasscodeLimiter(db),
+	}
+
+	if wsHub != nil {
+		wsHub.OnClientMessage("screen_share_request", s.handleScreenShareRequest)
+		wsHub.OnClientMessage("screen_share_respond", s.handleScreenShareResponse)
+		wsHub.OnRequest("respond_invitation", s.handleRespondInvitation)
+		wsHub.OnRequest("end_call", s.handleEndCall)
+		wsHub.OnRequest("cancel_call", s.handleCancelCall)
+		wsHub.OnRequest("get_invitations", s.handleGetInvitations)
+		wsHub.OnUndelivered(s.reportUndelivered)
+	}
+
+	return s, nil
+}
+
+// OnCallEnded registers a hook that runs once a call has ended or was cancelled,
+// letting other services release what they hold for the call
+func (s *CallService) OnCallEnded(hook func(call *models.ActiveCall)) {
+	s.callEndedHooks = append(s.callEndedHooks, hook)
+}
+
+func (s *CallService) runCallEndedHooks(call *models.ActiveCall) {
+	for _, hook := range s.callEndedHooks {
+		hook(call)
+	}
+}
+
+// OnCallJoined registers a hook that runs when a user starts a call, accepts an
+// invitation to one or is admitted from its lobby
+func (s *CallService) OnCallJoined(hook func(call *models.ActiveCall, userID int64)) {
+	s.callJoinedHooks = append(s.callJoinedHooks, hook)
+}
+
+func (s *CallService) runCallJoinedHooks(call *models.ActiveCall, userID int64) {
+	for _, hook := range s.callJoinedHooks {
+		hook(call, userID)
+	}
 }
 
-func (s *CallService) CreateCallAndInvite(creatorID int64, callType string, inviteeUsernames []string, roomName string) (*CreateCallResult, error) {
+func (s *CallService) CreateCallAndInvite(creatorID int64, callType string, inviteeUsernames []string, roomName string, opts CallOptions) (*CreateCallResult, error) {
 	if roomName == "" {
 		roomName = uuid.New().String()
 	}
 
 	callID := uuid.New().String()
 
+	passcodeHash := opts.passcodeHash
+	if opts.Passcode != "" {
+		var err error
+		passcodeHash, err = hashPasscode(opts.Passcode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	userRepo := database.NewUserRepo(s.db)
 	invitationRepo := database.NewInvitationRepo(s.db)
 	callRepo := database.NewCallRepo(s.db)
@@ -80,10 +150,30 @@ func (s *CallService) CreateCallAndInvite(creatorID int64, callType string, invi
 		return nil, fmt.Errorf("failed to create room: %w", err)
 	}
 
-	_, err = callRepo.Create(callID, roomName, callType, creatorID)
+	call, err := callRepo.Create(callID, roomName, callType, creatorID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create call record: %w", err)
 	}
+	if !opts.GuestAccessEnabled {
+		if err := callRepo.UpdateGuestAccess(callID, false); err != nil {
+			return nil, err
+		}
+	}
+	if opts.LobbyEnabled {
+		if err := callRepo.UpdateLobby(callID, true); err != nil {
+			return nil, err
+		}
+	}
+	if passcodeHash != "" {
+		if err := callRepo.UpdatePasscode(callID, passcodeHash); err != nil {
+			return nil, err
+		}
+	}
+	if opts.scheduledCallID != 0 {
+		if err := callRepo.SetScheduledOccurrence(callID, opts.scheduledCallID, opts.occurrence); err != nil {
+			return nil, err
+		}
+	}
 
 	var createdInvitations []*models.Invitation
 	for _, username := range inviteeUsernames {
@@ -99,13 +189,27 @@ func (s *CallService) CreateCallAndInvite(creatorID int64, callType string, invi
 		if err != nil {
 			continue
 		}
+		if reason := s.declineReason(invitee.ID); reason != "" {
+			if err := invitationRepo.UpdateStatus(invitation.ID, reason); err != nil {
+				fmt.Printf("Failed to update invitation %d status: %v\n", invitation.ID, err)
+			} else {
+				invitation.Status = reason
+			}
+		}
 		createdInvitations = append(createdInvitations, invitation)
 	}
 
 	if s.wsHub != nil {
 		for _, invitation := range createdInvitations {
 			if invitation.Status == "pending" {
-				s.wsHub.BroadcastInvitation(invitation.Invitee, invitation)
+				s.wsHub.Publish(context.Background(), []string{invitation.Invitee}, events.CallInvitation{
+					InvitationID: invitation.ID,
+					CallID:       invitation.CallID,
+					Inviter:      invitation.Inviter,
+					CallType:     invitation.CallType,
+					RoomName:     invitation.RoomName,
+					Timestamp:    invitation.CreatedAt,
+				})
 			}
 		}
 	}
@@ -119,23 +223,70 @@ func (s *CallService) CreateCallAndInvite(creatorID int64, callType string, invi
 		// Log error but don't fail call creation
 		fmt.Printf("Failed to create call history entry: %v\n", err)
 	}
+	invitees := make(map[string]string, len(createdInvitations))
+	declined := false
+	for _, invitation := range createdInvitations {
+		if err := s.historyService.RecordInvitation(invitation); err != nil {
+			fmt.Printf("Failed to record invitation %d in call history: %v\n", invitation.ID, err)
+		}
+		invitees[invitation.Invitee] = "ringing"
+		if invitation.Status != "pending" {
+			invitees[invitation.Invitee] = invitation.Status
+			declined = true
+			if err := s.historyService.RecordOutcome(callID, invitation.Invitee, invitation.Status); err != nil {
+				fmt.Printf("Failed to record invitation outcome in call history: %v\n", err)
+			}
+		}
+	}
 	// Set initial status to pending (will be updated when call is accepted/rejected/ended)
 	if err := s.historyService.UpdateHistoryEntry(callID, time.Now(), 0, "pending"); err != nil {
 		fmt.Printf("Failed to update call history status: %v\n", err)
 	}
+	if declined {
+		s.refreshHistoryStatus(callID)
+	}
 
-	token, err := s.generateToken(roomName, creator.Username)
+	token, err := s.generateToken(roomName, creator.Username, tokenOptions{CallType: callType, ScreenShare: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
+	s.runCallJoinedHooks(call, creatorID)
+
 	return &CreateCallResult{
 		CallID:   callID,
 		RoomName: roomName,
 		Token:    token,
+		State:    "joined",
+		Invitees: invitees,
 	}, nil
 }
 
+// declineReason tells why an invitee is not rung: "dnd" while they do not want
+// to be disturbed, "busy" while they are in another call and reject calls then
+func (s *CallService) declineReason(inviteeID int64) string {
+	preferenceRepo := database.NewPreferenceRepo(s.db)
+	prefs, err := preferenceRepo.Get(inviteeID)
+	if err != nil {
+		fmt.Printf("Failed to get preferences of user %d: %v\n", inviteeID, err)
+		return ""
+	}
+	if doNotDisturb(prefs, time.Now()) {
+		return "dnd"
+	}
+	if prefs.RejectWhileInCall {
+		callRepo := database.NewCallRepo(s.db)
+		inCall, err := callRepo.IsInActiveCall(inviteeID)
+		if err != nil {
+			fmt.Printf("Failed to check calls of user %d: %v\n", inviteeID, err)
+		}
+		if inCall {
+			return "busy"
+		}
+	}
+	return ""
+}
+
 func (s *CallService) RespondToInvitation(invitationID, userID int64, action string) (*RespondInvitationResult, error) {
 	invitationRepo := database.NewInvitationRepo(s.db)
 	invitation, err := invitationRepo.GetByID(invitationID)
@@ -154,26 +305,46 @@ func (s *CallService) RespondToInvitation(invitationID, userID int64, action str
 		return nil, fmt.Errorf("invitation already responded")
 	}
 
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(invitation.CallID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	lobby := call != nil && call.LobbyEnabled && call.CreatedBy != userID
+
+	// An invitee waiting in the lobby is not a member of the call until the
+	// host admits them, see RespondToLobbyRequest
 	status := "rejected"
 	if action == "accept" {
 		status = "accepted"
+		if lobby {
+			status = "lobby"
+		}
 	}
 
 	if err := invitationRepo.UpdateStatus(invitationID, status); err != nil {
 		return nil, fmt.Errorf("failed to update invitation status: %w", err)
 	}
 
-	if action == "reject" {
-		// Update call history with rejected status
-		if err := s.historyService.UpdateHistoryEntry(invitation.CallID, time.Now(), 0, "rejected"); err != nil {
-			fmt.Printf("Failed to update call history for rejection: %v\n", err)
+	if status != "lobby" {
+		if err := s.historyService.RecordOutcome(invitation.CallID, invitation.Invitee, status); err != nil {
+			fmt.Printf("Failed to record invitation outcome in call history: %v\n", err)
 		}
+	}
+
+	if action == "reject" {
+		// Only a rejection by every invitee marks the whole call as rejected
+		s.refreshHistoryStatus(invitation.CallID)
 
 		if s.wsHub != nil {
 			userRepo := database.NewUserRepo(s.db)
 			inviter, err := userRepo.GetByID(invitation.InviterID)
 			if err == nil && inviter != nil {
-				s.wsHub.BroadcastInvitationResponse(inviter.Username, invitationID, invitation.Invitee, "rejected")
+				s.wsHub.Publish(context.Background(), []string{inviter.Username}, events.InvitationResponse{
+					InvitationID: invitationID,
+					Invitee:      invitation.Invitee,
+					Status:       "rejected",
+				})
 			}
 		}
 		return nil, nil
@@ -185,25 +356,188 @@ func (s *CallService) RespondToInvitation(invitationID, userID int64, action str
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	token, err := s.generateToken(invitation.RoomName, user.Username)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %w", err)
-	}
-
 	if s.wsHub != nil {
 		userRepo := database.NewUserRepo(s.db)
 		inviter, err := userRepo.GetByID(invitation.InviterID)
 		if err == nil && inviter != nil {
-			s.wsHub.BroadcastInvitationResponse(inviter.Username, invitationID, invitation.Invitee, "accepted")
+			s.wsHub.Publish(context.Background(), []string{inviter.Username}, events.InvitationResponse{
+				InvitationID: invitationID,
+				Invitee:      invitation.Invitee,
+				Status:       "accepted",
+			})
+		}
+	}
+
+	if lobby {
+		request, err := s.requestLobbyAdmission(call, userID, user.Username, user.Username, "participant")
+		if err != nil {
+			return nil, fmt.Errorf("failed to enter lobby: %w", err)
 		}
+		return &RespondInvitationResult{
+			RoomName:       invitation.RoomName,
+			State:          "lobby",
+			LobbyRequestID: request.ID,
+		}, nil
+	}
+
+	opts := tokenOptions{CallType: invitation.CallType}
+	if call != nil {
+		s.runCallJoinedHooks(call, userID)
+		opts = tokenOptions{CallType: call.CallType, ScreenShare: s.canScreenShare(call, user.Username)}
+	}
+	token, err := s.generateToken(invitation.RoomName, user.Username, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
 	return &RespondInvitationResult{
 		Token:    token,
 		RoomName: invitation.RoomName,
+		State:    "joined",
+	}, nil
+}
+
+// JoinStartedCall puts a non-host into the lobby of the call started for an
+// occurrence of a scheduled call
+func (s *CallService) JoinStartedCall(scheduledCallID int64, occurrence time.Time, userID int64) (*CreateCallResult, error) {
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByScheduledOccurrence(scheduledCallID, occurrence)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil || call.Status != "active" {
+		return nil, ErrCallNotStarted
+	}
+
+	userRepo := database.NewUserRepo(s.db)
+	user, err := userRepo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	request, err := s.requestLobbyAdmission(call, userID, user.Username, user.Username, "participant")
+	if err != nil {
+		return nil, fmt.Errorf("failed to enter lobby: %w", err)
+	}
+
+	return &CreateCallResult{
+		CallID:         call.CallID,
+		RoomName:       call.RoomName,
+		State:          "lobby",
+		LobbyRequestID: request.ID,
 	}, nil
 }
 
+// CallSettingsUpdate carries host controls for an active call; nil fields are left unchanged
+type CallSettingsUpdate struct {
+	GuestAccessEnabled *bool   `json:"guestAccessEnabled,omitempty"`
+	LobbyEnabled       *bool   `json:"lobbyEnabled,omitempty"`
+	Passcode           *string `json:"passcode,omitempty"` // Empty string removes the passcode
+	ScreenSharePolicy  *string `json:"screenSharePolicy,omitempty"`
+}
+
+func (s *CallService) UpdateCallSettings(callID string, userID int64, update CallSettingsUpdate) (*models.ActiveCall, error) {
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+
+	if update.GuestAccessEnabled != nil {
+		if err := callRepo.UpdateGuestAccess(callID, *update.GuestAccessEnabled); err != nil {
+			return nil, err
+		}
+		call.GuestAccessEnabled = *update.GuestAccessEnabled
+	}
+
+	if update.LobbyEnabled != nil {
+		if err := callRepo.UpdateLobby(callID, *update.LobbyEnabled); err != nil {
+			return nil, err
+		}
+		call.LobbyEnabled = *update.LobbyEnabled
+	}
+
+	if update.Passcode != nil {
+		passcodeHash, err := hashPasscode(*update.Passcode)
+		if err != nil {
+			return nil, err
+		}
+		if err := callRepo.UpdatePasscode(callID, passcodeHash); err != nil {
+			return nil, err
+		}
+		call.PasscodeHash = passcodeHash
+		call.HasPasscode = passcodeHash != ""
+	}
+
+	if update.ScreenSharePolicy != nil {
+		if err := s.setScreenSharePolicy(call, *update.ScreenSharePolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	return call, nil
+}
+
+// ChangeCallType switches a running call between voice and video, updating what
+// everyone in the room may publish
+func (s *CallService) ChangeCallType(callID string, userID int64, callType string) (*models.ActiveCall, error) {
+	if callType != "video" && callType != "voice" {
+		return nil, ErrInvalidCallType
+	}
+
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+	if call.CallType == callType {
+		return call, nil
+	}
+
+	if err := callRepo.UpdateCallType(callID, callType); err != nil {
+		return nil, err
+	}
+	call.CallType = callType
+
+	if err := s.historyService.UpdateCallType(callID, callType); err != nil {
+		fmt.Printf("Failed to update call type in call history: %v\n", err)
+	}
+
+	s.refreshPublishSources(call, "")
+
+	if s.wsHub != nil {
+		host := ""
+		if user, err := database.NewUserRepo(s.db).GetByID(userID); err == nil && user != nil {
+			host = user.Username
+		}
+		s.wsHub.Publish(context.Background(), s.callMemberUsernames(call), events.CallTypeChanged{
+			CallID:    callID,
+			CallType:  callType,
+			ChangedBy: host,
+		})
+	}
+
+	return call, nil
+}
+
 func (s *CallService) CreateRoomForScheduledCall(roomName string, maxParticipants, maxDurationSeconds int) error {
 	return s.createRoom(roomName, maxParticipants, maxDurationSeconds)
 }
@@ -241,16 +575,98 @@ func (s *CallService) createRoom(roomName string, maxParticipants, maxDurationSe
 	return nil
 }
 
-func (s *CallService) generateToken(roomName, identity string) (string, error) {
+// deleteRoom closes a LiveKit room, disconnecting anyone still in it
+func (s *CallService) deleteRoom(roomName string) {
+	if _, err := s.roomClient.DeleteRoom(context.Background(), &livekit.DeleteRoomRequest{Room: roomName}); err != nil {
+		fmt.Printf("Failed to delete room %s: %v\n", roomName, err)
+	}
+}
+
+// tokenOptions describe who a LiveKit token is minted for
+type tokenOptions struct {
+	Name        string // display name shown to other participants
+	Role        string // "" for registered users, "guest" for link joiners
+	CallType    string // "voice" or "video"; limits which tracks may be published
+	ScreenShare bool   // whether the screen share policy lets this participant share
+}
+
+// publishSources lists the track sources participants may publish for a call type
+func publishSources(callType string, screenShare bool) []livekit.TrackSource {
+	if callType == "voice" {
+		return []livekit.TrackSource{livekit.TrackSource_MICROPHONE}
+	}
+	sources := []livekit.TrackSource{livekit.TrackSource_MICROPHONE, livekit.TrackSource_CAMERA}
+	if screenShare {
+		sources = append(sources, livekit.TrackSource_SCREEN_SHARE, livekit.TrackSource_SCREEN_SHARE_AUDIO)
+	}
+	return sources
+}
+
+// refreshPublishSources re-applies the allowed track sources to participants
+// already in the room, or to a single identity when one is given
+func (s *CallService) refreshPublishSources(call *models.ActiveCall, identity string) {
+	participants, err := s.participantService.ListParticipants(call.RoomName)
+	if err != nil {
+		fmt.Printf("Failed to list participants of room %s: %v\n", call.RoomName, err)
+		return
+	}
+
+	for _, p := range participants {
+		// Recorders, SIP callers and agents keep the permissions they joined with
+		if p.Kind != livekit.ParticipantInfo_STANDARD {
+			continue
+		}
+		if identity != "" && p.Identity != identity {
+			continue
+		}
+
+		permission := &livekit.ParticipantPermission{
+			CanSubscribe:      true,
+			CanPublish:        true,
+			CanPublishData:    true,
+			CanPublishSources: publishSources(call.CallType, s.canScreenShare(call, p.Identity)),
+		}
+		if p.Permission != nil {
+			permission.CanSubscribe = p.Permission.CanSubscribe
+			permission.CanPublish = p.Permission.CanPublish
+			permission.CanPublishData = p.Permission.CanPublishData
+		}
+		if err := s.participantService.UpdateParticipant(call.RoomName, p.Identity, permission, ""); err != nil {
+			fmt.Printf("Failed to update permissions of %s: %v\n", p.Identity, err)
+		}
+	}
+}
+
+func (s *CallService) generateToken(roomName, identity string, opts tokenOptions) (string, error) {
 	at := auth.NewAccessToken(s.config.APIKey, s.config.APISecret)
 	grant := &auth.VideoGrant{
 		RoomJoin:   true,
 		RoomCreate: true,
 		Room:       roomName,
 	}
+	validFor := 24 * time.Hour
+
+	if opts.Role == "guest" {
+		// Guests may only join the room they were linked to
+		grant.RoomCreate = false
+		grant.SetCanSubscribe(true)
+		grant.SetCanPublish(true)
+		grant.SetCanPublishData(true)
+		validFor = 6 * time.Hour
+	}
+	if opts.CallType != "" {
+		grant.SetCanPublishSources(publishSources(opts.CallType, opts.ScreenShare))
+	}
+	if opts.Role != "" {
+		at.SetAttributes(map[string]string{"role": opts.Role})
+	}
+	if opts.Name != "" {
+		at.SetName(opts.Name)
+	}
+
 	at.SetVideoGrant(grant).
 		SetIdentity(identity).
-		SetValidFor(24 * time.Hour)
+		SetValidFor(validFor)
 
 	token, err := at.ToJWT()
 	if err != nil {
@@ -267,7 +683,22 @@ func (s *CallService) EndCall(callID string, userID int64) error {
 		return fmt.Errorf("failed to get call: %w", err)
 	}
 	if call == nil {
-		return fmt.Errorf("call not found")
+		return ErrCallNotFound
+	}
+	if call.Status != "active" {
+		return ErrCallNotActive
+	}
+
+	// Only the host and those who accepted an invitation may end it for everyone
+	invitationRepo := database.NewInvitationRepo(s.db)
+	invitations, err := invitationRepo.GetCallParticipants(callID)
+	if err != nil {
+		return fmt.Errorf("failed to get invitations: %w", err)
+	}
+	if call.CreatedBy != userID && !slices.ContainsFunc(invitations, func(invitation *models.Invitation) bool {
+		return invitation.InviteeID == userID && invitation.Status == "accepted"
+	}) {
+		return ErrNotCallHost
 	}
 
 	participants, err := s.participantService.ListParticipants(call.RoomName)
@@ -278,15 +709,39 @@ func (s *CallService) EndCall(callID string, userID int64) error {
 	participantNames := make([]string, 0, len(participants))
 
 	for _, p := range participants {
-		if p.Identity != "" {
-			participantNames = append(participantNames, p.Identity)
+		if p.Identity == "" {
+			continue
+		}
+		// Phone participants have no account to notify but belong in the history
+		if p.Kind == livekit.ParticipantInfo_SIP {
+			if err := s.historyService.RecordSIPParticipant(callID, p.Identity, "accepted"); err != nil {
+				fmt.Printf("Failed to record SIP participant in call history: %v\n", err)
+			}
+			continue
 		}
+		participantNames = append(participantNames, p.Identity)
 	}
 
 	startedAt := call.CreatedAt
 	endedAt := time.Now()
 	duration := int(endedAt.Sub(startedAt).Seconds())
 
+	// Invitees who never answered or were never admitted missed the call
+	var missedUsernames []string
+	for _, invitation := range invitations {
+		if invitation.Status != "pending" && invitation.Status != "lobby" {
+			continue
+		}
+		if err := invitationRepo.UpdateStatus(invitation.ID, "missed"); err != nil {
+			fmt.Printf("Failed to update invitation %d status: %v\n", invitation.ID, err)
+			continue
+		}
+		missedUsernames = append(missedUsernames, invitation.Invitee)
+	}
+	if err := s.historyService.CloseOutcomes(callID, "missed"); err != nil {
+		fmt.Printf("Failed to close call history outcomes: %v\n", err)
+	}
+
 	// Check if history entry already exists (created when call was initiated)
 	existingHistory, err := s.historyService.GetCallDetails(callID)
 	if err != nil || existingHistory == nil {
@@ -296,8 +751,13 @@ func (s *CallService) EndCall(callID string, userID int64) error {
 		}
 	}
 
-	// Update history entry with completed status
-	if err := s.historyService.UpdateHistoryEntry(callID, endedAt, duration, "completed"); err != nil {
+	status, err := s.historyService.DeriveStatus(callID, true)
+	if err != nil {
+		fmt.Printf("Failed to derive call history status: %v\n", err)
+		status = "completed"
+	}
+
+	if err := s.historyService.UpdateHistoryEntry(callID, endedAt, duration, status); err != nil {
 		return fmt.Errorf("failed to update history entry: %w", err)
 	}
 
@@ -305,11 +765,24 @@ func (s *CallService) EndCall(callID string, userID int64) error {
 		return fmt.Errorf("failed to update call status: %w", err)
 	}
 
+	lobbyRepo := database.NewLobbyRepo(s.db)
+	if err := lobbyRepo.CloseWaiting(callID); err != nil {
+		fmt.Printf("Failed to close lobby for call %s: %v\n", callID, err)
+	}
+
+	screenShareRepo := database.NewScreenShareRepo(s.db)
+	if err := screenShareRepo.CloseOpen(callID); err != nil {
+		fmt.Printf("Failed to close screen share requests for call %s: %v\n", callID, err)
+	}
+
+	call.Status = "ended"
+	s.runCallEndedHooks(call)
+
 	// Broadcast call_ended event to all participants
 	if s.wsHub != nil {
-		for _, participantName := range participantNames {
-			s.wsHub.BroadcastCallEnded(participantName, callID)
-		}
+		// Missed invitees are included to stop their ringing
+		recipients := append(participantNames, missedUsernames...)
+		s.wsHub.Publish(context.Background(), recipients, events.CallEnded{CallID: callID})
 	}
 
 	return nil
@@ -324,6 +797,9 @@ func (s *CallService) CancelCall(callID string, userID int64) error {
 	if call == nil {
 		return fmt.Errorf("call not found")
 	}
+	if call.Status != "active" {
+		return ErrCallNotActive
+	}
 
 	// Only creator can cancel the call
 	if call.CreatedBy != userID {
@@ -337,11 +813,11 @@ func (s *CallService) CancelCall(callID string, userID int64) error {
 		return fmt.Errorf("failed to get invitations: %w", err)
 	}
 
-	// Update all pending invitations to cancelled
+	// Update all pending invitations, including those waiting in the lobby, to cancelled
 	userRepo := database.NewUserRepo(s.db)
 	var inviteeUsernames []string
 	for _, invitation := range invitations {
-		if invitation.Status == "pending" {
+		if invitation.Status == "pending" || invitation.Status == "lobby" {
 			if err := invitationRepo.UpdateStatus(invitation.ID, "cancelled"); err != nil {
 				fmt.Printf("Failed to update invitation %d status: %v\n", invitation.ID, err)
 				continue
@@ -359,6 +835,23 @@ func (s *CallService) CancelCall(callID string, userID int64) error {
 		return fmt.Errorf("failed to update call status: %w", err)
 	}
 
+	lobbyRepo := database.NewLobbyRepo(s.db)
+	if err := lobbyRepo.CloseWaiting(callID); err != nil {
+		fmt.Printf("Failed to close lobby for call %s: %v\n", callID, err)
+	}
+
+	screenShareRepo := database.NewScreenShareRepo(s.db)
+	if err := screenShareRepo.CloseOpen(callID); err != nil {
+		fmt.Printf("Failed to close screen share requests for call %s: %v\n", callID, err)
+	}
+
+	if err := s.historyService.CloseOutcomes(callID, "cancelled"); err != nil {
+		fmt.Printf("Failed to close call history outcomes: %v\n", err)
+	}
+
+	call.Status = "cancelled"
+	s.runCallEndedHooks(call)
+
 	// Update call history status to cancelled
 	if err := s.historyService.UpdateHistoryEntry(callID, time.Time{}, 0, "cancelled"); err != nil {
 		fmt.Printf("Failed to update call history status: %v\n", err)
@@ -366,10 +859,138 @@ func (s *CallService) CancelCall(callID string, userID int64) error {
 
 	// Broadcast call_cancelled event to all invitees
 	if s.wsHub != nil {
-		for _, inviteeUsername := range inviteeUsernames {
-			s.wsHub.BroadcastCallCancelled(inviteeUsername, callID)
-		}
+		s.wsHub.Publish(context.Background(), inviteeUsernames, events.CallCancelled{CallID: callID})
 	}
 
 	return nil
 }
+
+// reportUndelivered tells the host when a participant never acknowledged an
+// invitation or the end of their call
+func (s *CallService) reportUndelivered(username string, msgType string, data json.RawMessage) {
+	var payload struct {
+		CallID       string `json:"callId"`
+		InvitationID int64  `json:"invitationId"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil || payload.CallID == "" {
+		return
+	}
+
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(payload.CallID)
+	if err != nil || call == nil {
+		return
+	}
+	userRepo := database.NewUserRepo(s.db)
+	host, err := userRepo.GetByID(call.CreatedBy)
+	if err != nil || host == nil || host.Username == username {
+		return
+	}
+
+	s.wsHub.Publish(context.Background(), []string{host.Username}, events.DeliveryFailed{
+		Event:        msgType,
+		Recipient:    username,
+		CallID:       payload.CallID,
+		InvitationID: payload.InvitationID,
+	})
+}
+
+// refreshHistoryStatus re-derives the history status of a call that is still
+// in progress from the outcomes recorded so far
+func (s *CallService) refreshHistoryStatus(callID string) {
+	status, err := s.historyService.DeriveStatus(callID, false)
+	if err != nil {
+		fmt.Printf("Failed to derive call history status: %v\n", err)
+		return
+	}
+
+	endedAt := time.Time{}
+	if status == "rejected" {
+		endedAt = time.Now()
+	}
+	if err := s.historyService.UpdateHistoryEntry(callID, endedAt, 0, status); err != nil {
+		fmt.Printf("Failed to update call history status: %v\n", err)
+	}
+}
+
+// canViewCall reports whether a user may read what a call left behind: the
+// host, members of the running call and, also after it has ended, invitees
+// who accepted and anyone recorded as attending. Invitees who declined,
+// missed the call or were never admitted from the lobby may not.
+func (s *CallService) canViewCall(callID string, userID int64, username string) (bool, error) {
+	history, err := s.historyService.GetCallDetailsForUser(callID, userID)
+	if err != nil {
+		return false, err
+	}
+	if history != nil {
+		if history.CreatedBy == userID {
+			return true, nil
+		}
+		invited := false
+		for _, outcome := range history.Outcomes {
+			if outcome.Role != "invitee" || outcome.UserID != userID {
+				continue
+			}
+			if outcome.Outcome == "accepted" {
+				return true, nil
+			}
+			invited = true
+		}
+		// The participants list also names every invitee, so it only counts
+		// for those who joined without an invitation, e.g. into a breakout
+		if !invited {
+			if participants, err := s.historyService.ParseParticipants(history.Participants); err == nil && slices.Contains(participants, username) {
+				return true, nil
+			}
+		}
+	}
+
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(callID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return false, nil
+	}
+	return call.CreatedBy == userID || slices.Contains(s.callMemberUsernames(call), username), nil
+}
+
+// callMemberUsernames lists everyone who should hear about in-call events: the
+// host, invitees who accepted and whoever is currently connected to the room
+func (s *CallService) callMemberUsernames(call *models.ActiveCall) []string {
+	seen := make(map[string]bool)
+	var usernames []string
+	add := func(username string) {
+		if username != "" && !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+
+	userRepo := database.NewUserRepo(s.db)
+	if host, err := userRepo.GetByID(call.CreatedBy); err == nil && host != nil {
+		add(host.Username)
+	}
+
+	invitationRepo := database.NewInvitationRepo(s.db)
+	invitations, err := invitationRepo.GetCallParticipants(call.CallID)
+	if err != nil {
+		fmt.Printf("Failed to get invitations for call %s: %v\n", call.CallID, err)
+	}
+	for _, invitation := range invitations {
+		if invitation.Status == "accepted" {
+			add(invitation.Invitee)
+		}
+	}
+
+	participants, err := s.participantService.ListParticipants(call.RoomName)
+	if err != nil {
+		fmt.Printf("Failed to list participants for room %s: %v\n", call.RoomName, err)
+	}
+	for _, p := range participants {
+		add(p.Identity)
+	}
+
+	return usernames
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"slices"
+	"testing"
+
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+func TestEndCallAuthorization(t *testing.T) {
+	tests := []struct {
+		name    string
+		caller  string
+		wantErr error
+	}{
+		{name: "host", caller: "host"},
+		{name: "accepted invitee", caller: "accepted"},
+		{name: "invitee still ringing", caller: "ringing", wantErr: ErrNotCallHost},
+		{name: "invitee who rejected", caller: "rejected", wantErr: ErrNotCallHost},
+		{name: "outsider", caller: "outsider", wantErr: ErrNotCallHost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			s := newTestCallService(t, db, newFakeRoomClient(), nil)
+			users := make(map[string]*models.User)
+			for _, username := range []string{"host", "accepted", "ringing", "rejected", "outsider"} {
+				users[username] = createTestUser(t, db, username)
+			}
+			callID := startTestCall(t, s, users["host"], "accepted", "ringing", "rejected")
+
+			invitationRepo := database.NewInvitationRepo(db)
+			invitations, err := invitationRepo.GetCallParticipants(callID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, invitation := range invitations {
+				if invitation.Invitee != "ringing" {
+					if err := invitationRepo.UpdateStatus(invitation.ID, invitation.Invitee); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			err = s.EndCall(callID, users[tt.caller].ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("EndCall error = %v, want %v", err, tt.wantErr)
+			}
+
+			call, err := database.NewCallRepo(db).GetByCallID(callID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantStatus := "ended"
+			if tt.wantErr != nil {
+				wantStatus = "active"
+			}
+			if call.Status != wantStatus {
+				t.Errorf("call is %s, want %s", call.Status, wantStatus)
+			}
+		})
+	}
+
+	t.Run("unknown call", func(t *testing.T) {
+		db := newTestDB(t)
+		s := newTestCallService(t, db, newFakeRoomClient(), nil)
+		host := createTestUser(t, db, "host")
+		if err := s.EndCall("unknown", host.ID); !errors.Is(err, ErrCallNotFound) {
+			t.Errorf("EndCall error = %v, want %v", err, ErrCallNotFound)
+		}
+	})
+}
+
+func TestEndedCallCannotBeEndedAgain(t *testing.T) {
+	tests := []struct {
+		name   string
+		finish func(s *CallService, callID string, hostID int64) error
+	}{
+		{name: "ended", finish: func(s *CallService, callID string, hostID int64) error {
+			return s.EndCall(callID, hostID)
+		}},
+		{name: "cancelled", finish: func(s *CallService, callID string, hostID int64) error {
+			return s.CancelCall(callID, hostID)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			s := newTestCallService(t, db, newFakeRoomClient(), nil)
+			host := createTestUser(t, db, "host")
+			createTestUser(t, db, "alice")
+			callID := startTestCall(t, s, host, "alice")
+
+			var ended int
+			s.OnCallEnded(func(call *models.ActiveCall) { ended++ })
+
+			if err := tt.finish(s, callID, host.ID); err != nil {
+				t.Fatalf("first end: %v", err)
+			}
+			if err := s.EndCall(callID, host.ID); !errors.Is(err, ErrCallNotActive) {
+				t.Errorf("EndCall error = %v, want %v", err, ErrCallNotActive)
+			}
+			if err := s.CancelCall(callID, host.ID); !errors.Is(err, ErrCallNotActive) {
+				t.Errorf("CancelCall error = %v, want %v", err, ErrCallNotActive)
+			}
+			if ended != 1 {
+				t.Errorf("call ended hooks ran %d times, want 1", ended)
+			}
+		})
+	}
+}
+
+func TestCanViewEndedCall(t *testing.T) {
+	db := newTestDB(t)
+	s := newTestCallService(t, db, newFakeRoomClient(), nil)
+	users := make(map[string]*models.User)
+	for _, username := range []string{"host", "accepted", "rejected", "missed", "attendee", "outsider"} {
+		users[username] = createTestUser(t, db, username)
+	}
+	callID := startTestCall(t, s, users["host"], "accepted", "rejected", "missed")
+
+	invitations, err := database.NewInvitationRepo(db).GetCallParticipants(callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, invitation := range invitations {
+		action := map[string]string{"accepted": "accept", "rejected": "reject"}[invitation.Invitee]
+		if action == "" {
+			continue
+		}
+		if _, err := s.RespondToInvitation(invitation.ID, invitation.InviteeID, action); err != nil {
+			t.Fatalf("RespondToInvitation: %v", err)
+		}
+	}
+	// Someone who only joined a breakout room is recorded as attending
+	if err := s.historyService.AddParticipants(callID, []string{"attendee"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EndCall(callID, users["host"].ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+
+	want := map[string]bool{
+		"host":     true,
+		"accepted": true,
+		"attendee": true,
+		"rejected": false,
+		"missed":   false,
+		"outsider": false,
+	}
+	for username, wantAllowed := range want {
+		allowed, err := s.canViewCall(callID, users[username].ID, username)
+		if err != nil {
+			t.Fatalf("canViewCall(%s): %v", username, err)
+		}
+		if allowed != wantAllowed {
+			t.Errorf("%s may view the call: %v, want %v", username, allowed, wantAllowed)
+		}
+	}
+}
+
+func TestChangeCallType(t *testing.T) {
+	db := newTestDB(t)
+	rooms := newFakeRoomClient()
+	wsHub := websocket.NewWebSocketHub()
+	s := newTestCallService(t, db, rooms, wsHub)
+	host := createTestUser(t, db, "host")
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	callID := startTestCall(t, s, host, "alice", "bob")
+
+	invitations, err := database.NewInvitationRepo(db).GetCallParticipants(callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invitationIDs := make(map[string]int64)
+	for _, invitation := range invitations {
+		invitationIDs[invitation.Invitee] = invitation.ID
+	}
+	joined, err := s.RespondToInvitation(invitationIDs["alice"], alice.ID, "accept")
+	if err != nil {
+		t.Fatalf("RespondToInvitation: %v", err)
+	}
+	video := []livekit.TrackSource{
+		livekit.TrackSource_MICROPHONE, livekit.TrackSource_CAMERA,
+		livekit.TrackSource_SCREEN_SHARE, livekit.TrackSource_SCREEN_SHARE_AUDIO,
+	}
+	if got := tokenSources(t, joined.Token); !slices.Equal(got, video) {
+		t.Errorf("video call token allows %v, want %v", got, video)
+	}
+	rooms.join(joined.RoomName, &livekit.ParticipantInfo{Identity: "host"})
+	rooms.join(joined.RoomName, &livekit.ParticipantInfo{Identity: "alice"})
+	conn := &websocket.Connection{ID: "alice", Username: "alice", Send: make(chan []byte, 64)}
+	wsHub.Register("alice", conn)
+
+	if _, err := s.ChangeCallType(callID, alice.ID, "voice"); !errors.Is(err, ErrNotCallHost) {
+		t.Errorf("ChangeCallType by a participant: %v, want ErrNotCallHost", err)
+	}
+	if _, err := s.ChangeCallType(callID, host.ID, "fax"); !errors.Is(err, ErrInvalidCallType) {
+		t.Errorf("ChangeCallType to fax: %v, want ErrInvalidCallType", err)
+	}
+
+	call, err := s.ChangeCallType(callID, host.ID, "voice")
+	if err != nil {
+		t.Fatalf("ChangeCallType: %v", err)
+	}
+	if call.CallType != "voice" {
+		t.Errorf("call type %s, want voice", call.CallType)
+	}
+	voice := []livekit.TrackSource{livekit.TrackSource_MICROPHONE}
+	for _, identity := range []string{"host", "alice"} {
+		if got := rooms.permission(joined.RoomName, identity); got == nil || !slices.Equal(got.CanPublishSources, voice) {
+			t.Errorf("%s may publish %v after switching to voice, want %v", identity, got, voice)
+		}
+	}
+	// Tokens minted from now on carry the new call type
+	late, err := s.RespondToInvitation(invitationIDs["bob"], bob.ID, "accept")
+	if err != nil {
+		t.Fatalf("RespondToInvitation: %v", err)
+	}
+	if got := tokenSources(t, late.Token); !slices.Equal(got, voice) {
+		t.Errorf("voice call token allows %v, want %v", got, voice)
+	}
+	history, err := s.historyService.GetCallDetails(callID)
+	if err != nil || history == nil || history.CallType != "voice" {
+		t.Errorf("history call type %v, %v; want voice", history, err)
+	}
+
+	var changed []events.CallTypeChanged
+	for _, envelope := range drain(t, conn) {
+		if envelope.Type != "call_type_changed" {
+			continue
+		}
+		var event events.CallTypeChanged
+		if err := json.Unmarshal(envelope.Data, &event); err != nil {
+			t.Fatal(err)
+		}
+		changed = append(changed, event)
+	}
+	want := events.CallTypeChanged{CallID: callID, CallType: "voice", ChangedBy: "host"}
+	if len(changed) != 1 || changed[0] != want {
+		t.Errorf("call_type_changed events %+v, want %+v", changed, want)
+	}
+
+	if _, err := s.ChangeCallType(callID, host.ID, "video"); err != nil {
+		t.Fatalf("ChangeCallType: %v", err)
+	}
+	if got := rooms.permission(joined.RoomName, "alice"); got == nil || !slices.Equal(got.CanPublishSources, video) {
+		t.Errorf("alice may publish %v after switching back to video, want %v", got, video)
+	}
+
+	if err := s.EndCall(callID, host.ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+	if _, err := s.ChangeCallType(callID, host.ID, "voice"); !errors.Is(err, ErrCallNotActive) {
+		t.Errorf("ChangeCallType on an ended call: %v, want ErrCallNotActive", err)
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxChatMessageLength = 4000
+
+var ErrInvalidChatMessage = errors.New("message must be between 1 and 4000 characters")
+
+// chatMessageRequest is the payload of a "chat_message" socket message
+type chatMessageRequest struct {
+	CallID string `json:"callId"`
+	Body   string `json:"body"`
+}
+
+type ChatService struct {
+	db          *database.DB
+	messageRepo *database.CallMessageRepo
+	callRepo    *database.CallRepo
+	userRepo    *database.UserRepo
+	callService *CallService
+	wsHub       *websocket.WebSocketHub
+}
+
+func NewChatService(db *database.DB, callService *CallService, wsHub *websocket.WebSocketHub) *ChatService {
+	s := &ChatService{
+		db:          db,
+		messageRepo: database.NewCallMessageRepo(db),
+		callRepo:    database.NewCallRepo(db),
+		userRepo:    database.NewUserRepo(db),
+		callService: callService,
+		wsHub:       wsHub,
+	}
+	if wsHub != nil {
+		wsHub.OnClientMessage("chat_message", s.handleSocketMessage)
+	}
+	return s
+}
+
+// SendMessage stores a chat message for an active call and fans it out to
+// everyone in the call, including the sender's other devices
+func (s *ChatService) SendMessage(callID string, userID int64, username, body string) (*models.CallMessage, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > maxChatMessageLength {
+		return nil, ErrInvalidChatMessage
+	}
+
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+
+	members := s.callService.callMemberUsernames(call)
+	if call.CreatedBy != userID && !slices.Contains(members, username) {
+		return nil, ErrCallNotFound
+	}
+	if call.Status == "ended" || call.Status == "cancelled" {
+		return nil, ErrCallNotActive
+	}
+
+	message, err := s.messageRepo.Create(callID, userID, username, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.wsHub != nil {
+		s.wsHub.Publish(context.Background(), members, events.ChatMessage{CallMessage: message})
+	}
+
+	return message, nil
+}
+
+// GetMessages returns a page of a call's chat. Members of a running call and
+// anyone in the call's history can read it, also after the call has ended.
+func (s *ChatService) GetMessages(callID string, userID int64, username string, limit, offset int) ([]*models.CallMessage, error) {
+	allowed, err := s.callService.canViewCall(callID, userID, username)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrCallNotFound
+	}
+
+	return s.messageRepo.GetByCallID(callID, limit, offset)
+}
+
+func (s *ChatService) handleSocketMessage(username string, data json.RawMessage) error {
+	var req chatMessageRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.CallID == "" {
+		return fmt.Errorf("callId and body are required")
+	}
+
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	_, err = s.SendMessage(req.CallID, user.ID, username, req.Body)
+	return err
+}
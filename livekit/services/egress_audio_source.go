@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+	"time"
+
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+const (
+	// Track egress streams audio to a websocket as raw 16-bit little-endian PCM
+	egressAudioSampleRate = 48000
+	egressAudioChannels   = 1
+
+	audioChunkDuration = 5 * time.Second
+	trackPollInterval  = 10 * time.Second
+)
+
+var ErrUnknownAudioStream = errors.New("unknown audio stream")
+
+// EgressAudioSource captures audio with one track egress per published
+// microphone track. Egress connects back to AudioURL over a websocket, which
+// is served by handlers.HandleTranscriptionAudio and fed into OpenStream.
+//
+// The streams only live in the memory of the instance that started the
+// transcription, so AudioURL must reach that instance: with several API
+// instances, point it at each instance's own address rather than a shared
+// load balancer, or egress connections landing elsewhere are refused with
+// ErrUnknownAudioStream. Ending the call on any instance stops the egresses
+// through the hub's call ended announcement.
+type EgressAudioSource struct {
+	egressClient       EgressClient
+	participantService *ParticipantService
+	audioURL           string
+
+	mu      sync.Mutex
+	streams map[string]*audioStream // stream key -> stream
+}
+
+type audioStream struct {
+	identity string
+	egressID string
+	sink     func(AudioChunk)
+}
+
+func NewEgressAudioSource(egressClient EgressClient, callService *CallService, audioURL string) *EgressAudioSource {
+	return &EgressAudioSource{
+		egressClient:       egressClient,
+		participantService: callService.participantService,
+		audioURL:           audioURL,
+		streams:            make(map[string]*audioStream),
+	}
+}
+
+// Start captures the audio tracks in the room now and polls for tracks
+// published later, e.g. by late joiners
+func (s *EgressAudioSource) Start(roomName string, sink func(AudioChunk)) (func(), error) {
+	if s.audioURL == "" {
+		return nil, ErrTranscriptionNotConfigured
+	}
+
+	captured := make(map[string]string) // track SID -> stream key
+	if err := s.captureTracks(roomName, sink, captured); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(trackPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.captureTracks(roomName, sink, captured); err != nil {
+					fmt.Printf("Failed to capture audio tracks in room %s: %v\n", roomName, err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			for _, key := range captured {
+				s.stopStream(key)
+			}
+		})
+	}
+	return stop, nil
+}
+
+// OpenStream attaches the audio an egress sends for key. Writes are buffered
+// into chunks for the transcriber; Close flushes the rest.
+func (s *EgressAudioSource) OpenStream(key string) (io.WriteCloser, error) {
+	s.mu.Lock()
+	stream, ok := s.streams[key]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownAudioStream
+	}
+	return &audioChunker{identity: stream.identity, sink: stream.sink}, nil
+}
+
+func (s *EgressAudioSource) captureTracks(roomName string, sink func(AudioChunk), captured map[string]string) error {
+	participants, err := s.participantService.ListParticipants(roomName)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range participants {
+		for _, track := range p.Tracks {
+			if track.Type != livekit.TrackType_AUDIO {
+				continue
+			}
+			if _, ok := captured[track.Sid]; ok {
+				continue
+			}
+
+			key, err := randomStreamKey()
+			if err != nil {
+				return err
+			}
+			info, err := s.egressClient.StartTrackEgress(context.Background(), &livekit.TrackEgressRequest{
+				RoomName: roomName,
+				TrackId:  track.Sid,
+				Output:   &livekit.TrackEgressRequest_WebsocketUrl{WebsocketUrl: s.audioURL + "?key=" + url.QueryEscape(key)},
+			})
+			if err != nil {
+				fmt.Printf("Failed to start audio egress for track %s: %v\n", track.Sid, err)
+				continue
+			}
+
+			s.mu.Lock()
+			s.streams[key] = &audioStream{identity: p.Identity, egressID: info.EgressId, sink: sink}
+			s.mu.Unlock()
+			captured[track.Sid] = key
+		}
+	}
+	return nil
+}
+
+func (s *EgressAudioSource) stopStream(key string) {
+	s.mu.Lock()
+	stream, ok := s.streams[key]
+	delete(s.streams, key)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	// The egress may already be gone if the participant left
+	if _, err := s.egressClient.StopEgress(context.Background(), &livekit.StopEgressRequest{EgressId: stream.egressID}); err != nil {
+		fmt.Printf("Failed to stop audio egress %s: %v\n", stream.egressID, err)
+	}
+}
+
+// audioChunker cuts a PCM stream into fixed length chunks
+type audioChunker struct {
+	identity  string
+	sink      func(AudioChunk)
+	buf       []byte
+	startedAt time.Time
+}
+
+func (c *audioChunker) Write(p []byte) (int, error) {
+	if len(c.buf) == 0 {
+		c.startedAt = time.Now()
+	}
+	c.buf = append(c.buf, p...)
+
+	chunkSize := int(audioChunkDuration.Seconds()) * egressAudioSampleRate * egressAudioChannels * 2
+	for len(c.buf) >= chunkSize {
+		c.emit(c.buf[:chunkSize])
+		c.buf = c.buf[chunkSize:]
+		c.startedAt = c.startedAt.Add(audioChunkDuration)
+	}
+	return len(p), nil
+}
+
+func (c *audioChunker) Close() error {
+	if len(c.buf) > 0 {
+		c.emit(c.buf)
+		c.buf = nil
+	}
+	return nil
+}
+
+func (c *audioChunker) emit(samples []byte) {
+	c.sink(AudioChunk{
+		Identity:   c.identity,
+		Samples:    append([]byte(nil), samples...),
+		SampleRate: egressAudioSampleRate,
+		Channels:   egressAudioChannels,
+		StartedAt:  c.startedAt,
+	})
+}
+
+func randomStreamKey() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate stream key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,12 @@
+package services
+
+import "errors"
+
+var (
+	ErrCallNotFound          = errors.New("call not found")
+	ErrScheduledCallNotFound = errors.New("scheduled call not found")
+	ErrNotCallHost           = errors.New("unauthorized: only the host can manage this call")
+	ErrCallNotStarted        = errors.New("call has not started yet")
+	ErrCallNotActive         = errors.New("call is not active")
+	ErrInvalidCallType       = errors.New("callType must be 'video' or 'voice'")
+)
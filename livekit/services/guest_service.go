@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"livekit/auth"
+	"livekit/database"
+	"livekit/models"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const maxGuestLinkTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidGuestLink    = errors.New("invalid or expired guest link")
+	ErrGuestAccessDisabled = errors.New("guest access is disabled for this call")
+	ErrInvalidGuestName    = errors.New("displayName must be between 1 and 64 characters")
+)
+
+type GuestServiceConfig struct {
+	LinkBaseURL string
+	LinkTTL     time.Duration
+}
+
+type GuestService struct {
+	db                *database.DB
+	config            *GuestServiceConfig
+	callRepo          *database.CallRepo
+	scheduledCallRepo *database.ScheduledCallRepo
+	callService       *CallService
+}
+
+type GuestLinkResult struct {
+	Link      string    `json:"link"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type GuestJoinResult struct {
+	Token          string `json:"token,omitempty"`
+	RoomName       string `json:"roomName"`
+	CallID         string `json:"callId"`
+	Identity       string `json:"identity"`
+	DisplayName    string `json:"displayName"`
+	State          string `json:"state"` // "joined", "lobby"
+	LobbyRequestID int64  `json:"lobbyRequestId,omitempty"`
+	WaitToken      string `json:"waitToken,omitempty"` // Proves ownership when polling the lobby status
+}
+
+func NewGuestService(db *database.DB, cfg *GuestServiceConfig, callService *CallService) *GuestService {
+	return &GuestService{
+		db:                db,
+		config:            cfg,
+		callRepo:          database.NewCallRepo(db),
+		scheduledCallRepo: database.NewScheduledCallRepo(db),
+		callService:       callService,
+	}
+}
+
+func (s *GuestService) CreateCallGuestLink(callID string, userID int64, ttl time.Duration) (*GuestLinkResult, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+
+	return s.createLink(callID, 0, time.Time{}, ttl)
+}
+
+func (s *GuestService) CreateScheduledCallGuestLink(scheduledCallID, userID int64, ttl time.Duration) (*GuestLinkResult, error) {
+	call, err := s.scheduledCallRepo.GetByID(scheduledCallID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrScheduledCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+	if call.Status == "cancelled" || call.Status == "completed" {
+		return nil, fmt.Errorf("scheduled call is %s", call.Status)
+	}
+
+	// Keep the link usable until the meeting is over
+	if ttl <= 0 {
+		ttl = s.config.LinkTTL
+		if untilStart := time.Until(call.ScheduledAt); untilStart > 0 {
+			ttl += untilStart
+		}
+	}
+
+	return s.createLink("", scheduledCallID, call.ScheduledAt, ttl)
+}
+
+// JoinAsGuest exchanges a guest link for a restricted LiveKit token. client
+// identifies the caller (e.g. its address) for passcode attempt limiting.
+func (s *GuestService) JoinAsGuest(linkToken, displayName, passcode, client string) (*GuestJoinResult, error) {
+	displayName = strings.TrimSpace(displayName)
+	if displayName == "" || utf8.RuneCountInString(displayName) > 64 {
+		return nil, ErrInvalidGuestName
+	}
+
+	claims, err := auth.ValidateGuestLinkToken(linkToken)
+	if err != nil {
+		return nil, ErrInvalidGuestLink
+	}
+
+	call, err := s.resolveCall(claims)
+	if err != nil {
+		return nil, err
+	}
+	if !call.GuestAccessEnabled {
+		return nil, ErrGuestAccessDisabled
+	}
+	if err := s.callService.verifyPasscode(call.PasscodeHash, passcode, "call:"+call.CallID, "guest:"+client); err != nil {
+		return nil, err
+	}
+
+	identity := "guest-" + strings.ReplaceAll(uuid.New().String(), "-", "")[:12]
+
+	if call.LobbyEnabled {
+		request, err := s.callService.requestLobbyAdmission(call, 0, identity, displayName, "guest")
+		if err != nil {
+			return nil, fmt.Errorf("failed to enter lobby: %w", err)
+		}
+		return &GuestJoinResult{
+			RoomName:       call.RoomName,
+			CallID:         call.CallID,
+			Identity:       identity,
+			DisplayName:    displayName,
+			State:          "lobby",
+			LobbyRequestID: request.ID,
+			WaitToken:      request.WaitSecret,
+		}, nil
+	}
+
+	token, err := s.callService.generateToken(call.RoomName, identity, tokenOptions{
+		Name:        displayName,
+		Role:        "guest",
+		CallType:    call.CallType,
+		ScreenShare: s.callService.canScreenShare(call, identity),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	if err := s.callService.historyService.RecordGuest(call.CallID, identity); err != nil {
+		fmt.Printf("Failed to record guest in call history: %v\n", err)
+	}
+
+	return &GuestJoinResult{
+		Token:       token,
+		RoomName:    call.RoomName,
+		CallID:      call.CallID,
+		Identity:    identity,
+		DisplayName: displayName,
+		State:       "joined",
+	}, nil
+}
+
+// resolveCall finds the running call a guest link points at. Links for
+// scheduled calls resolve to the call started for the linked occurrence; the
+// room alone would not do, as a recurring schedule reuses it.
+func (s *GuestService) resolveCall(claims *auth.GuestLinkClaims) (*models.ActiveCall, error) {
+	var call *models.ActiveCall
+	var err error
+
+	if claims.ScheduledCallID != 0 {
+		scheduled, err := s.scheduledCallRepo.GetByID(claims.ScheduledCallID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get scheduled call: %w", err)
+		}
+		if scheduled == nil || scheduled.Status == "cancelled" {
+			return nil, ErrScheduledCallNotFound
+		}
+		if !scheduled.GuestAccessEnabled {
+			return nil, ErrGuestAccessDisabled
+		}
+
+		call, err = s.callRepo.GetByScheduledOccurrence(scheduled.ID, time.Unix(claims.Occurrence, 0))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get call: %w", err)
+		}
+		if call == nil {
+			return nil, ErrCallNotStarted
+		}
+	} else {
+		call, err = s.callRepo.GetByCallID(claims.CallID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get call: %w", err)
+		}
+		if call == nil {
+			return nil, ErrCallNotFound
+		}
+	}
+
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+
+	return call, nil
+}
+
+func (s *GuestService) createLink(callID string, scheduledCallID int64, occurrence time.Time, ttl time.Duration) (*GuestLinkResult, error) {
+	if ttl <= 0 {
+		ttl = s.config.LinkTTL
+	}
+	if ttl > maxGuestLinkTTL {
+		ttl = maxGuestLinkTTL
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	token, err := auth.GenerateGuestLinkToken(callID, scheduledCallID, occurrence, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign guest link: %w", err)
+	}
+
+	separator := "?"
+	if strings.Contains(s.config.LinkBaseURL, "?") {
+		separator = "&"
+	}
+
+	return &GuestLinkResult{
+		Link:      s.config.LinkBaseURL + separator + "token=" + url.QueryEscape(token),
+		Token:     token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
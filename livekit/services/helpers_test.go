@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"livekit/database"
+	"livekit/models"
+	"livekit/websocket"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	lkauth "github.com/livekit/protocol/auth"
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+// newTestDB opens a fresh, migrated database in the test's temp directory
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "test.db"))
+	t.Setenv("SECRETS_ENCRYPTION_KEY", "test-secrets-key")
+	db, err := database.NewDB()
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func createTestUser(t *testing.T, db *database.DB, username string) *models.User {
+	t.Helper()
+
+	user, err := database.NewUserRepo(db).Create(username, "hash")
+	if err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+// createTestCall starts an active call hosted by the user, without a LiveKit room
+func createTestCall(t *testing.T, db *database.DB, host *models.User, callType string) *models.ActiveCall {
+	t.Helper()
+
+	call, err := database.NewCallRepo(db).Create(uuid.New().String(), uuid.New().String(), callType, host.ID)
+	if err != nil {
+		t.Fatalf("create call: %v", err)
+	}
+	return call
+}
+
+// endTestCall marks the call as ended in the database only
+func endTestCall(t *testing.T, db *database.DB, callID string) {
+	t.Helper()
+
+	if err := database.NewCallRepo(db).UpdateStatus(callID, "ended"); err != nil {
+		t.Fatalf("end call: %v", err)
+	}
+}
+
+// fakeRoomClient keeps rooms and their participants in memory
+type fakeRoomClient struct {
+	mu           sync.Mutex
+	rooms        map[string]bool
+	participants map[string][]*livekit.ParticipantInfo // Room name -> participants
+}
+
+func newFakeRoomClient() *fakeRoomClient {
+	return &fakeRoomClient{
+		rooms:        make(map[string]bool),
+		participants: make(map[string][]*livekit.ParticipantInfo),
+	}
+}
+
+// join puts a participant into a room as if they had connected to it
+func (c *fakeRoomClient) join(roomName string, participant *livekit.ParticipantInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.participants[roomName] = append(c.participants[roomName], participant)
+}
+
+func (c *fakeRoomClient) CreateRoom(ctx context.Context, req *livekit.CreateRoomRequest) (*livekit.Room, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rooms[req.Name] = true
+	return &livekit.Room{Name: req.Name}, nil
+}
+
+func (c *fakeRoomClient) DeleteRoom(ctx context.Context, req *livekit.DeleteRoomRequest) (*livekit.DeleteRoomResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.rooms, req.Room)
+	delete(c.participants, req.Room)
+	return &livekit.DeleteRoomResponse{}, nil
+}
+
+func (c *fakeRoomClient) ListParticipants(ctx context.Context, req *livekit.ListParticipantsRequest) (*livekit.ListParticipantsResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &livekit.ListParticipantsResponse{Participants: c.participants[req.Room]}, nil
+}
+
+func (c *fakeRoomClient) GetParticipant(ctx context.Context, req *livekit.RoomParticipantIdentity) (*livekit.ParticipantInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, participant := range c.participants[req.Room] {
+		if participant.Identity == req.Identity {
+			return participant, nil
+		}
+	}
+	return nil, fmt.Errorf("participant %s not found", req.Identity)
+}
+
+func (c *fakeRoomClient) UpdateParticipant(ctx context.Context, req *livekit.UpdateParticipantRequest) (*livekit.ParticipantInfo, error) {
+	participant, err := c.GetParticipant(ctx, &livekit.RoomParticipantIdentity{Room: req.Room, Identity: req.Identity})
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if req.Permission != nil {
+		participant.Permission = req.Permission
+	}
+	return participant, nil
+}
+
+// permission returns the publish permission a participant currently holds
+func (c *fakeRoomClient) permission(roomName, identity string) *livekit.ParticipantPermission {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, participant := range c.participants[roomName] {
+		if participant.Identity == identity {
+			return participant.Permission
+		}
+	}
+	return nil
+}
+
+func (c *fakeRoomClient) RemoveParticipant(ctx context.Context, req *livekit.RoomParticipantIdentity) (*livekit.RemoveParticipantResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	participants := c.participants[req.Room]
+	for i, participant := range participants {
+		if participant.Identity == req.Identity {
+			c.participants[req.Room] = append(participants[:i:i], participants[i+1:]...)
+			break
+		}
+	}
+	return &livekit.RemoveParticipantResponse{}, nil
+}
+
+func (c *fakeRoomClient) MutePublishedTrack(ctx context.Context, req *livekit.MuteRoomTrackRequest) (*livekit.MuteRoomTrackResponse, error) {
+	return &livekit.MuteRoomTrackResponse{}, nil
+}
+
+// newTestCallService returns a CallService whose rooms live in the fake client
+func newTestCallService(t *testing.T, db *database.DB, rooms *fakeRoomClient, wsHub *websocket.WebSocketHub) *CallService {
+	t.Helper()
+
+	s, err := NewCallService(db, &CallServiceConfig{
+		APIKey:      "test-key",
+		APISecret:   "test-secret-that-is-long-enough-to-sign",
+		LiveKitHost: "http://localhost:7880",
+	}, wsHub)
+	if err != nil {
+		t.Fatalf("NewCallService: %v", err)
+	}
+	s.roomClient = rooms
+	s.participantService.roomClient = rooms
+	return s
+}
+
+// startTestCall creates a call through the service, with its history entry
+func startTestCall(t *testing.T, s *CallService, host *models.User, invitees ...string) string {
+	t.Helper()
+
+	result, err := s.CreateCallAndInvite(host.ID, "video", invitees, "", CallOptions{GuestAccessEnabled: true})
+	if err != nil {
+		t.Fatalf("CreateCallAndInvite: %v", err)
+	}
+	return result.CallID
+}
+
+// tokenGrants verifies a LiveKit token minted by newTestCallService and returns its grants
+func tokenGrants(t *testing.T, token string) *lkauth.ClaimGrants {
+	t.Helper()
+
+	verifier, err := lkauth.ParseAPIToken(token)
+	if err != nil {
+		t.Fatalf("ParseAPIToken: %v", err)
+	}
+	grants, err := verifier.Verify("test-secret-that-is-long-enough-to-sign")
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	return grants
+}
+
+// tokenSources returns the track sources a LiveKit token lets its holder publish
+func tokenSources(t *testing.T, token string) []livekit.TrackSource {
+	t.Helper()
+	return tokenGrants(t, token).Video.GetCanPublishSources()
+}
@@ -5,20 +5,31 @@ import (
 	"fmt"
 	"livekit/database"
 	"livekit/models"
+	"slices"
 	"time"
 )
 
 type HistoryService struct {
-	db            *database.DB
-	historyRepo   *database.CallHistoryRepo
-	userRepo      *database.UserRepo
+	db              *database.DB
+	historyRepo     *database.CallHistoryRepo
+	participantRepo *database.CallHistoryParticipantRepo
+	userRepo        *database.UserRepo
+	breakoutRepo    *database.BreakoutRepo
+	messageRepo     *database.CallMessageRepo
+	pollRepo        *database.PollRepo
+	transcriptRepo  *database.TranscriptRepo
 }
 
 func NewHistoryService(db *database.DB) *HistoryService {
 	return &HistoryService{
-		db:          db,
-		historyRepo: database.NewCallHistoryRepo(db),
-		userRepo:    database.NewUserRepo(db),
+		db:              db,
+		historyRepo:     database.NewCallHistoryRepo(db),
+		participantRepo: database.NewCallHistoryParticipantRepo(db),
+		userRepo:        database.NewUserRepo(db),
+		breakoutRepo:    database.NewBreakoutRepo(db),
+		messageRepo:     database.NewCallMessageRepo(db),
+		pollRepo:        database.NewPollRepo(db),
+		transcriptRepo:  database.NewTranscriptRepo(db),
 	}
 }
 
@@ -31,18 +42,158 @@ func (s *HistoryService) UpdateHistoryEntry(callID string, endedAt time.Time, du
 	return s.historyRepo.Update(callID, endedAt, duration, status)
 }
 
+// UpdateCallType records that a call was switched between voice and video
+func (s *HistoryService) UpdateCallType(callID, callType string) error {
+	return s.historyRepo.UpdateCallType(callID, callType)
+}
+
+// AddParticipants merges identities into the participants of a call's history,
+// e.g. people who only showed up in a breakout room
+func (s *HistoryService) AddParticipants(callID string, identities []string) error {
+	if len(identities) == 0 {
+		return nil
+	}
+
+	history, err := s.historyRepo.GetByCallID(callID)
+	if err != nil || history == nil {
+		return err
+	}
+
+	participants, err := s.ParseParticipants(history.Participants)
+	if err != nil {
+		participants = []string{}
+	}
+	changed := false
+	for _, identity := range identities {
+		if !slices.Contains(participants, identity) {
+			participants = append(participants, identity)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	return s.historyRepo.UpdateParticipants(callID, participants)
+}
+
+// RecordInvitation starts tracking the outcome of a single invitee
+func (s *HistoryService) RecordInvitation(invitation *models.Invitation) error {
+	return s.participantRepo.Create(invitation.CallID, invitation.InviteeID, invitation.Invitee, "invitee", invitation.ID)
+}
+
+// RecordGuest adds a guest who joined through a link to the call's participants
+func (s *HistoryService) RecordGuest(callID, identity string) error {
+	if err := s.participantRepo.Create(callID, 0, identity, "guest", 0); err != nil {
+		return err
+	}
+	return s.participantRepo.UpdateOutcome(callID, identity, "accepted")
+}
+
+// RecordSIPParticipant records a phone participant that dialed in or was dialed out to
+func (s *HistoryService) RecordSIPParticipant(callID, identity, outcome string) error {
+	if err := s.participantRepo.Create(callID, 0, identity, "sip", 0); err != nil {
+		return err
+	}
+	return s.participantRepo.UpdateOutcome(callID, identity, outcome)
+}
+
+func (s *HistoryService) RecordOutcome(callID, identity, outcome string) error {
+	return s.participantRepo.UpdateOutcome(callID, identity, outcome)
+}
+
+// CloseOutcomes resolves the invitees that never answered, e.g. as "missed"
+// when the call ends or "cancelled" when the caller hangs up first
+func (s *HistoryService) CloseOutcomes(callID, outcome string) error {
+	return s.participantRepo.UpdatePendingOutcomes(callID, outcome)
+}
+
+// DeriveStatus computes the aggregate history status from the per-invitee outcomes
+func (s *HistoryService) DeriveStatus(callID string, ended bool) (string, error) {
+	outcomes, err := s.participantRepo.GetByCallID(callID)
+	if err != nil {
+		return "", err
+	}
+	return deriveAggregateStatus(outcomes, ended), nil
+}
+
 func (s *HistoryService) GetCallHistory(userID int64, limit, offset int) ([]*models.CallHistory, error) {
-	return s.historyRepo.GetByUserID(userID, limit, offset)
+	histories, err := s.historyRepo.GetByUserID(userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	s.applyPerspectives(histories, userID)
+	return histories, nil
 }
 
 func (s *HistoryService) GetCallHistoryByDateRange(userID int64, startDate, endDate time.Time) ([]*models.CallHistory, error) {
-	return s.historyRepo.GetByDateRange(userID, startDate, endDate)
+	histories, err := s.historyRepo.GetByDateRange(userID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	s.applyPerspectives(histories, userID)
+	return histories, nil
 }
 
 func (s *HistoryService) GetCallDetails(callID string) (*models.CallHistory, error) {
 	return s.historyRepo.GetByCallID(callID)
 }
 
+// GetCallDetailsForUser returns the history entry with per-invitee outcomes
+// and the status as seen by the given user
+func (s *HistoryService) GetCallDetailsForUser(callID string, userID int64) (*models.CallHistory, error) {
+	history, err := s.historyRepo.GetByCallID(callID)
+	if err != nil || history == nil {
+		return history, err
+	}
+
+	outcomes, err := s.participantRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+	history.Outcomes = outcomes
+	history.PerspectiveStatus = perspectiveStatus(history, outcomes, userID)
+
+	breakouts, err := s.breakoutRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+	history.Breakouts = breakouts
+
+	messageCount, err := s.messageRepo.CountByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+	history.MessageCount = messageCount
+
+	polls, err := s.pollRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+	history.Polls = polls
+
+	transcriptSegments, err := s.transcriptRepo.CountByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+	history.TranscriptSegments = transcriptSegments
+
+	return history, nil
+}
+
+// IsParticipant reports whether the user created the call or was invited to it
+func (s *HistoryService) IsParticipant(history *models.CallHistory, userID int64) bool {
+	if history.CreatedBy == userID {
+		return true
+	}
+	for _, outcome := range history.Outcomes {
+		if outcome.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *HistoryService) DeleteCallHistory(callID string) error {
 	return s.historyRepo.Delete(callID)
 }
@@ -55,3 +206,90 @@ func (s *HistoryService) ParseParticipants(participantsJSON string) ([]string, e
 	return participants, nil
 }
 
+func (s *HistoryService) applyPerspectives(histories []*models.CallHistory, userID int64) {
+	callIDs := make([]string, 0, len(histories))
+	for _, history := range histories {
+		callIDs = append(callIDs, history.CallID)
+	}
+
+	outcomes, err := s.participantRepo.GetByCallIDs(callIDs)
+	if err != nil {
+		// Log error but don't fail the entire request
+		fmt.Printf("Failed to get call outcomes: %v\n", err)
+		for _, history := range histories {
+			history.PerspectiveStatus = history.Status
+		}
+		return
+	}
+
+	for _, history := range histories {
+		history.PerspectiveStatus = perspectiveStatus(history, outcomes[history.CallID], userID)
+	}
+}
+
+// deriveAggregateStatus folds invitee outcomes into a single call status.
+// While the call is still running only a unanimous rejection is final.
+func deriveAggregateStatus(outcomes []*models.CallParticipantOutcome, ended bool) string {
+	var invitees, accepted, rejected int
+	for _, outcome := range outcomes {
+		if outcome.Role != "invitee" {
+			continue
+		}
+		invitees++
+		switch outcome.Outcome {
+		case "accepted":
+			accepted++
+		case "rejected", "dnd", "busy": // Declined on the invitee's behalf
+			rejected++
+		}
+	}
+
+	if invitees > 0 && rejected == invitees {
+		return "rejected"
+	}
+	if !ended {
+		return "pending"
+	}
+
+	switch {
+	case invitees == 0 || accepted == invitees:
+		return "completed"
+	case accepted > 0:
+		return "partially_answered"
+	default:
+		return "missed"
+	}
+}
+
+func perspectiveStatus(history *models.CallHistory, outcomes []*models.CallParticipantOutcome, userID int64) string {
+	if history.CreatedBy == userID {
+		if history.Status == "partially_answered" {
+			return "completed"
+		}
+		return history.Status
+	}
+
+	for _, outcome := range outcomes {
+		if outcome.UserID != userID {
+			continue
+		}
+		switch outcome.Outcome {
+		case "accepted":
+			if history.Status == "pending" {
+				return "pending"
+			}
+			return "completed"
+		case "rejected":
+			return "rejected"
+		case "pending":
+			if history.Status == "pending" {
+				return "pending"
+			}
+			return "missed"
+		default:
+			return "missed"
+		}
+	}
+
+	return history.Status
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"livekit/database"
+	"testing"
+)
+
+func TestGetCallHistoryPerspectives(t *testing.T) {
+	db := newTestDB(t)
+	s := newTestCallService(t, db, newFakeRoomClient(), nil)
+	host := createTestUser(t, db, "host")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+
+	// answer records an invitee's response the way RespondToInvitation does
+	answer := func(callID, username, outcome string) {
+		t.Helper()
+		invitations, err := database.NewInvitationRepo(db).GetCallParticipants(callID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, invitation := range invitations {
+			if invitation.Invitee == username {
+				if err := database.NewInvitationRepo(db).UpdateStatus(invitation.ID, outcome); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := s.historyService.RecordOutcome(callID, username, outcome); err != nil {
+			t.Fatal(err)
+		}
+	}
+	end := func(callID string) {
+		t.Helper()
+		if err := s.EndCall(callID, host.ID); err != nil {
+			t.Fatalf("EndCall: %v", err)
+		}
+	}
+
+	bobAnswered := startTestCall(t, s, host, "bob", "carol")
+	answer(bobAnswered, "bob", "accepted")
+	end(bobAnswered)
+
+	bobRejected := startTestCall(t, s, host, "bob")
+	answer(bobRejected, "bob", "rejected")
+	end(bobRejected)
+
+	carolAnswered := startTestCall(t, s, host, "carol")
+	answer(carolAnswered, "carol", "accepted")
+	end(carolAnswered)
+
+	tests := []struct {
+		name   string
+		userID int64
+		want   map[string]string // Call ID -> perspective status
+	}{
+		{name: "host", userID: host.ID, want: map[string]string{
+			bobAnswered: "completed", bobRejected: "rejected", carolAnswered: "completed",
+		}},
+		{name: "bob", userID: bob.ID, want: map[string]string{
+			bobAnswered: "completed", bobRejected: "rejected",
+		}},
+		{name: "carol", userID: carol.ID, want: map[string]string{
+			bobAnswered: "missed", carolAnswered: "completed",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			histories, err := s.historyService.GetCallHistory(tt.userID, 50, 0)
+			if err != nil {
+				t.Fatalf("GetCallHistory: %v", err)
+			}
+
+			got := make(map[string]string, len(histories))
+			for _, history := range histories {
+				got[history.CallID] = history.PerspectiveStatus
+			}
+			for callID, want := range tt.want {
+				if got[callID] != want {
+					t.Errorf("call %s seen as %q, want %q", callID, got[callID], want)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("history lists %d calls, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestDeleteCallHistoryRemovesCallRecords(t *testing.T) {
+	env := newPollTestEnv(t)
+	poll := env.create(t, CreatePollRequest{Question: "Lunch?", Options: []string{"Pizza", "Sushi"}})
+	if _, err := env.polls.Vote(poll.ID, env.alice.ID, "alice", []int64{poll.Options[0].ID}); err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+	if _, err := NewChatService(env.db, env.callService, nil).SendMessage(env.callID, env.alice.ID, "alice", "hi"); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	conn := env.db.Conn()
+	if _, err := conn.Exec(
+		`INSERT INTO recordings (call_id, room_name, egress_id, started_by, started_at) VALUES (?, 'room', 'EG_1', ?, CURRENT_TIMESTAMP)`,
+		env.callID, env.host.ID,
+	); err != nil {
+		t.Fatal(err)
+	}
+	result, err := conn.Exec(`INSERT INTO breakout_rooms (call_id, room_name, name) VALUES (?, 'room-1', 'Room 1')`, env.callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breakoutID, _ := result.LastInsertId()
+	if _, err := conn.Exec(`INSERT INTO breakout_assignments (breakout_room_id, identity) VALUES (?, 'alice')`, breakoutID); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.callService.EndCall(env.callID, env.host.ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+
+	if err := env.callService.historyService.DeleteCallHistory(env.callID); err != nil {
+		t.Fatalf("DeleteCallHistory: %v", err)
+	}
+
+	for _, table := range []string{
+		"call_history", "call_history_participants", "call_messages", "call_polls", "call_poll_options",
+		"call_poll_votes", "recordings", "breakout_rooms", "breakout_assignments",
+	} {
+		var count int
+		if err := conn.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%s still has %d rows", table, count)
+		}
+	}
+}
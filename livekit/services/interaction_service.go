@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"slices"
+)
+
+var ErrInvalidReaction = errors.New("unsupported reaction")
+
+var allowedReactions = []string{"👍", "👎", "👏", "❤️", "😂", "😮", "🎉", "🤔"}
+
+type interactionRequest struct {
+	CallID   string `json:"callId"`
+	Username string `json:"username,omitempty"` // lower_hand: whose hand, hosts only
+	Emoji    string `json:"emoji,omitempty"`    // reaction
+}
+
+// InteractionService keeps raised hands and reactions for active calls in the
+// database, so late joiners on any instance see the current speaker queue
+type InteractionService struct {
+	db              *database.DB
+	interactionRepo *database.InteractionRepo
+	callRepo        *database.CallRepo
+	userRepo        *database.UserRepo
+	callService     *CallService
+	wsHub           *websocket.WebSocketHub
+}
+
+func NewInteractionService(db *database.DB, callService *CallService, wsHub *websocket.WebSocketHub) *InteractionService {
+	s := &InteractionService{
+		db:              db,
+		interactionRepo: database.NewInteractionRepo(db),
+		callRepo:        database.NewCallRepo(db),
+		userRepo:        database.NewUserRepo(db),
+		callService:     callService,
+		wsHub:           wsHub,
+	}
+	// Hands and reactions live as long as the call does
+	callService.OnCallEnded(func(call *models.ActiveCall) {
+		if err := s.interactionRepo.DeleteByCallID(call.CallID); err != nil {
+			fmt.Printf("Failed to clear interactions for call %s: %v\n", call.CallID, err)
+		}
+	})
+	if wsHub != nil {
+		wsHub.OnClientMessage("raise_hand", s.handleRaiseHand)
+		wsHub.OnClientMessage("lower_hand", s.handleLowerHand)
+		wsHub.OnClientMessage("reaction", s.handleReaction)
+	}
+	return s
+}
+
+// GetInteractions returns the speaker queue in the order hands were raised
+// along with the reaction tally
+func (s *InteractionService) GetInteractions(callID string, userID int64, username string) (*models.CallInteractions, error) {
+	if _, _, err := s.memberCall(callID, userID, username); err != nil {
+		return nil, err
+	}
+
+	hands, err := s.interactionRepo.GetRaisedHands(callID)
+	if err != nil {
+		return nil, err
+	}
+	reactions, err := s.interactionRepo.GetReactions(callID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CallInteractions{
+		CallID:      callID,
+		RaisedHands: hands,
+		Reactions:   reactions,
+	}, nil
+}
+
+// RaiseHand queues the user to speak; raising an already raised hand keeps its place
+func (s *InteractionService) RaiseHand(callID string, userID int64, username string) (*models.RaisedHand, error) {
+	_, members, err := s.memberCall(callID, userID, username)
+	if err != nil {
+		return nil, err
+	}
+
+	raised, err := s.interactionRepo.RaiseHand(callID, username)
+	if err != nil {
+		return nil, err
+	}
+	hands, err := s.interactionRepo.GetRaisedHands(callID)
+	if err != nil {
+		return nil, err
+	}
+	index := slices.IndexFunc(hands, func(hand *models.RaisedHand) bool { return hand.Username == username })
+	if index < 0 {
+		// Lowered again in the meantime
+		return nil, fmt.Errorf("hand of %s is no longer raised", username)
+	}
+	hand := hands[index]
+
+	if raised && s.wsHub != nil {
+		s.wsHub.Publish(context.Background(), members, events.HandRaised{
+			CallID:   callID,
+			Username: hand.Username,
+			Position: hand.Position,
+			RaisedAt: hand.RaisedAt,
+		})
+	}
+	return hand, nil
+}
+
+// LowerHand lowers the caller's own hand, or target's hand when the caller is
+// the host, e.g. after giving them the floor
+func (s *InteractionService) LowerHand(callID string, userID int64, username, target string) error {
+	call, members, err := s.memberCall(callID, userID, username)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		target = username
+	}
+	if target != username && call.CreatedBy != userID {
+		return ErrNotCallHost
+	}
+
+	lowered, err := s.interactionRepo.LowerHand(callID, target)
+	if err != nil || !lowered {
+		return err
+	}
+
+	if s.wsHub != nil {
+		s.wsHub.Publish(context.Background(), members, events.HandLowered{
+			CallID:    callID,
+			Username:  target,
+			LoweredBy: username,
+		})
+	}
+	return nil
+}
+
+func (s *InteractionService) React(callID string, userID int64, username, emoji string) error {
+	if !slices.Contains(allowedReactions, emoji) {
+		return ErrInvalidReaction
+	}
+
+	_, members, err := s.memberCall(callID, userID, username)
+	if err != nil {
+		return err
+	}
+
+	if err := s.interactionRepo.AddReaction(callID, emoji); err != nil {
+		return err
+	}
+
+	if s.wsHub != nil {
+		s.wsHub.Publish(context.Background(), members, events.Reaction{
+			CallID:   callID,
+			Username: username,
+			Emoji:    emoji,
+		})
+	}
+	return nil
+}
+
+// memberCall loads an active call the user belongs to along with everyone
+// who should hear about changes
+func (s *InteractionService) memberCall(callID string, userID int64, username string) (*models.ActiveCall, []string, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, nil, ErrCallNotFound
+	}
+
+	members := s.callService.callMemberUsernames(call)
+	if call.CreatedBy != userID && !slices.Contains(members, username) {
+		return nil, nil, ErrCallNotFound
+	}
+	if call.Status == "ended" || call.Status == "cancelled" {
+		return nil, nil, ErrCallNotActive
+	}
+	return call, members, nil
+}
+
+func (s *InteractionService) handleRaiseHand(username string, data json.RawMessage) error {
+	req, userID, err := s.parseSocketRequest(username, data)
+	if err != nil {
+		return err
+	}
+	_, err = s.RaiseHand(req.CallID, userID, username)
+	return err
+}
+
+func (s *InteractionService) handleLowerHand(username string, data json.RawMessage) error {
+	req, userID, err := s.parseSocketRequest(username, data)
+	if err != nil {
+		return err
+	}
+	return s.LowerHand(req.CallID, userID, username, req.Username)
+}
+
+func (s *InteractionService) handleReaction(username string, data json.RawMessage) error {
+	req, userID, err := s.parseSocketRequest(username, data)
+	if err != nil {
+		return err
+	}
+	return s.React(req.CallID, userID, username, req.Emoji)
+}
+
+func (s *InteractionService) parseSocketRequest(username string, data json.RawMessage) (*interactionRequest, int64, error) {
+	var req interactionRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.CallID == "" {
+		return nil, 0, fmt.Errorf("callId is required")
+	}
+
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		return nil, 0, fmt.Errorf("user not found")
+	}
+	return &req, user.ID, nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"livekit/database"
+	"livekit/models"
+	"livekit/websocket"
+	"slices"
+	"testing"
+)
+
+type interactionTestEnv struct {
+	db          *database.DB
+	wsHub       *websocket.WebSocketHub
+	callService *CallService
+	users       map[string]*models.User
+	callID      string
+}
+
+// newInteractionTestEnv starts a call hosted by "host" that every other user has joined
+func newInteractionTestEnv(t *testing.T, invitees ...string) *interactionTestEnv {
+	t.Helper()
+
+	db := newTestDB(t)
+	wsHub := websocket.NewWebSocketHub()
+	env := &interactionTestEnv{
+		db:          db,
+		wsHub:       wsHub,
+		callService: newTestCallService(t, db, newFakeRoomClient(), wsHub),
+		users:       map[string]*models.User{"host": createTestUser(t, db, "host")},
+	}
+	for _, username := range append(invitees, "outsider") {
+		env.users[username] = createTestUser(t, db, username)
+	}
+	env.callID = startTestCall(t, env.callService, env.users["host"], invitees...)
+
+	invitations, err := database.NewInvitationRepo(db).GetCallParticipants(env.callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, invitation := range invitations {
+		if _, err := env.callService.RespondToInvitation(invitation.ID, invitation.InviteeID, "accept"); err != nil {
+			t.Fatalf("RespondToInvitation: %v", err)
+		}
+	}
+	return env
+}
+
+func (env *interactionTestEnv) raise(t *testing.T, s *InteractionService, username string) *models.RaisedHand {
+	t.Helper()
+
+	hand, err := s.RaiseHand(env.callID, env.users[username].ID, username)
+	if err != nil {
+		t.Fatalf("RaiseHand(%s): %v", username, err)
+	}
+	return hand
+}
+
+func (env *interactionTestEnv) queue(t *testing.T, s *InteractionService, viewer string) []string {
+	t.Helper()
+
+	interactions, err := s.GetInteractions(env.callID, env.users[viewer].ID, viewer)
+	if err != nil {
+		t.Fatalf("GetInteractions(%s): %v", viewer, err)
+	}
+	var queue []string
+	for i, hand := range interactions.RaisedHands {
+		if hand.Position != i+1 {
+			t.Errorf("%s is at position %d, want %d", hand.Username, hand.Position, i+1)
+		}
+		queue = append(queue, hand.Username)
+	}
+	return queue
+}
+
+func TestSpeakerQueueOrder(t *testing.T) {
+	env := newInteractionTestEnv(t, "alice", "bob", "carol")
+	s := NewInteractionService(env.db, env.callService, env.wsHub)
+	conn := &websocket.Connection{ID: "host", Username: "host", Send: make(chan []byte, 64)}
+	env.wsHub.Register("host", conn)
+
+	for _, username := range []string{"alice", "bob", "carol"} {
+		env.raise(t, s, username)
+	}
+	// Raising again keeps the original place
+	if hand := env.raise(t, s, "alice"); hand.Position != 1 {
+		t.Errorf("alice raised again at position %d, want 1", hand.Position)
+	}
+	if got := env.queue(t, s, "host"); !slices.Equal(got, []string{"alice", "bob", "carol"}) {
+		t.Errorf("queue %v, want alice, bob, carol", got)
+	}
+
+	var positions []int
+	for _, envelope := range drain(t, conn) {
+		if envelope.Type != "hand_raised" {
+			continue
+		}
+		var event struct{ Position int }
+		if err := json.Unmarshal(envelope.Data, &event); err != nil {
+			t.Fatal(err)
+		}
+		positions = append(positions, event.Position)
+	}
+	if !slices.Equal(positions, []int{1, 2, 3}) {
+		t.Errorf("hand_raised positions %v, want one event each at 1, 2, 3", positions)
+	}
+
+	if err := s.LowerHand(env.callID, env.users["bob"].ID, "bob", ""); err != nil {
+		t.Fatalf("LowerHand: %v", err)
+	}
+	if got := env.queue(t, s, "host"); !slices.Equal(got, []string{"alice", "carol"}) {
+		t.Errorf("queue after bob lowered %v, want alice, carol", got)
+	}
+	if hand := env.raise(t, s, "bob"); hand.Position != 3 {
+		t.Errorf("bob raised again at position %d, want the end of the queue", hand.Position)
+	}
+}
+
+func TestOnlyHostLowersOtherHands(t *testing.T) {
+	env := newInteractionTestEnv(t, "alice", "bob")
+	s := NewInteractionService(env.db, env.callService, nil)
+	env.raise(t, s, "alice")
+	env.raise(t, s, "bob")
+
+	if err := s.LowerHand(env.callID, env.users["bob"].ID, "bob", "alice"); !errors.Is(err, ErrNotCallHost) {
+		t.Errorf("bob lowering alice's hand: %v, want ErrNotCallHost", err)
+	}
+	if err := s.LowerHand(env.callID, env.users["host"].ID, "host", "alice"); err != nil {
+		t.Fatalf("LowerHand by the host: %v", err)
+	}
+	if got := env.queue(t, s, "host"); !slices.Equal(got, []string{"bob"}) {
+		t.Errorf("queue after the host lowered alice %v, want bob", got)
+	}
+	if _, err := s.GetInteractions(env.callID, env.users["outsider"].ID, "outsider"); !errors.Is(err, ErrCallNotFound) {
+		t.Errorf("GetInteractions by an outsider: %v, want ErrCallNotFound", err)
+	}
+	if _, err := s.RaiseHand(env.callID, env.users["outsider"].ID, "outsider"); !errors.Is(err, ErrCallNotFound) {
+		t.Errorf("RaiseHand by an outsider: %v, want ErrCallNotFound", err)
+	}
+}
+
+func TestInteractionsAreSharedAcrossInstances(t *testing.T) {
+	env := newInteractionTestEnv(t, "alice", "bob")
+	first := NewInteractionService(env.db, env.callService, nil)
+	env.raise(t, first, "bob")
+	env.raise(t, first, "alice")
+	for _, emoji := range []string{"👍", "👍", "🎉"} {
+		if err := first.React(env.callID, env.users["alice"].ID, "alice", emoji); err != nil {
+			t.Fatalf("React: %v", err)
+		}
+	}
+	if err := first.React(env.callID, env.users["alice"].ID, "alice", "🍕"); !errors.Is(err, ErrInvalidReaction) {
+		t.Errorf("unsupported reaction: %v, want ErrInvalidReaction", err)
+	}
+
+	// Another instance, or this one after a restart, sees the same state
+	second := NewInteractionService(env.db, env.callService, nil)
+	if got := env.queue(t, second, "alice"); !slices.Equal(got, []string{"bob", "alice"}) {
+		t.Errorf("late joiner sees queue %v, want bob, alice", got)
+	}
+	interactions, err := second.GetInteractions(env.callID, env.users["bob"].ID, "bob")
+	if err != nil {
+		t.Fatalf("GetInteractions: %v", err)
+	}
+	if len(interactions.Reactions) != 2 || interactions.Reactions["👍"] != 2 || interactions.Reactions["🎉"] != 1 {
+		t.Errorf("reactions %v, want 2 👍 and 1 🎉", interactions.Reactions)
+	}
+
+	if err := env.callService.EndCall(env.callID, env.users["host"].ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+	repo := database.NewInteractionRepo(env.db)
+	hands, err := repo.GetRaisedHands(env.callID)
+	if err != nil || len(hands) != 0 {
+		t.Errorf("raised hands after the call ended: %v, %v", hands, err)
+	}
+	reactions, err := repo.GetReactions(env.callID)
+	if err != nil || len(reactions) != 0 {
+		t.Errorf("reactions after the call ended: %v, %v", reactions, err)
+	}
+}
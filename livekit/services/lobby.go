@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+)
+
+var (
+	ErrLobbyRequestNotFound = errors.New("lobby request not found")
+	ErrLobbyRequestResolved = errors.New("lobby request has already been answered")
+)
+
+type LobbyStatusResult struct {
+	RequestID int64  `json:"requestId"`
+	CallID    string `json:"callId"`
+	State     string `json:"state"` // "waiting", "admitted", "denied", "closed"
+	Token     string `json:"token,omitempty"`
+	RoomName  string `json:"roomName,omitempty"`
+}
+
+// requestLobbyAdmission parks a joiner in the lobby of a call and asks the host to admit them
+func (s *CallService) requestLobbyAdmission(call *models.ActiveCall, userID int64, identity, displayName, role string) (*models.LobbyRequest, error) {
+	lobbyRepo := database.NewLobbyRepo(s.db)
+
+	existing, err := lobbyRepo.GetWaitingByIdentity(call.CallID, identity)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate wait token: %w", err)
+	}
+
+	request, err := lobbyRepo.Create(call.CallID, call.RoomName, userID, identity, displayName, role, hex.EncodeToString(secret))
+	if err != nil {
+		return nil, err
+	}
+
+	if s.wsHub != nil {
+		userRepo := database.NewUserRepo(s.db)
+		host, err := userRepo.GetByID(call.CreatedBy)
+		if err == nil && host != nil {
+			s.wsHub.Publish(context.Background(), []string{host.Username}, events.LobbyJoinRequest{LobbyRequest: request})
+		}
+	}
+
+	return request, nil
+}
+
+func (s *CallService) GetLobby(callID string, userID int64) ([]*models.LobbyRequest, error) {
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+
+	lobbyRepo := database.NewLobbyRepo(s.db)
+	return lobbyRepo.GetWaitingByCallID(callID)
+}
+
+func (s *CallService) RespondToLobbyRequest(requestID, hostID int64, admit bool) (*models.LobbyRequest, error) {
+	lobbyRepo := database.NewLobbyRepo(s.db)
+	request, err := lobbyRepo.GetByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, ErrLobbyRequestNotFound
+	}
+
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(request.CallID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != hostID {
+		return nil, ErrNotCallHost
+	}
+	if request.Status != "waiting" {
+		return nil, ErrLobbyRequestResolved
+	}
+
+	status := "denied"
+	if admit {
+		status = "admitted"
+	}
+	if err := lobbyRepo.UpdateStatus(requestID, status); err != nil {
+		return nil, err
+	}
+	request.Status = status
+
+	if admit && request.Role == "guest" {
+		if err := s.historyService.RecordGuest(request.CallID, request.Identity); err != nil {
+			fmt.Printf("Failed to record guest in call history: %v\n", err)
+		}
+	}
+	if request.UserID != 0 {
+		s.resolveLobbyInvitation(request, admit)
+	}
+	if admit && request.UserID != 0 {
+		s.runCallJoinedHooks(call, request.UserID)
+	}
+
+	// Registered users are waiting on the socket; guests poll for their status
+	if s.wsHub != nil && request.UserID != 0 {
+		decision := events.LobbyDecision{
+			RequestID: request.ID,
+			CallID:    request.CallID,
+			Status:    request.Status,
+		}
+		if admit {
+			token, err := s.admittedToken(call, request)
+			if err != nil {
+				fmt.Printf("Failed to generate token for lobby request %d: %v\n", request.ID, err)
+			} else {
+				decision.Token = token
+				decision.RoomName = request.RoomName
+			}
+		}
+		s.wsHub.Publish(context.Background(), []string{request.Identity}, decision)
+	}
+
+	return request, nil
+}
+
+// resolveLobbyInvitation settles the invitation of an invitee the host just
+// answered: admitted invitees become members of the call, denied ones never do
+func (s *CallService) resolveLobbyInvitation(request *models.LobbyRequest, admit bool) {
+	invitationRepo := database.NewInvitationRepo(s.db)
+	invitations, err := invitationRepo.GetCallParticipants(request.CallID)
+	if err != nil {
+		fmt.Printf("Failed to get invitations of call %s: %v\n", request.CallID, err)
+		return
+	}
+
+	status, outcome := "denied", "rejected"
+	if admit {
+		status, outcome = "accepted", "accepted"
+	}
+	for _, invitation := range invitations {
+		if invitation.InviteeID != request.UserID || invitation.Status != "lobby" {
+			continue
+		}
+		if err := invitationRepo.UpdateStatus(invitation.ID, status); err != nil {
+			fmt.Printf("Failed to update invitation %d status: %v\n", invitation.ID, err)
+			continue
+		}
+		if err := s.historyService.RecordOutcome(request.CallID, invitation.Invitee, outcome); err != nil {
+			fmt.Printf("Failed to record invitation outcome in call history: %v\n", err)
+		}
+		if !admit {
+			s.refreshHistoryStatus(request.CallID)
+		}
+	}
+}
+
+func (s *CallService) GetLobbyStatus(requestID, userID int64) (*LobbyStatusResult, error) {
+	lobbyRepo := database.NewLobbyRepo(s.db)
+	request, err := lobbyRepo.GetByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil || request.UserID != userID {
+		return nil, ErrLobbyRequestNotFound
+	}
+
+	return s.lobbyStatus(request)
+}
+
+func (s *CallService) GetGuestLobbyStatus(requestID int64, waitToken string) (*LobbyStatusResult, error) {
+	lobbyRepo := database.NewLobbyRepo(s.db)
+	request, err := lobbyRepo.GetByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil || request.Role != "guest" ||
+		subtle.ConstantTimeCompare([]byte(request.WaitSecret), []byte(waitToken)) != 1 {
+		return nil, ErrLobbyRequestNotFound
+	}
+
+	return s.lobbyStatus(request)
+}
+
+func (s *CallService) lobbyStatus(request *models.LobbyRequest) (*LobbyStatusResult, error) {
+	result := &LobbyStatusResult{
+		RequestID: request.ID,
+		CallID:    request.CallID,
+		State:     request.Status,
+	}
+	if request.Status != "admitted" {
+		return result, nil
+	}
+
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(request.CallID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil || call.Status != "active" {
+		result.State = "closed"
+		return result, nil
+	}
+
+	token, err := s.admittedToken(call, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	result.Token = token
+	result.RoomName = request.RoomName
+
+	return result, nil
+}
+
+// admittedToken mints the LiveKit token for a lobby joiner, and only once the host admitted them
+func (s *CallService) admittedToken(call *models.ActiveCall, request *models.LobbyRequest) (string, error) {
+	if request.Status != "admitted" {
+		return "", fmt.Errorf("lobby request %d is not admitted", request.ID)
+	}
+
+	opts := tokenOptions{CallType: call.CallType, ScreenShare: s.canScreenShare(call, request.Identity)}
+	if request.Role == "guest" {
+		opts.Name = request.DisplayName
+		opts.Role = "guest"
+	}
+	return s.generateToken(request.RoomName, request.Identity, opts)
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"errors"
+	"livekit/database"
+	"livekit/models"
+	"slices"
+	"testing"
+)
+
+func TestLobbyAdmission(t *testing.T) {
+	tests := []struct {
+		name      string
+		admit     bool
+		wantState string
+	}{
+		{name: "admitted", admit: true, wantState: "admitted"},
+		{name: "denied", admit: false, wantState: "denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			s := newTestCallService(t, db, newFakeRoomClient(), nil)
+			host := createTestUser(t, db, "host")
+			alice := createTestUser(t, db, "alice")
+
+			var joined []int64
+			s.OnCallJoined(func(call *models.ActiveCall, userID int64) {
+				joined = append(joined, userID)
+			})
+
+			created, err := s.CreateCallAndInvite(host.ID, "video", []string{"alice"}, "", CallOptions{LobbyEnabled: true})
+			if err != nil {
+				t.Fatalf("CreateCallAndInvite: %v", err)
+			}
+			invitations, err := database.NewInvitationRepo(db).GetCallParticipants(created.CallID)
+			if err != nil || len(invitations) != 1 {
+				t.Fatalf("invitations %v, %v", invitations, err)
+			}
+			joined = nil
+
+			accepted, err := s.RespondToInvitation(invitations[0].ID, alice.ID, "accept")
+			if err != nil {
+				t.Fatalf("RespondToInvitation: %v", err)
+			}
+			if accepted.State != "lobby" || accepted.Token != "" || accepted.LobbyRequestID == 0 {
+				t.Fatalf("accepting gave %+v, want a lobby request without token", accepted)
+			}
+			if len(joined) != 0 {
+				t.Errorf("joined hooks ran for %v while still in the lobby", joined)
+			}
+
+			requestID := accepted.LobbyRequestID
+			status, err := s.GetLobbyStatus(requestID, alice.ID)
+			if err != nil {
+				t.Fatalf("GetLobbyStatus: %v", err)
+			}
+			if status.State != "waiting" || status.Token != "" {
+				t.Errorf("waiting status %+v, want waiting without token", status)
+			}
+			if _, err := s.GetLobbyStatus(requestID, host.ID); !errors.Is(err, ErrLobbyRequestNotFound) {
+				t.Errorf("someone else's lobby status error = %v, want %v", err, ErrLobbyRequestNotFound)
+			}
+
+			if _, err := s.GetLobby(created.CallID, alice.ID); !errors.Is(err, ErrNotCallHost) {
+				t.Errorf("GetLobby by a joiner error = %v, want %v", err, ErrNotCallHost)
+			}
+			waiting, err := s.GetLobby(created.CallID, host.ID)
+			if err != nil || len(waiting) != 1 || waiting[0].ID != requestID {
+				t.Errorf("GetLobby = %v, %v; want request %d", waiting, err, requestID)
+			}
+			if _, err := s.RespondToLobbyRequest(requestID, alice.ID, true); !errors.Is(err, ErrNotCallHost) {
+				t.Errorf("admitting oneself error = %v, want %v", err, ErrNotCallHost)
+			}
+
+			request, err := s.RespondToLobbyRequest(requestID, host.ID, tt.admit)
+			if err != nil {
+				t.Fatalf("RespondToLobbyRequest: %v", err)
+			}
+			if request.Status != tt.wantState {
+				t.Errorf("request is %s, want %s", request.Status, tt.wantState)
+			}
+			if _, err := s.RespondToLobbyRequest(requestID, host.ID, !tt.admit); !errors.Is(err, ErrLobbyRequestResolved) {
+				t.Errorf("answering twice error = %v, want %v", err, ErrLobbyRequestResolved)
+			}
+
+			status, err = s.GetLobbyStatus(requestID, alice.ID)
+			if err != nil {
+				t.Fatalf("GetLobbyStatus: %v", err)
+			}
+			if status.State != tt.wantState {
+				t.Errorf("status is %s, want %s", status.State, tt.wantState)
+			}
+			if tt.admit {
+				if status.Token == "" || status.RoomName != created.RoomName {
+					t.Errorf("admitted status %+v, want a token for room %s", status, created.RoomName)
+				}
+				if len(joined) != 1 || joined[0] != alice.ID {
+					t.Errorf("joined hooks ran for %v, want [%d]", joined, alice.ID)
+				}
+			} else {
+				if status.Token != "" {
+					t.Error("denied joiner got a token")
+				}
+				if len(joined) != 0 {
+					t.Errorf("joined hooks ran for %v after a denial", joined)
+				}
+			}
+		})
+	}
+}
+
+func TestLobbyStatusAfterCallEnded(t *testing.T) {
+	db := newTestDB(t)
+	s := newTestCallService(t, db, newFakeRoomClient(), nil)
+	host := createTestUser(t, db, "host")
+	alice := createTestUser(t, db, "alice")
+
+	created, err := s.CreateCallAndInvite(host.ID, "video", []string{"alice"}, "", CallOptions{LobbyEnabled: true})
+	if err != nil {
+		t.Fatalf("CreateCallAndInvite: %v", err)
+	}
+	invitations, err := database.NewInvitationRepo(db).GetCallParticipants(created.CallID)
+	if err != nil || len(invitations) != 1 {
+		t.Fatalf("invitations %v, %v", invitations, err)
+	}
+	accepted, err := s.RespondToInvitation(invitations[0].ID, alice.ID, "accept")
+	if err != nil {
+		t.Fatalf("RespondToInvitation: %v", err)
+	}
+	if _, err := s.RespondToLobbyRequest(accepted.LobbyRequestID, host.ID, true); err != nil {
+		t.Fatalf("RespondToLobbyRequest: %v", err)
+	}
+	if err := s.EndCall(created.CallID, host.ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+
+	status, err := s.GetLobbyStatus(accepted.LobbyRequestID, alice.ID)
+	if err != nil {
+		t.Fatalf("GetLobbyStatus: %v", err)
+	}
+	if status.State != "closed" || status.Token != "" {
+		t.Errorf("status after the call ended %+v, want closed without token", status)
+	}
+}
+
+func TestLobbyJoinerIsMemberOnlyOnceAdmitted(t *testing.T) {
+	tests := []struct {
+		name           string
+		answer         func(s *CallService, requestID, hostID int64) error
+		wantInvitation string
+		wantMember     bool
+	}{
+		{name: "waiting", wantInvitation: "lobby"},
+		{
+			name: "denied",
+			answer: func(s *CallService, requestID, hostID int64) error {
+				_, err := s.RespondToLobbyRequest(requestID, hostID, false)
+				return err
+			},
+			wantInvitation: "denied",
+		},
+		{
+			name: "admitted",
+			answer: func(s *CallService, requestID, hostID int64) error {
+				_, err := s.RespondToLobbyRequest(requestID, hostID, true)
+				return err
+			},
+			wantInvitation: "accepted",
+			wantMember:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			s := newTestCallService(t, db, newFakeRoomClient(), nil)
+			chat := NewChatService(db, s, nil)
+			host := createTestUser(t, db, "host")
+			alice := createTestUser(t, db, "alice")
+
+			created, err := s.CreateCallAndInvite(host.ID, "video", []string{"alice"}, "", CallOptions{LobbyEnabled: true})
+			if err != nil {
+				t.Fatalf("CreateCallAndInvite: %v", err)
+			}
+			invitationRepo := database.NewInvitationRepo(db)
+			invitations, err := invitationRepo.GetCallParticipants(created.CallID)
+			if err != nil || len(invitations) != 1 {
+				t.Fatalf("invitations %v, %v", invitations, err)
+			}
+			accepted, err := s.RespondToInvitation(invitations[0].ID, alice.ID, "accept")
+			if err != nil {
+				t.Fatalf("RespondToInvitation: %v", err)
+			}
+			if tt.answer != nil {
+				if err := tt.answer(s, accepted.LobbyRequestID, host.ID); err != nil {
+					t.Fatalf("RespondToLobbyRequest: %v", err)
+				}
+			}
+
+			invitation, err := invitationRepo.GetByID(invitations[0].ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if invitation.Status != tt.wantInvitation {
+				t.Errorf("invitation is %s, want %s", invitation.Status, tt.wantInvitation)
+			}
+
+			call, _ := database.NewCallRepo(db).GetByCallID(created.CallID)
+			if got := slices.Contains(s.callMemberUsernames(call), "alice"); got != tt.wantMember {
+				t.Errorf("alice is a call member: %v, want %v", got, tt.wantMember)
+			}
+
+			_, err = chat.SendMessage(created.CallID, alice.ID, "alice", "Hello")
+			if tt.wantMember && err != nil {
+				t.Errorf("SendMessage by an admitted joiner: %v", err)
+			}
+			if !tt.wantMember && !errors.Is(err, ErrCallNotFound) {
+				t.Errorf("SendMessage error = %v, want %v", err, ErrCallNotFound)
+			}
+
+			if tt.wantMember {
+				return
+			}
+			if err := s.EndCall(created.CallID, alice.ID); !errors.Is(err, ErrNotCallHost) {
+				t.Errorf("EndCall error = %v, want %v", err, ErrNotCallHost)
+			}
+			call, _ = database.NewCallRepo(db).GetByCallID(created.CallID)
+			if call.Status != "active" {
+				t.Errorf("call is %s after a joiner who is not a member tried to end it", call.Status)
+			}
+		})
+	}
+}
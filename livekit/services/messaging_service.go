@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxConversationMembers = 10
+	// maxOfflineDelivery caps how many missed messages are pushed on reconnect;
+	// anything beyond that is still fetched through the messages endpoint
+	maxOfflineDelivery = 200
+)
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrInvalidConversation  = errors.New("invalid conversation")
+	ErrNotContact           = errors.New("you can only message your contacts")
+)
+
+type directMessageRequest struct {
+	ConversationID int64  `json:"conversationId"`
+	Body           string `json:"body"`
+}
+
+type directMessageReadRequest struct {
+	ConversationID int64 `json:"conversationId"`
+	MessageID      int64 `json:"messageId"`
+}
+
+type MessagingService struct {
+	db               *database.DB
+	conversationRepo *database.ConversationRepo
+	contactRepo      *database.ContactRepo
+	userRepo         *database.UserRepo
+	wsHub            *websocket.WebSocketHub
+}
+
+func NewMessagingService(db *database.DB, wsHub *websocket.WebSocketHub) *MessagingService {
+	s := &MessagingService{
+		db:               db,
+		conversationRepo: database.NewConversationRepo(db),
+		contactRepo:      database.NewContactRepo(db),
+		userRepo:         database.NewUserRepo(db),
+		wsHub:            wsHub,
+	}
+	if wsHub != nil {
+		wsHub.OnConnect(s.deliverPending)
+		wsHub.OnClientMessage("direct_message", s.handleSocketMessage)
+		wsHub.OnClientMessage("direct_message_read", s.handleSocketRead)
+	}
+	return s
+}
+
+// CreateConversation starts a conversation with one or more contacts. A 1:1
+// conversation is reused if the pair already has one, reported by created=false.
+func (s *MessagingService) CreateConversation(userID int64, usernames []string, name string) (conv *models.Conversation, created bool, err error) {
+	var others []string
+	for _, username := range usernames {
+		username = strings.TrimSpace(username)
+		if username != "" && !slices.Contains(others, username) {
+			others = append(others, username)
+		}
+	}
+
+	self, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get user: %w", err)
+	}
+	if self == nil {
+		return nil, false, fmt.Errorf("user not found")
+	}
+	others = slices.DeleteFunc(others, func(username string) bool { return username == self.Username })
+
+	if len(others) == 0 || len(others) > maxConversationMembers-1 {
+		return nil, false, fmt.Errorf("%w: a conversation needs between 1 and %d other members", ErrInvalidConversation, maxConversationMembers-1)
+	}
+
+	memberIDs := []int64{userID}
+	for _, username := range others {
+		user, err := s.userRepo.GetByUsername(username)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get user: %w", err)
+		}
+		if user == nil {
+			return nil, false, fmt.Errorf("%w: user %s not found", ErrInvalidConversation, username)
+		}
+		isContact, err := s.contactRepo.Exists(userID, user.ID)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to check contact: %w", err)
+		}
+		if !isContact {
+			return nil, false, ErrNotContact
+		}
+		memberIDs = append(memberIDs, user.ID)
+	}
+
+	convType := "group"
+	var directKey string
+	if len(memberIDs) == 2 {
+		convType = "direct"
+		name = ""
+		directKey = fmt.Sprintf("%d:%d", min(memberIDs[0], memberIDs[1]), max(memberIDs[0], memberIDs[1]))
+
+		existingID, err := s.conversationRepo.GetIDByDirectKey(directKey)
+		if err != nil {
+			return nil, false, err
+		}
+		if existingID != 0 {
+			conv, err := s.loadConversation(existingID, userID)
+			return conv, false, err
+		}
+	}
+
+	id, err := s.conversationRepo.Create(convType, strings.TrimSpace(name), userID, directKey, memberIDs)
+	if err != nil {
+		return nil, false, err
+	}
+
+	conv, err = s.loadConversation(id, userID)
+	return conv, true, err
+}
+
+// ListConversations returns the user's conversations with their last message
+// and unread count, most recently active first
+func (s *MessagingService) ListConversations(userID int64) ([]*models.Conversation, error) {
+	conversations, err := s.conversationRepo.GetForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, conv := range conversations {
+		if err := s.fillConversation(conv, userID); err != nil {
+			return nil, err
+		}
+	}
+	return conversations, nil
+}
+
+// GetMessages pages backwards through a conversation, beforeID 0 starting at the newest message
+func (s *MessagingService) GetMessages(conversationID, userID, beforeID int64, limit int) ([]*models.DirectMessage, error) {
+	members, err := s.memberOf(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.conversationRepo.GetMessages(conversationID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		applyMessageStatus(message, members, userID)
+	}
+	return messages, nil
+}
+
+// SendMessage stores a message and pushes it to every member that is online.
+// Members that are offline get it from deliverPending when they reconnect.
+func (s *MessagingService) SendMessage(conversationID, userID int64, body string) (*models.DirectMessage, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > maxChatMessageLength {
+		return nil, ErrInvalidChatMessage
+	}
+
+	members, err := s.memberOf(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := s.conversationRepo.CreateMessage(conversationID, userID, body)
+	if err != nil {
+		return nil, err
+	}
+
+	var sender string
+	for _, member := range members {
+		if member.UserID == userID {
+			sender = member.Username
+		}
+	}
+	message.Sender = sender
+
+	if s.wsHub == nil {
+		message.Status = "sent"
+		return message, nil
+	}
+
+	delivered := true
+	for _, member := range members {
+		if member.UserID == userID {
+			continue
+		}
+		if s.push(member.Username, message) {
+			s.markDelivered(message, member.UserID, member.Username)
+		} else {
+			delivered = false
+		}
+	}
+
+	// Only the sender's copy carries the receipt status
+	message.Status = "sent"
+	if delivered {
+		message.Status = "delivered"
+	}
+	s.push(sender, message)
+
+	return message, nil
+}
+
+// MarkRead moves the user's read cursor up to messageID, or to the newest
+// message when messageID is 0, and tells the other members
+func (s *MessagingService) MarkRead(conversationID, userID, messageID int64) error {
+	members, err := s.memberOf(conversationID, userID)
+	if err != nil {
+		return err
+	}
+
+	if messageID == 0 {
+		last, err := s.conversationRepo.GetLastMessage(conversationID)
+		if err != nil {
+			return err
+		}
+		if last == nil {
+			return nil
+		}
+		messageID = last.ID
+	}
+
+	moved, err := s.conversationRepo.MarkRead(conversationID, userID, messageID)
+	if err != nil || !moved || s.wsHub == nil {
+		return err
+	}
+
+	var reader string
+	var recipients []string
+	for _, member := range members {
+		if member.UserID == userID {
+			reader = member.Username
+		} else {
+			recipients = append(recipients, member.Username)
+		}
+	}
+	s.wsHub.Publish(context.Background(), recipients, events.MessageReceipt{
+		ConversationID: conversationID,
+		Username:       reader,
+		Type:           "read",
+		MessageID:      messageID,
+	})
+	return nil
+}
+
+func (s *MessagingService) UnreadCount(userID int64) (int, error) {
+	return s.conversationRepo.TotalUnread(userID)
+}
+
+// deliverPending pushes the messages a user missed while offline
+func (s *MessagingService) deliverPending(username string) {
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		fmt.Printf("Failed to get user %s for message delivery: %v\n", username, err)
+		return
+	}
+
+	messages, err := s.conversationRepo.GetUndelivered(user.ID, maxOfflineDelivery)
+	if err != nil {
+		fmt.Printf("Failed to get undelivered messages for %s: %v\n", username, err)
+		return
+	}
+
+	for _, message := range messages {
+		if !s.push(username, message) {
+			return
+		}
+		s.markDelivered(message, user.ID, username)
+	}
+}
+
+// markDelivered records that a message reached a recipient and lets the sender know
+func (s *MessagingService) markDelivered(message *models.DirectMessage, recipientID int64, recipient string) {
+	moved, err := s.conversationRepo.MarkDelivered(message.ConversationID, recipientID, message.ID)
+	if err != nil {
+		fmt.Printf("Failed to mark message %d delivered: %v\n", message.ID, err)
+		return
+	}
+	if !moved {
+		return
+	}
+
+	sender, err := s.userRepo.GetByID(message.SenderID)
+	if err != nil || sender == nil {
+		return
+	}
+	s.wsHub.Publish(context.Background(), []string{sender.Username}, events.MessageReceipt{
+		ConversationID: message.ConversationID,
+		Username:       recipient,
+		Type:           "delivered",
+		MessageID:      message.ID,
+	})
+}
+
+// push sends a message to the user's sockets. Only a connected user counts as
+// delivered; offline users get it from deliverPending when they come back.
+func (s *MessagingService) push(username string, message *models.DirectMessage) bool {
+	if !s.wsHub.IsConnected(username) {
+		return false
+	}
+	return s.wsHub.Publish(context.Background(), []string{username}, events.DirectMessage{DirectMessage: message}) == nil
+}
+
+func (s *MessagingService) memberOf(conversationID, userID int64) ([]*models.ConversationMember, error) {
+	members, err := s.conversationRepo.GetMembers(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		if member.UserID == userID {
+			return members, nil
+		}
+	}
+	return nil, ErrConversationNotFound
+}
+
+func (s *MessagingService) loadConversation(conversationID, userID int64) (*models.Conversation, error) {
+	conv, err := s.conversationRepo.GetByID(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if conv == nil {
+		return nil, ErrConversationNotFound
+	}
+	if err := s.fillConversation(conv, userID); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+func (s *MessagingService) fillConversation(conv *models.Conversation, userID int64) error {
+	members, err := s.conversationRepo.GetMembers(conv.ID)
+	if err != nil {
+		return err
+	}
+	conv.Members = members
+
+	last, err := s.conversationRepo.GetLastMessage(conv.ID)
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		applyMessageStatus(last, members, userID)
+	}
+	conv.LastMessage = last
+
+	unread, err := s.conversationRepo.UnreadCount(conv.ID, userID)
+	if err != nil {
+		return err
+	}
+	conv.UnreadCount = unread
+	return nil
+}
+
+func (s *MessagingService) handleSocketMessage(username string, data json.RawMessage) error {
+	var req directMessageRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.ConversationID == 0 {
+		return fmt.Errorf("conversationId and body are required")
+	}
+
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	_, err = s.SendMessage(req.ConversationID, user.ID, req.Body)
+	return err
+}
+
+func (s *MessagingService) handleSocketRead(username string, data json.RawMessage) error {
+	var req directMessageReadRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.ConversationID == 0 {
+		return fmt.Errorf("conversationId is required")
+	}
+
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	return s.MarkRead(req.ConversationID, user.ID, req.MessageID)
+}
+
+// applyMessageStatus sets the receipt status on the viewer's own messages:
+// "read" once every other member read it, "delivered" once it reached all of them
+func applyMessageStatus(message *models.DirectMessage, members []*models.ConversationMember, viewerID int64) {
+	if message.SenderID != viewerID {
+		return
+	}
+
+	status := "read"
+	for _, member := range members {
+		if member.UserID == viewerID {
+			continue
+		}
+		if member.LastDeliveredMessageID < message.ID {
+			status = "sent"
+			break
+		}
+		if member.LastReadMessageID < message.ID {
+			status = "delivered"
+		}
+	}
+	message.Status = status
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"encoding/json"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"testing"
+)
+
+type messagingTestEnv struct {
+	db        *database.DB
+	wsHub     *websocket.WebSocketHub
+	messaging *MessagingService
+	users     map[string]*models.User
+}
+
+// newMessagingTestEnv creates the users and makes the first one a contact of every other
+func newMessagingTestEnv(t *testing.T, usernames ...string) *messagingTestEnv {
+	t.Helper()
+
+	env := &messagingTestEnv{
+		db:    newTestDB(t),
+		wsHub: websocket.NewWebSocketHub(),
+		users: make(map[string]*models.User),
+	}
+	env.messaging = NewMessagingService(env.db, env.wsHub)
+	for _, username := range usernames {
+		env.users[username] = createTestUser(t, env.db, username)
+	}
+	contacts := database.NewContactRepo(env.db)
+	for _, username := range usernames[1:] {
+		if err := contacts.Add(env.users[usernames[0]].ID, env.users[username].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return env
+}
+
+// connect opens a socket for the user and delivers what they missed, as the connect hook does
+func (env *messagingTestEnv) connect(username string) *websocket.Connection {
+	conn := &websocket.Connection{ID: username, Username: username, Send: make(chan []byte, 64)}
+	env.wsHub.Register(username, conn)
+	env.messaging.deliverPending(username)
+	return conn
+}
+
+func (env *messagingTestEnv) conversation(t *testing.T, creator string, others ...string) int64 {
+	t.Helper()
+
+	conv, _, err := env.messaging.CreateConversation(env.users[creator].ID, others, "")
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	return conv.ID
+}
+
+func (env *messagingTestEnv) send(t *testing.T, conversationID int64, sender, body string) *models.DirectMessage {
+	t.Helper()
+
+	message, err := env.messaging.SendMessage(conversationID, env.users[sender].ID, body)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	return message
+}
+
+// statusSeenBy is the receipt status of a message as its sender sees it
+func (env *messagingTestEnv) statusSeenBy(t *testing.T, conversationID int64, sender string, messageID int64) string {
+	t.Helper()
+
+	messages, err := env.messaging.GetMessages(conversationID, env.users[sender].ID, 0, 50)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	for _, message := range messages {
+		if message.ID == messageID {
+			return message.Status
+		}
+	}
+	t.Fatalf("message %d not found", messageID)
+	return ""
+}
+
+// receipts drains the socket and returns the "username:type" receipts it was told about
+func receipts(t *testing.T, conn *websocket.Connection) []string {
+	t.Helper()
+
+	var got []string
+	for {
+		select {
+		case data := <-conn.Send:
+			var envelope events.Envelope
+			if err := json.Unmarshal(data, &envelope); err != nil {
+				t.Fatalf("decode %s: %v", data, err)
+			}
+			if envelope.Type != "message_receipt" {
+				continue
+			}
+			var receipt events.MessageReceipt
+			if err := json.Unmarshal(envelope.Data, &receipt); err != nil {
+				t.Fatalf("decode %s: %v", envelope.Data, err)
+			}
+			got = append(got, receipt.Username+":"+receipt.Type)
+		default:
+			return got
+		}
+	}
+}
+
+func TestDirectMessageReceipts(t *testing.T) {
+	env := newMessagingTestEnv(t, "alice", "bob")
+	alice := env.connect("alice")
+	conversationID := env.conversation(t, "alice", "bob")
+
+	// Bob is offline, so the message is only sent
+	first := env.send(t, conversationID, "alice", "Are you there?")
+	if first.Status != "sent" {
+		t.Errorf("message to an offline member is %s, want sent", first.Status)
+	}
+	if got := receipts(t, alice); len(got) != 0 {
+		t.Errorf("alice got receipts %v before bob connected", got)
+	}
+
+	// Connecting delivers it
+	bob := env.connect("bob")
+	var delivered []int64
+	for _, envelope := range drain(t, bob) {
+		if envelope.Type == "direct_message" {
+			var message models.DirectMessage
+			json.Unmarshal(envelope.Data, &message)
+			delivered = append(delivered, message.ID)
+		}
+	}
+	if len(delivered) != 1 || delivered[0] != first.ID {
+		t.Errorf("bob got messages %v on connect, want [%d]", delivered, first.ID)
+	}
+	if got := receipts(t, alice); len(got) != 1 || got[0] != "bob:delivered" {
+		t.Errorf("alice got receipts %v, want [bob:delivered]", got)
+	}
+	if got := env.statusSeenBy(t, conversationID, "alice", first.ID); got != "delivered" {
+		t.Errorf("message is %s after bob connected, want delivered", got)
+	}
+
+	// A second connect delivers nothing again
+	env.wsHub.Unregister("bob", "bob")
+	env.connect("bob")
+	if got := receipts(t, alice); len(got) != 0 {
+		t.Errorf("alice got receipts %v on bob's reconnect", got)
+	}
+
+	// While bob is online, messages are delivered right away
+	second := env.send(t, conversationID, "alice", "Call me")
+	if second.Status != "delivered" {
+		t.Errorf("message to an online member is %s, want delivered", second.Status)
+	}
+	receipts(t, alice)
+
+	// Reading up to the first message leaves the second delivered
+	if err := env.messaging.MarkRead(conversationID, env.users["bob"].ID, first.ID); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if got := receipts(t, alice); len(got) != 1 || got[0] != "bob:read" {
+		t.Errorf("alice got receipts %v, want [bob:read]", got)
+	}
+	if got := env.statusSeenBy(t, conversationID, "alice", first.ID); got != "read" {
+		t.Errorf("first message is %s, want read", got)
+	}
+	if got := env.statusSeenBy(t, conversationID, "alice", second.ID); got != "delivered" {
+		t.Errorf("second message is %s, want delivered", got)
+	}
+
+	// Reading to the end reads both; the read cursor never moves back
+	if err := env.messaging.MarkRead(conversationID, env.users["bob"].ID, 0); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if err := env.messaging.MarkRead(conversationID, env.users["bob"].ID, first.ID); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if got := receipts(t, alice); len(got) != 1 || got[0] != "bob:read" {
+		t.Errorf("alice got receipts %v, want a single bob:read", got)
+	}
+	if got := env.statusSeenBy(t, conversationID, "alice", second.ID); got != "read" {
+		t.Errorf("second message is %s, want read", got)
+	}
+}
+
+func TestGroupMessageIsReadOnceEveryMemberRead(t *testing.T) {
+	env := newMessagingTestEnv(t, "alice", "bob", "carol")
+	env.connect("alice")
+	env.connect("bob")
+	env.connect("carol")
+	conversationID := env.conversation(t, "alice", "bob", "carol")
+
+	message := env.send(t, conversationID, "alice", "Hello both")
+	if message.Status != "delivered" {
+		t.Errorf("message is %s, want delivered", message.Status)
+	}
+
+	env.messaging.MarkRead(conversationID, env.users["bob"].ID, 0)
+	if got := env.statusSeenBy(t, conversationID, "alice", message.ID); got != "delivered" {
+		t.Errorf("message read by one of two members is %s, want delivered", got)
+	}
+	env.messaging.MarkRead(conversationID, env.users["carol"].ID, 0)
+	if got := env.statusSeenBy(t, conversationID, "alice", message.ID); got != "read" {
+		t.Errorf("message read by every member is %s, want read", got)
+	}
+}
+
+func TestUnreadCountPerConversation(t *testing.T) {
+	env := newMessagingTestEnv(t, "alice", "bob", "carol")
+	database.NewContactRepo(env.db).Add(env.users["bob"].ID, env.users["carol"].ID)
+	direct := env.conversation(t, "alice", "bob")
+	group := env.conversation(t, "alice", "bob", "carol")
+
+	firstDirect := env.send(t, direct, "bob", "One")
+	env.send(t, direct, "bob", "Two")
+	env.send(t, group, "alice", "Hi") // Own messages are never unread
+	env.send(t, group, "carol", "Hi all")
+
+	unread := func(username string) map[int64]int {
+		t.Helper()
+		conversations, err := env.messaging.ListConversations(env.users[username].ID)
+		if err != nil {
+			t.Fatalf("ListConversations: %v", err)
+		}
+		counts := make(map[int64]int)
+		for _, conv := range conversations {
+			counts[conv.ID] = conv.UnreadCount
+		}
+		return counts
+	}
+	total := func(username string) int {
+		t.Helper()
+		count, err := env.messaging.UnreadCount(env.users[username].ID)
+		if err != nil {
+			t.Fatalf("UnreadCount: %v", err)
+		}
+		return count
+	}
+
+	if got := unread("alice"); got[direct] != 2 || got[group] != 1 {
+		t.Errorf("alice has %d unread in the direct and %d in the group conversation, want 2 and 1", got[direct], got[group])
+	}
+	if got := total("alice"); got != 3 {
+		t.Errorf("alice has %d unread in total, want 3", got)
+	}
+	if got := unread("bob"); got[direct] != 0 || got[group] != 2 {
+		t.Errorf("bob has %d unread in the direct and %d in the group conversation, want 0 and 2", got[direct], got[group])
+	}
+
+	if err := env.messaging.MarkRead(direct, env.users["alice"].ID, firstDirect.ID); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if got := unread("alice"); got[direct] != 1 || got[group] != 1 {
+		t.Errorf("after reading one message alice has %d and %d unread, want 1 and 1", got[direct], got[group])
+	}
+	if err := env.messaging.MarkRead(group, env.users["alice"].ID, 0); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if got := total("alice"); got != 1 {
+		t.Errorf("alice has %d unread in total, want 1", got)
+	}
+	if got := unread("bob"); got[group] != 2 {
+		t.Errorf("alice reading the group changed bob's unread count to %d, want 2", got[group])
+	}
+}
+
+// drain returns every envelope queued on the socket
+func drain(t *testing.T, conn *websocket.Connection) []events.Envelope {
+	t.Helper()
+
+	var envelopes []events.Envelope
+	for {
+		select {
+		case data := <-conn.Send:
+			var envelope events.Envelope
+			if err := json.Unmarshal(data, &envelope); err != nil {
+				t.Fatalf("decode %s: %v", data, err)
+			}
+			envelopes = append(envelopes, envelope)
+		default:
+			return envelopes
+		}
+	}
+}
@@ -6,15 +6,27 @@ import (
 	"livekit/websocket"
 
 	livekit "github.com/livekit/protocol/livekit"
-	lksdk "github.com/livekit/server-sdk-go/v2"
 )
 
+// RoomClient is the subset of the LiveKit Room API used for rooms and their
+// participants.
+// *lksdk.RoomServiceClient satisfies it.
+type RoomClient interface {
+	CreateRoom(ctx context.Context, req *livekit.CreateRoomRequest) (*livekit.Room, error)
+	DeleteRoom(ctx context.Context, req *livekit.DeleteRoomRequest) (*livekit.DeleteRoomResponse, error)
+	ListParticipants(ctx context.Context, req *livekit.ListParticipantsRequest) (*livekit.ListParticipantsResponse, error)
+	GetParticipant(ctx context.Context, req *livekit.RoomParticipantIdentity) (*livekit.ParticipantInfo, error)
+	UpdateParticipant(ctx context.Context, req *livekit.UpdateParticipantRequest) (*livekit.ParticipantInfo, error)
+	RemoveParticipant(ctx context.Context, req *livekit.RoomParticipantIdentity) (*livekit.RemoveParticipantResponse, error)
+	MutePublishedTrack(ctx context.Context, req *livekit.MuteRoomTrackRequest) (*livekit.MuteRoomTrackResponse, error)
+}
+
 type ParticipantService struct {
-	roomClient *lksdk.RoomServiceClient
+	roomClient RoomClient
 	wsHub      *websocket.WebSocketHub
 }
 
-func NewParticipantService(roomClient *lksdk.RoomServiceClient, wsHub *websocket.WebSocketHub) *ParticipantService {
+func NewParticipantService(roomClient RoomClient, wsHub *websocket.WebSocketHub) *ParticipantService {
 	return &ParticipantService{
 		roomClient: roomClient,
 		wsHub:      wsHub,
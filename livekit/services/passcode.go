@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"livekit/auth"
+	"livekit/database"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxPasscodeAttempts   = 5
+	passcodeAttemptWindow = 15 * time.Minute
+)
+
+var (
+	ErrPasscodeRequired        = errors.New("a passcode is required to join this call")
+	ErrInvalidPasscode         = errors.New("invalid passcode")
+	ErrInvalidPasscodeFormat   = errors.New("passcode must be between 4 and 64 characters")
+	ErrTooManyPasscodeAttempts = errors.New("too many passcode attempts, try again later")
+)
+
+// passcodeLimiter counts failed passcode attempts per call and client so a
+// shared join link can't be brute forced. The counts live in the database so
+// every instance behind a load balancer shares them.
+type passcodeLimiter struct {
+	repo *database.PasscodeAttemptRepo
+	now  func() time.Time
+}
+
+func newPasscodeLimiter(db *database.DB) *passcodeLimiter {
+	return &passcodeLimiter{repo: database.NewPasscodeAttemptRepo(db), now: time.Now}
+}
+
+func (l *passcodeLimiter) allow(key string) (bool, error) {
+	failures, err := l.repo.Failures(key, l.now().Add(-passcodeAttemptWindow))
+	if err != nil {
+		return false, err
+	}
+	return failures < maxPasscodeAttempts, nil
+}
+
+func (l *passcodeLimiter) recordFailure(key string) error {
+	now := l.now()
+	return l.repo.RecordFailure(key, now, now.Add(-passcodeAttemptWindow))
+}
+
+func (l *passcodeLimiter) reset(key string) error {
+	return l.repo.Reset(key)
+}
+
+// hashPasscode validates and hashes a passcode. An empty passcode yields an
+// empty hash, which means the call has no passcode.
+func hashPasscode(passcode string) (string, error) {
+	if passcode == "" {
+		return "", nil
+	}
+	if n := utf8.RuneCountInString(passcode); n < 4 || n > 64 {
+		return "", ErrInvalidPasscodeFormat
+	}
+
+	hash, err := auth.HashPassword(passcode)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash passcode: %w", err)
+	}
+	return hash, nil
+}
+
+// verifyPasscode checks a passcode against the stored hash. target identifies
+// the call and client identifies the caller for attempt limiting.
+func (s *CallService) verifyPasscode(passcodeHash, passcode, target, client string) error {
+	if passcodeHash == "" {
+		return nil
+	}
+
+	key := target + "|" + client
+	allowed, err := s.passcodeLimiter.allow(key)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrTooManyPasscodeAttempts
+	}
+	if passcode == "" {
+		return ErrPasscodeRequired
+	}
+	if err := auth.VerifyPassword(passcodeHash, passcode); err != nil {
+		if err := s.passcodeLimiter.recordFailure(key); err != nil {
+			fmt.Printf("Failed to record passcode attempt for %s: %v\n", target, err)
+		}
+		return ErrInvalidPasscode
+	}
+
+	if err := s.passcodeLimiter.reset(key); err != nil {
+		fmt.Printf("Failed to reset passcode attempts for %s: %v\n", target, err)
+	}
+	return nil
+}
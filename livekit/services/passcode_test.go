@@ -0,0 +1,130 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHashPasscode(t *testing.T) {
+	if hash, err := hashPasscode(""); err != nil || hash != "" {
+		t.Errorf("empty passcode hashed to %q, %v; want no passcode", hash, err)
+	}
+	for _, passcode := range []string{"123", string(make([]rune, 65))} {
+		if _, err := hashPasscode(passcode); !errors.Is(err, ErrInvalidPasscodeFormat) {
+			t.Errorf("hashPasscode(%d runes) error = %v, want %v", len([]rune(passcode)), err, ErrInvalidPasscodeFormat)
+		}
+	}
+
+	hash, err := hashPasscode("1234")
+	if err != nil {
+		t.Fatalf("hashPasscode: %v", err)
+	}
+	if hash == "1234" || hash == "" {
+		t.Errorf("passcode stored as %q", hash)
+	}
+}
+
+func TestVerifyPasscode(t *testing.T) {
+	db := newTestDB(t)
+	s := newTestCallService(t, db, newFakeRoomClient(), nil)
+	hash, err := hashPasscode("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hash     string
+		passcode string
+		wantErr  error
+	}{
+		{name: "call without passcode", passcode: "anything"},
+		{name: "correct passcode", hash: hash, passcode: "1234"},
+		{name: "wrong passcode", hash: hash, passcode: "4321", wantErr: ErrInvalidPasscode},
+		{name: "missing passcode", hash: hash, wantErr: ErrPasscodeRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.verifyPasscode(tt.hash, tt.passcode, "call:"+tt.name, "guest:10.0.0.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("verifyPasscode error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPasscodeAttemptLimit(t *testing.T) {
+	db := newTestDB(t)
+	s := newTestCallService(t, db, newFakeRoomClient(), nil)
+	hash, err := hashPasscode("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	s.passcodeLimiter.now = func() time.Time { return now }
+
+	fail := func(target, client string) {
+		t.Helper()
+		if err := s.verifyPasscode(hash, "0000", target, client); !errors.Is(err, ErrInvalidPasscode) {
+			t.Fatalf("wrong passcode error = %v, want %v", err, ErrInvalidPasscode)
+		}
+	}
+	verify := func(target, client string) error {
+		return s.verifyPasscode(hash, "1234", target, client)
+	}
+
+	for i := 0; i < maxPasscodeAttempts; i++ {
+		fail("call:a", "guest:10.0.0.1")
+	}
+	if err := verify("call:a", "guest:10.0.0.1"); !errors.Is(err, ErrTooManyPasscodeAttempts) {
+		t.Fatalf("correct passcode after %d failures: error = %v, want %v", maxPasscodeAttempts, err, ErrTooManyPasscodeAttempts)
+	}
+
+	// The count is per call and client
+	if err := verify("call:a", "guest:10.0.0.2"); err != nil {
+		t.Errorf("another client of the same call was limited: %v", err)
+	}
+	if err := verify("call:b", "guest:10.0.0.1"); err != nil {
+		t.Errorf("the same client on another call was limited: %v", err)
+	}
+
+	// Another instance on the same database sees the same count
+	other := newTestCallService(t, db, newFakeRoomClient(), nil)
+	other.passcodeLimiter.now = s.passcodeLimiter.now
+	if err := other.verifyPasscode(hash, "1234", "call:a", "guest:10.0.0.1"); !errors.Is(err, ErrTooManyPasscodeAttempts) {
+		t.Errorf("another instance error = %v, want %v", err, ErrTooManyPasscodeAttempts)
+	}
+
+	// A new window starts once the old one has passed
+	now = now.Add(passcodeAttemptWindow + time.Second)
+	if err := verify("call:a", "guest:10.0.0.1"); err != nil {
+		t.Errorf("still limited after the window passed: %v", err)
+	}
+
+	// Failures spread over the window add up
+	for i := 0; i < maxPasscodeAttempts-1; i++ {
+		fail("call:c", "guest:10.0.0.1")
+	}
+	now = now.Add(passcodeAttemptWindow / 2)
+	fail("call:c", "guest:10.0.0.1")
+	if err := verify("call:c", "guest:10.0.0.1"); !errors.Is(err, ErrTooManyPasscodeAttempts) {
+		t.Errorf("failures across the window: error = %v, want %v", err, ErrTooManyPasscodeAttempts)
+	}
+
+	// A correct passcode clears earlier failures
+	for i := 0; i < maxPasscodeAttempts-1; i++ {
+		fail("call:d", "guest:10.0.0.1")
+	}
+	if err := verify("call:d", "guest:10.0.0.1"); err != nil {
+		t.Fatalf("limited before reaching %d failures: %v", maxPasscodeAttempts, err)
+	}
+	for i := 0; i < maxPasscodeAttempts-1; i++ {
+		fail("call:d", "guest:10.0.0.1")
+	}
+	if err := verify("call:d", "guest:10.0.0.1"); err != nil {
+		t.Errorf("earlier failures still counted after a correct passcode: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxPollOptions        = 10
+	maxPollQuestionLength = 300
+	maxPollOptionLength   = 100
+)
+
+var (
+	ErrInvalidPoll  = errors.New("invalid poll")
+	ErrPollNotFound = errors.New("poll not found")
+	ErrPollClosed   = errors.New("poll is closed")
+	ErrInvalidVote  = errors.New("invalid vote")
+)
+
+type CreatePollRequest struct {
+	Question       string   `json:"question"`
+	Options        []string `json:"options"`
+	MultipleChoice bool     `json:"multipleChoice"`
+	Anonymous      bool     `json:"anonymous"`
+}
+
+type pollVoteRequest struct {
+	PollID    int64   `json:"pollId"`
+	OptionIDs []int64 `json:"optionIds"`
+}
+
+type PollService struct {
+	db          *database.DB
+	pollRepo    *database.PollRepo
+	callRepo    *database.CallRepo
+	userRepo    *database.UserRepo
+	callService *CallService
+	wsHub       *websocket.WebSocketHub
+}
+
+func NewPollService(db *database.DB, callService *CallService, wsHub *websocket.WebSocketHub) *PollService {
+	s := &PollService{
+		db:          db,
+		pollRepo:    database.NewPollRepo(db),
+		callRepo:    database.NewCallRepo(db),
+		userRepo:    database.NewUserRepo(db),
+		callService: callService,
+		wsHub:       wsHub,
+	}
+	// Results are final once the call is over
+	callService.OnCallEnded(func(call *models.ActiveCall) {
+		if err := s.pollRepo.CloseOpenByCallID(call.CallID); err != nil {
+			fmt.Printf("Failed to close polls for call %s: %v\n", call.CallID, err)
+		}
+	})
+	if wsHub != nil {
+		wsHub.OnClientMessage("poll_vote", s.handleSocketVote)
+	}
+	return s
+}
+
+func (s *PollService) CreatePoll(callID string, userID int64, req CreatePollRequest) (*models.Poll, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+
+	poll, err := newPoll(callID, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pollRepo.Create(poll); err != nil {
+		return nil, err
+	}
+
+	s.broadcast(call, events.PollCreated{Poll: poll})
+	return poll, nil
+}
+
+// GetPolls lists a call's polls with live tallies and the user's own votes
+func (s *PollService) GetPolls(callID string, userID int64, username string) ([]*models.Poll, error) {
+	if _, err := s.memberCall(callID, userID, username); err != nil {
+		return nil, err
+	}
+
+	polls, err := s.pollRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+	for _, poll := range polls {
+		myVotes, err := s.pollRepo.GetUserVotes(poll.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		poll.MyVotes = myVotes
+	}
+
+	if polls == nil {
+		polls = []*models.Poll{}
+	}
+	return polls, nil
+}
+
+// Vote records the user's choice, replacing an earlier vote on the same poll,
+// and broadcasts the new tally
+func (s *PollService) Vote(pollID, userID int64, username string, optionIDs []int64) (*models.Poll, error) {
+	poll, err := s.pollRepo.GetByID(pollID)
+	if err != nil {
+		return nil, err
+	}
+	if poll == nil {
+		return nil, ErrPollNotFound
+	}
+
+	call, err := s.memberCall(poll.CallID, userID, username)
+	if err != nil {
+		if errors.Is(err, ErrCallNotFound) {
+			return nil, ErrPollNotFound
+		}
+		return nil, err
+	}
+	if poll.Status != "open" {
+		return nil, ErrPollClosed
+	}
+
+	var choices []int64
+	for _, optionID := range optionIDs {
+		if !slices.Contains(choices, optionID) {
+			choices = append(choices, optionID)
+		}
+	}
+	if len(choices) == 0 {
+		return nil, fmt.Errorf("%w: choose at least one option", ErrInvalidVote)
+	}
+	if !poll.MultipleChoice && len(choices) > 1 {
+		return nil, fmt.Errorf("%w: this poll allows only one option", ErrInvalidVote)
+	}
+	for _, optionID := range choices {
+		if !slices.ContainsFunc(poll.Options, func(option *models.PollOption) bool { return option.ID == optionID }) {
+			return nil, fmt.Errorf("%w: option %d is not part of this poll", ErrInvalidVote, optionID)
+		}
+	}
+
+	if err := s.pollRepo.ReplaceVotes(pollID, userID, username, choices); err != nil {
+		return nil, err
+	}
+
+	poll, err = s.pollRepo.GetByID(pollID)
+	if err != nil {
+		return nil, err
+	}
+	s.broadcast(call, events.PollUpdated{Poll: poll})
+
+	poll.MyVotes = choices
+	return poll, nil
+}
+
+func (s *PollService) ClosePoll(pollID, userID int64) (*models.Poll, error) {
+	poll, err := s.pollRepo.GetByID(pollID)
+	if err != nil {
+		return nil, err
+	}
+	if poll == nil {
+		return nil, ErrPollNotFound
+	}
+
+	call, err := s.callRepo.GetByCallID(poll.CallID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil || call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+	if poll.Status != "open" {
+		return nil, ErrPollClosed
+	}
+
+	if err := s.pollRepo.Close(pollID); err != nil {
+		return nil, err
+	}
+	poll, err = s.pollRepo.GetByID(pollID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.broadcast(call, events.PollClosed{Poll: poll})
+	return poll, nil
+}
+
+func (s *PollService) broadcast(call *models.ActiveCall, event events.Event) {
+	if s.wsHub == nil {
+		return
+	}
+	s.wsHub.Publish(context.Background(), s.callService.callMemberUsernames(call), event)
+}
+
+func (s *PollService) memberCall(callID string, userID int64, username string) (*models.ActiveCall, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID && !slices.Contains(s.callService.callMemberUsernames(call), username) {
+		return nil, ErrCallNotFound
+	}
+	return call, nil
+}
+
+func (s *PollService) handleSocketVote(username string, data json.RawMessage) error {
+	var req pollVoteRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.PollID == 0 {
+		return fmt.Errorf("pollId and optionIds are required")
+	}
+
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	_, err = s.Vote(req.PollID, user.ID, username, req.OptionIDs)
+	return err
+}
+
+func newPoll(callID string, userID int64, req CreatePollRequest) (*models.Poll, error) {
+	question := strings.TrimSpace(req.Question)
+	if question == "" || utf8.RuneCountInString(question) > maxPollQuestionLength {
+		return nil, fmt.Errorf("%w: question must be between 1 and %d characters", ErrInvalidPoll, maxPollQuestionLength)
+	}
+	if len(req.Options) < 2 || len(req.Options) > maxPollOptions {
+		return nil, fmt.Errorf("%w: a poll needs between 2 and %d options", ErrInvalidPoll, maxPollOptions)
+	}
+
+	poll := &models.Poll{
+		CallID:         callID,
+		CreatedBy:      userID,
+		Question:       question,
+		MultipleChoice: req.MultipleChoice,
+		Anonymous:      req.Anonymous,
+	}
+	for _, label := range req.Options {
+		label = strings.TrimSpace(label)
+		if label == "" || utf8.RuneCountInString(label) > maxPollOptionLength {
+			return nil, fmt.Errorf("%w: options must be between 1 and %d characters", ErrInvalidPoll, maxPollOptionLength)
+		}
+		poll.Options = append(poll.Options, &models.PollOption{Label: label})
+	}
+	return poll, nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"livekit/database"
+	"livekit/models"
+	"slices"
+	"testing"
+)
+
+type pollTestEnv struct {
+	db          *database.DB
+	callService *CallService
+	polls       *PollService
+	host        *models.User
+	alice       *models.User
+	bob         *models.User
+	callID      string
+}
+
+// newPollTestEnv starts a call hosted by "host" that alice and bob have joined
+func newPollTestEnv(t *testing.T) *pollTestEnv {
+	t.Helper()
+
+	db := newTestDB(t)
+	callService := newTestCallService(t, db, newFakeRoomClient(), nil)
+	env := &pollTestEnv{
+		db:          db,
+		callService: callService,
+		polls:       NewPollService(db, callService, nil),
+		host:        createTestUser(t, db, "host"),
+		alice:       createTestUser(t, db, "alice"),
+		bob:         createTestUser(t, db, "bob"),
+	}
+	env.callID = startTestCall(t, callService, env.host, "alice", "bob")
+
+	invitations, err := database.NewInvitationRepo(db).GetCallParticipants(env.callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, invitation := range invitations {
+		if _, err := callService.RespondToInvitation(invitation.ID, invitation.InviteeID, "accept"); err != nil {
+			t.Fatalf("RespondToInvitation: %v", err)
+		}
+	}
+	return env
+}
+
+func (env *pollTestEnv) create(t *testing.T, req CreatePollRequest) *models.Poll {
+	t.Helper()
+
+	poll, err := env.polls.CreatePoll(env.callID, env.host.ID, req)
+	if err != nil {
+		t.Fatalf("CreatePoll: %v", err)
+	}
+	return poll
+}
+
+func optionVotes(poll *models.Poll) []int {
+	votes := make([]int, len(poll.Options))
+	for i, option := range poll.Options {
+		votes[i] = option.Votes
+	}
+	return votes
+}
+
+func TestPollSingleChoiceVoting(t *testing.T) {
+	env := newPollTestEnv(t)
+	poll := env.create(t, CreatePollRequest{Question: "Lunch?", Options: []string{"Pizza", "Sushi"}})
+	pizza, sushi := poll.Options[0].ID, poll.Options[1].ID
+
+	if _, err := env.polls.Vote(poll.ID, env.alice.ID, "alice", []int64{pizza, sushi}); !errors.Is(err, ErrInvalidVote) {
+		t.Errorf("two options on a single choice poll: %v, want ErrInvalidVote", err)
+	}
+	if _, err := env.polls.Vote(poll.ID, env.alice.ID, "alice", []int64{sushi}); err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+	// A second vote replaces the first
+	if _, err := env.polls.Vote(poll.ID, env.alice.ID, "alice", []int64{pizza}); err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+	result, err := env.polls.Vote(poll.ID, env.bob.ID, "bob", []int64{pizza})
+	if err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+
+	if got := optionVotes(result); !slices.Equal(got, []int{2, 0}) {
+		t.Errorf("votes %v, want [2 0]", got)
+	}
+	if result.TotalVoters != 2 || !slices.Equal(result.Options[0].Voters, []string{"alice", "bob"}) {
+		t.Errorf("voters %d %v, want alice and bob", result.TotalVoters, result.Options[0].Voters)
+	}
+	if !slices.Equal(result.MyVotes, []int64{pizza}) {
+		t.Errorf("bob's votes %v, want [%d]", result.MyVotes, pizza)
+	}
+}
+
+func TestPollMultipleChoiceAnonymousVoting(t *testing.T) {
+	env := newPollTestEnv(t)
+	poll := env.create(t, CreatePollRequest{
+		Question:       "Which days work?",
+		Options:        []string{"Monday", "Tuesday", "Friday"},
+		MultipleChoice: true,
+		Anonymous:      true,
+	})
+	monday, tuesday, friday := poll.Options[0].ID, poll.Options[1].ID, poll.Options[2].ID
+
+	if _, err := env.polls.Vote(poll.ID, env.alice.ID, "alice", []int64{monday, friday, monday}); err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+	if _, err := env.polls.Vote(poll.ID, env.bob.ID, "bob", []int64{tuesday, friday}); err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+	if _, err := env.polls.Vote(poll.ID, env.bob.ID, "bob", []int64{999}); !errors.Is(err, ErrInvalidVote) {
+		t.Errorf("vote for an unknown option: %v, want ErrInvalidVote", err)
+	}
+
+	polls, err := env.polls.GetPolls(env.callID, env.alice.ID, "alice")
+	if err != nil || len(polls) != 1 {
+		t.Fatalf("GetPolls: %v, %v", polls, err)
+	}
+	if got := optionVotes(polls[0]); !slices.Equal(got, []int{1, 1, 2}) {
+		t.Errorf("votes %v, want [1 1 2]", got)
+	}
+	if polls[0].TotalVoters != 2 {
+		t.Errorf("total voters %d, want 2", polls[0].TotalVoters)
+	}
+	for _, option := range polls[0].Options {
+		if len(option.Voters) != 0 {
+			t.Errorf("anonymous poll option %q names voters %v", option.Label, option.Voters)
+		}
+	}
+	if !slices.Equal(polls[0].MyVotes, []int64{monday, friday}) {
+		t.Errorf("alice's votes %v, want [%d %d]", polls[0].MyVotes, monday, friday)
+	}
+}
+
+func TestClosePoll(t *testing.T) {
+	env := newPollTestEnv(t)
+	poll := env.create(t, CreatePollRequest{Question: "Ship it?", Options: []string{"Yes", "No"}})
+
+	if _, err := env.polls.ClosePoll(poll.ID, env.alice.ID); !errors.Is(err, ErrNotCallHost) {
+		t.Errorf("ClosePoll by a participant: %v, want ErrNotCallHost", err)
+	}
+	closed, err := env.polls.ClosePoll(poll.ID, env.host.ID)
+	if err != nil {
+		t.Fatalf("ClosePoll: %v", err)
+	}
+	if closed.Status != "closed" || closed.ClosedAt == nil {
+		t.Errorf("poll is %s, closed at %v; want closed with a close time", closed.Status, closed.ClosedAt)
+	}
+	if _, err := env.polls.Vote(poll.ID, env.alice.ID, "alice", []int64{poll.Options[0].ID}); !errors.Is(err, ErrPollClosed) {
+		t.Errorf("Vote on a closed poll: %v, want ErrPollClosed", err)
+	}
+	if _, err := env.polls.ClosePoll(poll.ID, env.host.ID); !errors.Is(err, ErrPollClosed) {
+		t.Errorf("closing a poll twice: %v, want ErrPollClosed", err)
+	}
+}
+
+func TestPollResultsInHistory(t *testing.T) {
+	env := newPollTestEnv(t)
+	poll := env.create(t, CreatePollRequest{Question: "Lunch?", Options: []string{"Pizza", "Sushi"}})
+	if _, err := env.polls.Vote(poll.ID, env.alice.ID, "alice", []int64{poll.Options[1].ID}); err != nil {
+		t.Fatalf("Vote: %v", err)
+	}
+	if err := env.callService.EndCall(env.callID, env.host.ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+
+	history, err := env.callService.historyService.GetCallDetailsForUser(env.callID, env.alice.ID)
+	if err != nil || history == nil {
+		t.Fatalf("GetCallDetailsForUser: %v, %v", history, err)
+	}
+	if len(history.Polls) != 1 {
+		t.Fatalf("history has %d polls, want 1", len(history.Polls))
+	}
+	// Ending the call closes the poll with its final tally
+	if got := history.Polls[0]; got.Status != "closed" || !slices.Equal(optionVotes(got), []int{0, 1}) {
+		t.Errorf("history poll is %s with votes %v, want closed with [0 1]", got.Status, optionVotes(got))
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidQuietHours = errors.New("quietHours needs start and end as different HH:MM times and a valid timezone")
+	ErrInvalidMutedEvent = errors.New("mutedEvents may only name known events that can be muted")
+)
+
+// quietHoursLayout is the format of the start and end of quiet hours
+const quietHoursLayout = "15:04"
+
+// ringingEvents ring or remind the user and are held back while they do not
+// want to be disturbed
+var ringingEvents = map[string]bool{
+	"call_invitation":         true,
+	"scheduled_call_reminder": true,
+	"scheduled_call_starting": true,
+}
+
+// unmutableEvents change what the client has to do in a call, e.g. leave it or
+// switch rooms, so they cannot be muted
+var unmutableEvents = map[string]bool{
+	"call_ended":            true,
+	"call_cancelled":        true,
+	"call_type_changed":     true,
+	"lobby_admitted":        true,
+	"lobby_denied":          true,
+	"breakout_assigned":     true,
+	"breakout_return":       true,
+	"screen_share_decision": true,
+}
+
+// PreferenceService keeps users' do-not-disturb and notification settings and
+// filters the events sent to their sockets by them
+type PreferenceService struct {
+	db             *database.DB
+	preferenceRepo *database.PreferenceRepo
+}
+
+func NewPreferenceService(db *database.DB, wsHub *websocket.WebSocketHub) *PreferenceService {
+	s := &PreferenceService{
+		db:             db,
+		preferenceRepo: database.NewPreferenceRepo(db),
+	}
+	if wsHub != nil {
+		wsHub.SetEventFilter(s)
+	}
+	return s
+}
+
+func (s *PreferenceService) GetPreferences(userID int64) (*models.UserPreferences, error) {
+	return s.preferenceRepo.Get(userID)
+}
+
+// UpdatePreferences replaces the user's preferences
+func (s *PreferenceService) UpdatePreferences(userID int64, prefs *models.UserPreferences) (*models.UserPreferences, error) {
+	if prefs.QuietHours != nil {
+		if prefs.QuietHours.Timezone == "" {
+			prefs.QuietHours.Timezone = "UTC"
+		}
+		if _, _, err := quietHoursWindow(prefs.QuietHours); err != nil {
+			return nil, ErrInvalidQuietHours
+		}
+	}
+	if prefs.MutedEvents == nil {
+		prefs.MutedEvents = []string{}
+	}
+	for _, eventType := range prefs.MutedEvents {
+		if events.Lookup(eventType) == nil || unmutableEvents[eventType] {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMutedEvent, eventType)
+		}
+	}
+	slices.Sort(prefs.MutedEvents)
+	prefs.MutedEvents = slices.Compact(prefs.MutedEvents)
+
+	if err := s.preferenceRepo.Save(userID, prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// Accepts implements websocket.EventFilter: muted events are dropped, and so
+// are ringing events while the user does not want to be disturbed
+func (s *PreferenceService) Accepts(username, eventType string) bool {
+	prefs, err := s.preferenceRepo.GetByUsername(username)
+	if err != nil {
+		fmt.Printf("Failed to get preferences of %s: %v\n", username, err)
+		return true
+	}
+
+	if slices.Contains(prefs.MutedEvents, eventType) && !unmutableEvents[eventType] {
+		return false
+	}
+	return !ringingEvents[eventType] || !doNotDisturb(prefs, time.Now())
+}
+
+// doNotDisturb reports whether the user is not to be rung at the given time,
+// because do-not-disturb is on or it falls within their quiet hours
+func doNotDisturb(prefs *models.UserPreferences, now time.Time) bool {
+	if prefs.DoNotDisturb {
+		return true
+	}
+	if prefs.QuietHours == nil {
+		return false
+	}
+
+	start, end, err := quietHoursWindow(prefs.QuietHours)
+	if err != nil {
+		return false
+	}
+	clock := now.In(start.Location())
+	minute := clock.Hour()*60 + clock.Minute()
+	from := start.Hour()*60 + start.Minute()
+	until := end.Hour()*60 + end.Minute()
+	if from < until {
+		return minute >= from && minute < until
+	}
+	return minute >= from || minute < until
+}
+
+// quietHoursWindow parses the start and end of quiet hours in their timezone
+func quietHoursWindow(quietHours *models.QuietHours) (time.Time, time.Time, error) {
+	location, err := time.LoadLocation(quietHours.Timezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	start, err := time.ParseInLocation(quietHoursLayout, quietHours.Start, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := time.ParseInLocation(quietHoursLayout, quietHours.End, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if start.Equal(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("quiet hours start and end at %s", quietHours.Start)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"livekit/database"
+	"livekit/models"
+	"testing"
+	"time"
+)
+
+func TestDoNotDisturb(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 18, hour, minute, 0, 0, tokyo)
+	}
+	overnight := &models.QuietHours{Start: "22:00", End: "07:00", Timezone: "Asia/Tokyo"}
+	daytime := &models.QuietHours{Start: "09:00", End: "17:00", Timezone: "Asia/Tokyo"}
+
+	tests := []struct {
+		name  string
+		prefs *models.UserPreferences
+		now   time.Time
+		want  bool
+	}{
+		{name: "no preferences", prefs: &models.UserPreferences{}, now: at(23, 0)},
+		{name: "do not disturb", prefs: &models.UserPreferences{DoNotDisturb: true}, now: at(12, 0), want: true},
+		{name: "overnight before midnight", prefs: &models.UserPreferences{QuietHours: overnight}, now: at(23, 30), want: true},
+		{name: "overnight at start", prefs: &models.UserPreferences{QuietHours: overnight}, now: at(22, 0), want: true},
+		{name: "overnight after midnight", prefs: &models.UserPreferences{QuietHours: overnight}, now: at(3, 0), want: true},
+		{name: "overnight last minute", prefs: &models.UserPreferences{QuietHours: overnight}, now: at(6, 59), want: true},
+		{name: "overnight at end", prefs: &models.UserPreferences{QuietHours: overnight}, now: at(7, 0)},
+		{name: "overnight midday", prefs: &models.UserPreferences{QuietHours: overnight}, now: at(12, 0)},
+		{name: "overnight just before start", prefs: &models.UserPreferences{QuietHours: overnight}, now: at(21, 59)},
+		{name: "daytime inside", prefs: &models.UserPreferences{QuietHours: daytime}, now: at(12, 0), want: true},
+		{name: "daytime at night", prefs: &models.UserPreferences{QuietHours: daytime}, now: at(23, 0)},
+		// 23:30 in Tokyo is 14:30 UTC, so the window is read in its own timezone
+		{name: "other timezone", prefs: &models.UserPreferences{QuietHours: overnight}, now: at(23, 30).UTC(), want: true},
+		{
+			name:  "unknown timezone",
+			prefs: &models.UserPreferences{QuietHours: &models.QuietHours{Start: "22:00", End: "07:00", Timezone: "Mars/Olympus"}},
+			now:   at(23, 30),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := doNotDisturb(tt.prefs, tt.now); got != tt.want {
+				t.Errorf("doNotDisturb at %s = %v, want %v", tt.now.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreferencesAccepts(t *testing.T) {
+	db := newTestDB(t)
+	s := NewPreferenceService(db, nil)
+	alice := createTestUser(t, db, "alice")
+	createTestUser(t, db, "bob")
+
+	if _, err := s.UpdatePreferences(alice.ID, &models.UserPreferences{MutedEvents: []string{"call_ended"}}); !errors.Is(err, ErrInvalidMutedEvent) {
+		t.Errorf("muting call_ended error = %v, want %v", err, ErrInvalidMutedEvent)
+	}
+	if _, err := s.UpdatePreferences(alice.ID, &models.UserPreferences{
+		DoNotDisturb: true,
+		MutedEvents:  []string{"reaction", "poll_created"},
+	}); err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+
+	tests := []struct {
+		username  string
+		eventType string
+		want      bool
+	}{
+		{username: "alice", eventType: "call_invitation"},
+		{username: "alice", eventType: "scheduled_call_reminder"},
+		{username: "alice", eventType: "reaction"},
+		{username: "alice", eventType: "poll_created"},
+		{username: "alice", eventType: "chat_message", want: true},
+		{username: "alice", eventType: "call_ended", want: true},
+		{username: "alice", eventType: "lobby_admitted", want: true},
+		{username: "alice", eventType: "breakout_assigned", want: true},
+		{username: "bob", eventType: "call_invitation", want: true},
+		{username: "bob", eventType: "reaction", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := s.Accepts(tt.username, tt.eventType); got != tt.want {
+			t.Errorf("Accepts(%s, %s) = %v, want %v", tt.username, tt.eventType, got, tt.want)
+		}
+	}
+
+	// Events that change what the client has to do get through even if an
+	// older client stored them as muted
+	stored := &models.UserPreferences{MutedEvents: []string{"call_ended", "call_type_changed"}}
+	if err := database.NewPreferenceRepo(db).Save(alice.ID, stored); err != nil {
+		t.Fatal(err)
+	}
+	for _, eventType := range stored.MutedEvents {
+		if !s.Accepts("alice", eventType) {
+			t.Errorf("Accepts(alice, %s) = false, want true", eventType)
+		}
+	}
+}
+
+func TestCreateCallDeclineReasons(t *testing.T) {
+	db := newTestDB(t)
+	s := newTestCallService(t, db, newFakeRoomClient(), nil)
+	preferences := NewPreferenceService(db, nil)
+
+	host := createTestUser(t, db, "host")
+	users := make(map[string]*models.User)
+	for _, username := range []string{"free", "dnd", "quiet", "busy", "in-call"} {
+		users[username] = createTestUser(t, db, username)
+	}
+
+	now := time.Now().UTC()
+	save := map[string]*models.UserPreferences{
+		"dnd": {DoNotDisturb: true},
+		"quiet": {QuietHours: &models.QuietHours{
+			Start:    now.Add(-time.Hour).Format(quietHoursLayout),
+			End:      now.Add(time.Hour).Format(quietHoursLayout),
+			Timezone: "UTC",
+		}},
+		"busy": {RejectWhileInCall: true},
+	}
+	for username, prefs := range save {
+		if _, err := preferences.UpdatePreferences(users[username].ID, prefs); err != nil {
+			t.Fatalf("UpdatePreferences(%s): %v", username, err)
+		}
+	}
+
+	// "busy" and "in-call" are both hosting another call, only "busy" declines calls then
+	startTestCall(t, s, users["busy"])
+	startTestCall(t, s, users["in-call"])
+
+	result, err := s.CreateCallAndInvite(host.ID, "video", []string{"free", "dnd", "quiet", "busy", "in-call"}, "", CallOptions{})
+	if err != nil {
+		t.Fatalf("CreateCallAndInvite: %v", err)
+	}
+
+	want := map[string]string{"free": "ringing", "dnd": "dnd", "quiet": "dnd", "busy": "busy", "in-call": "ringing"}
+	for username, status := range want {
+		if got := result.Invitees[username]; got != status {
+			t.Errorf("invitee %s is %q, want %q", username, got, status)
+		}
+	}
+
+	pending, err := database.NewInvitationRepo(db).GetPendingForUser(users["dnd"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("dnd invitee has %d pending invitations, want none", len(pending))
+	}
+}
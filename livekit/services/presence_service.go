@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const maxStatusTextLength = 100
+
+var (
+	ErrInvalidPresenceStatus = errors.New("status must be 'online' or 'away'")
+	ErrStatusTextTooLong     = errors.New("statusText must be at most 100 characters")
+)
+
+// PresenceUpdate is what a user sets about themselves; nil fields are left unchanged
+type PresenceUpdate struct {
+	Status     *string `json:"status,omitempty"`     // "online" or "away"
+	StatusText *string `json:"statusText,omitempty"` // Empty string clears it
+}
+
+// PresenceService follows users' sockets and calls to tell their contacts
+// whether they are online, away, in a call or offline
+type PresenceService struct {
+	db             *database.DB
+	presenceRepo   *database.PresenceRepo
+	userRepo       *database.UserRepo
+	callRepo       *database.CallRepo
+	contactRepo    *database.ContactRepo
+	invitationRepo *database.InvitationRepo
+	wsHub          *websocket.WebSocketHub
+
+	// locks keep updates of the same user from interleaving, so contacts never
+	// end on a stale status, while different users update in parallel
+	locks userLocks
+}
+
+// userLocks is a mutex per user ID. Entries are dropped once nobody holds or
+// waits for them, so the map only grows with concurrently updated users.
+type userLocks struct {
+	mu    sync.Mutex
+	users map[int64]*userLock
+}
+
+type userLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock blocks until the user's mutex is held and returns the function releasing it
+func (l *userLocks) lock(userID int64) func() {
+	l.mu.Lock()
+	if l.users == nil {
+		l.users = make(map[int64]*userLock)
+	}
+	lock, ok := l.users[userID]
+	if !ok {
+		lock = &userLock{}
+		l.users[userID] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		l.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.users, userID)
+		}
+		l.mu.Unlock()
+	}
+}
+
+func NewPresenceService(db *database.DB, callService *CallService, wsHub *websocket.WebSocketHub) *PresenceService {
+	s := &PresenceService{
+		db:             db,
+		presenceRepo:   database.NewPresenceRepo(db),
+		userRepo:       database.NewUserRepo(db),
+		callRepo:       database.NewCallRepo(db),
+		contactRepo:    database.NewContactRepo(db),
+		invitationRepo: database.NewInvitationRepo(db),
+		wsHub:          wsHub,
+	}
+	callService.OnCallJoined(func(call *models.ActiveCall, userID int64) {
+		s.Refresh(userID)
+	})
+	callService.OnCallEnded(func(call *models.ActiveCall) {
+		for _, userID := range s.callUserIDs(call) {
+			s.Refresh(userID)
+		}
+	})
+	if wsHub != nil {
+		wsHub.OnConnect(s.refreshUser)
+		wsHub.OnDisconnect(func(username string) {
+			s.heartbeat(username)
+			s.refreshUser(username)
+		})
+		wsHub.OnHeartbeat(s.heartbeat)
+		wsHub.OnRequest("set_presence", s.handleSetPresence)
+	}
+	return s
+}
+
+// GetPresence returns the user's own presence
+func (s *PresenceService) GetPresence(userID int64) (*models.Presence, error) {
+	presence, err := s.presenceRepo.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if presence == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	return presence, nil
+}
+
+// GetContactPresences returns the presence of the user's contacts by user ID
+func (s *PresenceService) GetContactPresences(userID int64) (map[int64]*models.Presence, error) {
+	presences, err := s.presenceRepo.GetForContacts(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byUserID := make(map[int64]*models.Presence, len(presences))
+	for _, presence := range presences {
+		byUserID[presence.UserID] = presence
+	}
+	return byUserID, nil
+}
+
+// UpdatePresence lets users mark themselves away or back and set a status text
+func (s *PresenceService) UpdatePresence(userID int64, update PresenceUpdate) (*models.Presence, error) {
+	if update.Status != nil && *update.Status != "online" && *update.Status != "away" {
+		return nil, ErrInvalidPresenceStatus
+	}
+	if update.StatusText != nil && utf8.RuneCountInString(*update.StatusText) > maxStatusTextLength {
+		return nil, ErrStatusTextTooLong
+	}
+
+	defer s.locks.lock(userID)()
+
+	presence, err := s.GetPresence(userID)
+	if err != nil {
+		return nil, err
+	}
+	if update.Status != nil {
+		presence.Away = *update.Status == "away"
+	}
+	if update.StatusText != nil {
+		presence.StatusText = *update.StatusText
+	}
+
+	if err := s.apply(presence, true); err != nil {
+		return nil, err
+	}
+	return presence, nil
+}
+
+// Refresh works out the user's status again and tells their contacts when it changed
+func (s *PresenceService) Refresh(userID int64) {
+	defer s.locks.lock(userID)()
+
+	presence, err := s.presenceRepo.Get(userID)
+	if err != nil {
+		fmt.Printf("Failed to get presence of user %d: %v\n", userID, err)
+		return
+	}
+	if presence == nil {
+		return
+	}
+	if err := s.apply(presence, false); err != nil {
+		fmt.Printf("Failed to update presence of user %d: %v\n", userID, err)
+	}
+}
+
+// Sweep refreshes everyone shown as connected, catching users whose sockets
+// were on an instance that stopped without saying goodbye
+func (s *PresenceService) Sweep() error {
+	presences, err := s.presenceRepo.GetNotOffline()
+	if err != nil {
+		return err
+	}
+	for _, presence := range presences {
+		s.Refresh(presence.UserID)
+	}
+	return nil
+}
+
+// apply stores the user's current status and publishes it to their contacts
+// when it changed or the user updated it themselves. Callers hold the user's lock.
+func (s *PresenceService) apply(presence *models.Presence, updated bool) error {
+	previous := presence.Status
+	status := s.currentStatus(presence)
+	if status == previous && !updated {
+		return nil
+	}
+
+	if status != "offline" {
+		now := time.Now()
+		presence.LastSeenAt = &now
+	} else if previous != "offline" {
+		// Coming back starts out online
+		presence.Away = false
+	}
+	presence.Status = status
+
+	if err := s.presenceRepo.Save(presence); err != nil {
+		return err
+	}
+
+	if s.wsHub != nil {
+		s.wsHub.Publish(context.Background(), s.contactUsernames(presence.UserID), events.PresenceChanged{Presence: presence})
+	}
+	return nil
+}
+
+func (s *PresenceService) currentStatus(presence *models.Presence) string {
+	if s.wsHub == nil || !s.wsHub.IsConnected(presence.Username) {
+		return "offline"
+	}
+
+	inCall, err := s.callRepo.IsInActiveCall(presence.UserID)
+	if err != nil {
+		fmt.Printf("Failed to check calls of user %d: %v\n", presence.UserID, err)
+	}
+	switch {
+	case inCall:
+		return "in-call"
+	case presence.Away:
+		return "away"
+	default:
+		return "online"
+	}
+}
+
+func (s *PresenceService) contactUsernames(userID int64) []string {
+	contacts, err := s.contactRepo.GetUserContacts(userID)
+	if err != nil {
+		fmt.Printf("Failed to get contacts of user %d: %v\n", userID, err)
+		return nil
+	}
+
+	usernames := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		usernames = append(usernames, contact.Username)
+	}
+	return usernames
+}
+
+// callUserIDs returns the host and every invitee who accepted the call
+func (s *PresenceService) callUserIDs(call *models.ActiveCall) []int64 {
+	userIDs := []int64{call.CreatedBy}
+
+	invitations, err := s.invitationRepo.GetCallParticipants(call.CallID)
+	if err != nil {
+		fmt.Printf("Failed to get invitations for call %s: %v\n", call.CallID, err)
+	}
+	for _, invitation := range invitations {
+		if invitation.Status == "accepted" {
+			userIDs = append(userIDs, invitation.InviteeID)
+		}
+	}
+	return userIDs
+}
+
+func (s *PresenceService) refreshUser(username string) {
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		return
+	}
+	s.Refresh(user.ID)
+}
+
+// heartbeat moves the last seen time of a connected user forward, so it stays
+// accurate for users whose instance stopped
+func (s *PresenceService) heartbeat(username string) {
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		return
+	}
+	if err := s.presenceRepo.Touch(user.ID, time.Now()); err != nil {
+		fmt.Printf("Failed to record heartbeat of %s: %v\n", username, err)
+	}
+}
+
+func (s *PresenceService) handleSetPresence(username string, data json.RawMessage) (interface{}, error) {
+	var update PresenceUpdate
+	if err := json.Unmarshal(data, &update); err != nil {
+		return nil, fmt.Errorf("invalid presence update")
+	}
+
+	user, err := s.userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return s.UpdatePresence(user.ID, update)
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"encoding/json"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUserLocks(t *testing.T) {
+	var locks userLocks
+
+	unlockAlice := locks.lock(1)
+
+	// Another user is not held up by alice's update
+	done := make(chan struct{})
+	go func() {
+		locks.lock(2)()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock of another user blocked")
+	}
+
+	// A second update of alice waits for the first
+	acquired, released := make(chan struct{}), make(chan struct{})
+	go func() {
+		unlock := locks.lock(1)
+		close(acquired)
+		unlock()
+		close(released)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second lock of the same user did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlockAlice()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second lock of the same user never acquired")
+	}
+	<-released
+
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+	if len(locks.users) != 0 {
+		t.Errorf("%d locks left after every user was released", len(locks.users))
+	}
+}
+
+type presenceTestEnv struct {
+	db          *database.DB
+	wsHub       *websocket.WebSocketHub
+	callService *CallService
+	presence    *PresenceService
+	users       map[string]*models.User
+	sockets     map[string]*websocket.Connection
+}
+
+func newPresenceTestEnv(t *testing.T, usernames ...string) *presenceTestEnv {
+	t.Helper()
+
+	env := &presenceTestEnv{
+		db:      newTestDB(t),
+		wsHub:   websocket.NewWebSocketHub(),
+		users:   make(map[string]*models.User),
+		sockets: make(map[string]*websocket.Connection),
+	}
+	env.callService = newTestCallService(t, env.db, newFakeRoomClient(), env.wsHub)
+	env.presence = NewPresenceService(env.db, env.callService, env.wsHub)
+	for _, username := range usernames {
+		env.users[username] = createTestUser(t, env.db, username)
+	}
+	return env
+}
+
+// connect opens a socket for the user and runs what the handler does once it authenticated
+func (env *presenceTestEnv) connect(t *testing.T, username, connID string) *websocket.Connection {
+	t.Helper()
+
+	conn := &websocket.Connection{ID: connID, Username: username, Send: make(chan []byte, 64)}
+	env.wsHub.Register(username, conn)
+	env.sockets[connID] = conn
+	env.presence.Refresh(env.users[username].ID)
+	return conn
+}
+
+func (env *presenceTestEnv) disconnect(username, connID string) {
+	env.wsHub.Unregister(username, connID)
+}
+
+func (env *presenceTestEnv) status(t *testing.T, username string) string {
+	t.Helper()
+
+	presence, err := env.presence.GetPresence(env.users[username].ID)
+	if err != nil {
+		t.Fatalf("GetPresence(%s): %v", username, err)
+	}
+	return presence.Status
+}
+
+// presenceChanges drains the socket and returns the "username:status" changes it was told about
+func presenceChanges(t *testing.T, conn *websocket.Connection) []string {
+	t.Helper()
+
+	var changes []string
+	for {
+		select {
+		case data := <-conn.Send:
+			var envelope events.Envelope
+			if err := json.Unmarshal(data, &envelope); err != nil {
+				t.Fatalf("decode %s: %v", data, err)
+			}
+			if envelope.Type != "presence_changed" {
+				continue
+			}
+			var presence models.Presence
+			if err := json.Unmarshal(envelope.Data, &presence); err != nil {
+				t.Fatalf("decode %s: %v", envelope.Data, err)
+			}
+			changes = append(changes, presence.Username+":"+presence.Status)
+		default:
+			return changes
+		}
+	}
+}
+
+func TestPresenceFollowsSockets(t *testing.T) {
+	env := newPresenceTestEnv(t, "alice", "bob", "carol")
+	if err := database.NewContactRepo(env.db).Add(env.users["alice"].ID, env.users["bob"].ID); err != nil {
+		t.Fatal(err)
+	}
+	bob := env.connect(t, "bob", "bob-phone")
+	carol := env.connect(t, "carol", "carol-phone")
+	presenceChanges(t, bob)
+	presenceChanges(t, carol)
+
+	steps := []struct {
+		name        string
+		do          func()
+		wantStatus  string
+		wantChanges []string
+	}{
+		{
+			name:        "first socket",
+			do:          func() { env.connect(t, "alice", "alice-phone") },
+			wantStatus:  "online",
+			wantChanges: []string{"alice:online"},
+		},
+		{
+			name:       "second socket",
+			do:         func() { env.connect(t, "alice", "alice-laptop") },
+			wantStatus: "online",
+		},
+		{
+			name:       "one of two sockets closed",
+			do:         func() { env.disconnect("alice", "alice-phone") },
+			wantStatus: "online",
+		},
+		{
+			name:        "last socket closed",
+			do:          func() { env.disconnect("alice", "alice-laptop") },
+			wantStatus:  "offline",
+			wantChanges: []string{"alice:offline"},
+		},
+		{
+			name:        "back again",
+			do:          func() { env.connect(t, "alice", "alice-phone") },
+			wantStatus:  "online",
+			wantChanges: []string{"alice:online"},
+		},
+	}
+
+	for _, step := range steps {
+		step.do()
+		if got := env.status(t, "alice"); got != step.wantStatus {
+			t.Errorf("%s: alice is %s, want %s", step.name, got, step.wantStatus)
+		}
+		if got := presenceChanges(t, bob); !slices.Equal(got, step.wantChanges) {
+			t.Errorf("%s: contact was told %v, want %v", step.name, got, step.wantChanges)
+		}
+		if got := presenceChanges(t, carol); len(got) != 0 {
+			t.Errorf("%s: someone who is not a contact was told %v", step.name, got)
+		}
+	}
+}
+
+func TestPresenceInCall(t *testing.T) {
+	env := newPresenceTestEnv(t, "alice", "bob", "carol")
+	contacts := database.NewContactRepo(env.db)
+	if err := contacts.Add(env.users["alice"].ID, env.users["carol"].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := contacts.Add(env.users["bob"].ID, env.users["carol"].ID); err != nil {
+		t.Fatal(err)
+	}
+	env.connect(t, "alice", "alice-phone")
+	env.connect(t, "bob", "bob-phone")
+	carol := env.connect(t, "carol", "carol-phone")
+	presenceChanges(t, carol)
+
+	callID := startTestCall(t, env.callService, env.users["alice"], "bob")
+	if got := env.status(t, "alice"); got != "in-call" {
+		t.Errorf("host is %s after starting the call, want in-call", got)
+	}
+	if got := env.status(t, "bob"); got != "online" {
+		t.Errorf("invitee is %s while ringing, want online", got)
+	}
+
+	invitations, err := database.NewInvitationRepo(env.db).GetCallParticipants(callID)
+	if err != nil || len(invitations) != 1 {
+		t.Fatalf("invitations %v, %v", invitations, err)
+	}
+	if _, err := env.callService.RespondToInvitation(invitations[0].ID, env.users["bob"].ID, "accept"); err != nil {
+		t.Fatalf("RespondToInvitation: %v", err)
+	}
+	if got := env.status(t, "bob"); got != "in-call" {
+		t.Errorf("invitee is %s after accepting, want in-call", got)
+	}
+	if got := presenceChanges(t, carol); !slices.Equal(got, []string{"alice:in-call", "bob:in-call"}) {
+		t.Errorf("contact was told %v, want alice and bob in-call", got)
+	}
+
+	if err := env.callService.EndCall(callID, env.users["alice"].ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+	for _, username := range []string{"alice", "bob"} {
+		if got := env.status(t, username); got != "online" {
+			t.Errorf("%s is %s after the call ended, want online", username, got)
+		}
+	}
+	if got := presenceChanges(t, carol); !slices.Equal(got, []string{"alice:online", "bob:online"}) {
+		t.Errorf("contact was told %v, want alice and bob online", got)
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"time"
+
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+var (
+	ErrRecordingInProgress = errors.New("call is already being recorded")
+	ErrNoActiveRecording   = errors.New("call is not being recorded")
+)
+
+// EgressClient is the subset of the LiveKit Egress API used for recordings,
+// live streams and transcription audio.
+// *lksdk.EgressClient satisfies it.
+type EgressClient interface {
+	StartRoomCompositeEgress(ctx context.Context, req *livekit.RoomCompositeEgressRequest) (*livekit.EgressInfo, error)
+	StartTrackEgress(ctx context.Context, req *livekit.TrackEgressRequest) (*livekit.EgressInfo, error)
+	StopEgress(ctx context.Context, req *livekit.StopEgressRequest) (*livekit.EgressInfo, error)
+	ListEgress(ctx context.Context, req *livekit.ListEgressRequest) (*livekit.ListEgressResponse, error)
+}
+
+type RecordingServiceConfig struct {
+	Filepath string // Egress filepath template, e.g. "recordings/{room_name}-{time}.mp4"
+}
+
+type RecordingService struct {
+	db            *database.DB
+	config        *RecordingServiceConfig
+	egressClient  EgressClient
+	recordingRepo *database.RecordingRepo
+	callRepo      *database.CallRepo
+	callService   *CallService
+	wsHub         *websocket.WebSocketHub
+}
+
+func NewRecordingService(db *database.DB, cfg *RecordingServiceConfig, egressClient EgressClient, callService *CallService, wsHub *websocket.WebSocketHub) *RecordingService {
+	s := &RecordingService{
+		db:            db,
+		config:        cfg,
+		egressClient:  egressClient,
+		recordingRepo: database.NewRecordingRepo(db),
+		callRepo:      database.NewCallRepo(db),
+		callService:   callService,
+		wsHub:         wsHub,
+	}
+	callService.OnCallEnded(s.stopOnCallEnded)
+	return s
+}
+
+// StartRecording starts a room composite egress to file for an active call
+func (s *RecordingService) StartRecording(callID string, userID int64) (*models.Recording, error) {
+	call, err := s.hostCall(callID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+
+	active, err := s.activeRecording(callID)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return nil, ErrRecordingInProgress
+	}
+
+	info, err := s.egressClient.StartRoomCompositeEgress(context.Background(), &livekit.RoomCompositeEgressRequest{
+		RoomName:  call.RoomName,
+		AudioOnly: call.CallType == "voice",
+		FileOutputs: []*livekit.EncodedFileOutput{
+			{
+				FileType: livekit.EncodedFileType_MP4,
+				Filepath: s.config.Filepath,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start egress: %w", err)
+	}
+
+	recording, err := s.recordingRepo.Create(callID, call.RoomName, info.EgressId, userID)
+	if err != nil {
+		// Don't leave an untracked egress running
+		if _, stopErr := s.egressClient.StopEgress(context.Background(), &livekit.StopEgressRequest{EgressId: info.EgressId}); stopErr != nil {
+			fmt.Printf("Failed to stop egress %s: %v\n", info.EgressId, stopErr)
+		}
+		return nil, err
+	}
+
+	if s.wsHub != nil {
+		s.wsHub.Publish(context.Background(), s.callService.callMemberUsernames(call), events.RecordingStarted{Recording: recording})
+	}
+
+	return recording, nil
+}
+
+func (s *RecordingService) StopRecording(callID string, userID int64) (*models.Recording, error) {
+	call, err := s.hostCall(callID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	recording, err := s.activeRecording(callID)
+	if err != nil {
+		return nil, err
+	}
+	if recording == nil {
+		return nil, ErrNoActiveRecording
+	}
+
+	return s.stopRecording(call, recording)
+}
+
+// stopOnCallEnded stops a recording that was still running when the call ended
+func (s *RecordingService) stopOnCallEnded(call *models.ActiveCall) {
+	recording, err := s.activeRecording(call.CallID)
+	if err != nil {
+		fmt.Printf("Failed to get recording of call %s: %v\n", call.CallID, err)
+		return
+	}
+	if recording == nil {
+		return
+	}
+	if _, err := s.stopRecording(call, recording); err != nil {
+		fmt.Printf("Failed to stop recording of call %s: %v\n", call.CallID, err)
+	}
+}
+
+func (s *RecordingService) stopRecording(call *models.ActiveCall, recording *models.Recording) (*models.Recording, error) {
+	info, err := s.egressClient.StopEgress(context.Background(), &livekit.StopEgressRequest{EgressId: recording.EgressID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop egress: %w", err)
+	}
+
+	applyEgressInfo(recording, info)
+	// The file is still being finalized while the egress is ending
+	if recording.Status == "recording" {
+		recording.Status = "completed"
+	}
+	if recording.EndedAt == nil {
+		now := time.Now()
+		recording.EndedAt = &now
+	}
+	if err := s.recordingRepo.Finish(recording); err != nil {
+		return nil, err
+	}
+
+	if s.wsHub != nil {
+		s.wsHub.Publish(context.Background(), s.callService.callMemberUsernames(call), events.RecordingStopped{Recording: recording})
+	}
+
+	return recording, nil
+}
+
+// GetRecordings lists the recordings of a call, refreshing any that still look
+// like they are running since egress ends on its own when the room closes
+func (s *RecordingService) GetRecordings(callID string) ([]*models.Recording, error) {
+	recordings, err := s.recordingRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, recording := range recordings {
+		if recording.Status == "recording" {
+			if err := s.syncRecording(recording); err != nil {
+				fmt.Printf("Failed to sync recording %s: %v\n", recording.EgressID, err)
+			}
+		}
+	}
+
+	return recordings, nil
+}
+
+func (s *RecordingService) hostCall(callID string, userID int64) (*models.ActiveCall, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+	return call, nil
+}
+
+func (s *RecordingService) activeRecording(callID string) (*models.Recording, error) {
+	recording, err := s.recordingRepo.GetActiveByCallID(callID)
+	if err != nil || recording == nil {
+		return nil, err
+	}
+
+	if err := s.syncRecording(recording); err != nil {
+		fmt.Printf("Failed to sync recording %s: %v\n", recording.EgressID, err)
+	}
+	if recording.Status != "recording" {
+		return nil, nil
+	}
+	return recording, nil
+}
+
+// syncRecording pulls the egress state and stores it once the egress is over
+func (s *RecordingService) syncRecording(recording *models.Recording) error {
+	res, err := s.egressClient.ListEgress(context.Background(), &livekit.ListEgressRequest{EgressId: recording.EgressID})
+	if err != nil {
+		return fmt.Errorf("failed to list egress: %w", err)
+	}
+	if len(res.Items) == 0 {
+		return nil
+	}
+
+	applyEgressInfo(recording, res.Items[0])
+	if recording.Status == "recording" {
+		return nil
+	}
+	return s.recordingRepo.Finish(recording)
+}
+
+func applyEgressInfo(recording *models.Recording, info *livekit.EgressInfo) {
+	switch info.Status {
+	case livekit.EgressStatus_EGRESS_COMPLETE, livekit.EgressStatus_EGRESS_LIMIT_REACHED:
+		recording.Status = "completed"
+	case livekit.EgressStatus_EGRESS_FAILED, livekit.EgressStatus_EGRESS_ABORTED:
+		recording.Status = "failed"
+		recording.Error = info.Error
+	}
+
+	if info.EndedAt > 0 {
+		endedAt := time.Unix(0, info.EndedAt)
+		recording.EndedAt = &endedAt
+	}
+
+	if len(info.FileResults) > 0 {
+		file := info.FileResults[0]
+		recording.FilePath = file.Filename
+		recording.Location = file.Location
+		recording.DurationSeconds = int(time.Duration(file.Duration).Seconds())
+		recording.SizeBytes = file.Size
+	}
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/websocket"
+	"sync"
+	"testing"
+	"time"
+
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+// fakeEgressClient hands out egress IDs and reports whatever state a test set
+// for them; egresses without a set state are still running
+type fakeEgressClient struct {
+	mu        sync.Mutex
+	next      int
+	startErr  error
+	composite []*livekit.RoomCompositeEgressRequest
+	tracks    []*livekit.TrackEgressRequest
+	stopped   []string
+	infos     map[string]*livekit.EgressInfo
+}
+
+func newFakeEgressClient() *fakeEgressClient {
+	return &fakeEgressClient{infos: make(map[string]*livekit.EgressInfo)}
+}
+
+func (c *fakeEgressClient) start() (*livekit.EgressInfo, error) {
+	if c.startErr != nil {
+		return nil, c.startErr
+	}
+	c.next++
+	info := &livekit.EgressInfo{
+		EgressId: fmt.Sprintf("EG_%d", c.next),
+		Status:   livekit.EgressStatus_EGRESS_ACTIVE,
+	}
+	c.infos[info.EgressId] = info
+	return info, nil
+}
+
+func (c *fakeEgressClient) StartRoomCompositeEgress(ctx context.Context, req *livekit.RoomCompositeEgressRequest) (*livekit.EgressInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.composite = append(c.composite, req)
+	return c.start()
+}
+
+func (c *fakeEgressClient) StartTrackEgress(ctx context.Context, req *livekit.TrackEgressRequest) (*livekit.EgressInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tracks = append(c.tracks, req)
+	return c.start()
+}
+
+func (c *fakeEgressClient) StopEgress(ctx context.Context, req *livekit.StopEgressRequest) (*livekit.EgressInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, ok := c.infos[req.EgressId]
+	if !ok {
+		return nil, fmt.Errorf("egress %s not found", req.EgressId)
+	}
+	c.stopped = append(c.stopped, req.EgressId)
+	if info.Status == livekit.EgressStatus_EGRESS_ACTIVE {
+		info.Status = livekit.EgressStatus_EGRESS_ENDING
+	}
+	return info, nil
+}
+
+func (c *fakeEgressClient) ListEgress(ctx context.Context, req *livekit.ListEgressRequest) (*livekit.ListEgressResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := &livekit.ListEgressResponse{}
+	if info, ok := c.infos[req.EgressId]; ok {
+		res.Items = append(res.Items, info)
+	}
+	return res, nil
+}
+
+// finish makes an egress end on its own, as when the room closes
+func (c *fakeEgressClient) finish(egressID string, status livekit.EgressStatus, file *livekit.FileInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info := c.infos[egressID]
+	info.Status = status
+	info.EndedAt = time.Now().UnixNano()
+	if status == livekit.EgressStatus_EGRESS_FAILED {
+		info.Error = "encoder crashed"
+	}
+	if file != nil {
+		info.FileResults = []*livekit.FileInfo{file}
+	}
+}
+
+func newTestRecordingService(t *testing.T) (*RecordingService, *fakeEgressClient, *database.DB) {
+	t.Helper()
+
+	db := newTestDB(t)
+	egress := newFakeEgressClient()
+	cfg := &RecordingServiceConfig{Filepath: "recordings/{room_name}-{time}.mp4"}
+	callService := newTestCallService(t, db, newFakeRoomClient(), nil)
+	return NewRecordingService(db, cfg, egress, callService, nil), egress, db
+}
+
+func TestStartRecording(t *testing.T) {
+	tests := []struct {
+		name      string
+		callType  string
+		setup     func(t *testing.T, s *RecordingService, egress *fakeEgressClient, db *database.DB, callID string, hostID int64)
+		byGuest   bool
+		unknown   bool
+		wantErr   error
+		wantAudio bool
+	}{
+		{name: "video call", callType: "video"},
+		{name: "voice call records audio only", callType: "voice", wantAudio: true},
+		{name: "unknown call", callType: "video", unknown: true, wantErr: ErrCallNotFound},
+		{name: "not the host", callType: "video", byGuest: true, wantErr: ErrNotCallHost},
+		{
+			name:     "ended call",
+			callType: "video",
+			setup: func(t *testing.T, s *RecordingService, egress *fakeEgressClient, db *database.DB, callID string, hostID int64) {
+				endTestCall(t, db, callID)
+			},
+			wantErr: ErrCallNotActive,
+		},
+		{
+			name:     "already recording",
+			callType: "video",
+			setup: func(t *testing.T, s *RecordingService, egress *fakeEgressClient, db *database.DB, callID string, hostID int64) {
+				if _, err := s.StartRecording(callID, hostID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrRecordingInProgress,
+		},
+		{
+			name:     "previous recording ended with the room",
+			callType: "video",
+			setup: func(t *testing.T, s *RecordingService, egress *fakeEgressClient, db *database.DB, callID string, hostID int64) {
+				recording, err := s.StartRecording(callID, hostID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				egress.finish(recording.EgressID, livekit.EgressStatus_EGRESS_COMPLETE, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, egress, db := newTestRecordingService(t)
+			host := createTestUser(t, db, "host")
+			guest := createTestUser(t, db, "guest")
+			call := createTestCall(t, db, host, tt.callType)
+			if tt.setup != nil {
+				tt.setup(t, s, egress, db, call.CallID, host.ID)
+			}
+
+			callID, userID := call.CallID, host.ID
+			if tt.unknown {
+				callID = "unknown"
+			}
+			if tt.byGuest {
+				userID = guest.ID
+			}
+			started := len(egress.composite)
+
+			recording, err := s.StartRecording(callID, userID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("StartRecording error = %v, want %v", err, tt.wantErr)
+				}
+				if len(egress.composite) != started {
+					t.Error("egress started although recording was refused")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StartRecording: %v", err)
+			}
+
+			req := egress.composite[len(egress.composite)-1]
+			if req.RoomName != call.RoomName || req.AudioOnly != tt.wantAudio {
+				t.Errorf("egress for room %s, audio only %v; want %s, %v", req.RoomName, req.AudioOnly, call.RoomName, tt.wantAudio)
+			}
+			if recording.Status != "recording" || recording.EgressID == "" || recording.StartedBy != host.ID {
+				t.Errorf("unexpected recording %+v", recording)
+			}
+
+			stored, err := database.NewRecordingRepo(db).GetActiveByCallID(call.CallID)
+			if err != nil || stored == nil || stored.EgressID != recording.EgressID {
+				t.Errorf("stored active recording %+v, %v; want egress %s", stored, err, recording.EgressID)
+			}
+		})
+	}
+}
+
+func TestStartRecordingEgressFailure(t *testing.T) {
+	s, egress, db := newTestRecordingService(t)
+	host := createTestUser(t, db, "host")
+	call := createTestCall(t, db, host, "video")
+	egress.startErr = errors.New("egress unavailable")
+
+	if _, err := s.StartRecording(call.CallID, host.ID); err == nil {
+		t.Fatal("StartRecording succeeded although egress failed")
+	}
+	recordings, err := s.GetRecordings(call.CallID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != 0 {
+		t.Errorf("stored %d recordings of a failed egress", len(recordings))
+	}
+}
+
+func TestStopRecording(t *testing.T) {
+	file := &livekit.FileInfo{
+		Filename: "recordings/room.mp4",
+		Location: "s3://bucket/recordings/room.mp4",
+		Duration: int64(90 * time.Second),
+		Size:     1 << 20,
+	}
+
+	tests := []struct {
+		name       string
+		start      bool
+		finishWith *livekit.FileInfo
+		byGuest    bool
+		wantErr    error
+	}{
+		{name: "stops running egress", start: true},
+		{name: "keeps file results", start: true, finishWith: file},
+		{name: "nothing recording", wantErr: ErrNoActiveRecording},
+		{name: "not the host", start: true, byGuest: true, wantErr: ErrNotCallHost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, egress, db := newTestRecordingService(t)
+			host := createTestUser(t, db, "host")
+			guest := createTestUser(t, db, "guest")
+			call := createTestCall(t, db, host, "video")
+
+			var egressID string
+			if tt.start {
+				recording, err := s.StartRecording(call.CallID, host.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				egressID = recording.EgressID
+				if tt.finishWith != nil {
+					// The egress already wrote its file but is still reported active
+					egress.mu.Lock()
+					egress.infos[egressID].FileResults = []*livekit.FileInfo{tt.finishWith}
+					egress.mu.Unlock()
+				}
+			}
+
+			userID := host.ID
+			if tt.byGuest {
+				userID = guest.ID
+			}
+
+			recording, err := s.StopRecording(call.CallID, userID)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("StopRecording error = %v, want %v", err, tt.wantErr)
+				}
+				if len(egress.stopped) != 0 {
+					t.Errorf("stopped egresses %v although stopping was refused", egress.stopped)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StopRecording: %v", err)
+			}
+
+			if len(egress.stopped) != 1 || egress.stopped[0] != egressID {
+				t.Errorf("stopped egresses %v, want [%s]", egress.stopped, egressID)
+			}
+			if recording.Status != "completed" || recording.EndedAt == nil {
+				t.Errorf("recording status %s, ended %v; want completed with an end time", recording.Status, recording.EndedAt)
+			}
+			if tt.finishWith != nil && (recording.FilePath != file.Filename || recording.Location != file.Location ||
+				recording.DurationSeconds != 90 || recording.SizeBytes != file.Size) {
+				t.Errorf("file results not stored: %+v", recording)
+			}
+
+			active, err := database.NewRecordingRepo(db).GetActiveByCallID(call.CallID)
+			if err != nil || active != nil {
+				t.Errorf("recording still active after stop: %+v, %v", active, err)
+			}
+		})
+	}
+}
+
+func TestGetRecordingsSyncsEgressStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     livekit.EgressStatus
+		wantStatus string
+		wantError  string
+		wantEnded  bool
+	}{
+		{name: "still running", status: livekit.EgressStatus_EGRESS_ACTIVE, wantStatus: "recording"},
+		{name: "completed", status: livekit.EgressStatus_EGRESS_COMPLETE, wantStatus: "completed", wantEnded: true},
+		{name: "limit reached", status: livekit.EgressStatus_EGRESS_LIMIT_REACHED, wantStatus: "completed", wantEnded: true},
+		{name: "failed", status: livekit.EgressStatus_EGRESS_FAILED, wantStatus: "failed", wantError: "encoder crashed", wantEnded: true},
+		{name: "aborted", status: livekit.EgressStatus_EGRESS_ABORTED, wantStatus: "failed", wantEnded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, egress, db := newTestRecordingService(t)
+			host := createTestUser(t, db, "host")
+			call := createTestCall(t, db, host, "video")
+
+			recording, err := s.StartRecording(call.CallID, host.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.status != livekit.EgressStatus_EGRESS_ACTIVE {
+				egress.finish(recording.EgressID, tt.status, &livekit.FileInfo{Filename: "recordings/room.mp4"})
+			}
+
+			if _, err := s.GetRecordings(call.CallID); err != nil {
+				t.Fatalf("GetRecordings: %v", err)
+			}
+
+			// Read back what was stored rather than the synced copy
+			stored, err := database.NewRecordingRepo(db).GetByCallID(call.CallID)
+			if err != nil || len(stored) != 1 {
+				t.Fatalf("stored recordings %v, %v", stored, err)
+			}
+			got := stored[0]
+			if got.Status != tt.wantStatus || got.Error != tt.wantError || (got.EndedAt != nil) != tt.wantEnded {
+				t.Errorf("stored status %q, error %q, ended %v; want %q, %q, ended %v",
+					got.Status, got.Error, got.EndedAt, tt.wantStatus, tt.wantError, tt.wantEnded)
+			}
+			if tt.wantEnded && got.FilePath != "recordings/room.mp4" {
+				t.Errorf("stored file %q, want recordings/room.mp4", got.FilePath)
+			}
+		})
+	}
+}
+
+func TestCallEndStopsRecording(t *testing.T) {
+	for _, end := range []string{"ended", "cancelled"} {
+		t.Run(end, func(t *testing.T) {
+			db := newTestDB(t)
+			wsHub := websocket.NewWebSocketHub()
+			callService := newTestCallService(t, db, newFakeRoomClient(), wsHub)
+			egress := newFakeEgressClient()
+			s := NewRecordingService(db, &RecordingServiceConfig{Filepath: "recordings/{room_name}-{time}.mp4"}, egress, callService, wsHub)
+
+			host := createTestUser(t, db, "host")
+			hostSocket := &websocket.Connection{ID: "host-socket", Username: "host", Send: make(chan []byte, 16)}
+			wsHub.Register("host", hostSocket)
+			t.Cleanup(func() { wsHub.Unregister("host", "host-socket") })
+
+			callID := startTestCall(t, callService, host)
+			recording, err := s.StartRecording(callID, host.ID)
+			if err != nil {
+				t.Fatalf("StartRecording: %v", err)
+			}
+
+			if end == "ended" {
+				err = callService.EndCall(callID, host.ID)
+			} else {
+				err = callService.CancelCall(callID, host.ID)
+			}
+			if err != nil {
+				t.Fatalf("ending call: %v", err)
+			}
+
+			if len(egress.stopped) != 1 || egress.stopped[0] != recording.EgressID {
+				t.Errorf("stopped egresses %v, want [%s]", egress.stopped, recording.EgressID)
+			}
+			stored, err := database.NewRecordingRepo(db).GetByCallID(callID)
+			if err != nil || len(stored) != 1 {
+				t.Fatalf("stored recordings %v, %v", stored, err)
+			}
+			if stored[0].Status != "completed" || stored[0].EndedAt == nil {
+				t.Errorf("stored recording status %s, ended %v; want completed with an end time", stored[0].Status, stored[0].EndedAt)
+			}
+
+			deadline := time.After(2 * time.Second)
+			for {
+				select {
+				case data := <-hostSocket.Send:
+					var envelope events.Envelope
+					if err := json.Unmarshal(data, &envelope); err != nil {
+						t.Fatalf("decode %s: %v", data, err)
+					}
+					if envelope.Type == "recording_stopped" {
+						return
+					}
+				case <-deadline:
+					t.Fatal("host never heard that the recording stopped")
+				}
+			}
+		})
+	}
+}
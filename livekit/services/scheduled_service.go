@@ -1,8 +1,10 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"livekit/database"
+	"livekit/events"
 	"livekit/models"
 	"livekit/websocket"
 	"time"
@@ -40,6 +42,8 @@ type CreateScheduledCallRequest struct {
 	Description       string    `json:"description"`
 	MaxParticipants   int       `json:"maxParticipants"`
 	MaxDurationSeconds int      `json:"maxDurationSeconds"`
+	LobbyEnabled      bool      `json:"lobbyEnabled"`
+	Passcode          string    `json:"passcode,omitempty"`
 }
 
 func (s *ScheduledService) CreateScheduledCall(creatorID int64, req CreateScheduledCallRequest) (*models.ScheduledCall, error) {
@@ -66,10 +70,22 @@ func (s *ScheduledService) CreateScheduledCall(creatorID int64, req CreateSchedu
 		maxDurationSeconds = 0
 	}
 
-	call, err := s.scheduledCallRepo.Create(callID, roomName, req.CallType, creatorID, req.ScheduledAt, req.Timezone, recurrenceJSON, req.Title, req.Description, joinLink, maxParticipants, maxDurationSeconds)
+	passcodeHash, err := hashPasscode(req.Passcode)
+	if err != nil {
+		return nil, err
+	}
+
+	call, err := s.scheduledCallRepo.Create(callID, roomName, req.CallType, creatorID, req.ScheduledAt, req.Timezone, recurrenceJSON, req.Title, req.Description, joinLink, maxParticipants, maxDurationSeconds, req.LobbyEnabled)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create scheduled call: %w", err)
 	}
+	if passcodeHash != "" {
+		if err := s.scheduledCallRepo.UpdatePasscode(call.ID, passcodeHash); err != nil {
+			return nil, err
+		}
+		call.PasscodeHash = passcodeHash
+		call.HasPasscode = true
+	}
 
 	// Track invitees for broadcasting
 	var inviteeUsernames []string
@@ -92,12 +108,8 @@ func (s *ScheduledService) CreateScheduledCall(creatorID int64, req CreateSchedu
 	if s.wsHub != nil {
 		creator, err := s.userRepo.GetByID(creatorID)
 		if err == nil && creator != nil {
-			// Broadcast to creator
-			s.wsHub.BroadcastScheduledCallCreated(creator.Username, call)
-			// Broadcast to all invitees
-			for _, inviteeUsername := range inviteeUsernames {
-				s.wsHub.BroadcastScheduledCallCreated(inviteeUsername, call)
-			}
+			recipients := append([]string{creator.Username}, inviteeUsernames...)
+			s.wsHub.Publish(context.Background(), recipients, events.ScheduledCallCreated{ScheduledCall: call})
 		}
 	}
 
@@ -195,6 +207,31 @@ func (s *ScheduledService) UpdateScheduledCall(id int64, updates map[string]inte
 		}
 	}
 
+	if lobbyEnabled, ok := updates["lobbyEnabled"].(bool); ok {
+		_, err = s.db.Conn().Exec(`UPDATE scheduled_calls SET lobby_enabled = ?, updated_at = ? WHERE id = ?`, lobbyEnabled, time.Now(), id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if guestAccessEnabled, ok := updates["guestAccessEnabled"].(bool); ok {
+		_, err = s.db.Conn().Exec(`UPDATE scheduled_calls SET guest_access_enabled = ?, updated_at = ? WHERE id = ?`, guestAccessEnabled, time.Now(), id)
+		if err != nil {
+			return err
+		}
+	}
+
+	// An empty passcode removes it
+	if passcode, ok := updates["passcode"].(string); ok {
+		passcodeHash, err := hashPasscode(passcode)
+		if err != nil {
+			return err
+		}
+		if err := s.scheduledCallRepo.UpdatePasscode(id, passcodeHash); err != nil {
+			return err
+		}
+	}
+
 	if scheduledAt, ok := updates["scheduledAt"].(time.Time); ok {
 		_, err = s.db.Conn().Exec(`UPDATE scheduled_calls SET scheduled_at = ?, updated_at = ? WHERE id = ?`, scheduledAt, time.Now(), id)
 		if err != nil {
@@ -220,7 +257,9 @@ func (s *ScheduledService) CancelScheduledCall(id int64, userID int64) error {
 	return s.scheduledCallRepo.UpdateStatus(id, "cancelled")
 }
 
-func (s *ScheduledService) StartScheduledCall(id int64, userID int64) (*CreateCallResult, error) {
+// StartScheduledCall starts the meeting or joins it through its link. Everyone
+// but the creator has to present the passcode when one is set.
+func (s *ScheduledService) StartScheduledCall(id int64, userID int64, passcode string) (*CreateCallResult, error) {
 	call, err := s.scheduledCallRepo.GetByID(id)
 	if err != nil {
 		return nil, err
@@ -238,6 +277,15 @@ func (s *ScheduledService) StartScheduledCall(id int64, userID int64) (*CreateCa
 		if !isInvitee {
 			return nil, fmt.Errorf("unauthorized: user is not creator or invitee")
 		}
+
+		if err := s.callService.verifyPasscode(call.PasscodeHash, passcode, fmt.Sprintf("scheduled:%d", id), fmt.Sprintf("user:%d", userID)); err != nil {
+			return nil, err
+		}
+	}
+
+	// With a lobby only the host starts the meeting; everyone else waits to be admitted
+	if call.LobbyEnabled && call.CreatedBy != userID {
+		return s.callService.JoinStartedCall(call.ID, call.ScheduledAt, userID)
 	}
 
 	if call.Status != "scheduled" {
@@ -263,7 +311,13 @@ func (s *ScheduledService) StartScheduledCall(id int64, userID int64) (*CreateCa
 		return nil, fmt.Errorf("failed to create room: %w", err)
 	}
 
-	result, err := s.callService.CreateCallAndInvite(call.CreatedBy, call.CallType, []string{}, call.RoomName)
+	result, err := s.callService.CreateCallAndInvite(call.CreatedBy, call.CallType, []string{}, call.RoomName, CallOptions{
+		GuestAccessEnabled: call.GuestAccessEnabled,
+		LobbyEnabled:       call.LobbyEnabled,
+		passcodeHash:       call.PasscodeHash,
+		scheduledCallID:    call.ID,
+		occurrence:         call.ScheduledAt,
+	})
 	if err != nil {
 		return nil, err
 	}
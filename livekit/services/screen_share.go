@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"slices"
+)
+
+var (
+	ErrInvalidScreenSharePolicy     = errors.New("screenSharePolicy must be 'everyone', 'host' or 'request'")
+	ErrScreenShareHostOnly          = errors.New("only the host may share their screen in this call")
+	ErrScreenShareApprovalNotNeeded = errors.New("screen sharing does not need approval in this call")
+	ErrScreenShareRequestNotFound   = errors.New("screen share request not found")
+	ErrScreenShareRequestResolved   = errors.New("screen share request has already been answered")
+)
+
+// canScreenShare applies the call's screen share policy to a participant; the
+// host may always share
+func (s *CallService) canScreenShare(call *models.ActiveCall, identity string) bool {
+	if call.ScreenSharePolicy == "" || call.ScreenSharePolicy == "everyone" {
+		return true
+	}
+
+	userRepo := database.NewUserRepo(s.db)
+	host, err := userRepo.GetByID(call.CreatedBy)
+	if err == nil && host != nil && host.Username == identity {
+		return true
+	}
+	if call.ScreenSharePolicy != "request" {
+		return false
+	}
+
+	screenShareRepo := database.NewScreenShareRepo(s.db)
+	request, err := screenShareRepo.GetOpenByIdentity(call.CallID, identity)
+	if err != nil {
+		fmt.Printf("Failed to get screen share request of %s: %v\n", identity, err)
+		return false
+	}
+	return request != nil && request.Status == "approved"
+}
+
+// setScreenSharePolicy switches who may share their screen; earlier requests and
+// approvals are dropped so nobody keeps a grant from the previous policy
+func (s *CallService) setScreenSharePolicy(call *models.ActiveCall, policy string) error {
+	if policy != "everyone" && policy != "host" && policy != "request" {
+		return ErrInvalidScreenSharePolicy
+	}
+	if call.ScreenSharePolicy == policy {
+		return nil
+	}
+
+	callRepo := database.NewCallRepo(s.db)
+	if err := callRepo.UpdateScreenSharePolicy(call.CallID, policy); err != nil {
+		return err
+	}
+	call.ScreenSharePolicy = policy
+
+	screenShareRepo := database.NewScreenShareRepo(s.db)
+	if err := screenShareRepo.CloseOpen(call.CallID); err != nil {
+		fmt.Printf("Failed to close screen share requests for call %s: %v\n", call.CallID, err)
+	}
+
+	if call.Status == "active" {
+		s.refreshPublishSources(call, "")
+	}
+
+	if s.wsHub != nil {
+		s.wsHub.Publish(context.Background(), s.callMemberUsernames(call), events.ScreenSharePolicyChanged{
+			CallID: call.CallID,
+			Policy: policy,
+		})
+	}
+
+	return nil
+}
+
+// RequestScreenShare asks the host for permission to share in a call whose
+// policy is "request"
+func (s *CallService) RequestScreenShare(callID string, userID int64, username string) (*models.ScreenShareRequest, error) {
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+	if call.CreatedBy != userID && !slices.Contains(s.callMemberUsernames(call), username) {
+		return nil, ErrCallNotFound
+	}
+
+	switch {
+	case call.ScreenSharePolicy == "host" && call.CreatedBy != userID:
+		return nil, ErrScreenShareHostOnly
+	case call.ScreenSharePolicy != "request" || call.CreatedBy == userID:
+		return nil, ErrScreenShareApprovalNotNeeded
+	}
+
+	screenShareRepo := database.NewScreenShareRepo(s.db)
+	existing, err := screenShareRepo.GetOpenByIdentity(callID, username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	request, err := screenShareRepo.Create(callID, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.wsHub != nil {
+		userRepo := database.NewUserRepo(s.db)
+		host, err := userRepo.GetByID(call.CreatedBy)
+		if err == nil && host != nil {
+			s.wsHub.Publish(context.Background(), []string{host.Username}, events.ScreenShareRequested{ScreenShareRequest: request})
+		}
+	}
+
+	return request, nil
+}
+
+// GetScreenShareRequests lists pending requests and current approvals for the host
+func (s *CallService) GetScreenShareRequests(callID string, userID int64) ([]*models.ScreenShareRequest, error) {
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+
+	screenShareRepo := database.NewScreenShareRepo(s.db)
+	return screenShareRepo.GetOpenByCallID(callID)
+}
+
+// RespondToScreenShareRequest approves or denies a pending request; denying an
+// approved request takes the permission away again
+func (s *CallService) RespondToScreenShareRequest(requestID, hostID int64, approve bool) (*models.ScreenShareRequest, error) {
+	screenShareRepo := database.NewScreenShareRepo(s.db)
+	request, err := screenShareRepo.GetByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, ErrScreenShareRequestNotFound
+	}
+
+	callRepo := database.NewCallRepo(s.db)
+	call, err := callRepo.GetByCallID(request.CallID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != hostID {
+		return nil, ErrNotCallHost
+	}
+	if request.Status != "pending" && request.Status != "approved" {
+		return nil, ErrScreenShareRequestResolved
+	}
+	if request.Status == "approved" && approve {
+		return nil, ErrScreenShareRequestResolved
+	}
+
+	status := "denied"
+	if approve {
+		status = "approved"
+	}
+	if err := screenShareRepo.UpdateStatus(requestID, status); err != nil {
+		return nil, err
+	}
+	request.Status = status
+
+	s.refreshPublishSources(call, request.Identity)
+
+	if s.wsHub != nil {
+		s.wsHub.Publish(context.Background(), []string{request.Identity}, events.ScreenShareDecision{ScreenShareRequest: request})
+	}
+
+	return request, nil
+}
+
+type screenShareSocketRequest struct {
+	CallID    string `json:"callId"`
+	RequestID int64  `json:"requestId"`
+	Approve   bool   `json:"approve"`
+}
+
+func (s *CallService) handleScreenShareRequest(username string, data json.RawMessage) error {
+	var req screenShareSocketRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.CallID == "" {
+		return fmt.Errorf("callId is required")
+	}
+
+	userRepo := database.NewUserRepo(s.db)
+	user, err := userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	_, err = s.RequestScreenShare(req.CallID, user.ID, username)
+	return err
+}
+
+func (s *CallService) handleScreenShareResponse(username string, data json.RawMessage) error {
+	var req screenShareSocketRequest
+	if err := json.Unmarshal(data, &req); err != nil || req.RequestID == 0 {
+		return fmt.Errorf("requestId is required")
+	}
+
+	userRepo := database.NewUserRepo(s.db)
+	user, err := userRepo.GetByUsername(username)
+	if err != nil || user == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	_, err = s.RespondToScreenShareRequest(req.RequestID, user.ID, req.Approve)
+	return err
+}
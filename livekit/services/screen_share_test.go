@@ -0,0 +1,217 @@
+package services
+
+import (
+	"errors"
+	"livekit/database"
+	"slices"
+	"testing"
+
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+// setTestScreenSharePolicy switches the policy as the host does through the call settings
+func setTestScreenSharePolicy(t *testing.T, s *CallService, callID string, hostID int64, policy string) {
+	t.Helper()
+
+	if _, err := s.UpdateCallSettings(callID, hostID, CallSettingsUpdate{ScreenSharePolicy: &policy}); err != nil {
+		t.Fatalf("UpdateCallSettings: %v", err)
+	}
+}
+
+func canShare(sources []livekit.TrackSource) bool {
+	return slices.Contains(sources, livekit.TrackSource_SCREEN_SHARE)
+}
+
+func TestScreenSharePolicyTokens(t *testing.T) {
+	tests := []struct {
+		policy        string
+		inviteeShares bool
+	}{
+		{policy: "everyone", inviteeShares: true},
+		{policy: "host", inviteeShares: false},
+		{policy: "request", inviteeShares: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			db := newTestDB(t)
+			rooms := newFakeRoomClient()
+			s := newTestCallService(t, db, rooms, nil)
+			host := createTestUser(t, db, "host")
+			alice := createTestUser(t, db, "alice")
+
+			created, err := s.CreateCallAndInvite(host.ID, "video", []string{"alice"}, "", CallOptions{})
+			if err != nil {
+				t.Fatalf("CreateCallAndInvite: %v", err)
+			}
+			rooms.join(created.RoomName, &livekit.ParticipantInfo{Identity: "host"})
+			setTestScreenSharePolicy(t, s, created.CallID, host.ID, tt.policy)
+
+			invitations, err := database.NewInvitationRepo(db).GetCallParticipants(created.CallID)
+			if err != nil || len(invitations) != 1 {
+				t.Fatalf("invitations %v, %v", invitations, err)
+			}
+			joined, err := s.RespondToInvitation(invitations[0].ID, alice.ID, "accept")
+			if err != nil {
+				t.Fatalf("RespondToInvitation: %v", err)
+			}
+			if got := canShare(tokenSources(t, joined.Token)); got != tt.inviteeShares {
+				t.Errorf("invitee token allows screen sharing: %v, want %v", got, tt.inviteeShares)
+			}
+			// The host keeps the grant of their own token unless a policy change refreshed it
+			if permission := rooms.permission(created.RoomName, "host"); permission != nil && !canShare(permission.CanPublishSources) {
+				t.Errorf("host lost screen sharing under policy %s: %v", tt.policy, permission)
+			}
+		})
+	}
+}
+
+func TestScreenSharePolicySettings(t *testing.T) {
+	db := newTestDB(t)
+	s := newTestCallService(t, db, newFakeRoomClient(), nil)
+	host := createTestUser(t, db, "host")
+	alice := createTestUser(t, db, "alice")
+	callID := startTestCall(t, s, host, "alice")
+
+	policy := "presenters"
+	if _, err := s.UpdateCallSettings(callID, host.ID, CallSettingsUpdate{ScreenSharePolicy: &policy}); !errors.Is(err, ErrInvalidScreenSharePolicy) {
+		t.Errorf("unknown policy: %v, want ErrInvalidScreenSharePolicy", err)
+	}
+	policy = "host"
+	if _, err := s.UpdateCallSettings(callID, alice.ID, CallSettingsUpdate{ScreenSharePolicy: &policy}); !errors.Is(err, ErrNotCallHost) {
+		t.Errorf("policy change by an invitee: %v, want ErrNotCallHost", err)
+	}
+}
+
+func TestScreenShareApprovalFlow(t *testing.T) {
+	db := newTestDB(t)
+	rooms := newFakeRoomClient()
+	s := newTestCallService(t, db, rooms, nil)
+	host := createTestUser(t, db, "host")
+	alice := createTestUser(t, db, "alice")
+	callID := startTestCall(t, s, host, "alice")
+
+	invitations, err := database.NewInvitationRepo(db).GetCallParticipants(callID)
+	if err != nil || len(invitations) != 1 {
+		t.Fatalf("invitations %v, %v", invitations, err)
+	}
+	joined, err := s.RespondToInvitation(invitations[0].ID, alice.ID, "accept")
+	if err != nil {
+		t.Fatalf("RespondToInvitation: %v", err)
+	}
+	rooms.join(joined.RoomName, &livekit.ParticipantInfo{Identity: "alice"})
+	aliceShares := func() bool {
+		permission := rooms.permission(joined.RoomName, "alice")
+		return permission != nil && canShare(permission.CanPublishSources)
+	}
+
+	if _, err := s.RequestScreenShare(callID, alice.ID, "alice"); !errors.Is(err, ErrScreenShareApprovalNotNeeded) {
+		t.Errorf("request when everyone may share: %v, want ErrScreenShareApprovalNotNeeded", err)
+	}
+	setTestScreenSharePolicy(t, s, callID, host.ID, "host")
+	if _, err := s.RequestScreenShare(callID, alice.ID, "alice"); !errors.Is(err, ErrScreenShareHostOnly) {
+		t.Errorf("request when only the host may share: %v, want ErrScreenShareHostOnly", err)
+	}
+
+	setTestScreenSharePolicy(t, s, callID, host.ID, "request")
+	if aliceShares() {
+		t.Fatal("alice may share before asking")
+	}
+	if _, err := s.RequestScreenShare(callID, host.ID, "host"); !errors.Is(err, ErrScreenShareApprovalNotNeeded) {
+		t.Errorf("host asking to share: %v, want ErrScreenShareApprovalNotNeeded", err)
+	}
+	request, err := s.RequestScreenShare(callID, alice.ID, "alice")
+	if err != nil {
+		t.Fatalf("RequestScreenShare: %v", err)
+	}
+	if again, err := s.RequestScreenShare(callID, alice.ID, "alice"); err != nil || again.ID != request.ID {
+		t.Errorf("asking twice gave %v, %v; want request %d again", again, err, request.ID)
+	}
+
+	if _, err := s.GetScreenShareRequests(callID, alice.ID); !errors.Is(err, ErrNotCallHost) {
+		t.Errorf("GetScreenShareRequests by alice: %v, want ErrNotCallHost", err)
+	}
+	open, err := s.GetScreenShareRequests(callID, host.ID)
+	if err != nil || len(open) != 1 || open[0].ID != request.ID {
+		t.Errorf("GetScreenShareRequests = %v, %v; want request %d", open, err, request.ID)
+	}
+	if _, err := s.RespondToScreenShareRequest(request.ID, alice.ID, true); !errors.Is(err, ErrNotCallHost) {
+		t.Errorf("alice approving herself: %v, want ErrNotCallHost", err)
+	}
+
+	if _, err := s.RespondToScreenShareRequest(request.ID, host.ID, true); err != nil {
+		t.Fatalf("RespondToScreenShareRequest: %v", err)
+	}
+	if !aliceShares() {
+		t.Error("alice may not share after the host approved")
+	}
+	if _, err := s.RespondToScreenShareRequest(request.ID, host.ID, true); !errors.Is(err, ErrScreenShareRequestResolved) {
+		t.Errorf("approving twice: %v, want ErrScreenShareRequestResolved", err)
+	}
+
+	// Denying an approved request takes the permission away
+	if _, err := s.RespondToScreenShareRequest(request.ID, host.ID, false); err != nil {
+		t.Fatalf("RespondToScreenShareRequest: %v", err)
+	}
+	if aliceShares() {
+		t.Error("alice may still share after the host revoked it")
+	}
+
+	// An approval does not outlive a policy change
+	request, err = s.RequestScreenShare(callID, alice.ID, "alice")
+	if err != nil {
+		t.Fatalf("RequestScreenShare: %v", err)
+	}
+	if _, err := s.RespondToScreenShareRequest(request.ID, host.ID, true); err != nil {
+		t.Fatalf("RespondToScreenShareRequest: %v", err)
+	}
+	setTestScreenSharePolicy(t, s, callID, host.ID, "host")
+	setTestScreenSharePolicy(t, s, callID, host.ID, "request")
+	if aliceShares() {
+		t.Error("alice kept her approval across policy changes")
+	}
+}
+
+func TestLobbyAdmittedTokenAppliesScreenSharePolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		shares bool
+	}{
+		{policy: "everyone", shares: true},
+		{policy: "host", shares: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			db := newTestDB(t)
+			s := newTestCallService(t, db, newFakeRoomClient(), nil)
+			host := createTestUser(t, db, "host")
+			alice := createTestUser(t, db, "alice")
+
+			created, err := s.CreateCallAndInvite(host.ID, "video", []string{"alice"}, "", CallOptions{LobbyEnabled: true})
+			if err != nil {
+				t.Fatalf("CreateCallAndInvite: %v", err)
+			}
+			setTestScreenSharePolicy(t, s, created.CallID, host.ID, tt.policy)
+			invitations, err := database.NewInvitationRepo(db).GetCallParticipants(created.CallID)
+			if err != nil || len(invitations) != 1 {
+				t.Fatalf("invitations %v, %v", invitations, err)
+			}
+			waiting, err := s.RespondToInvitation(invitations[0].ID, alice.ID, "accept")
+			if err != nil {
+				t.Fatalf("RespondToInvitation: %v", err)
+			}
+			if _, err := s.RespondToLobbyRequest(waiting.LobbyRequestID, host.ID, true); err != nil {
+				t.Fatalf("RespondToLobbyRequest: %v", err)
+			}
+
+			status, err := s.GetLobbyStatus(waiting.LobbyRequestID, alice.ID)
+			if err != nil || status.Token == "" {
+				t.Fatalf("GetLobbyStatus = %+v, %v; want a token", status, err)
+			}
+			if got := canShare(tokenSources(t, status.Token)); got != tt.shares {
+				t.Errorf("admitted token allows screen sharing: %v, want %v", got, tt.shares)
+			}
+		})
+	}
+}
@@ -0,0 +1,443 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"livekit/auth"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"math/big"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+const sipDialOutTimeout = 60 * time.Second
+
+var (
+	ErrSIPNotConfigured   = errors.New("SIP is not configured on this server")
+	ErrInvalidPhoneNumber = errors.New("phoneNumber must be in international format, e.g. +15551234567")
+	ErrDialInNotEnabled   = errors.New("dial-in is not enabled for this call")
+)
+
+var phoneNumberPattern = regexp.MustCompile(`^\+?[1-9][0-9]{4,14}$`)
+
+// SIPClient is the subset of the LiveKit SIP API used for dial-in and dial-out.
+// *lksdk.SIPClient satisfies it.
+type SIPClient interface {
+	CreateSIPInboundTrunk(ctx context.Context, in *livekit.CreateSIPInboundTrunkRequest) (*livekit.SIPInboundTrunkInfo, error)
+	CreateSIPOutboundTrunk(ctx context.Context, in *livekit.CreateSIPOutboundTrunkRequest) (*livekit.SIPOutboundTrunkInfo, error)
+	DeleteSIPTrunk(ctx context.Context, in *livekit.DeleteSIPTrunkRequest) (*livekit.SIPTrunkInfo, error)
+	CreateSIPDispatchRule(ctx context.Context, in *livekit.CreateSIPDispatchRuleRequest) (*livekit.SIPDispatchRuleInfo, error)
+	DeleteSIPDispatchRule(ctx context.Context, in *livekit.DeleteSIPDispatchRuleRequest) (*livekit.SIPDispatchRuleInfo, error)
+	CreateSIPParticipant(ctx context.Context, in *livekit.CreateSIPParticipantRequest) (*livekit.SIPParticipantInfo, error)
+}
+
+type SIPServiceConfig struct {
+	InboundNumbers   []string // Phone numbers callers dial; empty disables dial-in
+	OutboundAddress  string   // SIP provider host; empty disables dial-out
+	OutboundNumbers  []string // Caller IDs used when dialing out
+	OutboundUsername string
+	OutboundPassword string
+}
+
+type DialOutResult struct {
+	CallID      string `json:"callId"`
+	Identity    string `json:"identity"`
+	PhoneNumber string `json:"phoneNumber"`
+	Status      string `json:"status"` // "dialing"
+}
+
+type SIPService struct {
+	db          *database.DB
+	config      *SIPServiceConfig
+	sipClient   SIPClient
+	sipRepo     *database.SIPRepo
+	callRepo    *database.CallRepo
+	userRepo    *database.UserRepo
+	callService *CallService
+	wsHub       *websocket.WebSocketHub
+}
+
+func NewSIPService(db *database.DB, cfg *SIPServiceConfig, sipClient SIPClient, callService *CallService, wsHub *websocket.WebSocketHub) *SIPService {
+	s := &SIPService{
+		db:          db,
+		config:      cfg,
+		sipClient:   sipClient,
+		sipRepo:     database.NewSIPRepo(db),
+		callRepo:    database.NewCallRepo(db),
+		userRepo:    database.NewUserRepo(db),
+		callService: callService,
+		wsHub:       wsHub,
+	}
+	callService.OnCallEnded(s.closeDialIn)
+	return s
+}
+
+// SyncTrunks makes sure LiveKit has trunks matching the configuration. Trunks
+// are stored so restarts reuse them instead of creating new ones each time.
+func (s *SIPService) SyncTrunks() error {
+	if err := s.syncInboundTrunk(); err != nil {
+		return err
+	}
+	return s.syncOutboundTrunk()
+}
+
+func (s *SIPService) syncInboundTrunk() error {
+	stored, err := s.sipRepo.GetTrunk("inbound")
+	if err != nil {
+		return err
+	}
+	if len(s.config.InboundNumbers) == 0 {
+		return s.removeTrunk(stored)
+	}
+	if stored != nil && slices.Equal(stored.Numbers, s.config.InboundNumbers) {
+		return nil
+	}
+
+	info, err := s.sipClient.CreateSIPInboundTrunk(context.Background(), &livekit.CreateSIPInboundTrunkRequest{
+		Trunk: &livekit.SIPInboundTrunkInfo{
+			Name:    "vidconf inbound",
+			Numbers: s.config.InboundNumbers,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create inbound trunk: %w", err)
+	}
+
+	if err := s.removeTrunk(stored); err != nil {
+		fmt.Printf("Failed to remove previous inbound trunk: %v\n", err)
+	}
+	return s.sipRepo.SaveTrunk(&models.SIPTrunk{
+		Direction: "inbound",
+		TrunkID:   info.SipTrunkId,
+		Numbers:   s.config.InboundNumbers,
+	})
+}
+
+func (s *SIPService) syncOutboundTrunk() error {
+	stored, err := s.sipRepo.GetTrunk("outbound")
+	if err != nil {
+		return err
+	}
+	if s.config.OutboundAddress == "" {
+		return s.removeTrunk(stored)
+	}
+	if stored != nil && stored.Address == s.config.OutboundAddress &&
+		slices.Equal(stored.Numbers, s.config.OutboundNumbers) &&
+		stored.AuthUsername == s.config.OutboundUsername && s.storedPassword(stored) == s.config.OutboundPassword {
+		return nil
+	}
+
+	info, err := s.sipClient.CreateSIPOutboundTrunk(context.Background(), &livekit.CreateSIPOutboundTrunkRequest{
+		Trunk: &livekit.SIPOutboundTrunkInfo{
+			Name:         "vidconf outbound",
+			Address:      s.config.OutboundAddress,
+			Numbers:      s.config.OutboundNumbers,
+			AuthUsername: s.config.OutboundUsername,
+			AuthPassword: s.config.OutboundPassword,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create outbound trunk: %w", err)
+	}
+
+	trunk := &models.SIPTrunk{
+		Direction:    "outbound",
+		TrunkID:      info.SipTrunkId,
+		Numbers:      s.config.OutboundNumbers,
+		Address:      s.config.OutboundAddress,
+		AuthUsername: s.config.OutboundUsername,
+	}
+	if s.config.OutboundPassword != "" {
+		trunk.AuthPasswordEncrypted, err = auth.EncryptSecret(s.config.OutboundPassword)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt trunk password: %w", err)
+		}
+	}
+
+	if err := s.removeTrunk(stored); err != nil {
+		fmt.Printf("Failed to remove previous outbound trunk: %v\n", err)
+	}
+	return s.sipRepo.SaveTrunk(trunk)
+}
+
+func (s *SIPService) removeTrunk(trunk *models.SIPTrunk) error {
+	if trunk == nil {
+		return nil
+	}
+	if _, err := s.sipClient.DeleteSIPTrunk(context.Background(), &livekit.DeleteSIPTrunkRequest{SipTrunkId: trunk.TrunkID}); err != nil {
+		return fmt.Errorf("failed to delete trunk %s: %w", trunk.TrunkID, err)
+	}
+	return s.sipRepo.DeleteTrunk(trunk.Direction)
+}
+
+func (s *SIPService) storedPassword(trunk *models.SIPTrunk) string {
+	if trunk.AuthPasswordEncrypted == "" {
+		return ""
+	}
+	password, err := auth.DecryptSecret(trunk.AuthPasswordEncrypted)
+	if err != nil {
+		return ""
+	}
+	return password
+}
+
+// EnableDialIn gives a call a PIN that routes callers of the inbound numbers
+// into its room. Enabling it twice returns the existing PIN.
+func (s *SIPService) EnableDialIn(callID string, userID int64) (*models.SIPDialIn, error) {
+	call, err := s.hostCall(callID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+
+	trunk, err := s.sipRepo.GetTrunk("inbound")
+	if err != nil {
+		return nil, err
+	}
+	if trunk == nil {
+		return nil, ErrSIPNotConfigured
+	}
+
+	existing, err := s.sipRepo.GetActiveDialIn(callID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		existing.PhoneNumbers = trunk.Numbers
+		return existing, nil
+	}
+
+	pin, err := s.uniquePin()
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := s.sipClient.CreateSIPDispatchRule(context.Background(), &livekit.CreateSIPDispatchRuleRequest{
+		DispatchRule: &livekit.SIPDispatchRuleInfo{
+			Name:     "call " + callID,
+			TrunkIds: []string{trunk.TrunkID},
+			Rule: &livekit.SIPDispatchRule{
+				Rule: &livekit.SIPDispatchRule_DispatchRuleDirect{
+					DispatchRuleDirect: &livekit.SIPDispatchRuleDirect{
+						RoomName: call.RoomName,
+						Pin:      pin,
+					},
+				},
+			},
+			Attributes: map[string]string{"role": "sip", "callId": callID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dispatch rule: %w", err)
+	}
+
+	dialIn, err := s.sipRepo.CreateDialIn(callID, call.RoomName, rule.SipDispatchRuleId, pin, userID)
+	if err != nil {
+		s.deleteDispatchRule(rule.SipDispatchRuleId)
+		return nil, err
+	}
+	dialIn.PhoneNumbers = trunk.Numbers
+
+	return dialIn, nil
+}
+
+// GetDialIn returns the dial-in details of a call to its host and members
+func (s *SIPService) GetDialIn(callID string, userID int64, username string) (*models.SIPDialIn, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID && !slices.Contains(s.callService.callMemberUsernames(call), username) {
+		return nil, ErrCallNotFound
+	}
+
+	dialIn, err := s.sipRepo.GetActiveDialIn(callID)
+	if err != nil {
+		return nil, err
+	}
+	if dialIn == nil {
+		return nil, ErrDialInNotEnabled
+	}
+
+	trunk, err := s.sipRepo.GetTrunk("inbound")
+	if err != nil {
+		return nil, err
+	}
+	if trunk != nil {
+		dialIn.PhoneNumbers = trunk.Numbers
+	}
+
+	return dialIn, nil
+}
+
+func (s *SIPService) DisableDialIn(callID string, userID int64) error {
+	call, err := s.hostCall(callID, userID)
+	if err != nil {
+		return err
+	}
+
+	dialIn, err := s.sipRepo.GetActiveDialIn(call.CallID)
+	if err != nil {
+		return err
+	}
+	if dialIn == nil {
+		return ErrDialInNotEnabled
+	}
+
+	s.deleteDispatchRule(dialIn.DispatchRuleID)
+	return s.sipRepo.CloseDialIn(dialIn.ID)
+}
+
+// DialOut calls a phone number and connects it to the call's room. The call is
+// placed in the background and the host hears about the outcome on the socket.
+func (s *SIPService) DialOut(callID string, userID int64, phoneNumber, displayName string) (*DialOutResult, error) {
+	call, err := s.hostCall(callID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+
+	phoneNumber = strings.ReplaceAll(strings.TrimSpace(phoneNumber), " ", "")
+	if !phoneNumberPattern.MatchString(phoneNumber) {
+		return nil, ErrInvalidPhoneNumber
+	}
+
+	trunk, err := s.sipRepo.GetTrunk("outbound")
+	if err != nil {
+		return nil, err
+	}
+	if trunk == nil {
+		return nil, ErrSIPNotConfigured
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("failed to generate identity: %w", err)
+	}
+	identity := "sip-" + hex.EncodeToString(suffix)
+	if displayName == "" {
+		displayName = phoneNumber
+	}
+
+	if err := s.callService.historyService.RecordSIPParticipant(callID, identity, "pending"); err != nil {
+		fmt.Printf("Failed to record SIP participant in call history: %v\n", err)
+	}
+
+	go s.placeCall(call, userID, trunk.TrunkID, phoneNumber, identity, displayName)
+
+	return &DialOutResult{
+		CallID:      callID,
+		Identity:    identity,
+		PhoneNumber: phoneNumber,
+		Status:      "dialing",
+	}, nil
+}
+
+func (s *SIPService) placeCall(call *models.ActiveCall, hostID int64, trunkID, phoneNumber, identity, displayName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sipDialOutTimeout)
+	defer cancel()
+
+	_, err := s.sipClient.CreateSIPParticipant(ctx, &livekit.CreateSIPParticipantRequest{
+		SipTrunkId:            trunkID,
+		SipCallTo:             phoneNumber,
+		RoomName:              call.RoomName,
+		ParticipantIdentity:   identity,
+		ParticipantName:       displayName,
+		ParticipantAttributes: map[string]string{"role": "sip", "callId": call.CallID},
+		WaitUntilAnswered:     true,
+	})
+
+	outcome, status, reason := "accepted", "answered", ""
+	if err != nil {
+		outcome, status, reason = "missed", "failed", err.Error()
+	}
+	if err := s.callService.historyService.RecordOutcome(call.CallID, identity, outcome); err != nil {
+		fmt.Printf("Failed to record SIP outcome in call history: %v\n", err)
+	}
+
+	if s.wsHub != nil {
+		host, err := s.userRepo.GetByID(hostID)
+		if err == nil && host != nil {
+			s.wsHub.Publish(context.Background(), []string{host.Username}, events.SIPDialOutStatus{
+				CallID:   call.CallID,
+				Identity: identity,
+				Status:   status,
+				Reason:   reason,
+			})
+		}
+	}
+}
+
+// closeDialIn removes the dispatch rule of a call once it is over so the PIN
+// can't be used anymore and becomes available again
+func (s *SIPService) closeDialIn(call *models.ActiveCall) {
+	dialIn, err := s.sipRepo.GetActiveDialIn(call.CallID)
+	if err != nil {
+		fmt.Printf("Failed to get dial-in for call %s: %v\n", call.CallID, err)
+		return
+	}
+	if dialIn == nil {
+		return
+	}
+
+	s.deleteDispatchRule(dialIn.DispatchRuleID)
+	if err := s.sipRepo.CloseDialIn(dialIn.ID); err != nil {
+		fmt.Printf("Failed to close dial-in for call %s: %v\n", call.CallID, err)
+	}
+}
+
+func (s *SIPService) deleteDispatchRule(ruleID string) {
+	if _, err := s.sipClient.DeleteSIPDispatchRule(context.Background(), &livekit.DeleteSIPDispatchRuleRequest{SipDispatchRuleId: ruleID}); err != nil {
+		fmt.Printf("Failed to delete dispatch rule %s: %v\n", ruleID, err)
+	}
+}
+
+func (s *SIPService) hostCall(callID string, userID int64) (*models.ActiveCall, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+	return call, nil
+}
+
+func (s *SIPService) uniquePin() (string, error) {
+	for i := 0; i < 10; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate pin: %w", err)
+		}
+		pin := fmt.Sprintf("%06d", n.Int64())
+
+		inUse, err := s.sipRepo.PinInUse(pin)
+		if err != nil {
+			return "", err
+		}
+		if !inUse {
+			return pin, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate a unique pin")
+}
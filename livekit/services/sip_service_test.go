@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"sync"
+	"testing"
+	"time"
+
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+// fakeSIPClient answers dial-outs with dialErr, or picks up when it is nil
+type fakeSIPClient struct {
+	mu      sync.Mutex
+	next    int
+	dialErr error
+	dialed  []*livekit.CreateSIPParticipantRequest
+	deleted []string
+	rules   []*livekit.CreateSIPDispatchRuleRequest
+}
+
+func (c *fakeSIPClient) id(prefix string) string {
+	c.next++
+	return fmt.Sprintf("%s_%d", prefix, c.next)
+}
+
+func (c *fakeSIPClient) CreateSIPInboundTrunk(ctx context.Context, in *livekit.CreateSIPInboundTrunkRequest) (*livekit.SIPInboundTrunkInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &livekit.SIPInboundTrunkInfo{SipTrunkId: c.id("ST_IN")}, nil
+}
+
+func (c *fakeSIPClient) CreateSIPOutboundTrunk(ctx context.Context, in *livekit.CreateSIPOutboundTrunkRequest) (*livekit.SIPOutboundTrunkInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &livekit.SIPOutboundTrunkInfo{SipTrunkId: c.id("ST_OUT")}, nil
+}
+
+func (c *fakeSIPClient) DeleteSIPTrunk(ctx context.Context, in *livekit.DeleteSIPTrunkRequest) (*livekit.SIPTrunkInfo, error) {
+	return &livekit.SIPTrunkInfo{SipTrunkId: in.SipTrunkId}, nil
+}
+
+func (c *fakeSIPClient) CreateSIPDispatchRule(ctx context.Context, in *livekit.CreateSIPDispatchRuleRequest) (*livekit.SIPDispatchRuleInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = append(c.rules, in)
+	return &livekit.SIPDispatchRuleInfo{SipDispatchRuleId: c.id("SDR")}, nil
+}
+
+func (c *fakeSIPClient) DeleteSIPDispatchRule(ctx context.Context, in *livekit.DeleteSIPDispatchRuleRequest) (*livekit.SIPDispatchRuleInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, in.SipDispatchRuleId)
+	return &livekit.SIPDispatchRuleInfo{SipDispatchRuleId: in.SipDispatchRuleId}, nil
+}
+
+func (c *fakeSIPClient) CreateSIPParticipant(ctx context.Context, in *livekit.CreateSIPParticipantRequest) (*livekit.SIPParticipantInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dialed = append(c.dialed, in)
+	if c.dialErr != nil {
+		return nil, c.dialErr
+	}
+	return &livekit.SIPParticipantInfo{ParticipantIdentity: in.ParticipantIdentity, RoomName: in.RoomName}, nil
+}
+
+type sipTestEnv struct {
+	db          *database.DB
+	rooms       *fakeRoomClient
+	sipClient   *fakeSIPClient
+	callService *CallService
+	sipService  *SIPService
+	host        *models.User
+	hostSocket  *websocket.Connection
+}
+
+// newSIPTestEnv sets up dial-in and dial-out trunks and a host listening on a socket
+func newSIPTestEnv(t *testing.T) *sipTestEnv {
+	t.Helper()
+
+	env := &sipTestEnv{
+		db:        newTestDB(t),
+		rooms:     newFakeRoomClient(),
+		sipClient: &fakeSIPClient{},
+	}
+	wsHub := websocket.NewWebSocketHub()
+	env.callService = newTestCallService(t, env.db, env.rooms, wsHub)
+	env.sipService = NewSIPService(env.db, &SIPServiceConfig{
+		InboundNumbers:  []string{"+15550001111"},
+		OutboundAddress: "sip.example.com",
+		OutboundNumbers: []string{"+15550002222"},
+	}, env.sipClient, env.callService, wsHub)
+	if err := env.sipService.SyncTrunks(); err != nil {
+		t.Fatalf("SyncTrunks: %v", err)
+	}
+
+	env.host = createTestUser(t, env.db, "host")
+	env.hostSocket = &websocket.Connection{ID: "host-socket", Username: "host", Send: make(chan []byte, 16)}
+	wsHub.Register("host", env.hostSocket)
+	t.Cleanup(func() { wsHub.Unregister("host", "host-socket") })
+	return env
+}
+
+// dialOutStatus waits for the host to hear how a dial-out went
+func (env *sipTestEnv) dialOutStatus(t *testing.T) events.SIPDialOutStatus {
+	t.Helper()
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case data := <-env.hostSocket.Send:
+			var envelope events.Envelope
+			if err := json.Unmarshal(data, &envelope); err != nil {
+				t.Fatalf("decode %s: %v", data, err)
+			}
+			if envelope.Type != "sip_dial_out_status" {
+				continue
+			}
+			var status events.SIPDialOutStatus
+			if err := json.Unmarshal(envelope.Data, &status); err != nil {
+				t.Fatalf("decode %s: %v", envelope.Data, err)
+			}
+			return status
+		case <-deadline:
+			t.Fatal("host never heard about the dial-out")
+			return events.SIPDialOutStatus{}
+		}
+	}
+}
+
+func historyOutcome(t *testing.T, db *database.DB, callID, identity string) *models.CallParticipantOutcome {
+	t.Helper()
+
+	outcomes, err := database.NewCallHistoryParticipantRepo(db).GetByCallID(callID)
+	if err != nil {
+		t.Fatalf("GetByCallID: %v", err)
+	}
+	for _, outcome := range outcomes {
+		if outcome.Identity == identity {
+			return outcome
+		}
+	}
+	return nil
+}
+
+func TestDialOut(t *testing.T) {
+	tests := []struct {
+		name        string
+		dialErr     error
+		wantStatus  string
+		wantOutcome string
+	}{
+		{name: "answered", wantStatus: "answered", wantOutcome: "accepted"},
+		{name: "not answered", dialErr: errors.New("busy here"), wantStatus: "failed", wantOutcome: "missed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newSIPTestEnv(t)
+			env.sipClient.dialErr = tt.dialErr
+			callID := startTestCall(t, env.callService, env.host)
+			call, _ := database.NewCallRepo(env.db).GetByCallID(callID)
+
+			result, err := env.sipService.DialOut(callID, env.host.ID, "+1 555 123 4567", "")
+			if err != nil {
+				t.Fatalf("DialOut: %v", err)
+			}
+			if result.Status != "dialing" || result.PhoneNumber != "+15551234567" {
+				t.Errorf("DialOut = %+v, want dialing +15551234567", result)
+			}
+
+			status := env.dialOutStatus(t)
+			if status.CallID != callID || status.Identity != result.Identity || status.Status != tt.wantStatus {
+				t.Errorf("dial-out status %+v, want %s for %s", status, tt.wantStatus, result.Identity)
+			}
+			if tt.dialErr != nil && status.Reason == "" {
+				t.Error("failed dial-out has no reason")
+			}
+
+			env.sipClient.mu.Lock()
+			req := env.sipClient.dialed[0]
+			env.sipClient.mu.Unlock()
+			if req.SipTrunkId == "" || req.SipCallTo != "+15551234567" || req.RoomName != call.RoomName ||
+				req.ParticipantIdentity != result.Identity || req.ParticipantName != "+15551234567" || !req.WaitUntilAnswered {
+				t.Errorf("unexpected dial-out request %+v", req)
+			}
+
+			outcome := historyOutcome(t, env.db, callID, result.Identity)
+			if outcome == nil || outcome.Role != "sip" || outcome.Outcome != tt.wantOutcome {
+				t.Errorf("history participant %+v, want sip with outcome %s", outcome, tt.wantOutcome)
+			}
+		})
+	}
+}
+
+func TestDialOutRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		phone   string
+		byGuest bool
+		ended   bool
+		noTrunk bool
+		wantErr error
+	}{
+		{name: "invalid number", phone: "12ab", wantErr: ErrInvalidPhoneNumber},
+		{name: "not the host", phone: "+15551234567", byGuest: true, wantErr: ErrNotCallHost},
+		{name: "ended call", phone: "+15551234567", ended: true, wantErr: ErrCallNotActive},
+		{name: "no outbound trunk", phone: "+15551234567", noTrunk: true, wantErr: ErrSIPNotConfigured},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newSIPTestEnv(t)
+			guest := createTestUser(t, env.db, "guest")
+			callID := startTestCall(t, env.callService, env.host)
+			if tt.ended {
+				endTestCall(t, env.db, callID)
+			}
+			if tt.noTrunk {
+				if err := database.NewSIPRepo(env.db).DeleteTrunk("outbound"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			userID := env.host.ID
+			if tt.byGuest {
+				userID = guest.ID
+			}
+			if _, err := env.sipService.DialOut(callID, userID, tt.phone, ""); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DialOut error = %v, want %v", err, tt.wantErr)
+			}
+			if len(env.sipClient.dialed) != 0 {
+				t.Error("dialed out although the request was refused")
+			}
+		})
+	}
+}
+
+func TestEndCallRecordsSIPParticipants(t *testing.T) {
+	env := newSIPTestEnv(t)
+	callID := startTestCall(t, env.callService, env.host)
+	call, _ := database.NewCallRepo(env.db).GetByCallID(callID)
+
+	dialIn, err := env.sipService.EnableDialIn(callID, env.host.ID)
+	if err != nil {
+		t.Fatalf("EnableDialIn: %v", err)
+	}
+
+	// The host, a caller who dialed in and a number that was dialed out to
+	env.rooms.join(call.RoomName, &livekit.ParticipantInfo{Identity: "host", Kind: livekit.ParticipantInfo_STANDARD})
+	env.rooms.join(call.RoomName, &livekit.ParticipantInfo{Identity: "sip-caller", Kind: livekit.ParticipantInfo_SIP})
+	dialed, err := env.sipService.DialOut(callID, env.host.ID, "+15551234567", "")
+	if err != nil {
+		t.Fatalf("DialOut: %v", err)
+	}
+	env.dialOutStatus(t)
+	env.rooms.join(call.RoomName, &livekit.ParticipantInfo{Identity: dialed.Identity, Kind: livekit.ParticipantInfo_SIP})
+
+	if err := env.callService.EndCall(callID, env.host.ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+
+	for _, identity := range []string{"sip-caller", dialed.Identity} {
+		outcome := historyOutcome(t, env.db, callID, identity)
+		if outcome == nil || outcome.Role != "sip" || outcome.Outcome != "accepted" {
+			t.Errorf("history participant %s = %+v, want sip accepted", identity, outcome)
+		}
+	}
+
+	// Ending the call closes its dial-in so the PIN stops working
+	env.sipClient.mu.Lock()
+	deleted := env.sipClient.deleted
+	env.sipClient.mu.Unlock()
+	if len(deleted) != 1 || deleted[0] != dialIn.DispatchRuleID {
+		t.Errorf("deleted dispatch rules %v, want [%s]", deleted, dialIn.DispatchRuleID)
+	}
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"livekit/auth"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"net/url"
+	"strings"
+	"time"
+
+	livekit "github.com/livekit/protocol/livekit"
+)
+
+const maxStreamDestinations = 5
+
+var (
+	ErrInvalidStreamDestination  = errors.New("stream url must be an rtmp://, rtmps:// or srt:// url")
+	ErrTooManyStreamDestinations = fmt.Errorf("a call can stream to at most %d destinations", maxStreamDestinations)
+	ErrLiveStreamNotFound        = errors.New("live stream not found")
+)
+
+// StreamDestination is where a call is streamed to. The stream key is stored
+// encrypted; a key embedded in the URL is moved out of it before it is saved.
+type StreamDestination struct {
+	URL       string `json:"url"`
+	StreamKey string `json:"streamKey,omitempty"`
+}
+
+type StreamingService struct {
+	db             *database.DB
+	egressClient   EgressClient
+	liveStreamRepo *database.LiveStreamRepo
+	callRepo       *database.CallRepo
+	userRepo       *database.UserRepo
+	wsHub          *websocket.WebSocketHub
+}
+
+func NewStreamingService(db *database.DB, egressClient EgressClient, callService *CallService, wsHub *websocket.WebSocketHub) *StreamingService {
+	s := &StreamingService{
+		db:             db,
+		egressClient:   egressClient,
+		liveStreamRepo: database.NewLiveStreamRepo(db),
+		callRepo:       database.NewCallRepo(db),
+		userRepo:       database.NewUserRepo(db),
+		wsHub:          wsHub,
+	}
+	callService.OnCallEnded(s.stopOnCallEnded)
+	return s
+}
+
+// StartStreams starts one egress per destination so a failing endpoint
+// doesn't take the other streams down with it
+func (s *StreamingService) StartStreams(callID string, userID int64, destinations []StreamDestination) ([]*models.LiveStream, error) {
+	call, err := s.hostCall(callID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+
+	if len(destinations) == 0 {
+		return nil, ErrInvalidStreamDestination
+	}
+	existing, err := s.liveStreamRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+	live := 0
+	for _, stream := range existing {
+		if stream.Status == "starting" || stream.Status == "active" {
+			live++
+		}
+	}
+	if live+len(destinations) > maxStreamDestinations {
+		return nil, ErrTooManyStreamDestinations
+	}
+
+	protocols := make([]livekit.StreamProtocol, len(destinations))
+	for i, dest := range destinations {
+		protocols[i], err = streamProtocol(dest.URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var streams []*models.LiveStream
+	for i, dest := range destinations {
+		storedURL, streamKey := redactStreamURL(dest.URL, dest.StreamKey, protocols[i])
+
+		stream := &models.LiveStream{
+			CallID:       callID,
+			RoomName:     call.RoomName,
+			StartedBy:    userID,
+			Protocol:     strings.ToLower(protocols[i].String()),
+			URL:          storedURL,
+			Status:       "starting",
+			HasStreamKey: streamKey != "",
+		}
+		if streamKey != "" {
+			if stream.StreamKeyEncrypted, err = auth.EncryptSecret(streamKey); err != nil {
+				return nil, fmt.Errorf("failed to encrypt stream key: %w", err)
+			}
+		}
+
+		info, err := s.egressClient.StartRoomCompositeEgress(context.Background(), &livekit.RoomCompositeEgressRequest{
+			RoomName:  call.RoomName,
+			AudioOnly: call.CallType == "voice",
+			StreamOutputs: []*livekit.StreamOutput{
+				{
+					Protocol: protocols[i],
+					Urls:     []string{streamURL(dest.URL, dest.StreamKey, protocols[i])},
+				},
+			},
+		})
+		if err != nil {
+			now := time.Now()
+			stream.Status = "failed"
+			stream.Error = err.Error()
+			stream.EndedAt = &now
+		} else {
+			stream.EgressID = info.EgressId
+		}
+
+		if err := s.liveStreamRepo.Create(stream); err != nil {
+			// Don't leave an untracked egress running
+			if stream.EgressID != "" {
+				if _, stopErr := s.egressClient.StopEgress(context.Background(), &livekit.StopEgressRequest{EgressId: stream.EgressID}); stopErr != nil {
+					fmt.Printf("Failed to stop egress %s: %v\n", stream.EgressID, stopErr)
+				}
+			}
+			return nil, err
+		}
+		if stream.Status == "failed" {
+			s.notifyFailure(call, stream)
+		}
+		streams = append(streams, stream)
+	}
+
+	return streams, nil
+}
+
+// StopStreams stops one stream of a call, or all of them when streamID is 0
+func (s *StreamingService) StopStreams(callID string, userID, streamID int64) ([]*models.LiveStream, error) {
+	if _, err := s.hostCall(callID, userID); err != nil {
+		return nil, err
+	}
+
+	streams, err := s.liveStreamRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, err
+	}
+
+	var stopped []*models.LiveStream
+	for _, stream := range streams {
+		if streamID != 0 && stream.ID != streamID {
+			continue
+		}
+		if stream.Status != "starting" && stream.Status != "active" {
+			continue
+		}
+
+		if err := s.stopStream(stream); err != nil {
+			return nil, err
+		}
+		stopped = append(stopped, stream)
+	}
+
+	if streamID != 0 && len(stopped) == 0 {
+		return nil, ErrLiveStreamNotFound
+	}
+
+	return stopped, nil
+}
+
+// stopOnCallEnded stops every stream of a call that was still live when the call ended
+func (s *StreamingService) stopOnCallEnded(call *models.ActiveCall) {
+	streams, err := s.liveStreamRepo.GetByCallID(call.CallID)
+	if err != nil {
+		fmt.Printf("Failed to get live streams of call %s: %v\n", call.CallID, err)
+		return
+	}
+
+	for _, stream := range streams {
+		if stream.Status != "starting" && stream.Status != "active" {
+			continue
+		}
+		if err := s.stopStream(stream); err != nil {
+			fmt.Printf("Failed to stop live stream %d: %v\n", stream.ID, err)
+		}
+	}
+}
+
+func (s *StreamingService) stopStream(stream *models.LiveStream) error {
+	if _, err := s.egressClient.StopEgress(context.Background(), &livekit.StopEgressRequest{EgressId: stream.EgressID}); err != nil {
+		return fmt.Errorf("failed to stop egress: %w", err)
+	}
+
+	now := time.Now()
+	stream.Status = "stopped"
+	stream.EndedAt = &now
+	return s.liveStreamRepo.UpdateStatus(stream.ID, stream.Status, stream.Error, stream.EndedAt)
+}
+
+func (s *StreamingService) GetStreams(callID string, userID int64) ([]*models.LiveStream, error) {
+	if _, err := s.hostCall(callID, userID); err != nil {
+		return nil, err
+	}
+	return s.liveStreamRepo.GetByCallID(callID)
+}
+
+// RefreshStreams syncs the status of running streams with the egress service
+// and tells the host when one of them failed
+func (s *StreamingService) RefreshStreams() error {
+	streams, err := s.liveStreamRepo.GetLive()
+	if err != nil {
+		return err
+	}
+
+	for _, stream := range streams {
+		res, err := s.egressClient.ListEgress(context.Background(), &livekit.ListEgressRequest{EgressId: stream.EgressID})
+		if err != nil {
+			fmt.Printf("Failed to list egress %s: %v\n", stream.EgressID, err)
+			continue
+		}
+		if len(res.Items) == 0 {
+			continue
+		}
+
+		status, errorMessage := liveStreamStatus(res.Items[0])
+		if status == stream.Status {
+			continue
+		}
+
+		stream.Status = status
+		stream.Error = errorMessage
+		if status == "stopped" || status == "failed" {
+			now := time.Now()
+			stream.EndedAt = &now
+		}
+		if err := s.liveStreamRepo.UpdateStatus(stream.ID, stream.Status, stream.Error, stream.EndedAt); err != nil {
+			fmt.Printf("Failed to update live stream %d: %v\n", stream.ID, err)
+			continue
+		}
+
+		if status == "failed" {
+			call, err := s.callRepo.GetByCallID(stream.CallID)
+			if err == nil && call != nil {
+				s.notifyFailure(call, stream)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *StreamingService) hostCall(callID string, userID int64) (*models.ActiveCall, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+	return call, nil
+}
+
+func (s *StreamingService) notifyFailure(call *models.ActiveCall, stream *models.LiveStream) {
+	if s.wsHub == nil {
+		return
+	}
+	host, err := s.userRepo.GetByID(call.CreatedBy)
+	if err != nil || host == nil {
+		return
+	}
+	s.wsHub.Publish(context.Background(), []string{host.Username}, events.LiveStreamFailed{LiveStream: stream})
+}
+
+func streamProtocol(rawURL string) (livekit.StreamProtocol, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return 0, ErrInvalidStreamDestination
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "rtmp", "rtmps":
+		return livekit.StreamProtocol_RTMP, nil
+	case "srt":
+		return livekit.StreamProtocol_SRT, nil
+	default:
+		return 0, ErrInvalidStreamDestination
+	}
+}
+
+// streamURL combines the destination with its key the way each protocol expects it
+func streamURL(rawURL, streamKey string, protocol livekit.StreamProtocol) string {
+	if streamKey == "" {
+		return rawURL
+	}
+	if protocol == livekit.StreamProtocol_SRT {
+		separator := "?"
+		if strings.Contains(rawURL, "?") {
+			separator = "&"
+		}
+		return rawURL + separator + "streamid=" + url.QueryEscape(streamKey)
+	}
+	return strings.TrimRight(rawURL, "/") + "/" + streamKey
+}
+
+// redactStreamURL strips the credentials from a destination before it is
+// stored: user info, the query, where SRT carries its streamid and passphrase,
+// and for RTMP without a separate key the path segment after the application
+// name. It returns the stored URL and the key to keep encrypted alongside it.
+func redactStreamURL(rawURL, streamKey string, protocol livekit.StreamProtocol) (string, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", streamKey
+	}
+
+	if protocol == livekit.StreamProtocol_SRT {
+		if streamKey == "" {
+			streamKey = u.Query().Get("streamid")
+		}
+	} else if segments := strings.Split(strings.Trim(u.Path, "/"), "/"); streamKey == "" && len(segments) > 1 {
+		streamKey = segments[len(segments)-1]
+		u.Path = "/" + strings.Join(segments[:len(segments)-1], "/")
+		u.RawPath = ""
+	}
+
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String(), streamKey
+}
+
+func liveStreamStatus(info *livekit.EgressInfo) (string, string) {
+	if len(info.StreamResults) > 0 {
+		result := info.StreamResults[0]
+		switch result.Status {
+		case livekit.StreamInfo_FAILED:
+			return "failed", result.Error
+		case livekit.StreamInfo_FINISHED:
+			return "stopped", ""
+		}
+	}
+
+	switch info.Status {
+	case livekit.EgressStatus_EGRESS_ACTIVE:
+		return "active", ""
+	case livekit.EgressStatus_EGRESS_FAILED, livekit.EgressStatus_EGRESS_ABORTED:
+		return "failed", info.Error
+	case livekit.EgressStatus_EGRESS_ENDING, livekit.EgressStatus_EGRESS_COMPLETE, livekit.EgressStatus_EGRESS_LIMIT_REACHED:
+		return "stopped", ""
+	default:
+		return "starting", ""
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// AudioChunk is a slice of one participant's audio, as 16-bit little-endian PCM
+type AudioChunk struct {
+	Identity   string
+	Samples    []byte
+	SampleRate int
+	Channels   int
+	StartedAt  time.Time
+}
+
+// Duration is the playback length of the chunk
+func (c AudioChunk) Duration() time.Duration {
+	if c.SampleRate <= 0 || c.Channels <= 0 {
+		return 0
+	}
+	frames := len(c.Samples) / 2 / c.Channels
+	return time.Duration(frames) * time.Second / time.Duration(c.SampleRate)
+}
+
+// Transcriber turns speech into text. An empty result means the chunk held no
+// speech. Real speech-to-text engines plug in by implementing it.
+type Transcriber interface {
+	Transcribe(ctx context.Context, chunk AudioChunk) (string, error)
+}
+
+// AudioSource captures the audio of everyone in a room and hands it to sink
+// chunk by chunk until stop is called
+type AudioSource interface {
+	Start(roomName string, sink func(AudioChunk)) (stop func(), err error)
+}
+
+// FakeTranscriber is a deterministic Transcriber for tests and local
+// development. Chunks below the silence threshold yield nothing; anything else
+// yields the next line of Script for that speaker, or a description of the
+// chunk when there is no script.
+type FakeTranscriber struct {
+	Script []string
+
+	mu    sync.Mutex
+	lines map[string]int
+}
+
+// fakeSilenceRMS is the loudness below which a chunk counts as silence
+const fakeSilenceRMS = 500
+
+func (t *FakeTranscriber) Transcribe(ctx context.Context, chunk AudioChunk) (string, error) {
+	if chunkRMS(chunk.Samples) < fakeSilenceRMS {
+		return "", nil
+	}
+
+	if len(t.Script) == 0 {
+		return fmt.Sprintf("%s spoke for %.1f seconds", chunk.Identity, chunk.Duration().Seconds()), nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.lines == nil {
+		t.lines = make(map[string]int)
+	}
+	line := t.Script[t.lines[chunk.Identity]%len(t.Script)]
+	t.lines[chunk.Identity]++
+	return line, nil
+}
+
+// chunkRMS is the root mean square amplitude of 16-bit PCM samples
+func chunkRMS(samples []byte) float64 {
+	n := len(samples) / 2
+	if n == 0 {
+		return 0
+	}
+
+	var sum float64
+	for i := 0; i < n; i++ {
+		v := float64(int16(binary.LittleEndian.Uint16(samples[i*2:])))
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(n))
+}
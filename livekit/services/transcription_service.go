@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"livekit/database"
+	"livekit/events"
+	"livekit/models"
+	"livekit/websocket"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrTranscriptionNotConfigured = errors.New("transcription is not configured on this server")
+	ErrTranscriptionActive        = errors.New("call is already being transcribed")
+	ErrNoActiveTranscription      = errors.New("call is not being transcribed")
+	ErrInvalidSearchQuery         = errors.New("search query must be at least 2 characters")
+)
+
+type TranscriptionStatus struct {
+	CallID string `json:"callId"`
+	Active bool   `json:"active"`
+}
+
+// TranscriptionService runs live transcription for calls whose host turned it
+// on. Audio comes from an AudioSource and is turned into text by a Transcriber;
+// either may be nil, in which case transcription is unavailable.
+type TranscriptionService struct {
+	db             *database.DB
+	transcriber    Transcriber
+	audioSource    AudioSource
+	transcriptRepo *database.TranscriptRepo
+	callRepo       *database.CallRepo
+	callService    *CallService
+	wsHub          *websocket.WebSocketHub
+
+	mu       sync.Mutex
+	sessions map[string]func() // callID -> stop capturing
+}
+
+func NewTranscriptionService(db *database.DB, transcriber Transcriber, audioSource AudioSource, callService *CallService, wsHub *websocket.WebSocketHub) *TranscriptionService {
+	s := &TranscriptionService{
+		db:             db,
+		transcriber:    transcriber,
+		audioSource:    audioSource,
+		transcriptRepo: database.NewTranscriptRepo(db),
+		callRepo:       database.NewCallRepo(db),
+		callService:    callService,
+		wsHub:          wsHub,
+		sessions:       make(map[string]func()),
+	}
+	// The session runs on the instance that started it, which need not be the
+	// one the call ends on
+	callService.OnCallEnded(func(call *models.ActiveCall) {
+		if wsHub == nil {
+			s.stopSession(call.CallID)
+			return
+		}
+		wsHub.AnnounceCallEnded(call.CallID)
+	})
+	if wsHub != nil {
+		wsHub.OnCallEnded(func(callID string) {
+			s.stopSession(callID)
+		})
+	}
+	return s
+}
+
+func (s *TranscriptionService) StartTranscription(callID string, userID int64) (*TranscriptionStatus, error) {
+	if s.transcriber == nil || s.audioSource == nil {
+		return nil, ErrTranscriptionNotConfigured
+	}
+
+	call, err := s.hostCall(callID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if call.Status != "active" {
+		return nil, ErrCallNotActive
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[callID]; ok {
+		return nil, ErrTranscriptionActive
+	}
+
+	stop, err := s.audioSource.Start(call.RoomName, func(chunk AudioChunk) {
+		s.transcribe(call, chunk)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start audio capture: %w", err)
+	}
+	s.sessions[callID] = stop
+
+	return &TranscriptionStatus{CallID: callID, Active: true}, nil
+}
+
+func (s *TranscriptionService) StopTranscription(callID string, userID int64) (*TranscriptionStatus, error) {
+	if _, err := s.hostCall(callID, userID); err != nil {
+		return nil, err
+	}
+	if !s.stopSession(callID) {
+		return nil, ErrNoActiveTranscription
+	}
+	return &TranscriptionStatus{CallID: callID, Active: false}, nil
+}
+
+// GetTranscript returns a page of a call's transcript for anyone who can view the call
+func (s *TranscriptionService) GetTranscript(callID string, userID int64, username string, limit, offset int) ([]*models.TranscriptSegment, error) {
+	allowed, err := s.callService.canViewCall(callID, userID, username)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrCallNotFound
+	}
+	return s.transcriptRepo.GetByCallID(callID, limit, offset)
+}
+
+// Search looks for text in the transcripts of the user's calls
+func (s *TranscriptionService) Search(userID int64, username, query, callID string, limit int) ([]*models.TranscriptSegment, error) {
+	query = strings.TrimSpace(query)
+	if len([]rune(query)) < 2 {
+		return nil, ErrInvalidSearchQuery
+	}
+
+	segments, err := s.transcriptRepo.Search(userID, username, query, callID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// The participants list also names invitees who never took part
+	allowed := make(map[string]bool)
+	visible := segments[:0]
+	for _, segment := range segments {
+		ok, seen := allowed[segment.CallID]
+		if !seen {
+			if ok, err = s.callService.canViewCall(segment.CallID, userID, username); err != nil {
+				return nil, err
+			}
+			allowed[segment.CallID] = ok
+		}
+		if ok {
+			visible = append(visible, segment)
+		}
+	}
+	return visible, nil
+}
+
+func (s *TranscriptionService) IsActive(callID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sessions[callID]
+	return ok
+}
+
+func (s *TranscriptionService) transcribe(call *models.ActiveCall, chunk AudioChunk) {
+	if !s.IsActive(call.CallID) {
+		return
+	}
+
+	text, err := s.transcriber.Transcribe(context.Background(), chunk)
+	if err != nil {
+		fmt.Printf("Failed to transcribe audio for call %s: %v\n", call.CallID, err)
+		return
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+
+	segment := &models.TranscriptSegment{
+		CallID:    call.CallID,
+		Identity:  chunk.Identity,
+		Text:      text,
+		StartedAt: chunk.StartedAt,
+		EndedAt:   chunk.StartedAt.Add(chunk.Duration()),
+	}
+	if err := s.transcriptRepo.Create(segment); err != nil {
+		fmt.Printf("Failed to store transcript segment for call %s: %v\n", call.CallID, err)
+		return
+	}
+
+	if s.wsHub != nil {
+		s.wsHub.Publish(context.Background(), s.callService.callMemberUsernames(call), events.TranscriptSegment{TranscriptSegment: segment})
+	}
+}
+
+func (s *TranscriptionService) stopSession(callID string) bool {
+	s.mu.Lock()
+	stop, ok := s.sessions[callID]
+	delete(s.sessions, callID)
+	s.mu.Unlock()
+
+	if ok {
+		stop()
+	}
+	return ok
+}
+
+func (s *TranscriptionService) hostCall(callID string, userID int64) (*models.ActiveCall, error) {
+	call, err := s.callRepo.GetByCallID(callID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call: %w", err)
+	}
+	if call == nil {
+		return nil, ErrCallNotFound
+	}
+	if call.CreatedBy != userID {
+		return nil, ErrNotCallHost
+	}
+	return call, nil
+}
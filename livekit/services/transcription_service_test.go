@@ -0,0 +1,234 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+	"livekit/database"
+	"livekit/models"
+	"livekit/websocket"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAudioSource lets a test play chunks into the room it captures
+type fakeAudioSource struct {
+	mu       sync.Mutex
+	roomName string
+	sink     func(AudioChunk)
+	stopped  bool
+}
+
+func (s *fakeAudioSource) Start(roomName string, sink func(AudioChunk)) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roomName, s.sink, s.stopped = roomName, sink, false
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.stopped = true
+	}, nil
+}
+
+// play hands a chunk to the sink even after stop, like audio still in flight
+func (s *fakeAudioSource) play(chunk AudioChunk) {
+	s.mu.Lock()
+	sink := s.sink
+	s.mu.Unlock()
+	sink(chunk)
+}
+
+func (s *fakeAudioSource) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+const testSampleRate = 16000
+
+// pcmChunk is mono 16-bit PCM of the given length at a constant amplitude;
+// zero amplitude is silence
+func pcmChunk(identity string, startedAt time.Time, length time.Duration, amplitude int16) AudioChunk {
+	samples := make([]byte, int(length.Seconds()*testSampleRate)*2)
+	for i := 0; i < len(samples); i += 2 {
+		binary.LittleEndian.PutUint16(samples[i:], uint16(amplitude))
+	}
+	return AudioChunk{
+		Identity:   identity,
+		Samples:    samples,
+		SampleRate: testSampleRate,
+		Channels:   1,
+		StartedAt:  startedAt,
+	}
+}
+
+func TestTranscriptionStoresSegments(t *testing.T) {
+	db := newTestDB(t)
+	callService := newTestCallService(t, db, newFakeRoomClient(), nil)
+	source := &fakeAudioSource{}
+	transcriber := &FakeTranscriber{Script: []string{"Hello everyone", "Let's get started"}}
+	s := NewTranscriptionService(db, transcriber, source, callService, nil)
+
+	host := createTestUser(t, db, "host")
+	callID := startTestCall(t, callService, host)
+
+	if _, err := s.StartTranscription(callID, host.ID); err != nil {
+		t.Fatalf("StartTranscription: %v", err)
+	}
+	call, _ := database.NewCallRepo(db).GetByCallID(callID)
+	if source.roomName != call.RoomName {
+		t.Errorf("captured room %s, want %s", source.roomName, call.RoomName)
+	}
+
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	source.play(pcmChunk("host", start, 2*time.Second, 3000))
+	source.play(pcmChunk("guest", start.Add(2*time.Second), time.Second, 0)) // Silence
+	source.play(pcmChunk("guest", start.Add(3*time.Second), 1500*time.Millisecond, 4000))
+	source.play(pcmChunk("host", start.Add(5*time.Second), time.Second, 3000))
+
+	if _, err := s.StopTranscription(callID, host.ID); err != nil {
+		t.Fatalf("StopTranscription: %v", err)
+	}
+	if !source.isStopped() {
+		t.Error("audio capture still running after stop")
+	}
+	source.play(pcmChunk("host", start.Add(6*time.Second), time.Second, 3000))
+
+	segments, err := s.GetTranscript(callID, host.ID, host.Username, 50, 0)
+	if err != nil {
+		t.Fatalf("GetTranscript: %v", err)
+	}
+
+	want := []struct {
+		identity string
+		text     string
+		start    time.Duration
+		length   time.Duration
+	}{
+		{"host", "Hello everyone", 0, 2 * time.Second},
+		{"guest", "Hello everyone", 3 * time.Second, 1500 * time.Millisecond},
+		{"host", "Let's get started", 5 * time.Second, time.Second},
+	}
+	if len(segments) != len(want) {
+		t.Fatalf("stored %d segments, want %d: %+v", len(segments), len(want), segments)
+	}
+	for i, w := range want {
+		got := segments[i]
+		if got.CallID != callID || got.Identity != w.identity || got.Text != w.text {
+			t.Errorf("segment %d = %s %q, want %s %q", i, got.Identity, got.Text, w.identity, w.text)
+		}
+		if !got.StartedAt.Equal(start.Add(w.start)) || !got.EndedAt.Equal(start.Add(w.start+w.length)) {
+			t.Errorf("segment %d spans %v to %v, want %v to %v", i, got.StartedAt, got.EndedAt,
+				start.Add(w.start), start.Add(w.start+w.length))
+		}
+	}
+}
+
+func TestTranscriptionStopsWhenCallEnds(t *testing.T) {
+	db := newTestDB(t)
+	callService := newTestCallService(t, db, newFakeRoomClient(), nil)
+	source := &fakeAudioSource{}
+	s := NewTranscriptionService(db, &FakeTranscriber{}, source, callService, nil)
+
+	host := createTestUser(t, db, "host")
+	callID := startTestCall(t, callService, host)
+	if _, err := s.StartTranscription(callID, host.ID); err != nil {
+		t.Fatalf("StartTranscription: %v", err)
+	}
+	if _, err := s.StartTranscription(callID, host.ID); !errors.Is(err, ErrTranscriptionActive) {
+		t.Errorf("second StartTranscription error = %v, want %v", err, ErrTranscriptionActive)
+	}
+
+	if err := callService.EndCall(callID, host.ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+	if !source.isStopped() || s.IsActive(callID) {
+		t.Error("transcription still running after the call ended")
+	}
+
+	source.play(pcmChunk("host", time.Now(), time.Second, 3000))
+	segments, err := s.GetTranscript(callID, host.ID, host.Username, 50, 0)
+	if err != nil {
+		t.Fatalf("GetTranscript: %v", err)
+	}
+	if len(segments) != 0 {
+		t.Errorf("stored %d segments of audio after the call ended", len(segments))
+	}
+}
+
+func TestTranscriptionStopsWhenCallEndsOnAnotherInstance(t *testing.T) {
+	db := newTestDB(t)
+	pubsub := websocket.NewMemoryPubSub()
+	newInstance := func() (*CallService, *TranscriptionService, *fakeAudioSource) {
+		hub := websocket.NewWebSocketHub()
+		if err := hub.SetPubSub(pubsub); err != nil {
+			t.Fatalf("SetPubSub: %v", err)
+		}
+		callService := newTestCallService(t, db, newFakeRoomClient(), hub)
+		source := &fakeAudioSource{}
+		return callService, NewTranscriptionService(db, &FakeTranscriber{}, source, callService, hub), source
+	}
+	callServiceA, transcriptionA, sourceA := newInstance()
+	callServiceB, _, _ := newInstance()
+
+	host := createTestUser(t, db, "host")
+	callID := startTestCall(t, callServiceA, host)
+	if _, err := transcriptionA.StartTranscription(callID, host.ID); err != nil {
+		t.Fatalf("StartTranscription: %v", err)
+	}
+
+	if err := callServiceB.EndCall(callID, host.ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+	if !sourceA.isStopped() || transcriptionA.IsActive(callID) {
+		t.Error("transcription on the other instance still running after the call ended")
+	}
+}
+
+func TestTranscriptSearchOnlyFindsOwnCalls(t *testing.T) {
+	db := newTestDB(t)
+	callService := newTestCallService(t, db, newFakeRoomClient(), nil)
+	s := NewTranscriptionService(db, &FakeTranscriber{}, &fakeAudioSource{}, callService, nil)
+
+	users := make(map[string]*models.User)
+	for _, username := range []string{"host", "alice", "bob", "%", "_lice"} {
+		users[username] = createTestUser(t, db, username)
+	}
+	callID := startTestCall(t, callService, users["host"], "alice", "bob")
+	invitations, err := database.NewInvitationRepo(db).GetCallParticipants(callID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, invitation := range invitations {
+		action := map[string]string{"alice": "accept", "bob": "reject"}[invitation.Invitee]
+		if _, err := callService.RespondToInvitation(invitation.ID, invitation.InviteeID, action); err != nil {
+			t.Fatalf("RespondToInvitation: %v", err)
+		}
+	}
+
+	now := time.Now()
+	segment := &models.TranscriptSegment{CallID: callID, Identity: "host", Text: "The secret plans", StartedAt: now, EndedAt: now}
+	if err := database.NewTranscriptRepo(db).Create(segment); err != nil {
+		t.Fatal(err)
+	}
+	if err := callService.EndCall(callID, users["host"].ID); err != nil {
+		t.Fatalf("EndCall: %v", err)
+	}
+
+	want := map[string]int{
+		"host":  1,
+		"alice": 1,
+		"bob":   0, // Declined the invitation
+		"%":     0, // Usernames are not LIKE patterns
+		"_lice": 0,
+	}
+	for username, wantFound := range want {
+		found, err := s.Search(users[username].ID, username, "secret", "", 50)
+		if err != nil {
+			t.Fatalf("Search as %s: %v", username, err)
+		}
+		if len(found) != wantFound {
+			t.Errorf("%s found %d segments, want %d", username, len(found), wantFound)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package websocket
+
+import (
+	"encoding/json"
+	"livekit/events"
+	"log"
+	"time"
+)
+
+// ackEvents must be acknowledged by clients that opted into acks when they
+// authenticated. Unacknowledged events are resent and finally reported as
+// undelivered.
+var ackEvents = map[string]bool{
+	"call_invitation": true,
+	"call_cancelled":  true,
+	"call_ended":      true,
+}
+
+const maxAckAttempts = 3
+
+// ackTimeout is how long to wait for an ack before resending; tests shorten it
+var ackTimeout = 10 * time.Second
+
+// UndeliveredHandler is told about an event a user never acknowledged, e.g. to
+// let the inviter know an invitation did not arrive
+type UndeliveredHandler func(username string, msgType string, data json.RawMessage)
+
+// pendingAck is keyed by username and envelope ID, since one event published
+// to several users shares its ID
+type pendingAck struct {
+	username string
+	env      events.Envelope
+	data     []byte
+	attempts int
+	timer    *time.Timer
+}
+
+// OnUndelivered registers a handler for events whose ack never arrived
+func (h *WebSocketHub) OnUndelivered(handler UndeliveredHandler) {
+	h.ackMu.Lock()
+	defer h.ackMu.Unlock()
+	h.onUndelivered = append(h.onUndelivered, handler)
+}
+
+func ackKey(username string, id string) string {
+	return username + "/" + id
+}
+
+func (h *WebSocketHub) expectAck(username string, env events.Envelope, data []byte) {
+	h.ackMu.Lock()
+	defer h.ackMu.Unlock()
+
+	key := ackKey(username, env.ID)
+	pending := &pendingAck{
+		username: username,
+		env:      env,
+		data:     data,
+		attempts: 1,
+	}
+	pending.timer = time.AfterFunc(ackTimeout, func() { h.retryAck(key) })
+	h.pendingAcks[key] = pending
+}
+
+// ack passes a client's ack on to every instance, as the event may be pending
+// on another one
+func (h *WebSocketHub) ack(username string, id string) {
+	data, _ := json.Marshal(ackFrame{Username: username, ID: id})
+
+	h.mu.RLock()
+	pubsub := h.pubsub
+	h.mu.RUnlock()
+
+	if err := pubsub.Publish(ackSubject, data); err != nil {
+		log.Printf("Failed to publish ack of %s: %v", username, err)
+		h.settleAck(username, id)
+	}
+}
+
+// settleAck clears a pending event of the acknowledging user
+func (h *WebSocketHub) settleAck(username string, id string) {
+	h.ackMu.Lock()
+	defer h.ackMu.Unlock()
+
+	key := ackKey(username, id)
+	pending, ok := h.pendingAcks[key]
+	if !ok {
+		return
+	}
+	pending.timer.Stop()
+	delete(h.pendingAcks, key)
+}
+
+func (h *WebSocketHub) retryAck(key string) {
+	h.ackMu.Lock()
+	pending, ok := h.pendingAcks[key]
+	if !ok {
+		h.ackMu.Unlock()
+		return
+	}
+
+	if pending.attempts >= maxAckAttempts {
+		delete(h.pendingAcks, key)
+		handlers := append([]UndeliveredHandler{}, h.onUndelivered...)
+		h.ackMu.Unlock()
+
+		log.Printf("%s %s was not acknowledged by %s", pending.env.Type, pending.env.ID, pending.username)
+		for _, handler := range handlers {
+			handler(pending.username, pending.env.Type, pending.env.Data)
+		}
+		return
+	}
+
+	pending.attempts++
+	pending.timer = time.AfterFunc(ackTimeout, func() { h.retryAck(key) })
+	h.ackMu.Unlock()
+
+	h.publish(pending.username, pending.data)
+}
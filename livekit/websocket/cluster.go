@@ -0,0 +1,268 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+const (
+	// ackSubject carries client acks to every instance, since the instance that
+	// published an event may not be the one holding the socket that acknowledges it
+	ackSubject = "calls.acks"
+	// sessionSubject carries revoked session IDs to every instance
+	sessionSubject = "calls.sessions.revoked"
+	// callEndedSubject carries the IDs of ended calls to every instance, since a
+	// call may end on another instance than the one running work for it
+	callEndedSubject = "calls.ended"
+)
+
+// ConnectionRegistry records the sockets every hub instance holds, so an
+// instance can tell whether a user is connected anywhere. Entries expire unless
+// their instance refreshes them, which clears out the sockets of an instance
+// that went away without unregistering them.
+type ConnectionRegistry interface {
+	Add(instanceID, username, connID string, acks bool, ttl time.Duration) error
+	Remove(instanceID, username, connID string) error
+	Refresh(instanceID string, ttl time.Duration) error
+	// CountConnections returns the user's unexpired sockets across all
+	// instances and how many of them acknowledge critical events
+	CountConnections(username string) (total int, acking int, err error)
+}
+
+type ackFrame struct {
+	Username string `json:"username"`
+	ID       string `json:"id"`
+}
+
+// SetPubSub replaces the in-memory PubSub, e.g. with NATS to run several API
+// instances. It must be called before any client connects.
+func (h *WebSocketHub) SetPubSub(pubsub PubSub) error {
+	controlSubs, err := h.subscribeControl(pubsub)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	previous, previousSubs := h.pubsub, h.controlSubs
+	h.pubsub, h.controlSubs = pubsub, controlSubs
+	h.mu.Unlock()
+
+	for _, sub := range previousSubs {
+		sub.Unsubscribe()
+	}
+	return previous.Close()
+}
+
+// subscribeControl subscribes to the subjects every instance listens on
+// regardless of which users it holds sockets for
+func (h *WebSocketHub) subscribeControl(pubsub PubSub) ([]Subscription, error) {
+	ackSub, err := pubsub.Subscribe(ackSubject, h.receiveAck)
+	if err != nil {
+		return nil, err
+	}
+	sessionSub, err := pubsub.Subscribe(sessionSubject, func(data []byte) {
+		h.closeSession(string(data))
+	})
+	if err != nil {
+		ackSub.Unsubscribe()
+		return nil, err
+	}
+	callEndedSub, err := pubsub.Subscribe(callEndedSubject, func(data []byte) {
+		h.runHooks(&h.onCallEnded, string(data))
+	})
+	if err != nil {
+		ackSub.Unsubscribe()
+		sessionSub.Unsubscribe()
+		return nil, err
+	}
+	return []Subscription{ackSub, sessionSub, callEndedSub}, nil
+}
+
+// CloseSession closes the sockets opened with a revoked session on every instance
+func (h *WebSocketHub) CloseSession(sessionID string) {
+	h.mu.RLock()
+	pubsub := h.pubsub
+	h.mu.RUnlock()
+
+	if err := pubsub.Publish(sessionSubject, []byte(sessionID)); err != nil {
+		log.Printf("Failed to publish revoked session: %v", err)
+		h.closeSession(sessionID)
+	}
+}
+
+// OnCallEnded registers a hook that runs on every instance when any of them
+// announces the end of a call, e.g. to stop work kept in this instance's memory
+func (h *WebSocketHub) OnCallEnded(hook func(callID string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onCallEnded = append(h.onCallEnded, hook)
+}
+
+// AnnounceCallEnded runs the OnCallEnded hooks of every instance, this one included
+func (h *WebSocketHub) AnnounceCallEnded(callID string) {
+	h.mu.RLock()
+	pubsub := h.pubsub
+	h.mu.RUnlock()
+
+	if err := pubsub.Publish(callEndedSubject, []byte(callID)); err != nil {
+		log.Printf("Failed to publish ended call: %v", err)
+		h.runHooks(&h.onCallEnded, callID)
+	}
+}
+
+func (h *WebSocketHub) closeSession(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+
+	h.mu.RLock()
+	var revoked []*Connection
+	for _, conns := range h.connections {
+		for _, conn := range conns {
+			if conn.SessionID == sessionID && conn.close != nil {
+				revoked = append(revoked, conn)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, conn := range revoked {
+		conn.close("session revoked")
+	}
+}
+
+// updateSession moves a socket to the session of a refreshed token
+func (h *WebSocketHub) updateSession(conn *Connection, sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conn.SessionID = sessionID
+}
+
+// SetConnectionRegistry shares this instance's sockets with the other
+// instances; ttl is how long an entry lives without RefreshConnections
+func (h *WebSocketHub) SetConnectionRegistry(registry ConnectionRegistry, ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.registry = registry
+	h.connectionTTL = ttl
+}
+
+// RefreshConnections keeps this instance's registry entries from expiring
+func (h *WebSocketHub) RefreshConnections() error {
+	h.mu.RLock()
+	registry, ttl := h.registry, h.connectionTTL
+	h.mu.RUnlock()
+
+	if registry == nil {
+		return nil
+	}
+	return registry.Refresh(h.instanceID, ttl)
+}
+
+// subscribe starts receiving the user's events from other instances; the
+// caller holds h.mu
+func (h *WebSocketHub) subscribe(username string) {
+	sub, err := h.pubsub.Subscribe(inboxSubject(username), func(data []byte) {
+		h.receive(username, data)
+	})
+	if err != nil {
+		log.Printf("Failed to subscribe to events of %s: %v", username, err)
+		return
+	}
+	h.subscriptions[username] = sub
+}
+
+// unsubscribe stops receiving the user's events once their last local socket
+// is gone; the caller holds h.mu
+func (h *WebSocketHub) unsubscribe(username string) {
+	sub, ok := h.subscriptions[username]
+	if !ok {
+		return
+	}
+	delete(h.subscriptions, username)
+	if err := sub.Unsubscribe(); err != nil {
+		log.Printf("Failed to unsubscribe from events of %s: %v", username, err)
+	}
+}
+
+func (h *WebSocketHub) publish(username string, data []byte) {
+	h.mu.RLock()
+	pubsub := h.pubsub
+	h.mu.RUnlock()
+
+	if err := pubsub.Publish(inboxSubject(username), data); err != nil {
+		log.Printf("Failed to publish event for %s: %v", username, err)
+	}
+}
+
+// receive hands an event published by any instance to the local sockets
+func (h *WebSocketHub) receive(username string, data []byte) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		log.Printf("Failed to decode event for %s: %v", username, err)
+		return
+	}
+	h.deliver(username, header.Type, data)
+}
+
+func (h *WebSocketHub) receiveAck(data []byte) {
+	var frame ackFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		log.Printf("Failed to decode ack: %v", err)
+		return
+	}
+	h.settleAck(frame.Username, frame.ID)
+}
+
+func (h *WebSocketHub) registerConnection(username string, conn *Connection) {
+	h.mu.RLock()
+	registry, ttl := h.registry, h.connectionTTL
+	h.mu.RUnlock()
+
+	if registry == nil {
+		return
+	}
+	if err := registry.Add(h.instanceID, username, conn.ID, conn.Acks, ttl); err != nil {
+		log.Printf("Failed to register connection of %s: %v", username, err)
+	}
+}
+
+func (h *WebSocketHub) unregisterConnection(username string, connID string) {
+	h.mu.RLock()
+	registry := h.registry
+	h.mu.RUnlock()
+
+	if registry == nil {
+		return
+	}
+	if err := registry.Remove(h.instanceID, username, connID); err != nil {
+		log.Printf("Failed to unregister connection of %s: %v", username, err)
+	}
+}
+
+// countConnections counts the user's sockets on every instance, falling back
+// to this instance's own when there is no registry
+func (h *WebSocketHub) countConnections(username string) (total int, acking int) {
+	h.mu.RLock()
+	registry := h.registry
+	for _, conn := range h.connections[username] {
+		total++
+		if conn.Acks {
+			acking++
+		}
+	}
+	h.mu.RUnlock()
+
+	if registry == nil {
+		return total, acking
+	}
+	remoteTotal, remoteAcking, err := registry.CountConnections(username)
+	if err != nil {
+		log.Printf("Failed to count connections of %s: %v", username, err)
+		return total, acking
+	}
+	return remoteTotal, remoteAcking
+}
@@ -2,69 +2,210 @@ package websocket
 
 import (
 	"encoding/json"
+	"fmt"
 	"livekit/auth"
 	"log"
 	"net/http"
+	"slices"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-}
-
 const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
+	maxMessageSize = 64 * 1024 // Leaves room for chat messages and request payloads
+	// tokenProtocol is the subprotocol browsers use to pass the token in the
+	// handshake, as Sec-WebSocket-Protocol: access_token, <token>
+	tokenProtocol = "access_token"
+	// closeSessionEnded is sent when the socket's token expires or its
+	// session is revoked, telling the client to sign in again
+	closeSessionEnded = 4001
 )
 
+// authWait is how long a socket may stay open without authenticating; tests
+// shorten it before creating the handler
+var authWait = 10 * time.Second
+
 type Client struct {
-	hub      *WebSocketHub
-	conn     *websocket.Conn
-	send     chan []byte
-	username string
+	hub        *WebSocketHub
+	conn       *websocket.Conn
+	send       chan []byte
+	id         string
+	username   string
+	connection *Connection
+	expiry     *time.Timer
+	authWait   time.Duration
 }
 
 type ClientMessage struct {
-	Type  string `json:"type"`
-	Token string `json:"token,omitempty"`
+	Type      string          `json:"type"`
+	RequestID string          `json:"requestId,omitempty"` // Correlates the response with the request
+	Token     string          `json:"token,omitempty"`
+	LastSeq   *int64          `json:"lastSeq,omitempty"` // On authenticate: replay events after this seq
+	Acks      bool            `json:"acks,omitempty"`    // On authenticate: the client acknowledges critical events
+	Data      json.RawMessage `json:"data,omitempty"`
 }
 
-func HandleWebSocket(hub *WebSocketHub) http.HandlerFunc {
+// HandleWebSocket upgrades a connection from one of allowedOrigins; an empty
+// list allows every origin. Requests without an Origin header come from native
+// clients and are always accepted. The token may come with the handshake, as
+// the token query parameter or the access_token subprotocol, or in an
+// authenticate message within authWait of connecting.
+func HandleWebSocket(hub *WebSocketHub, allowedOrigins []string) http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || len(allowedOrigins) == 0 || slices.Contains(allowedOrigins, origin)
+		},
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+	authTimeout := authWait
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		conn, err := upgrader.Upgrade(w, r, nil)
+		var claims *auth.Claims
+		var responseHeader http.Header
+		token := r.URL.Query().Get("token")
+		if protocols := websocket.Subprotocols(r); len(protocols) == 2 && protocols[0] == tokenProtocol {
+			token = protocols[1]
+			responseHeader = http.Header{"Sec-WebSocket-Protocol": {tokenProtocol}}
+		}
+		if token != "" {
+			var err error
+			claims, err = auth.ValidateToken(token)
+			if err != nil {
+				http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		conn, err := upgrader.Upgrade(w, r, responseHeader)
 		if err != nil {
 			log.Printf("WebSocket upgrade error: %v", err)
 			return
 		}
 
 		client := &Client{
-			hub:  hub,
-			conn: conn,
-			send: make(chan []byte, 256),
+			hub:      hub,
+			conn:     conn,
+			send:     make(chan []byte, 256),
+			id:       uuid.New().String(),
+			authWait: authTimeout,
 		}
 
 		go client.writePump()
-		go client.readPump()
+
+		if claims == nil {
+			go client.readPump()
+			return
+		}
+
+		var lastSeq *int64
+		if seq, err := strconv.ParseInt(r.URL.Query().Get("lastSeq"), 10, 64); err == nil {
+			lastSeq = &seq
+		}
+		acks := r.URL.Query().Get("acks") == "true"
+		go func() {
+			if client.authenticate(claims, lastSeq, acks) {
+				client.readPump()
+			} else {
+				client.conn.Close()
+			}
+		}()
+	}
+}
+
+// authenticate registers the socket for the token's user. A socket that is
+// already authenticated may present a refreshed token of the same user, which
+// moves it to that token's session and expiry; switching users is refused.
+func (c *Client) authenticate(claims *auth.Claims, lastSeq *int64, acks bool) bool {
+	if c.connection != nil {
+		if claims.Username != c.username {
+			log.Printf("Socket of %s tried to authenticate as %s", c.username, claims.Username)
+			return false
+		}
+		c.hub.updateSession(c.connection, claims.SessionID)
+		c.watchExpiry(claims)
+		c.sendMessage("authenticated")
+		return true
+	}
+
+	c.username = claims.Username
+	c.connection = &Connection{
+		ID:        c.id,
+		Username:  claims.Username,
+		SessionID: claims.SessionID,
+		Send:      c.send,
+		Acks:      acks,
+		close:     c.kick,
+	}
+	c.hub.Register(claims.Username, c.connection)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.watchExpiry(claims)
+
+	c.sendMessage("authenticated")
+
+	if lastSeq != nil {
+		c.hub.replay(claims.Username, *lastSeq, c.queue)
+	}
+
+	c.hub.runConnectHooks(claims.Username)
+	return true
+}
+
+// watchExpiry disconnects the client when its token expires unless it
+// re-authenticates with a refreshed one first
+func (c *Client) watchExpiry(claims *auth.Claims) {
+	if c.expiry != nil {
+		c.expiry.Stop()
+	}
+	if claims.ExpiresAt == nil {
+		return
+	}
+	c.expiry = time.AfterFunc(time.Until(claims.ExpiresAt.Time), func() {
+		c.kick("token expired")
+	})
+}
+
+// kick closes the socket with a reason the client can act on; it is safe to
+// call from any goroutine
+func (c *Client) kick(reason string) {
+	message := websocket.FormatCloseMessage(closeSessionEnded, reason)
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+	c.conn.Close()
+}
+
+func (c *Client) sendMessage(msgType string) {
+	data, _ := json.Marshal(map[string]string{"type": msgType})
+	if !c.queue(data) {
+		log.Printf("Failed to send %s to %s: client too slow", msgType, c.username)
 	}
 }
 
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.Unregister(c.username)
+		if c.expiry != nil {
+			c.expiry.Stop()
+		}
+		c.hub.Unregister(c.username, c.id)
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetReadLimit(maxMessageSize)
+	if c.connection == nil {
+		c.conn.SetReadDeadline(time.Now().Add(c.authWait))
+	}
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		// Pongs keep an authenticated socket alive, not one that never signed in
+		if c.connection != nil {
+			c.conn.SetReadDeadline(time.Now().Add(pongWait))
+			c.hub.runHeartbeatHooks(c.username)
+		}
 		return nil
 	})
 
@@ -86,32 +227,85 @@ func (c *Client) readPump() {
 		switch msg.Type {
 		case "authenticate":
 			if msg.Token == "" {
-				c.conn.Close()
 				return
 			}
 
 			claims, err := auth.ValidateToken(msg.Token)
 			if err != nil {
 				log.Printf("Invalid token: %v", err)
-				c.conn.Close()
 				return
 			}
 
-			c.username = claims.Username
-			c.hub.Register(claims.Username, &Connection{
-				Username: claims.Username,
-				Send:     c.send,
-			})
+			if !c.authenticate(claims, msg.LastSeq, msg.Acks) {
+				return
+			}
 
-			response := map[string]string{"type": "authenticated"}
-			data, _ := json.Marshal(response)
-			c.send <- data
+		case "ack":
+			var ack struct {
+				ID string `json:"id"`
+			}
+			if c.username == "" || json.Unmarshal(msg.Data, &ack) != nil {
+				continue
+			}
+			c.hub.ack(c.username, ack.ID)
 
 		case "ping":
-			response := map[string]string{"type": "pong"}
-			data, _ := json.Marshal(response)
-			c.send <- data
+			c.sendMessage("pong")
+			if c.username != "" {
+				c.hub.runHeartbeatHooks(c.username)
+			}
+
+		default:
+			if c.username == "" {
+				continue
+			}
+			c.handleRequest(msg)
+		}
+	}
+}
+
+// handleRequest runs the handler registered for a message type. Requests with
+// an ID always get an answer, a response with the handler's result or an
+// error; without an ID only errors are reported.
+func (c *Client) handleRequest(msg ClientMessage) {
+	handler := c.hub.requestHandler(msg.Type)
+	if handler == nil && msg.RequestID == "" {
+		return
+	}
+
+	var result interface{}
+	var err error
+	if handler == nil {
+		err = fmt.Errorf("unknown request type %s", msg.Type)
+	} else {
+		result, err = handler(c.username, msg.Data)
+	}
+
+	response := Message{Type: "response", RequestID: msg.RequestID, Data: result}
+	if err != nil {
+		response = Message{
+			Type:      "error",
+			RequestID: msg.RequestID,
+			Data:      map[string]string{"requestType": msg.Type, "error": err.Error()},
 		}
+	} else if msg.RequestID == "" {
+		return
+	}
+
+	data, _ := json.Marshal(response)
+	if !c.queue(data) {
+		log.Printf("Failed to answer %s request of %s: client too slow", msg.Type, c.username)
+	}
+}
+
+// queue hands a message to the write pump, giving up when the client falls
+// too far behind
+func (c *Client) queue(data []byte) bool {
+	select {
+	case c.send <- data:
+		return true
+	case <-time.After(writeWait):
+		return false
 	}
 }
 
@@ -0,0 +1,233 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"livekit/auth"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+// dialHub opens a socket to HandleWebSocket; the response is returned even
+// when the handshake fails
+func dialHub(t *testing.T, handler http.Handler, query string, header http.Header, subprotocols ...string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{Subprotocols: subprotocols, HandshakeTimeout: time.Second}
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?" + query
+	conn, resp, err := dialer.Dial(url, header)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+func testToken(t *testing.T, username string) string {
+	t.Helper()
+
+	token, err := auth.GenerateToken(1, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// readType returns the type of the next message; messages the write pump
+// batched into one frame are split on newlines
+func readType(t *testing.T, conn *websocket.Conn, pending *[]string) string {
+	t.Helper()
+
+	if len(*pending) == 0 {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			var msg struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal(line, &msg)
+			*pending = append(*pending, msg.Type)
+		}
+	}
+	msgType := (*pending)[0]
+	*pending = (*pending)[1:]
+	return msgType
+}
+
+// readClose waits for the server to end the socket and returns the close error
+func readClose(t *testing.T, conn *websocket.Conn, wait time.Duration) error {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(wait))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var netErr interface{ Timeout() bool }
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				t.Fatal("socket still open")
+			}
+			return err
+		}
+	}
+}
+
+func waitDisconnected(t *testing.T, hub *WebSocketHub, username string) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for hub.IsConnected(username) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s is still connected", username)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandleWebSocketChecksOrigin(t *testing.T) {
+	tests := []struct {
+		name       string
+		origin     string
+		wantStatus int
+	}{
+		{name: "allowed origin", origin: "https://app.example.com", wantStatus: http.StatusSwitchingProtocols},
+		{name: "native client without origin", wantStatus: http.StatusSwitchingProtocols},
+		{name: "other origin", origin: "https://evil.example.com", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewWebSocketHub()
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+
+			_, resp, _ := dialHub(t, HandleWebSocket(hub, []string{"https://app.example.com"}), "", header)
+			if resp == nil {
+				t.Fatal("no handshake response")
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("handshake status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandleWebSocketAcceptsTokenSubprotocol(t *testing.T) {
+	hub := NewWebSocketHub()
+	conn, resp, err := dialHub(t, HandleWebSocket(hub, nil), "", nil, tokenProtocol, testToken(t, "alice"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != tokenProtocol {
+		t.Errorf("server selected subprotocol %q, want %s", got, tokenProtocol)
+	}
+
+	var pending []string
+	if got := readType(t, conn, &pending); got != "authenticated" {
+		t.Errorf("got %s, want authenticated", got)
+	}
+	if !hub.IsConnected("alice") {
+		t.Error("alice is not connected")
+	}
+
+	_, resp, err = dialHub(t, HandleWebSocket(hub, nil), "", nil, tokenProtocol, "not-a-token")
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("invalid subprotocol token was not refused: %v", err)
+	}
+}
+
+func TestHandleWebSocketClosesUnauthenticatedSocket(t *testing.T) {
+	previous := authWait
+	authWait = 50 * time.Millisecond
+	t.Cleanup(func() { authWait = previous })
+
+	hub := NewWebSocketHub()
+	conn, _, err := dialHub(t, HandleWebSocket(hub, nil), "", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	// Pings do not keep a socket alive that never signed in
+	conn.WriteJSON(ClientMessage{Type: "ping"})
+	readClose(t, conn, time.Second)
+}
+
+func TestHandleWebSocketAuthenticateMessage(t *testing.T) {
+	hub := NewWebSocketHub()
+	conn, _, err := dialHub(t, HandleWebSocket(hub, nil), "", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	conn.WriteJSON(ClientMessage{Type: "authenticate", Token: testToken(t, "alice")})
+	var pending []string
+	if got := readType(t, conn, &pending); got != "authenticated" {
+		t.Fatalf("got %s, want authenticated", got)
+	}
+
+	// A refreshed token of the same user is taken
+	conn.WriteJSON(ClientMessage{Type: "authenticate", Token: testToken(t, "alice")})
+	if got := readType(t, conn, &pending); got != "authenticated" {
+		t.Errorf("got %s on re-authenticate, want authenticated", got)
+	}
+
+	// Another user's token is not
+	conn.WriteJSON(ClientMessage{Type: "authenticate", Token: testToken(t, "bob")})
+	readClose(t, conn, time.Second)
+	waitDisconnected(t, hub, "alice")
+	if hub.IsConnected("bob") {
+		t.Error("socket of alice was registered for bob")
+	}
+}
+
+func TestClientClosesSocketWhenTokenExpires(t *testing.T) {
+	hub := NewWebSocketHub()
+	claims := &auth.Claims{
+		UserID:   1,
+		Username: "alice",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: &jwt.NumericDate{Time: time.Now().Add(100 * time.Millisecond)},
+		},
+	}
+
+	// HandleWebSocket only takes signed tokens, whose expiry has whole seconds;
+	// the client is authenticated with the claims directly instead
+	upgrader := websocket.Upgrader{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256), id: "1"}
+		go client.writePump()
+		go func() {
+			if client.authenticate(claims, nil, false) {
+				client.readPump()
+			}
+		}()
+	})
+
+	conn, _, err := dialHub(t, handler, "", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	err = readClose(t, conn, 2*time.Second)
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != closeSessionEnded || closeErr.Text != "token expired" {
+		t.Fatalf("socket ended with %v, want close %d token expired", err, closeSessionEnded)
+	}
+	waitDisconnected(t, hub, "alice")
+}
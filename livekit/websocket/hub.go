@@ -1,351 +1,388 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"livekit/events"
 	"livekit/models"
 	"log"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type WebSocketHub struct {
-	connections map[string]*Connection
-	mu          sync.RWMutex
+	connections  map[string]map[string]*Connection // username -> connection ID -> connection
+	handlers     map[string]RequestHandler
+	onConnect    []func(username string)
+	onDisconnect []func(username string)
+	onHeartbeat  []func(username string)
+	onCallEnded  []func(callID string)
+	store        EventStore
+	filter       EventFilter
+	bus          *events.Bus
+	mu           sync.RWMutex
+
+	instanceID    string
+	pubsub        PubSub
+	controlSubs   []Subscription
+	subscriptions map[string]Subscription // username -> inbox subscription
+	registry      ConnectionRegistry
+	connectionTTL time.Duration
+
+	pendingAcks   map[string]*pendingAck
+	onUndelivered []UndeliveredHandler
+	ackMu         sync.Mutex
 }
 
-type Connection struct {
-	Username string
-	Send     chan []byte
+// EventStore keeps the events sent to each user so a client that reconnects
+// can replay what it missed. With several instances it must be shared by all
+// of them, since a client may reconnect to another instance than it left.
+type EventStore interface {
+	Append(username, eventType string, data []byte) (*models.UserEvent, error)
+	GetSince(username string, afterSeq int64, limit int) ([]*models.UserEvent, error)
 }
 
-type Message struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+// EventFilter decides whether a user wants an event type on their sockets,
+// e.g. to hold back ringing while they do not want to be disturbed
+type EventFilter interface {
+	Accepts(username, eventType string) bool
 }
 
-type InvitationMessage struct {
-	InvitationID int64  `json:"invitationId"`
-	CallID        string `json:"callId"`
-	Inviter       string `json:"inviter"`
-	CallType      string `json:"callType"`
-	RoomName      string `json:"roomName"`
-	Timestamp     string `json:"timestamp"`
+// maxReplayEvents caps how many missed events are replayed on one reconnect
+const maxReplayEvents = 500
+
+// volatileEvents are not kept for replay: reactions only matter in the moment,
+// direct messages have their own delivery tracking and presence is reloaded
+// with the contacts. Lobby admissions and breakout moves carry a LiveKit join
+// token, which must not sit in the store in plaintext; a client that missed
+// one asks the lobby status endpoint or rejoins the call for a fresh token.
+var volatileEvents = map[string]bool{
+	"reaction":          true,
+	"direct_message":    true,
+	"presence_changed":  true,
+	"lobby_admitted":    true,
+	"breakout_assigned": true,
+	"breakout_return":   true,
 }
 
-type InvitationResponseMessage struct {
-	InvitationID int64  `json:"invitationId"`
-	Invitee       string `json:"invitee"`
-	Status        string `json:"status"`
-	Timestamp     string `json:"timestamp"`
+// ClientMessageHandler processes a message type sent by an authenticated client.
+// The returned error is reported back to that client.
+type ClientMessageHandler func(username string, data json.RawMessage) error
+
+// RequestHandler answers a client request; the result is sent back as the
+// data of a response carrying the request's ID
+type RequestHandler func(username string, data json.RawMessage) (interface{}, error)
+
+// Connection is one socket of a user; a user signed in on several devices has
+// one per device, told apart by ID
+type Connection struct {
+	ID        string
+	Username  string
+	SessionID string // Session of the token the socket authenticated with
+	Send      chan []byte
+	Acks      bool // The client acknowledges critical events
+
+	close func(reason string) // Disconnects the client, e.g. when its session is revoked
 }
 
-func NewWebSocketHub() *WebSocketHub {
-	return &WebSocketHub{
-		connections: make(map[string]*Connection),
-	}
+// Message is a control message of the socket itself, e.g. an error or the end
+// of a replay; events published on the bus are sent as envelopes instead
+type Message struct {
+	Type      string      `json:"type"`
+	RequestID string      `json:"requestId,omitempty"` // Set on the answer to a client request
+	Data      interface{} `json:"data"`
 }
 
-func (h *WebSocketHub) Register(username string, conn *Connection) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.connections[username] = conn
-	log.Printf("User %s connected to WebSocket", username)
+func NewWebSocketHub() *WebSocketHub {
+	h := &WebSocketHub{
+		connections:   make(map[string]map[string]*Connection),
+		handlers:      make(map[string]RequestHandler),
+		bus:           events.NewBus(),
+		instanceID:    uuid.New().String(),
+		pubsub:        NewMemoryPubSub(),
+		subscriptions: make(map[string]Subscription),
+		pendingAcks:   make(map[string]*pendingAck),
+	}
+	h.bus.Subscribe(h)
+	h.controlSubs, _ = h.subscribeControl(h.pubsub)
+	return h
 }
 
-func (h *WebSocketHub) Unregister(username string) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	if conn, ok := h.connections[username]; ok {
-		close(conn.Send)
-		delete(h.connections, username)
-		log.Printf("User %s disconnected from WebSocket", username)
+// Publish sends an event to the given users through the event bus, reaching
+// their sockets and every other subscriber
+func (h *WebSocketHub) Publish(ctx context.Context, recipients []string, event events.Event) error {
+	if err := h.bus.Publish(ctx, recipients, event); err != nil {
+		log.Printf("Failed to publish %s: %v", event.EventType(), err)
+		return err
 	}
+	return nil
 }
 
-func (h *WebSocketHub) BroadcastInvitation(username string, invitation *models.Invitation) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// Subscribe adds another sink, e.g. webhooks or an audit log, to the events
+// published through the hub
+func (h *WebSocketHub) Subscribe(subscriber events.Subscriber) {
+	h.bus.Subscribe(subscriber)
+}
 
-	log.Printf("Broadcasting invitation to user: %s, invitation ID: %d, inviter: %s", username, invitation.ID, invitation.Inviter)
-	log.Printf("Connected users: %v", h.getConnectedUsernames())
+// Deliver implements events.Subscriber by sending the envelope to each
+// recipient's sockets
+func (h *WebSocketHub) Deliver(ctx context.Context, recipients []string, env *events.Envelope) {
+	h.mu.RLock()
+	filter := h.filter
+	h.mu.RUnlock()
 
-	conn, ok := h.connections[username]
-	if !ok {
-		log.Printf("User %s not connected, cannot send invitation. Available connections: %v", username, h.getConnectedUsernames())
-		return
+	for _, username := range recipients {
+		if filter != nil && !filter.Accepts(username, env.Type) {
+			continue
+		}
+		h.send(username, *env)
 	}
+}
 
-	msg := Message{
-		Type: "call_invitation",
-		Data: InvitationMessage{
-			InvitationID: invitation.ID,
-			CallID:        invitation.CallID,
-			Inviter:       invitation.Inviter,
-			CallType:      invitation.CallType,
-			RoomName:      invitation.RoomName,
-			Timestamp:     invitation.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		},
-	}
+// IsConnected reports whether the user has at least one socket open on any instance
+func (h *WebSocketHub) IsConnected(username string) bool {
+	total, _ := h.countConnections(username)
+	return total > 0
+}
 
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Failed to marshal invitation message: %v", err)
-		return
-	}
+// SetEventStore enables persisting events for replay on reconnect
+func (h *WebSocketHub) SetEventStore(store EventStore) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.store = store
+}
 
-	select {
-	case conn.Send <- data:
-		log.Printf("Successfully sent invitation to %s", username)
-	default:
-		log.Printf("Failed to send invitation to %s: channel full", username)
-	}
+// SetEventFilter lets users' preferences decide which events reach their
+// sockets; filtered events are neither sent nor kept for replay
+func (h *WebSocketHub) SetEventFilter(filter EventFilter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.filter = filter
 }
 
-func (h *WebSocketHub) getConnectedUsernames() []string {
-	usernames := make([]string, 0, len(h.connections))
-	for username := range h.connections {
-		usernames = append(usernames, username)
-	}
-	return usernames
+// OnClientMessage registers the handler for a client message type, letting
+// services accept requests over the socket without the hub knowing about them
+func (h *WebSocketHub) OnClientMessage(msgType string, handler ClientMessageHandler) {
+	h.OnRequest(msgType, func(username string, data json.RawMessage) (interface{}, error) {
+		return nil, handler(username, data)
+	})
 }
 
-func (h *WebSocketHub) BroadcastInvitationResponse(username string, invitationID int64, invitee string, status string) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// OnRequest registers the handler for a client request type whose result the
+// client waits for, e.g. answering an invitation
+func (h *WebSocketHub) OnRequest(msgType string, handler RequestHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[msgType] = handler
+}
 
-	conn, ok := h.connections[username]
-	if !ok {
-		return
-	}
+// OnConnect registers a hook that runs after a user authenticates on the socket,
+// e.g. to deliver what they missed while offline
+func (h *WebSocketHub) OnConnect(hook func(username string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onConnect = append(h.onConnect, hook)
+}
 
-	msgType := "invitation_rejected"
-	if status == "accepted" {
-		msgType = "invitation_accepted"
-	}
+// OnDisconnect registers a hook that runs after one of a user's sockets on this
+// instance closed; IsConnected tells whether they are still around elsewhere
+func (h *WebSocketHub) OnDisconnect(hook func(username string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onDisconnect = append(h.onDisconnect, hook)
+}
 
-	msg := Message{
-		Type: msgType,
-		Data: InvitationResponseMessage{
-			InvitationID: invitationID,
-			Invitee:       invitee,
-			Status:        status,
-			Timestamp:     "",
-		},
-	}
+// OnHeartbeat registers a hook that runs whenever an authenticated socket shows
+// it is alive, by a ping message or by answering the server's pings
+func (h *WebSocketHub) OnHeartbeat(hook func(username string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onHeartbeat = append(h.onHeartbeat, hook)
+}
 
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Failed to marshal invitation response message: %v", err)
-		return
-	}
+func (h *WebSocketHub) runConnectHooks(username string) {
+	h.runHooks(&h.onConnect, username)
+}
 
-	select {
-	case conn.Send <- data:
-	default:
-		log.Printf("Failed to send invitation response to %s: channel full", username)
-	}
+func (h *WebSocketHub) runHeartbeatHooks(username string) {
+	h.runHooks(&h.onHeartbeat, username)
 }
 
-func (h *WebSocketHub) BroadcastCallEnded(username string, callID string) {
+func (h *WebSocketHub) runHooks(list *[]func(string), username string) {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	conn, ok := h.connections[username]
-	if !ok {
-		return
-	}
+	hooks := append([]func(string){}, (*list)...)
+	h.mu.RUnlock()
 
-	msg := Message{
-		Type: "call_ended",
-		Data: map[string]interface{}{
-			"callId":    callID,
-			"timestamp": "",
-		},
-	}
-
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Failed to marshal call ended message: %v", err)
-		return
-	}
-
-	select {
-	case conn.Send <- data:
-	default:
-		log.Printf("Failed to send call ended to %s: channel full", username)
+	for _, hook := range hooks {
+		hook(username)
 	}
 }
 
-func (h *WebSocketHub) BroadcastCallCancelled(username string, callID string) {
+func (h *WebSocketHub) requestHandler(msgType string) RequestHandler {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
+	return h.handlers[msgType]
+}
 
-	conn, ok := h.connections[username]
-	if !ok {
-		return
-	}
-
-	msg := Message{
-		Type: "call_cancelled",
-		Data: map[string]interface{}{
-			"callId":    callID,
-			"timestamp": "",
-		},
-	}
-
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Failed to marshal call cancelled message: %v", err)
-		return
+func (h *WebSocketHub) Register(username string, conn *Connection) {
+	h.mu.Lock()
+	if h.connections[username] == nil {
+		h.connections[username] = make(map[string]*Connection)
+		h.subscribe(username)
 	}
+	h.connections[username][conn.ID] = conn
+	log.Printf("User %s connected to WebSocket (%d connections)", username, len(h.connections[username]))
+	h.mu.Unlock()
 
-	select {
-	case conn.Send <- data:
-	default:
-		log.Printf("Failed to send call cancelled to %s: channel full", username)
-	}
+	h.registerConnection(username, conn)
 }
 
-func (h *WebSocketHub) BroadcastScheduledCallCreated(username string, call *models.ScheduledCall) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	conn, ok := h.connections[username]
+// Unregister removes a single socket of a user, leaving their other devices connected
+func (h *WebSocketHub) Unregister(username string, connID string) {
+	h.mu.Lock()
+	conns, ok := h.connections[username]
 	if !ok {
+		h.mu.Unlock()
 		return
 	}
-
-	msg := Message{
-		Type: "scheduled_call_created",
-		Data: call,
+	conn, found := conns[connID]
+	if found {
+		close(conn.Send)
+		delete(conns, connID)
+		log.Printf("User %s disconnected from WebSocket (%d connections left)", username, len(conns))
 	}
-
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Failed to marshal scheduled call created message: %v", err)
-		return
+	if len(conns) == 0 {
+		delete(h.connections, username)
+		h.unsubscribe(username)
 	}
+	h.mu.Unlock()
 
-	select {
-	case conn.Send <- data:
-	default:
-		log.Printf("Failed to send scheduled call created to %s: channel full", username)
+	if found {
+		h.unregisterConnection(username, connID)
+		h.runHooks(&h.onDisconnect, username)
 	}
 }
 
-func (h *WebSocketHub) BroadcastScheduledCallReminder(username string, call *models.ScheduledCall, reminderTime time.Duration) {
+// send stores an event for replay and publishes it to the user's inbox, which
+// every instance holding one of their sockets subscribes to. env is the
+// recipient's own copy, so its Seq can be set.
+func (h *WebSocketHub) send(username string, env events.Envelope) {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	conn, ok := h.connections[username]
-	if !ok {
-		return
-	}
-
-	msg := Message{
-		Type: "scheduled_call_reminder",
-		Data: map[string]interface{}{
-			"scheduledCall": call,
-			"reminderTime":   reminderTime.String(),
-		},
-	}
-
-	data, err := json.Marshal(msg)
+	store := h.store
+	h.mu.RUnlock()
+
+	if store != nil && !volatileEvents[env.Type] {
+		payload, err := json.Marshal(env)
+		if err != nil {
+			log.Printf("Failed to marshal %s event: %v", env.Type, err)
+			return
+		}
+		event, err := store.Append(username, env.Type, payload)
+		if err != nil {
+			log.Printf("Failed to store %s event for %s: %v", env.Type, username, err)
+		} else {
+			env.Seq = event.Seq
+		}
+	}
+
+	data, err := json.Marshal(env)
 	if err != nil {
-		log.Printf("Failed to marshal scheduled call reminder message: %v", err)
+		log.Printf("Failed to marshal %s event: %v", env.Type, err)
 		return
 	}
 
-	select {
-	case conn.Send <- data:
-	default:
-		log.Printf("Failed to send scheduled call reminder to %s: channel full", username)
+	// A socket without ack support would never confirm the event, so it is
+	// only tracked when the user is connected and every socket acknowledges
+	if ackEvents[env.Type] {
+		if total, acking := h.countConnections(username); total > 0 && total == acking {
+			h.expectAck(username, env, data)
+		}
 	}
+	h.publish(username, data)
 }
 
-func (h *WebSocketHub) BroadcastScheduledCallStarting(username string, call *models.ScheduledCall) {
+// deliver pushes an encoded message to the user's sockets on this instance
+func (h *WebSocketHub) deliver(username string, msgType string, data []byte) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	conn, ok := h.connections[username]
-	if !ok {
-		return
-	}
-
-	msg := Message{
-		Type: "scheduled_call_starting",
-		Data: call,
-	}
-
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Failed to marshal scheduled call starting message: %v", err)
-		return
-	}
-
-	select {
-	case conn.Send <- data:
-	default:
-		log.Printf("Failed to send scheduled call starting to %s: channel full", username)
+	for _, conn := range h.connections[username] {
+		select {
+		case conn.Send <- data:
+		default:
+			log.Printf("Failed to send %s to %s: channel full", msgType, username)
+		}
 	}
 }
 
-func (h *WebSocketHub) BroadcastCallHistoryUpdated(username string, callHistory *models.CallHistory) {
+// replay writes the stored events after lastSeq to a reconnecting client,
+// followed by a replay_complete marker; write reports whether the client took
+// the message. Events sent live while replaying may arrive twice; clients drop
+// anything at or below the last seq they have seen.
+func (h *WebSocketHub) replay(username string, lastSeq int64, write func(data []byte) bool) {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	conn, ok := h.connections[username]
-	if !ok {
+	store := h.store
+	h.mu.RUnlock()
+	if store == nil {
 		return
 	}
 
-	msg := Message{
-		Type: "call_history_updated",
-		Data: callHistory,
-	}
-
-	data, err := json.Marshal(msg)
+	missed, err := store.GetSince(username, lastSeq, maxReplayEvents+1)
 	if err != nil {
-		log.Printf("Failed to marshal call history updated message: %v", err)
+		log.Printf("Failed to load missed events for %s: %v", username, err)
 		return
 	}
 
-	select {
-	case conn.Send <- data:
-	default:
-		log.Printf("Failed to send call history updated to %s: channel full", username)
+	truncated := len(missed) > maxReplayEvents
+	if truncated {
+		missed = missed[:maxReplayEvents]
+	}
+	// Sequence numbers have no gaps, so a jump means older events already expired
+	if len(missed) > 0 && missed[0].Seq > lastSeq+1 {
+		truncated = true
 	}
-}
-
-func (h *WebSocketHub) BroadcastParticipantStateChanged(username string, roomName string, participantIdentity string, action string) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
 
-	conn, ok := h.connections[username]
-	if !ok {
-		return
+	for _, event := range missed {
+		// Kept before the type became volatile; skipped but counted as seen
+		if volatileEvents[event.Type] {
+			lastSeq = event.Seq
+			continue
+		}
+		data, err := json.Marshal(storedEnvelope(event))
+		if err != nil {
+			continue
+		}
+		if !write(data) {
+			log.Printf("Failed to replay events to %s", username)
+			truncated = true
+			break
+		}
+		lastSeq = event.Seq
 	}
 
-	msg := Message{
-		Type: "participant_state_changed",
+	data, _ := json.Marshal(Message{
+		Type: "replay_complete",
 		Data: map[string]interface{}{
-			"roomName":           roomName,
-			"participantIdentity": participantIdentity,
-			"action":             action,
+			"lastSeq":   lastSeq,
+			"truncated": truncated,
 		},
-	}
-
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Failed to marshal participant state changed message: %v", err)
-		return
-	}
-
-	select {
-	case conn.Send <- data:
-	default:
-		log.Printf("Failed to send participant state changed to %s: channel full", username)
-	}
+	})
+	write(data)
 }
 
-
+// storedEnvelope rebuilds the envelope of a stored event. Events stored before
+// envelopes were introduced hold only their payload and are wrapped here.
+func storedEnvelope(event *models.UserEvent) events.Envelope {
+	var env events.Envelope
+	if err := json.Unmarshal(event.Data, &env); err != nil || env.Version == 0 {
+		env = events.Envelope{
+			Type:      event.Type,
+			Timestamp: event.CreatedAt,
+			Data:      event.Data,
+		}
+	}
+	env.Seq = event.Seq
+	return env
+}
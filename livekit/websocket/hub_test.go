@@ -0,0 +1,458 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"livekit/events"
+	"livekit/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestCluster returns two hubs standing in for two API instances: they share
+// one in-memory PubSub and a connection registry in an embedded NATS server
+func newTestCluster(t *testing.T) (*WebSocketHub, *WebSocketHub) {
+	t.Helper()
+
+	srv := runNATS(t)
+	pubsub := NewMemoryPubSub()
+
+	hubs := make([]*WebSocketHub, 2)
+	for i := range hubs {
+		hub := NewWebSocketHub()
+		if err := hub.SetPubSub(pubsub); err != nil {
+			t.Fatalf("SetPubSub: %v", err)
+		}
+		hub.SetConnectionRegistry(newNATSRegistry(t, srv, time.Minute), time.Minute)
+		hubs[i] = hub
+	}
+	return hubs[0], hubs[1]
+}
+
+// testConn is a socket without a network behind it; closing it records the reason
+type testConn struct {
+	*Connection
+
+	mu     sync.Mutex
+	reason string
+}
+
+func connect(hub *WebSocketHub, username, connID, sessionID string, acks bool) *testConn {
+	conn := &testConn{}
+	conn.Connection = &Connection{
+		ID:        connID,
+		Username:  username,
+		SessionID: sessionID,
+		Send:      make(chan []byte, 16),
+		Acks:      acks,
+		close: func(reason string) {
+			conn.mu.Lock()
+			defer conn.mu.Unlock()
+			conn.reason = reason
+		},
+	}
+	hub.Register(username, conn.Connection)
+	return conn
+}
+
+func (c *testConn) closeReason() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reason
+}
+
+// receive returns the next envelope sent to the socket
+func (c *testConn) receive(t *testing.T) events.Envelope {
+	t.Helper()
+
+	select {
+	case data := <-c.Send:
+		var env events.Envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			t.Fatalf("failed to decode %s: %v", data, err)
+		}
+		return env
+	case <-time.After(time.Second):
+		t.Fatalf("nothing sent to %s", c.Username)
+		return events.Envelope{}
+	}
+}
+
+func (c *testConn) assertNothingSent(t *testing.T) {
+	t.Helper()
+
+	select {
+	case data := <-c.Send:
+		t.Errorf("unexpected message to %s: %s", c.Username, data)
+	default:
+	}
+}
+
+func pendingAckCount(hub *WebSocketHub) int {
+	hub.ackMu.Lock()
+	defer hub.ackMu.Unlock()
+	return len(hub.pendingAcks)
+}
+
+func TestHubDeliversAcrossInstances(t *testing.T) {
+	hubA, hubB := newTestCluster(t)
+	alice := connect(hubA, "alice", "1", "s1", false)
+	bob := connect(hubA, "bob", "2", "s2", false)
+
+	if !hubB.IsConnected("alice") {
+		t.Error("alice is not connected as seen from the other instance")
+	}
+
+	if err := hubB.Publish(context.Background(), []string{"alice"}, events.CallEnded{CallID: "call-1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	env := alice.receive(t)
+	if env.Type != "call_ended" {
+		t.Errorf("got %s, want call_ended", env.Type)
+	}
+	var ended events.CallEnded
+	if err := json.Unmarshal(env.Data, &ended); err != nil || ended.CallID != "call-1" {
+		t.Errorf("got data %s, want callId call-1", env.Data)
+	}
+	bob.assertNothingSent(t)
+
+	hubA.Unregister("alice", "1")
+	if hubB.IsConnected("alice") {
+		t.Error("alice is still connected after her only socket closed")
+	}
+}
+
+func TestHubSettlesAckFromOtherInstance(t *testing.T) {
+	hubA, hubB := newTestCluster(t)
+	alice := connect(hubA, "alice", "1", "s1", true)
+
+	invitation := events.CallInvitation{InvitationID: 1, CallID: "call-1", Inviter: "bob"}
+	if err := hubB.Publish(context.Background(), []string{"alice"}, invitation); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	env := alice.receive(t)
+	if got := pendingAckCount(hubB); got != 1 {
+		t.Fatalf("publishing instance waits for %d acks, want 1", got)
+	}
+
+	hubA.ack("alice", env.ID)
+	if got := pendingAckCount(hubB); got != 0 {
+		t.Errorf("publishing instance still waits for %d acks after alice acked", got)
+	}
+}
+
+func TestHubReportsUnacknowledgedEvents(t *testing.T) {
+	previous := ackTimeout
+	ackTimeout = 20 * time.Millisecond
+	t.Cleanup(func() { ackTimeout = previous })
+
+	hubA, hubB := newTestCluster(t)
+	alice := connect(hubA, "alice", "1", "s1", true)
+
+	undelivered := make(chan string, 1)
+	hubB.OnUndelivered(func(username string, msgType string, data json.RawMessage) {
+		undelivered <- username + " " + msgType
+	})
+
+	if err := hubB.Publish(context.Background(), []string{"alice"}, events.CallCancelled{CallID: "call-1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	select {
+	case got := <-undelivered:
+		if got != "alice call_cancelled" {
+			t.Errorf("undelivered %q, want alice call_cancelled", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("unacknowledged event was never reported")
+	}
+
+	// The first send and each resend reach the socket
+	for i := 0; i < maxAckAttempts; i++ {
+		if env := alice.receive(t); env.Type != "call_cancelled" {
+			t.Errorf("got %s, want call_cancelled", env.Type)
+		}
+	}
+}
+
+func TestHubDoesNotWaitForAcksOfSocketsWithoutAckSupport(t *testing.T) {
+	hubA, hubB := newTestCluster(t)
+	alice := connect(hubA, "alice", "1", "s1", true)
+	connect(hubB, "alice", "2", "s2", false)
+
+	if err := hubB.Publish(context.Background(), []string{"alice"}, events.CallEnded{CallID: "call-1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	alice.receive(t)
+	if got := pendingAckCount(hubB); got != 0 {
+		t.Errorf("waits for %d acks although one socket never acks", got)
+	}
+}
+
+func TestHubDoesNotWaitForAcksOfOfflineUsers(t *testing.T) {
+	_, hubB := newTestCluster(t)
+
+	invitation := events.CallInvitation{InvitationID: 1, CallID: "call-1", Inviter: "bob"}
+	if err := hubB.Publish(context.Background(), []string{"alice"}, invitation); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if got := pendingAckCount(hubB); got != 0 {
+		t.Errorf("waits for %d acks although alice has no socket", got)
+	}
+}
+
+func TestHubClosesRevokedSessionOnEveryInstance(t *testing.T) {
+	hubA, hubB := newTestCluster(t)
+	revokedA := connect(hubA, "alice", "1", "s1", false)
+	revokedB := connect(hubB, "alice", "2", "s1", false)
+	other := connect(hubA, "alice", "3", "s2", false)
+
+	hubB.CloseSession("s1")
+
+	for _, conn := range []*testConn{revokedA, revokedB} {
+		if got := conn.closeReason(); got != "session revoked" {
+			t.Errorf("socket %s closed with %q, want session revoked", conn.ID, got)
+		}
+	}
+	if got := other.closeReason(); got != "" {
+		t.Errorf("socket of another session closed with %q", got)
+	}
+}
+
+func TestHubAnnouncesEndedCallsToEveryInstance(t *testing.T) {
+	hubA, hubB := newTestCluster(t)
+	var mu sync.Mutex
+	var ended []string
+	for _, hub := range []*WebSocketHub{hubA, hubB} {
+		hub.OnCallEnded(func(callID string) {
+			mu.Lock()
+			defer mu.Unlock()
+			ended = append(ended, callID)
+		})
+	}
+
+	hubB.AnnounceCallEnded("call-1")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ended) != 2 || ended[0] != "call-1" || ended[1] != "call-1" {
+		t.Errorf("hooks ran for %v, want call-1 on both instances", ended)
+	}
+}
+
+// memoryEventStore is an EventStore without a database; expire drops a user's
+// oldest events like the retention cleanup does
+type memoryEventStore struct {
+	mu     sync.Mutex
+	events map[string][]*models.UserEvent
+	seq    map[string]int64
+}
+
+func newMemoryEventStore() *memoryEventStore {
+	return &memoryEventStore{
+		events: make(map[string][]*models.UserEvent),
+		seq:    make(map[string]int64),
+	}
+}
+
+func (s *memoryEventStore) Append(username, eventType string, data []byte) (*models.UserEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq[username]++
+	event := &models.UserEvent{
+		Username:  username,
+		Seq:       s.seq[username],
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: time.Now(),
+	}
+	s.events[username] = append(s.events[username], event)
+	return event, nil
+}
+
+func (s *memoryEventStore) GetSince(username string, afterSeq int64, limit int) ([]*models.UserEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var missed []*models.UserEvent
+	for _, event := range s.events[username] {
+		if event.Seq > afterSeq && len(missed) < limit {
+			missed = append(missed, event)
+		}
+	}
+	return missed, nil
+}
+
+func (s *memoryEventStore) expire(username string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[username] = s.events[username][count:]
+}
+
+// replayResult is what a reconnecting client received from replay
+type replayResult struct {
+	envelopes []events.Envelope
+	lastSeq   int64
+	truncated bool
+}
+
+// replayTo runs a replay for username; write fails once accept messages were taken
+func replayTo(t *testing.T, hub *WebSocketHub, username string, lastSeq int64, accept int) replayResult {
+	t.Helper()
+
+	var result replayResult
+	var complete bool
+	hub.replay(username, lastSeq, func(data []byte) bool {
+		var msg struct {
+			Type string `json:"type"`
+			Data struct {
+				LastSeq   int64 `json:"lastSeq"`
+				Truncated bool  `json:"truncated"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &msg); err == nil && msg.Type == "replay_complete" {
+			result.lastSeq, result.truncated, complete = msg.Data.LastSeq, msg.Data.Truncated, true
+			return true
+		}
+		if len(result.envelopes) >= accept {
+			return false
+		}
+		var env events.Envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			t.Fatalf("failed to decode %s: %v", data, err)
+		}
+		result.envelopes = append(result.envelopes, env)
+		return true
+	})
+	if !complete {
+		t.Fatal("replay did not end with replay_complete")
+	}
+	return result
+}
+
+func TestHubKeepsJoinTokensOutOfTheStore(t *testing.T) {
+	hub := NewWebSocketHub()
+	store := newMemoryEventStore()
+	hub.SetEventStore(store)
+	alice := connect(hub, "alice", "1", "s1", false)
+
+	const token = "livekit-join-token"
+	published := []events.Event{
+		events.LobbyDecision{RequestID: 1, CallID: "call-1", Status: "admitted", Token: token, RoomName: "room-1"},
+		events.BreakoutAssigned{CallID: "call-1", BreakoutRoomID: 1, Name: "Room 1", RoomName: "room-1-b1", Token: token},
+		events.BreakoutReturn{CallID: "call-1", RoomName: "room-1", Token: token},
+		events.LobbyDecision{RequestID: 2, CallID: "call-1", Status: "denied"},
+		events.CallEnded{CallID: "call-1"},
+	}
+	for _, event := range published {
+		if err := hub.Publish(context.Background(), []string{"alice"}, event); err != nil {
+			t.Fatalf("Publish %s: %v", event.EventType(), err)
+		}
+		// The live socket still gets the token
+		if env := alice.receive(t); env.Type != event.EventType() {
+			t.Errorf("got %s, want %s", env.Type, event.EventType())
+		}
+	}
+
+	// An event stored before its type became volatile must not be replayed either
+	legacy, _ := json.Marshal(events.BreakoutReturn{CallID: "call-1", RoomName: "room-1", Token: token})
+	store.Append("alice", "breakout_return", legacy)
+
+	stored, _ := store.GetSince("alice", 0, maxReplayEvents)
+	if len(stored) != 3 {
+		t.Errorf("stored %d events, want lobby_denied, call_ended and the legacy one", len(stored))
+	}
+	for _, event := range stored[:len(stored)-1] {
+		if bytes.Contains(event.Data, []byte(token)) {
+			t.Errorf("stored %s contains the join token: %s", event.Type, event.Data)
+		}
+	}
+
+	result := replayTo(t, hub, "alice", 0, maxReplayEvents)
+	if len(result.envelopes) != 2 {
+		t.Errorf("replayed %d events, want 2", len(result.envelopes))
+	}
+	for _, env := range result.envelopes {
+		data, _ := json.Marshal(env)
+		if bytes.Contains(data, []byte(token)) {
+			t.Errorf("replayed %s contains the join token: %s", env.Type, data)
+		}
+	}
+	if result.lastSeq != 3 || result.truncated {
+		t.Errorf("replay ended at seq %d (truncated %v), want 3 and complete", result.lastSeq, result.truncated)
+	}
+}
+
+func TestHubReplay(t *testing.T) {
+	publish := func(t *testing.T, hub *WebSocketHub, count int) {
+		t.Helper()
+		for i := 0; i < count; i++ {
+			if err := hub.Publish(context.Background(), []string{"alice"}, events.CallEnded{CallID: fmt.Sprint(i)}); err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+		}
+	}
+	newHub := func() (*WebSocketHub, *memoryEventStore) {
+		hub := NewWebSocketHub()
+		store := newMemoryEventStore()
+		hub.SetEventStore(store)
+		return hub, store
+	}
+
+	t.Run("missed events", func(t *testing.T) {
+		hub, _ := newHub()
+		publish(t, hub, 5)
+
+		result := replayTo(t, hub, "alice", 2, maxReplayEvents)
+		if len(result.envelopes) != 3 || result.envelopes[0].Seq != 3 {
+			t.Fatalf("replayed %d events from seq %d, want 3 from seq 3", len(result.envelopes), result.envelopes[0].Seq)
+		}
+		if result.lastSeq != 5 || result.truncated {
+			t.Errorf("replay ended at seq %d (truncated %v), want 5 and complete", result.lastSeq, result.truncated)
+		}
+	})
+
+	t.Run("more than the replay limit", func(t *testing.T) {
+		hub, _ := newHub()
+		publish(t, hub, maxReplayEvents+1)
+
+		result := replayTo(t, hub, "alice", 0, maxReplayEvents+1)
+		if len(result.envelopes) != maxReplayEvents {
+			t.Errorf("replayed %d events, want %d", len(result.envelopes), maxReplayEvents)
+		}
+		if result.lastSeq != maxReplayEvents || !result.truncated {
+			t.Errorf("replay ended at seq %d (truncated %v), want %d and truncated",
+				result.lastSeq, result.truncated, maxReplayEvents)
+		}
+	})
+
+	t.Run("expired events", func(t *testing.T) {
+		hub, store := newHub()
+		publish(t, hub, 5)
+		store.expire("alice", 2)
+
+		result := replayTo(t, hub, "alice", 1, maxReplayEvents)
+		if len(result.envelopes) != 3 || result.envelopes[0].Seq != 3 {
+			t.Fatalf("replayed %d events, want seq 3 to 5", len(result.envelopes))
+		}
+		if result.lastSeq != 5 || !result.truncated {
+			t.Errorf("replay ended at seq %d (truncated %v), want 5 and truncated", result.lastSeq, result.truncated)
+		}
+	})
+
+	t.Run("failed write", func(t *testing.T) {
+		hub, _ := newHub()
+		publish(t, hub, 5)
+
+		result := replayTo(t, hub, "alice", 0, 2)
+		if len(result.envelopes) != 2 {
+			t.Errorf("replayed %d events, want 2", len(result.envelopes))
+		}
+		// The client resumes after the last event it took
+		if result.lastSeq != 2 || !result.truncated {
+			t.Errorf("replay ended at seq %d (truncated %v), want 2 and truncated", result.lastSeq, result.truncated)
+		}
+	})
+}
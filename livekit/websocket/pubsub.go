@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"encoding/hex"
+	"sync"
+)
+
+// PubSub carries events between hub instances so a user connected to one API
+// replica receives events produced on another. Each instance subscribes to
+// the inbox subject of every user it holds a socket for.
+type PubSub interface {
+	Publish(subject string, data []byte) error
+	Subscribe(subject string, handler func(data []byte)) (Subscription, error)
+	Close() error
+}
+
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// inboxSubject is the per-user subject events are published on. Usernames are
+// hex encoded since they may contain characters that are not valid in a
+// subject, such as dots or spaces.
+func inboxSubject(username string) string {
+	return "calls.inbox." + hex.EncodeToString([]byte(username))
+}
+
+// MemoryPubSub is the PubSub of a single instance deployment; handlers are
+// called synchronously on Publish
+type MemoryPubSub struct {
+	subscriptions map[string]map[*memorySubscription]struct{}
+	mu            sync.RWMutex
+}
+
+type memorySubscription struct {
+	pubsub  *MemoryPubSub
+	subject string
+	handler func(data []byte)
+}
+
+func NewMemoryPubSub() *MemoryPubSub {
+	return &MemoryPubSub{
+		subscriptions: make(map[string]map[*memorySubscription]struct{}),
+	}
+}
+
+func (p *MemoryPubSub) Publish(subject string, data []byte) error {
+	p.mu.RLock()
+	handlers := make([]func([]byte), 0, len(p.subscriptions[subject]))
+	for sub := range p.subscriptions[subject] {
+		handlers = append(handlers, sub.handler)
+	}
+	p.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(data)
+	}
+	return nil
+}
+
+func (p *MemoryPubSub) Subscribe(subject string, handler func(data []byte)) (Subscription, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sub := &memorySubscription{pubsub: p, subject: subject, handler: handler}
+	if p.subscriptions[subject] == nil {
+		p.subscriptions[subject] = make(map[*memorySubscription]struct{})
+	}
+	p.subscriptions[subject][sub] = struct{}{}
+	return sub, nil
+}
+
+func (p *MemoryPubSub) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subscriptions = make(map[string]map[*memorySubscription]struct{})
+	return nil
+}
+
+func (s *memorySubscription) Unsubscribe() error {
+	p := s.pubsub
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	subs := p.subscriptions[s.subject]
+	delete(subs, s)
+	if len(subs) == 0 {
+		delete(p.subscriptions, s.subject)
+	}
+	return nil
+}
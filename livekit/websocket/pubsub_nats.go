@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSPubSub shares events between hub instances through a NATS server
+type NATSPubSub struct {
+	conn *nats.Conn
+}
+
+func NewNATSPubSub(url string) (*NATSPubSub, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("livekit-api"),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	return &NATSPubSub{conn: conn}, nil
+}
+
+func (p *NATSPubSub) Publish(subject string, data []byte) error {
+	if err := p.conn.Publish(subject, data); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", subject, err)
+	}
+	return nil
+}
+
+func (p *NATSPubSub) Subscribe(subject string, handler func(data []byte)) (Subscription, error) {
+	sub, err := p.conn.Subscribe(subject, func(msg *nats.Msg) {
+		handler(msg.Data)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+	return sub, nil
+}
+
+func (p *NATSPubSub) Close() error {
+	return p.conn.Drain()
+}
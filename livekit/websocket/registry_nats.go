@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// connectionBucket is the JetStream key-value bucket of the NATS registry
+const connectionBucket = "websocket_connections"
+
+// natsTimeout bounds every key-value operation of the registry
+const natsTimeout = 5 * time.Second
+
+// NATSConnectionRegistry shares the connection registry between instances in
+// a JetStream key-value bucket, keyed by user, instance and socket. The bucket
+// expires keys after the TTL it was created with, so an instance keeps its
+// entries alive by writing them again on every Refresh.
+type NATSConnectionRegistry struct {
+	kv jetstream.KeyValue
+
+	mu    sync.Mutex
+	local map[string][]byte // This instance's keys -> values, rewritten on Refresh
+}
+
+type registryEntry struct {
+	Acks bool `json:"acks"`
+}
+
+// ConnectionRegistry creates, or opens, the registry bucket on the server this
+// PubSub is connected to. It needs JetStream enabled on the server.
+func (p *NATSPubSub) ConnectionRegistry(ttl time.Duration) (*NATSConnectionRegistry, error) {
+	js, err := jetstream.New(p.conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open JetStream: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), natsTimeout)
+	defer cancel()
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      connectionBucket,
+		Description: "Open WebSocket connections of every API instance",
+		TTL:         ttl,
+		History:     1,
+		Storage:     jetstream.MemoryStorage,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection bucket: %w", err)
+	}
+
+	return &NATSConnectionRegistry{kv: kv, local: make(map[string][]byte)}, nil
+}
+
+// connectionKey is hex encoded like inboxSubject, since usernames may hold
+// characters that are not valid in a key
+func connectionKey(instanceID, username, connID string) string {
+	return hex.EncodeToString([]byte(username)) + "." + instanceID + "." + connID
+}
+
+// Add registers a socket; ttl is fixed by the bucket and not used here
+func (r *NATSConnectionRegistry) Add(instanceID, username, connID string, acks bool, ttl time.Duration) error {
+	value, err := json.Marshal(registryEntry{Acks: acks})
+	if err != nil {
+		return fmt.Errorf("failed to marshal connection: %w", err)
+	}
+
+	key := connectionKey(instanceID, username, connID)
+	ctx, cancel := context.WithTimeout(context.Background(), natsTimeout)
+	defer cancel()
+	if _, err := r.kv.Put(ctx, key, value); err != nil {
+		return fmt.Errorf("failed to register connection: %w", err)
+	}
+
+	r.mu.Lock()
+	r.local[key] = value
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *NATSConnectionRegistry) Remove(instanceID, username, connID string) error {
+	key := connectionKey(instanceID, username, connID)
+	r.mu.Lock()
+	delete(r.local, key)
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), natsTimeout)
+	defer cancel()
+	if err := r.kv.Purge(ctx, key); err != nil {
+		return fmt.Errorf("failed to unregister connection: %w", err)
+	}
+	return nil
+}
+
+// Refresh writes this instance's entries again, restarting their TTL
+func (r *NATSConnectionRegistry) Refresh(instanceID string, ttl time.Duration) error {
+	r.mu.Lock()
+	entries := make(map[string][]byte, len(r.local))
+	for key, value := range r.local {
+		entries[key] = value
+	}
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), natsTimeout)
+	defer cancel()
+	for key, value := range entries {
+		if _, err := r.kv.Put(ctx, key, value); err != nil {
+			return fmt.Errorf("failed to refresh connections: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *NATSConnectionRegistry) CountConnections(username string) (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), natsTimeout)
+	defer cancel()
+
+	lister, err := r.kv.ListKeysFiltered(ctx, hex.EncodeToString([]byte(username))+".>")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count connections: %w", err)
+	}
+	defer lister.Stop()
+
+	var total, acking int
+	for key := range lister.Keys() {
+		entry, err := r.kv.Get(ctx, key)
+		if errors.Is(err, jetstream.ErrKeyNotFound) {
+			continue // Removed or expired since it was listed
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to count connections: %w", err)
+		}
+
+		var value registryEntry
+		if err := json.Unmarshal(entry.Value(), &value); err != nil {
+			continue
+		}
+		total++
+		if value.Acks {
+			acking++
+		}
+	}
+	return total, acking, nil
+}
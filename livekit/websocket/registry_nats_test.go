@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats-server/v2/test"
+)
+
+// runNATS starts an embedded NATS server with JetStream for the test
+func runNATS(t *testing.T) *server.Server {
+	t.Helper()
+
+	opts := test.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	srv := test.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+// newNATSRegistry opens the registry bucket on srv through its own connection,
+// as a separate API instance would
+func newNATSRegistry(t *testing.T, srv *server.Server, ttl time.Duration) *NATSConnectionRegistry {
+	t.Helper()
+
+	pubsub, err := NewNATSPubSub(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("NewNATSPubSub: %v", err)
+	}
+	t.Cleanup(func() { pubsub.Close() })
+
+	registry, err := pubsub.ConnectionRegistry(ttl)
+	if err != nil {
+		t.Fatalf("ConnectionRegistry: %v", err)
+	}
+	return registry
+}
+
+func assertCount(t *testing.T, registry ConnectionRegistry, username string, wantTotal, wantAcking int) {
+	t.Helper()
+
+	total, acking, err := registry.CountConnections(username)
+	if err != nil {
+		t.Fatalf("CountConnections(%q): %v", username, err)
+	}
+	if total != wantTotal || acking != wantAcking {
+		t.Errorf("CountConnections(%q) = %d, %d; want %d, %d", username, total, acking, wantTotal, wantAcking)
+	}
+}
+
+func TestNATSConnectionRegistryCountsAcrossInstances(t *testing.T) {
+	srv := runNATS(t)
+	registryA := newNATSRegistry(t, srv, time.Minute)
+	registryB := newNATSRegistry(t, srv, time.Minute)
+
+	if err := registryA.Add("a", "alice", "1", true, time.Minute); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := registryB.Add("b", "alice", "2", false, time.Minute); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	// A username that is a prefix of another must not be counted with it
+	if err := registryB.Add("b", "al", "3", true, time.Minute); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	assertCount(t, registryA, "alice", 2, 1)
+	assertCount(t, registryB, "alice", 2, 1)
+	assertCount(t, registryA, "al", 1, 1)
+	assertCount(t, registryA, "bob", 0, 0)
+
+	if err := registryB.Remove("b", "alice", "2"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	assertCount(t, registryA, "alice", 1, 1)
+}
+
+func TestNATSConnectionRegistryExpiresUnrefreshedEntries(t *testing.T) {
+	const ttl = time.Second
+
+	srv := runNATS(t)
+	registryA := newNATSRegistry(t, srv, ttl)
+	registryB := newNATSRegistry(t, srv, ttl)
+
+	if err := registryA.Add("a", "alice", "1", true, ttl); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := registryB.Add("b", "alice", "2", true, ttl); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	// Only instance A keeps refreshing, as if B had stopped without unregistering
+	deadline := time.Now().Add(3 * ttl)
+	for time.Now().Before(deadline) {
+		if err := registryA.Refresh("a", ttl); err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+		time.Sleep(ttl / 4)
+	}
+	assertCount(t, registryA, "alice", 1, 1)
+
+	time.Sleep(2 * ttl)
+	assertCount(t, registryA, "alice", 0, 0)
+}
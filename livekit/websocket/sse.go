@@ -0,0 +1,134 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"livekit/auth"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HandleEvents streams the events of the WebSocket as Server-Sent Events, for
+// clients behind proxies that block WebSocket upgrades. The stream counts as
+// one of the user's connections; it cannot acknowledge events or send
+// requests. A reconnecting client resumes after the seq in Last-Event-ID. The
+// stream ends when the token it was opened with expires or its session is
+// revoked.
+func HandleEvents(hub *WebSocketHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			auth.RespondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+
+		userInfo, ok := auth.GetUserFromContext(r.Context())
+		if !ok {
+			auth.RespondError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			auth.RespondError(w, http.StatusInternalServerError, "Streaming not supported")
+			return
+		}
+
+		var lastSeq *int64
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("lastEventId")
+		}
+		if lastEventID != "" {
+			seq, err := strconv.ParseInt(lastEventID, 10, 64)
+			if err != nil {
+				auth.RespondError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+				return
+			}
+			lastSeq = &seq
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		if claims := userInfo.Claims; claims != nil && claims.ExpiresAt != nil {
+			expiry := time.AfterFunc(time.Until(claims.ExpiresAt.Time), cancel)
+			defer expiry.Stop()
+		}
+
+		username := userInfo.Username
+		conn := &Connection{
+			ID:       uuid.New().String(),
+			Username: username,
+			Send:     make(chan []byte, 256),
+			close:    func(string) { cancel() },
+		}
+		if userInfo.Claims != nil {
+			conn.SessionID = userInfo.Claims.SessionID
+		}
+		hub.Register(username, conn)
+		defer hub.Unregister(username, conn.ID)
+
+		write := func(data []byte) bool {
+			if err := writeServerSentEvent(w, data); err != nil {
+				return false
+			}
+			flusher.Flush()
+			return true
+		}
+
+		// Events published while replaying queue up on the connection and
+		// follow the replay
+		if lastSeq != nil {
+			hub.replay(username, *lastSeq, write)
+		}
+		hub.runConnectHooks(username)
+
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case data, ok := <-conn.Send:
+				if !ok || !write(data) {
+					return
+				}
+			case <-ticker.C:
+				// A comment line keeps proxies from closing an idle stream
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// writeServerSentEvent writes one event. Its seq becomes the SSE id so the
+// browser sends it back as Last-Event-ID; no event name is set, letting
+// clients handle every type in onmessage as they do on the WebSocket.
+func writeServerSentEvent(w http.ResponseWriter, data []byte) error {
+	var header struct {
+		Seq int64 `json:"seq"`
+	}
+	json.Unmarshal(data, &header)
+
+	if header.Seq > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", header.Seq); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
@@ -0,0 +1,179 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"livekit/auth"
+	"livekit/events"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serverSentEvent is one event read off the stream
+type serverSentEvent struct {
+	id   string
+	data string
+}
+
+// openEventStream connects to HandleEvents as username; setup adds the resume
+// position to the request; the returned cancel ends the stream.
+func openEventStream(t *testing.T, hub *WebSocketHub, username string, setup func(r *http.Request)) (<-chan serverSentEvent, context.CancelFunc) {
+	t.Helper()
+
+	server := httptest.NewServer(auth.AuthMiddleware(HandleEvents(hub)))
+	t.Cleanup(server.Close)
+
+	token, err := auth.GenerateToken(1, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	if setup != nil {
+		setup(r)
+	}
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("stream answered %s", resp.Status)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %s, want text/event-stream", got)
+	}
+
+	stream := make(chan serverSentEvent, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(stream)
+		scanner := bufio.NewScanner(resp.Body)
+		var event serverSentEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				event.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				event.data = strings.TrimPrefix(line, "data: ")
+			case line == "" && event.data != "":
+				stream <- event
+				event = serverSentEvent{}
+			}
+		}
+	}()
+	return stream, cancel
+}
+
+func nextEvent(t *testing.T, stream <-chan serverSentEvent) serverSentEvent {
+	t.Helper()
+
+	select {
+	case event, ok := <-stream:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("nothing sent on the stream")
+		return serverSentEvent{}
+	}
+}
+
+func TestHandleEventsReplaysAfterLastEventID(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(r *http.Request)
+	}{
+		{name: "Last-Event-ID header", setup: func(r *http.Request) {
+			r.Header.Set("Last-Event-ID", "1")
+		}},
+		{name: "lastEventId query", setup: func(r *http.Request) {
+			r.URL.RawQuery = "lastEventId=1"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewWebSocketHub()
+			hub.SetEventStore(newMemoryEventStore())
+			for i := 1; i <= 3; i++ {
+				hub.Publish(context.Background(), []string{"alice"}, events.CallEnded{CallID: fmt.Sprint("call-", i)})
+			}
+
+			stream, _ := openEventStream(t, hub, "alice", tt.setup)
+			for _, want := range []string{"2", "3"} {
+				event := nextEvent(t, stream)
+				if event.id != want {
+					t.Errorf("replayed event id %q, want %s", event.id, want)
+				}
+				var env events.Envelope
+				if err := json.Unmarshal([]byte(event.data), &env); err != nil || env.Type != "call_ended" {
+					t.Errorf("replayed %s, want a call_ended envelope", event.data)
+				}
+			}
+			if event := nextEvent(t, stream); !strings.Contains(event.data, `"replay_complete"`) || !strings.Contains(event.data, `"lastSeq":3`) {
+				t.Errorf("got %s, want replay_complete at seq 3", event.data)
+			}
+
+			hub.Publish(context.Background(), []string{"alice"}, events.CallEnded{CallID: "call-4"})
+			if event := nextEvent(t, stream); event.id != "4" {
+				t.Errorf("live event id %q, want 4", event.id)
+			}
+		})
+	}
+}
+
+func TestHandleEventsRegistersConnection(t *testing.T) {
+	hub := NewWebSocketHub()
+	connected := make(chan string, 1)
+	disconnected := make(chan string, 1)
+	hub.OnConnect(func(username string) { connected <- username })
+	hub.OnDisconnect(func(username string) { disconnected <- username })
+
+	_, cancel := openEventStream(t, hub, "alice", nil)
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("connect hooks did not run")
+	}
+	if !hub.IsConnected("alice") {
+		t.Error("alice is not connected while her stream is open")
+	}
+
+	cancel()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("disconnect hooks did not run after the stream closed")
+	}
+	if hub.IsConnected("alice") {
+		t.Error("alice is still connected after her stream closed")
+	}
+}
+
+func TestHandleEventsRejectsInvalidLastEventID(t *testing.T) {
+	hub := NewWebSocketHub()
+	token, _ := auth.GenerateToken(1, "alice")
+
+	r := httptest.NewRequest(http.MethodGet, "/api/events", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("Last-Event-ID", "latest")
+	w := httptest.NewRecorder()
+	auth.AuthMiddleware(HandleEvents(hub)).ServeHTTP(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if hub.IsConnected("alice") {
+		t.Error("a rejected stream was registered")
+	}
+}
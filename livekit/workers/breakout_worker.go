@@ -0,0 +1,36 @@
+package workers
+
+import (
+	"context"
+	"livekit/services"
+	"log"
+	"time"
+)
+
+// BreakoutWorker sends participants back to the main room once timed
+// breakout rooms run out
+type BreakoutWorker struct {
+	breakoutService *services.BreakoutService
+}
+
+func NewBreakoutWorker(breakoutService *services.BreakoutService) *BreakoutWorker {
+	return &BreakoutWorker{
+		breakoutService: breakoutService,
+	}
+}
+
+func (w *BreakoutWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.breakoutService.ExpireBreakouts(); err != nil {
+				log.Printf("Error expiring breakout rooms: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package workers
+
+import (
+	"context"
+	"livekit/database"
+	"livekit/websocket"
+	"log"
+	"time"
+)
+
+// ConnectionWorker keeps this instance's WebSocket connections registered and
+// drops the ones left behind by instances that stopped
+type ConnectionWorker struct {
+	wsHub          *websocket.WebSocketHub
+	connectionRepo *database.ConnectionRepo
+	ttl            time.Duration
+}
+
+func NewConnectionWorker(wsHub *websocket.WebSocketHub, connectionRepo *database.ConnectionRepo, ttl time.Duration) *ConnectionWorker {
+	return &ConnectionWorker{
+		wsHub:          wsHub,
+		connectionRepo: connectionRepo,
+		ttl:            ttl,
+	}
+}
+
+func (w *ConnectionWorker) Run(ctx context.Context) {
+	// Refresh well before entries expire so one slow tick does not drop them
+	ticker := time.NewTicker(w.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.refresh()
+		}
+	}
+}
+
+func (w *ConnectionWorker) refresh() {
+	if err := w.wsHub.RefreshConnections(); err != nil {
+		log.Printf("Error refreshing connections: %v", err)
+	}
+
+	deleted, err := w.connectionRepo.DeleteExpired()
+	if err != nil {
+		log.Printf("Error expiring connections: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Expired %d stale connections", deleted)
+	}
+}
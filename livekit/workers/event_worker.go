@@ -0,0 +1,47 @@
+package workers
+
+import (
+	"context"
+	"livekit/database"
+	"log"
+	"time"
+)
+
+// EventWorker expires stored WebSocket events once they are past retention
+type EventWorker struct {
+	eventRepo *database.EventRepo
+	retention time.Duration
+}
+
+func NewEventWorker(eventRepo *database.EventRepo, retention time.Duration) *EventWorker {
+	return &EventWorker{
+		eventRepo: eventRepo,
+		retention: retention,
+	}
+}
+
+func (w *EventWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	w.expire()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.expire()
+		}
+	}
+}
+
+func (w *EventWorker) expire() {
+	deleted, err := w.eventRepo.DeleteOlderThan(time.Now().Add(-w.retention))
+	if err != nil {
+		log.Printf("Error expiring events: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Expired %d events", deleted)
+	}
+}
@@ -0,0 +1,40 @@
+package workers
+
+import (
+	"context"
+	"livekit/services"
+	"log"
+	"time"
+)
+
+// PresenceWorker takes users offline whose sockets went away without a
+// disconnect, e.g. when their instance stopped
+type PresenceWorker struct {
+	presenceService *services.PresenceService
+	interval        time.Duration
+}
+
+func NewPresenceWorker(presenceService *services.PresenceService, interval time.Duration) *PresenceWorker {
+	return &PresenceWorker{
+		presenceService: presenceService,
+		interval:        interval,
+	}
+}
+
+func (w *PresenceWorker) Run(ctx context.Context) {
+	// Connections left behind expire after one interval, so sweeping at the
+	// same pace notices them within two
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.presenceService.Sweep(); err != nil {
+				log.Printf("Error sweeping presence: %v", err)
+			}
+		}
+	}
+}
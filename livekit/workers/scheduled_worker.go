@@ -3,6 +3,7 @@ package workers
 import (
 	"context"
 	"livekit/database"
+	"livekit/events"
 	"livekit/services"
 	"livekit/websocket"
 	"log"
@@ -57,7 +58,7 @@ func (w *ScheduledWorker) Run(ctx context.Context) {
 // 			if w.wsHub != nil {
 // 				username := w.getUsername(call.CreatedBy)
 // 				if username != "" {
-// 					w.wsHub.BroadcastScheduledCallStarting(username, call)
+// 					w.wsHub.Publish(context.Background(), []string{username}, events.ScheduledCallStarting{ScheduledCall: call})
 // 				}
 // 			}
 
@@ -87,7 +88,10 @@ func (w *ScheduledWorker) sendReminders() {
 				if w.wsHub != nil {
 					username := w.getUsername(call.CreatedBy)
 					if username != "" {
-						w.wsHub.BroadcastScheduledCallReminder(username, call, reminderTime)
+						w.wsHub.Publish(context.Background(), []string{username}, events.ScheduledCallReminder{
+							ScheduledCall: call,
+							ReminderTime:  reminderTime.String(),
+						})
 					}
 				}
 
@@ -0,0 +1,34 @@
+package workers
+
+import (
+	"context"
+	"livekit/services"
+	"log"
+	"time"
+)
+
+type StreamWorker struct {
+	streamingService *services.StreamingService
+}
+
+func NewStreamWorker(streamingService *services.StreamingService) *StreamWorker {
+	return &StreamWorker{
+		streamingService: streamingService,
+	}
+}
+
+func (w *StreamWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.streamingService.RefreshStreams(); err != nil {
+				log.Printf("Error refreshing live streams: %v", err)
+			}
+		}
+	}
+}